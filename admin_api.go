@@ -0,0 +1,204 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// adminCollectorStatus is the status of a collector returned by GET /admin/collectors
+type adminCollectorStatus struct {
+	Name               string  `json:"name"`
+	Type               string  `json:"type"`
+	Disabled           bool    `json:"disabled"`
+	ScrapeTime         string  `json:"scrapeTime"`
+	LastScrapeDuration float64 `json:"lastScrapeDuration"`
+}
+
+// adminApiHandler handles the admin api (--admin-token):
+//
+//	GET  /admin/collectors                              list collectors
+//	POST /admin/collectors/<name>/disable               pause scheduled collection runs
+//	POST /admin/collectors/<name>/enable                resume scheduled collection runs
+//	POST /admin/collectors/<name>/collect[?subscription=<id>] trigger a collection (of one subscription)
+//	POST /admin/cache/flush                             flush the collector caches and reload the hierarchy cache
+func adminApiHandler(w http.ResponseWriter, r *http.Request) {
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if subtle.ConstantTimeCompare([]byte(token), []byte(opts.Admin.Token)) != 1 {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	path := strings.Trim(strings.TrimPrefix(r.URL.Path, "/admin/"), "/")
+	logger := log.WithFields(log.Fields{"admin": path, "remoteAddr": r.RemoteAddr})
+
+	switch {
+	case path == "collectors" && r.Method == http.MethodGet:
+		adminWriteJson(w, adminCollectorList())
+		return
+	case path == "cache/flush" && r.Method == http.MethodPost:
+		if err := adminCacheFlush(); err != nil {
+			logger.Error(err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		logger.Info("admin: cache flushed")
+		adminWriteJson(w, map[string]string{"status": "ok"})
+		return
+	case strings.HasPrefix(path, "collectors/") && r.Method == http.MethodPost:
+		pathParts := strings.Split(strings.TrimPrefix(path, "collectors/"), "/")
+		if len(pathParts) != 2 {
+			break
+		}
+
+		if err := adminCollectorAction(pathParts[0], pathParts[1], r.URL.Query().Get("subscription")); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		logger.Infof("admin: %v collector %v", pathParts[1], pathParts[0])
+		adminWriteJson(w, map[string]string{"status": "ok"})
+		return
+	}
+
+	http.Error(w, "not found", http.StatusNotFound)
+}
+
+func adminCollectorList() []adminCollectorStatus {
+	ret := []adminCollectorStatus{}
+
+	addCollector := func(collectorType string, collector *CollectorBase) {
+		status := adminCollectorStatus{
+			Name:     collector.Name,
+			Type:     collectorType,
+			Disabled: collector.IsDisabled(),
+		}
+		if scrapeTime := collector.GetScrapeTime(); scrapeTime != nil {
+			status.ScrapeTime = scrapeTime.String()
+		}
+		if collector.LastScrapeDuration != nil {
+			status.LastScrapeDuration = collector.LastScrapeDuration.Seconds()
+		}
+		ret = append(ret, status)
+	}
+
+	for _, collector := range collectorGeneralList {
+		addCollector("general", &collector.CollectorBase)
+	}
+	for _, collector := range collectorCustomList {
+		addCollector("custom", &collector.CollectorBase)
+	}
+
+	sort.Slice(ret, func(i, j int) bool {
+		return ret[i].Name < ret[j].Name
+	})
+	return ret
+}
+
+// adminCollectorAction enables, disables or triggers a collector (name is case insensitive)
+func adminCollectorAction(name, action, subscriptionId string) error {
+	var generalCollector *CollectorGeneral
+	var customCollector *CollectorCustom
+	var collector *CollectorBase
+
+	for collectorName, val := range collectorGeneralList {
+		if strings.EqualFold(collectorName, name) {
+			generalCollector = val
+			collector = &val.CollectorBase
+		}
+	}
+	for collectorName, val := range collectorCustomList {
+		if strings.EqualFold(collectorName, name) {
+			customCollector = val
+			collector = &val.CollectorBase
+		}
+	}
+
+	if collector == nil {
+		return fmt.Errorf("collector \"%v\" not found", name)
+	}
+
+	switch action {
+	case "enable":
+		collector.SetIsDisabled(false)
+		if generalCollector != nil {
+			// subscriptions disabled by --scrape-soft-fail are probed again
+			generalCollector.SoftFailReset()
+		}
+	case "disable":
+		collector.SetIsDisabled(true)
+	case "collect":
+		switch {
+		case generalCollector != nil && subscriptionId != "":
+			found := false
+			for _, subscription := range generalCollector.GetAzureSubscriptions() {
+				if strings.EqualFold(*subscription.SubscriptionID, subscriptionId) {
+					found = true
+				}
+			}
+			if !found {
+				return fmt.Errorf("subscription \"%v\" is not collected", subscriptionId)
+			}
+			go generalCollector.CollectSubscription(subscriptionId)
+		case generalCollector != nil:
+			go generalCollector.Collect()
+		case subscriptionId != "":
+			return fmt.Errorf("collector \"%v\" doesn't support subscription collection", name)
+		default:
+			go customCollector.Collect()
+		}
+	default:
+		return fmt.Errorf("unknown action \"%v\"", action)
+	}
+
+	return nil
+}
+
+// adminCacheFlush flushes the caches kept across collection runs:
+//   - the collector store (lists shared with dependent collectors and the resource cache of the resource lookups)
+//   - the caches of the collectors (resource inventory of --resource-activitylog/Event Grid, region prefixes of --publicip-region)
+//   - the subscription/resourcegroup hierarchy cache (reloaded, only replaced if the reload succeeded)
+//
+// the recorded metrics of blackout windows are kept, they're the results of the previous runs and not a cache
+func adminCacheFlush() error {
+	collectorStore.Flush()
+	for _, collector := range collectorGeneralList {
+		if processor, ok := collector.Processor.(CollectorProcessorCacheInterface); ok {
+			processor.FlushCache()
+		}
+	}
+
+	if !opts.Metrics.HierarchyLabels && !opts.Azure.TagInheritance {
+		return nil
+	}
+
+	ctx := context.Background()
+
+	dynamicConfigLock.RLock()
+	subscriptionList := AzureSubscriptions
+	dynamicConfigLock.RUnlock()
+
+	cache, err := loadAzureHierarchy(ctx, subscriptionList)
+	if err != nil {
+		return fmt.Errorf("hierarchy cache reload failed: %v", err)
+	}
+	azureHierarchy.replace(cache)
+
+	if opts.Metrics.HierarchyLabels {
+		// previous paths are kept if the reload fails
+		azureHierarchy.refreshManagementGroups(ctx, true)
+	}
+
+	return nil
+}
+
+func adminWriteJson(w http.ResponseWriter, content interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(content); err != nil {
+		log.Error(err)
+	}
+}
@@ -1,85 +1,421 @@
 package main
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
+	"github.com/prometheus/client_golang/prometheus"
+	"io/ioutil"
+	"net"
+	"path"
+	"sort"
 	"strconv"
+	"strings"
+	"time"
 )
 
 // parse --portscan-range
 func argparserParsePortrange() (errorMessage error) {
-	var err error
-	var firstPort int64
-	var lastPort int64
-
 	if len(opts.Portscan.PortRange) > 0 {
 		portscanPortRange = []Portrange{}
 
 		for _, portrange := range opts.Portscan.PortRange {
-			// parse via regexp
-			portscanRangeSubMatch := portrangeRegexp.FindStringSubmatch(portrange)
-
-			if len(portscanRangeSubMatch) == 0 {
-				// portrange is invalid
-				errorMessage = fmt.Errorf("unable to parse \"--portscan-range\", has to be format \"nnn-mmm\"")
+			parsedPortrange, err := parsePortrange(portrange)
+			if err != nil {
+				errorMessage = fmt.Errorf("failed to parse \"--portscan-range\": %v", err)
 				return
 			}
 
-			// get named submatches
-			portscanRangeSubMatchResult := make(map[string]string)
-			for i, name := range portrangeRegexp.SubexpNames() {
-				if i != 0 && name != "" {
-					portscanRangeSubMatchResult[name] = portscanRangeSubMatch[i]
-				}
-			}
+			// add to portlist
+			portscanPortRange = append(portscanPortRange, parsedPortrange)
+		}
+	} else {
+		errorMessage = errors.New("no port range available, set via \"--portscan-range\"")
+		return
+	}
 
-			// parse first port
-			firstPort, err = strconv.ParseInt(portscanRangeSubMatchResult["first"], 10, 32)
-			if err != nil {
-				errorMessage = fmt.Errorf("failed to parse \"--portscan-range\": %v", err)
-				return
+	return
+}
+
+// parsePortrange parses a port range (format "nnn-mmm" or single port "nnn")
+func parsePortrange(portrange string) (Portrange, error) {
+	// parse via regexp
+	portscanRangeSubMatch := portrangeRegexp.FindStringSubmatch(portrange)
+
+	if len(portscanRangeSubMatch) == 0 {
+		// portrange is invalid
+		return Portrange{}, fmt.Errorf("has to be format \"nnn-mmm\" (%v)", portrange)
+	}
+
+	// get named submatches
+	portscanRangeSubMatchResult := make(map[string]string)
+	for i, name := range portrangeRegexp.SubexpNames() {
+		if i != 0 && name != "" {
+			portscanRangeSubMatchResult[name] = portscanRangeSubMatch[i]
+		}
+	}
+
+	// parse first port
+	firstPort, err := strconv.ParseInt(portscanRangeSubMatchResult["first"], 10, 32)
+	if err != nil {
+		return Portrange{}, err
+	}
+
+	// parse last port (optional)
+	lastPort := firstPort
+	if portscanRangeSubMatchResult["last"] != "" {
+		lastPort, err = strconv.ParseInt(portscanRangeSubMatchResult["last"], 10, 32)
+		if err != nil {
+			return Portrange{}, err
+		}
+	}
+
+	// check min port
+	if firstPort < 1 {
+		return Portrange{}, fmt.Errorf("first port cannot be smaller then 0 (%v -> %v)", firstPort, lastPort)
+	}
+
+	// check max port
+	if lastPort > 65535 {
+		return Portrange{}, fmt.Errorf("last port cannot be bigger then 65535 (%v -> %v)", firstPort, lastPort)
+	}
+
+	// check if range is ok
+	if firstPort > lastPort {
+		return Portrange{}, fmt.Errorf("first port cannot be beyond last port (%v -> %v)", firstPort, lastPort)
+	}
+
+	return Portrange{FirstPort: int(firstPort), LastPort: int(lastPort)}, nil
+}
+
+// parse --monitor-metric
+func argparserParseMonitorMetrics() (errorMessage error) {
+	monitorMetricList = []MonitorMetric{}
+
+	for _, monitorMetric := range opts.Monitor.Metrics {
+		// parse via regexp
+		monitorMetricSubMatch := monitorMetricRegexp.FindStringSubmatch(monitorMetric)
+
+		if len(monitorMetricSubMatch) == 0 {
+			// metric is invalid
+			errorMessage = fmt.Errorf("unable to parse \"--monitor-metric\" (%v), has to be format \"resourceType:metricName[:aggregation]\"", monitorMetric)
+			return
+		}
+
+		// get named submatches
+		monitorMetricSubMatchResult := make(map[string]string)
+		for i, name := range monitorMetricRegexp.SubexpNames() {
+			if i != 0 && name != "" {
+				monitorMetricSubMatchResult[name] = monitorMetricSubMatch[i]
 			}
+		}
+
+		// aggregation (optional)
+		aggregation := strings.ToLower(monitorMetricSubMatchResult["aggregation"])
+		switch aggregation {
+		case "":
+			aggregation = "average"
+		case "average", "minimum", "maximum", "total", "count":
+		default:
+			errorMessage = fmt.Errorf("failed to parse \"--monitor-metric\" (%v): invalid aggregation \"%v\"", monitorMetric, aggregation)
+			return
+		}
+
+		// add to metric list
+		monitorMetricList = append(
+			monitorMetricList,
+			MonitorMetric{
+				ResourceType: strings.ToLower(monitorMetricSubMatchResult["resourceType"]),
+				Metric:       monitorMetricSubMatchResult["metric"],
+				Aggregation:  aggregation,
+			},
+		)
+	}
+
+	return
+}
+
+// parse --publicip-allow-cidr and --publicip-deny-cidr
+func argparserParsePublicIpCidrs() (errorMessage error) {
+	publicIpAllowList = []*net.IPNet{}
+	for _, cidr := range opts.PublicIp.AllowCidr {
+		_, ipNet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			errorMessage = fmt.Errorf("failed to parse \"--publicip-allow-cidr\": %v", err)
+			return
+		}
+		publicIpAllowList = append(publicIpAllowList, ipNet)
+	}
+
+	publicIpDenyList = []*net.IPNet{}
+	for _, cidr := range opts.PublicIp.DenyCidr {
+		_, ipNet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			errorMessage = fmt.Errorf("failed to parse \"--publicip-deny-cidr\": %v", err)
+			return
+		}
+		publicIpDenyList = append(publicIpDenyList, ipNet)
+	}
+
+	return
+}
+
+// parse --vm-image-eol
+func argparserParseVmImageEol() (errorMessage error) {
+	for _, imagePattern := range opts.VirtualMachine.ImageEol {
+		if len(strings.Split(imagePattern, ":")) != 3 {
+			errorMessage = fmt.Errorf("unable to parse \"--vm-image-eol\" (%v), has to be format \"publisher:offer:sku\"", imagePattern)
+			return
+		}
+
+		if _, err := path.Match(strings.ToLower(imagePattern), ""); err != nil {
+			errorMessage = fmt.Errorf("failed to parse \"--vm-image-eol\" (%v): %v", imagePattern, err)
+			return
+		}
+	}
+
+	return
+}
+
+// parse --scrape-budget
+func argparserParseCollectorBudgets() (errorMessage error) {
+	collectorBudgets = map[string]time.Duration{}
+	for _, budget := range opts.Scrape.Budget {
+		parts := strings.SplitN(budget, "=", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			errorMessage = fmt.Errorf("unable to parse \"--scrape-budget\" (%v), has to be format \"ResourceType=duration\"", budget)
+			return
+		}
+
+		duration, err := time.ParseDuration(parts[1])
+		if err != nil || duration <= 0 {
+			errorMessage = fmt.Errorf("invalid duration in \"--scrape-budget\" (%v)", budget)
+			return
+		}
+
+		collectorBudgets[strings.ToLower(parts[0])] = duration
+	}
+
+	if len(collectorBudgets) > 0 && (opts.Scrape.BudgetOverruns <= 0 || opts.Scrape.BudgetBackoff <= 0) {
+		errorMessage = errors.New("\"--scrape-budget-overruns\" and \"--scrape-budget-backoff\" must be greater than zero")
+		return
+	}
+
+	return
+}
+
+// parse --metrics.const-label and --metrics.help
+func argparserParseMetricsOverrides() (errorMessage error) {
+	metricsConstLabels = map[string]prometheus.Labels{}
+	for _, constLabel := range opts.Metrics.ConstLabels {
+		parts := strings.SplitN(constLabel, "=", 2)
+		if len(parts) != 2 {
+			errorMessage = fmt.Errorf("unable to parse \"--metrics.const-label\" (%v), has to be format \"[collector:]name=value\"", constLabel)
+			return
+		}
 
-			// parse last port (optional)
-			if portscanRangeSubMatchResult["last"] != "" {
-				lastPort, err = strconv.ParseInt(portscanRangeSubMatchResult["last"], 10, 32)
-				if err != nil {
-					errorMessage = fmt.Errorf("failed to parse \"--portscan-range\": %v", err)
+		collectorName := ""
+		labelName := parts[0]
+		labelValue := parts[1]
+		if nameParts := strings.SplitN(labelName, ":", 2); len(nameParts) == 2 {
+			collectorName = strings.ToLower(nameParts[0])
+			labelName = nameParts[1]
+		}
+
+		if !metricsLabelNameRegexp.MatchString(labelName) {
+			errorMessage = fmt.Errorf("invalid label name in \"--metrics.const-label\" (%v)", constLabel)
+			return
+		}
+
+		if collectorName == "" {
+			for _, reservedLabelName := range metricsReservedLabelNames {
+				if strings.EqualFold(labelName, reservedLabelName) {
+					errorMessage = fmt.Errorf("label name \"%v\" in \"--metrics.const-label\" (%v) is used by the exporter metrics, use \"collector:%v=value\" for a single collector or another name", labelName, constLabel, labelName)
 					return
 				}
-			} else {
-				// single port only
-				lastPort = firstPort
 			}
+		}
 
-			// check min port
-			if firstPort < 1 {
-				errorMessage = fmt.Errorf("failed to parse \"--portscan-range\": first port cannot be smaller then 0 (%v -> %v)", firstPort, lastPort)
-				return
+		if _, exists := metricsConstLabels[collectorName]; !exists {
+			metricsConstLabels[collectorName] = prometheus.Labels{}
+		}
+		metricsConstLabels[collectorName][labelName] = labelValue
+	}
+
+	metricsHelpOverride = map[string]string{}
+	for _, helpOverride := range opts.Metrics.HelpOverride {
+		parts := strings.SplitN(strings.TrimSpace(helpOverride), "=", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			errorMessage = fmt.Errorf("unable to parse \"--metrics.help\" (%v), has to be format \"metric=help text\"", helpOverride)
+			return
+		}
+		metricsHelpOverride[parts[0]] = parts[1]
+	}
+
+	return
+}
+
+// parse --metrics.allow and --metrics.deny
+func argparserParseMetricsFilter() (errorMessage error) {
+	parse := func(optionName string, patterns []string) (map[string][]string, error) {
+		ret := map[string][]string{}
+		for _, pattern := range patterns {
+			collectorName := ""
+			metricPattern := strings.TrimSpace(pattern)
+			if parts := strings.SplitN(metricPattern, ":", 2); len(parts) == 2 {
+				collectorName = strings.ToLower(parts[0])
+				metricPattern = parts[1]
 			}
 
-			// check max port
-			if lastPort > 65535 {
-				errorMessage = fmt.Errorf("failed to parse \"--portscan-range\": last port cannot be bigger then 65535 (%v -> %v)", firstPort, lastPort)
-				return
+			if metricPattern == "" {
+				return nil, fmt.Errorf("unable to parse \"%v\" (%v), has to be format \"[collector:]metric\"", optionName, pattern)
+			}
+
+			if _, err := path.Match(metricPattern, ""); err != nil {
+				return nil, fmt.Errorf("failed to parse \"%v\" (%v): %v", optionName, pattern, err)
+			}
+
+			ret[collectorName] = append(ret[collectorName], metricPattern)
+		}
+		return ret, nil
+	}
+
+	if metricsAllowList, errorMessage = parse("--metrics.allow", opts.Metrics.Allow); errorMessage != nil {
+		return
+	}
+
+	if metricsDenyList, errorMessage = parse("--metrics.deny", opts.Metrics.Deny); errorMessage != nil {
+		return
+	}
+
+	return
+}
+
+// parse --metrics.group
+func argparserParseMetricsGroups() (errorMessage error) {
+	metricsGroups = []metricsGroup{}
+	groupNames := map[string]bool{}
+	for _, groupConfig := range opts.Metrics.Groups {
+		parts := strings.SplitN(strings.TrimSpace(groupConfig), "=", 2)
+		if len(parts) != 2 || !metricsLabelNameRegexp.MatchString(parts[0]) {
+			errorMessage = fmt.Errorf("unable to parse \"--metrics.group\" (%v), has to be format \"group=Collector,Collector[@bind]\"", groupConfig)
+			return
+		}
+
+		group := metricsGroup{
+			name:       strings.ToLower(parts[0]),
+			collectors: map[string]bool{},
+		}
+
+		collectorList := parts[1]
+		if collectorParts := strings.SplitN(collectorList, "@", 2); len(collectorParts) == 2 {
+			collectorList = collectorParts[0]
+			group.bind = collectorParts[1]
+		}
+
+		for _, collectorName := range strings.Split(collectorList, ",") {
+			if collectorName = strings.TrimSpace(collectorName); collectorName != "" {
+				group.collectors[strings.ToLower(collectorName)] = true
 			}
+		}
+
+		if len(group.collectors) == 0 {
+			errorMessage = fmt.Errorf("no collectors specified in \"--metrics.group\" (%v)", groupConfig)
+			return
+		}
+
+		if groupNames[group.name] {
+			errorMessage = fmt.Errorf("duplicate group \"%v\" in \"--metrics.group\"", group.name)
+			return
+		}
+		groupNames[group.name] = true
+
+		metricsGroups = append(metricsGroups, group)
+	}
+
+	return
+}
+
+// validate the collectors of --metrics.group, the collectors are only known after initMetricCollector
+func argparserValidateMetricsGroups() (errorMessage error) {
+	enabledCollectors := map[string]bool{}
+	for collectorName := range collectorGeneralList {
+		enabledCollectors[strings.ToLower(collectorName)] = true
+	}
+	for collectorName := range collectorCustomList {
+		enabledCollectors[strings.ToLower(collectorName)] = true
+	}
+
+	for _, group := range metricsGroups {
+		collectorNames := []string{}
+		for collectorName := range group.collectors {
+			collectorNames = append(collectorNames, collectorName)
+		}
+		sort.Strings(collectorNames)
 
-			// check if range is ok
-			if firstPort > lastPort {
-				errorMessage = fmt.Errorf("failed to parse \"--portscan-range\": first port cannot be beyond last port (%v -> %v)", firstPort, lastPort)
+		for _, collectorName := range collectorNames {
+			if !enabledCollectors[collectorName] {
+				errorMessage = fmt.Errorf("collector \"%v\" of group \"%v\" in \"--metrics.group\" is unknown or not enabled", collectorName, group.name)
 				return
 			}
+		}
+	}
 
-			// add to portlist
-			portscanPortRange = append(
-				portscanPortRange,
-				Portrange{FirstPort: int(firstPort), LastPort: int(lastPort)},
-			)
+	return
+}
+
+// parse --blackout-window
+func argparserParseBlackoutWindows() (errorMessage error) {
+	blackoutWindows = []blackoutWindow{}
+	for _, window := range opts.BlackoutWindows {
+		blackoutWindow, err := parseBlackoutWindow(window)
+		if err != nil {
+			errorMessage = fmt.Errorf("unable to parse \"--blackout-window\" (%v): %v", window, err)
+			return
 		}
-	} else {
-		errorMessage = errors.New("no port range available, set via \"--portscan-range\"")
+		blackoutWindows = append(blackoutWindows, blackoutWindow)
+	}
+
+	return
+}
+
+// parse --subscription-mapping
+func argparserParseSubscriptionMapping() (errorMessage error) {
+	subscriptionMappingLabels = map[string]map[string]string{}
+	subscriptionMappingLabelNames = []string{}
+
+	if opts.SubscriptionMapping == "" {
+		return
+	}
+
+	content, err := ioutil.ReadFile(opts.SubscriptionMapping)
+	if err != nil {
+		errorMessage = fmt.Errorf("failed to read \"--subscription-mapping\": %v", err)
 		return
 	}
 
+	mapping := map[string]map[string]string{}
+	if err := json.Unmarshal(content, &mapping); err != nil {
+		errorMessage = fmt.Errorf("failed to parse \"--subscription-mapping\": %v", err)
+		return
+	}
+
+	labelNames := map[string]bool{}
+	for subscriptionId, labels := range mapping {
+		for labelName := range labels {
+			if !metricsLabelNameRegexp.MatchString(labelName) {
+				errorMessage = fmt.Errorf("invalid label name \"%v\" in \"--subscription-mapping\" (subscription %v)", labelName, subscriptionId)
+				return
+			}
+			labelNames[labelName] = true
+		}
+		subscriptionMappingLabels[strings.ToLower(subscriptionId)] = labels
+	}
+
+	for labelName := range labelNames {
+		subscriptionMappingLabelNames = append(subscriptionMappingLabelNames, labelName)
+	}
+	sort.Strings(subscriptionMappingLabelNames)
+
 	return
 }
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestArgparserParseMetricsOverrides(t *testing.T) {
+	savedOpts := opts
+	defer func() { opts = savedOpts }()
+
+	testCases := []struct {
+		name         string
+		constLabels  []string
+		helpOverride []string
+		wantErr      bool
+		labels       map[string]map[string]string
+		help         map[string]string
+	}{
+		{
+			name:        "global label",
+			constLabels: []string{"team=platform"},
+			labels:      map[string]map[string]string{"": {"team": "platform"}},
+		},
+		{
+			name:        "collector label",
+			constLabels: []string{"Resource:team=resources", "Storage:collector=storage"},
+			labels:      map[string]map[string]string{"resource": {"team": "resources"}, "storage": {"collector": "storage"}},
+		},
+		{
+			name:        "value with equals sign",
+			constLabels: []string{"query=a=b"},
+			labels:      map[string]map[string]string{"": {"query": "a=b"}},
+		},
+		{
+			name:         "help override",
+			helpOverride: []string{"azurerm_resource_info=Azure resources"},
+			labels:       map[string]map[string]string{},
+			help:         map[string]string{"azurerm_resource_info": "Azure resources"},
+		},
+		{name: "reserved global label", constLabels: []string{"collector=foo"}, wantErr: true},
+		{name: "reserved global label case insensitive", constLabels: []string{"SubscriptionID=foo"}, wantErr: true},
+		{name: "missing value", constLabels: []string{"team"}, wantErr: true},
+		{name: "invalid label name", constLabels: []string{"my-team=platform"}, wantErr: true},
+		{name: "invalid collector label name", constLabels: []string{"Resource:1team=platform"}, wantErr: true},
+		{name: "help without text", helpOverride: []string{"azurerm_resource_info"}, wantErr: true},
+		{name: "help without metric", helpOverride: []string{"=Azure resources"}, wantErr: true},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			opts.Metrics.ConstLabels = testCase.constLabels
+			opts.Metrics.HelpOverride = testCase.helpOverride
+
+			err := argparserParseMetricsOverrides()
+			if testCase.wantErr {
+				if err == nil {
+					t.Fatalf("expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if len(metricsConstLabels) != len(testCase.labels) {
+				t.Errorf("expected const labels %v, got %v", testCase.labels, metricsConstLabels)
+			}
+			for collectorName, labels := range testCase.labels {
+				for labelName, labelValue := range labels {
+					if value := metricsConstLabels[collectorName][labelName]; value != labelValue {
+						t.Errorf("%v:%v: expected %q, got %q", collectorName, labelName, labelValue, value)
+					}
+				}
+			}
+
+			if len(metricsHelpOverride) != len(testCase.help) {
+				t.Errorf("expected help overrides %v, got %v", testCase.help, metricsHelpOverride)
+			}
+			for metricName, help := range testCase.help {
+				if value := metricsHelpOverride[metricName]; value != help {
+					t.Errorf("%v: expected %q, got %q", metricName, help, value)
+				}
+			}
+		})
+	}
+}
+
+func TestArgparserParseCollectorBudgets(t *testing.T) {
+	savedOpts := opts
+	defer func() { opts = savedOpts }()
+
+	testCases := []struct {
+		name     string
+		budgets  []string
+		overruns int
+		backoff  time.Duration
+		wantErr  bool
+		expected map[string]time.Duration
+	}{
+		{
+			name:     "no budgets",
+			expected: map[string]time.Duration{},
+		},
+		{
+			name:     "provider namespace and resource type",
+			budgets:  []string{"Microsoft.Storage=2m", "Microsoft.Compute/virtualMachines=30s"},
+			overruns: 3,
+			backoff:  time.Hour,
+			expected: map[string]time.Duration{
+				"microsoft.storage":                 2 * time.Minute,
+				"microsoft.compute/virtualmachines": 30 * time.Second,
+			},
+		},
+		{name: "missing duration", budgets: []string{"Microsoft.Storage"}, overruns: 3, backoff: time.Hour, wantErr: true},
+		{name: "missing resource type", budgets: []string{"=2m"}, overruns: 3, backoff: time.Hour, wantErr: true},
+		{name: "invalid duration", budgets: []string{"Microsoft.Storage=2"}, overruns: 3, backoff: time.Hour, wantErr: true},
+		{name: "zero duration", budgets: []string{"Microsoft.Storage=0s"}, overruns: 3, backoff: time.Hour, wantErr: true},
+		{name: "zero overruns", budgets: []string{"Microsoft.Storage=2m"}, overruns: 0, backoff: time.Hour, wantErr: true},
+		{name: "zero backoff", budgets: []string{"Microsoft.Storage=2m"}, overruns: 3, backoff: 0, wantErr: true},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			opts.Scrape.Budget = testCase.budgets
+			opts.Scrape.BudgetOverruns = testCase.overruns
+			opts.Scrape.BudgetBackoff = testCase.backoff
+
+			err := argparserParseCollectorBudgets()
+			if testCase.wantErr {
+				if err == nil {
+					t.Fatalf("expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if len(collectorBudgets) != len(testCase.expected) {
+				t.Errorf("expected budgets %v, got %v", testCase.expected, collectorBudgets)
+			}
+			for resourceType, budget := range testCase.expected {
+				if value := collectorBudgets[resourceType]; value != budget {
+					t.Errorf("%v: expected %v, got %v", resourceType, budget, value)
+				}
+			}
+		})
+	}
+}
+
+func TestArgparserValidateMetricsGroups(t *testing.T) {
+	savedOpts := opts
+	savedGeneralList, savedCustomList := collectorGeneralList, collectorCustomList
+	defer func() {
+		opts = savedOpts
+		collectorGeneralList, collectorCustomList = savedGeneralList, savedCustomList
+	}()
+
+	collectorGeneralList = map[string]*CollectorGeneral{"Resource": nil, "General": nil}
+	collectorCustomList = map[string]*CollectorCustom{"Portscan": nil}
+
+	testCases := []struct {
+		name    string
+		groups  []string
+		wantErr bool
+	}{
+		{name: "no groups"},
+		{name: "enabled collectors", groups: []string{"inventory=Resource,general", "portscan=Portscan@:8081"}},
+		{name: "unknown collector", groups: []string{"inventory=Resource,Resources"}, wantErr: true},
+		{name: "disabled collector", groups: []string{"security=Security"}, wantErr: true},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			opts.Metrics.Groups = testCase.groups
+			if err := argparserParseMetricsGroups(); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			err := argparserValidateMetricsGroups()
+			if testCase.wantErr && err == nil {
+				t.Fatalf("expected error")
+			}
+			if !testCase.wantErr && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		})
+	}
+}
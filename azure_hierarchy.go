@@ -0,0 +1,236 @@
+package main
+
+import (
+	"context"
+	"github.com/Azure/azure-sdk-for-go/profiles/latest/resources/mgmt/managementgroups"
+	"github.com/Azure/azure-sdk-for-go/profiles/latest/resources/mgmt/resources"
+	"github.com/Azure/azure-sdk-for-go/profiles/latest/resources/mgmt/subscriptions"
+	"github.com/Azure/go-autorest/autorest/to"
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	AZURE_HIERARCHY_LABEL_SUBSCRIPTION_NAME    = "subscriptionName"
+	AZURE_HIERARCHY_LABEL_MANAGEMENTGROUP_PATH = "managementGroupPath"
+
+	// subscriptions may be moved between management groups, the paths are reloaded by the subscription collector
+	AZURE_HIERARCHY_MANAGEMENTGROUP_TTL = 1 * time.Hour
+)
+
+var (
+	azureHierarchy = newAzureHierarchyCache()
+)
+
+// AzureHierarchyCache caches subscription names, management group paths (by lowercase subscription id)
+// and subscription/resourcegroup tags for tag inheritance
+type AzureHierarchyCache struct {
+	lock sync.RWMutex
+
+	subscriptionName    map[string]string
+	managementGroupPath map[string]string
+
+	subscriptionTags  map[string]map[string]*string
+	resourceGroupTags map[string]map[string]*string
+
+	managementGroupFetched time.Time
+	managementGroupLock    sync.Mutex
+}
+
+func newAzureHierarchyCache() *AzureHierarchyCache {
+	return &AzureHierarchyCache{
+		subscriptionName:    map[string]string{},
+		managementGroupPath: map[string]string{},
+		subscriptionTags:    map[string]map[string]*string{},
+		resourceGroupTags:   map[string]map[string]*string{},
+	}
+}
+
+// init cache from the detected subscriptions, the management group hierarchy and the resourcegroups
+func initAzureHierarchy() {
+	ctx := context.Background()
+
+	dynamicConfigLock.RLock()
+	subscriptionList := AzureSubscriptions
+	dynamicConfigLock.RUnlock()
+
+	cache, err := loadAzureHierarchy(ctx, subscriptionList)
+	if err != nil {
+		log.Panic(err)
+	}
+	azureHierarchy.merge(cache)
+
+	if opts.Metrics.HierarchyLabels {
+		azureHierarchy.refreshManagementGroups(ctx, true)
+	}
+}
+
+// loadAzureHierarchy loads the names, tags and resourcegroup tags of the subscriptions into a new cache
+// (management group paths are loaded by refreshManagementGroups)
+func loadAzureHierarchy(ctx context.Context, subscriptionList []subscriptions.Subscription) (*AzureHierarchyCache, error) {
+	cache := newAzureHierarchyCache()
+
+	for _, subscription := range subscriptionList {
+		cache.setSubscriptionName(to.String(subscription.SubscriptionID), to.String(subscription.DisplayName))
+		cache.setSubscriptionTags(to.String(subscription.SubscriptionID), subscription.Tags)
+
+		if !opts.Azure.TagInheritance {
+			continue
+		}
+
+		client := resources.NewGroupsClientWithBaseURI(azureEnvironment.ResourceManagerEndpoint, *subscription.SubscriptionID)
+		client.Authorizer = AzureAuthorizer
+
+		list, err := client.ListComplete(ctx, "", nil)
+		if err != nil {
+			return nil, err
+		}
+
+		for list.NotDone() {
+			val := list.Value()
+			cache.setResourceGroupTags(to.String(subscription.SubscriptionID), to.String(val.Name), val.Tags)
+
+			if err := list.NextWithContext(ctx); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return cache, nil
+}
+
+// refreshManagementGroups reloads the management group paths of all subscriptions if they are older than the ttl
+// (or if forced), the previous paths are kept if the reload fails
+func (c *AzureHierarchyCache) refreshManagementGroups(ctx context.Context, force bool) {
+	c.managementGroupLock.Lock()
+	defer c.managementGroupLock.Unlock()
+
+	if !force && time.Since(c.managementGroupFetched) < AZURE_HIERARCHY_MANAGEMENTGROUP_TTL {
+		return
+	}
+	c.managementGroupFetched = time.Now()
+
+	managementGroupPath, err := fetchAzureManagementGroupPaths(ctx)
+	if err != nil {
+		// management group read permissions are optional
+		log.Warnf("unable to fetch management group hierarchy: %v", err)
+		return
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.managementGroupPath = managementGroupPath
+}
+
+// fetchAzureManagementGroupPaths returns the management group path (eg. root/platform/prod) by lowercase subscription id
+func fetchAzureManagementGroupPaths(ctx context.Context) (map[string]string, error) {
+	client := managementgroups.NewEntitiesClientWithBaseURI(azureEnvironment.ResourceManagerEndpoint)
+	client.Authorizer = AzureAuthorizer
+
+	list, err := client.ListComplete(ctx, "", nil, nil, "", "", "", "", "", "")
+	if err != nil {
+		return nil, err
+	}
+
+	managementGroupPath := map[string]string{}
+	for list.NotDone() {
+		val := list.Value()
+
+		if strings.EqualFold(to.String(val.Type), "/subscriptions") && val.EntityInfoProperties != nil && val.ParentNameChain != nil {
+			managementGroupPath[strings.ToLower(to.String(val.Name))] = strings.Join(*val.ParentNameChain, "/")
+		}
+
+		if err := list.NextWithContext(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	return managementGroupPath, nil
+}
+
+// merge adds the subscription names and tags of the (loaded) cache, eg. for subscriptions added by --config-watch
+func (c *AzureHierarchyCache) merge(cache *AzureHierarchyCache) {
+	cache.lock.RLock()
+	defer cache.lock.RUnlock()
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	for subscriptionId, name := range cache.subscriptionName {
+		c.subscriptionName[subscriptionId] = name
+	}
+	for subscriptionId, tags := range cache.subscriptionTags {
+		c.subscriptionTags[subscriptionId] = tags
+	}
+	for resourceGroupId, tags := range cache.resourceGroupTags {
+		c.resourceGroupTags[resourceGroupId] = tags
+	}
+}
+
+// replace swaps in the subscription names and tags of the (completely loaded) cache, eg. for a reload by the admin api
+func (c *AzureHierarchyCache) replace(cache *AzureHierarchyCache) {
+	cache.lock.RLock()
+	defer cache.lock.RUnlock()
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.subscriptionName = cache.subscriptionName
+	c.subscriptionTags = cache.subscriptionTags
+	c.resourceGroupTags = cache.resourceGroupTags
+}
+
+func (c *AzureHierarchyCache) setSubscriptionName(subscriptionId, name string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.subscriptionName[strings.ToLower(subscriptionId)] = name
+}
+
+func (c *AzureHierarchyCache) setSubscriptionTags(subscriptionId string, tags map[string]*string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.subscriptionTags[strings.ToLower(subscriptionId)] = tags
+}
+
+func (c *AzureHierarchyCache) setResourceGroupTags(subscriptionId, resourceGroup string, tags map[string]*string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.resourceGroupTags[strings.ToLower(subscriptionId+"/"+resourceGroup)] = tags
+}
+
+// hierarchyLabels returns the subscription name and management group path labels of the subscription (lowercase id)
+func (c *AzureHierarchyCache) hierarchyLabels(subscriptionId string) map[string]string {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	return map[string]string{
+		AZURE_HIERARCHY_LABEL_SUBSCRIPTION_NAME:    c.subscriptionName[subscriptionId],
+		AZURE_HIERARCHY_LABEL_MANAGEMENTGROUP_PATH: c.managementGroupPath[subscriptionId],
+	}
+}
+
+// inheritTags returns the tags merged with the tags of the resourcegroup and subscription (identified by labels),
+// tags of the resource win over resourcegroup tags and resourcegroup tags win over subscription tags
+func (c *AzureHierarchyCache) inheritTags(labels prometheus.Labels, tags map[string]*string) map[string]*string {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	ret := map[string]*string{}
+	mergeTags := func(tags map[string]*string) {
+		for tagName, tagValue := range tags {
+			if to.String(tagValue) != "" {
+				ret[strings.ToLower(tagName)] = tagValue
+			}
+		}
+	}
+
+	subscriptionId := strings.ToLower(labels["subscriptionID"])
+	mergeTags(c.subscriptionTags[subscriptionId])
+	if resourceGroup := labels["resourceGroup"]; resourceGroup != "" {
+		mergeTags(c.resourceGroupTags[strings.ToLower(subscriptionId+"/"+resourceGroup)])
+	}
+	mergeTags(tags)
+
+	return ret
+}
@@ -0,0 +1,274 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/Azure/go-autorest/autorest/to"
+	log "github.com/sirupsen/logrus"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+const (
+	// placeholder for the endpoint in recorded fixtures (eg. nextLink urls)
+	AzureMockEndpointPlaceholder = "{{mockEndpoint}}"
+)
+
+var (
+	// json fields which are redacted in recorded fixtures
+	azureMockSanitizeRegexp = regexp.MustCompile(`(?i)(key|secret|password|token|connectionstring|credential)s?$`)
+
+	// subscription and tenant ids (in resource ids, urls and id fields) which are replaced in recorded fixtures
+	azureMockGuidRegexp           = regexp.MustCompile(`(?i)[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}`)
+	azureMockSubscriptionIdRegexp = regexp.MustCompile(`(?i)/subscriptions/([0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12})`)
+	azureMockIdFieldRegexp        = regexp.MustCompile(`(?i)"(?:subscriptionId|tenantId|homeTenantId)"\s*:\s*"([0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12})"`)
+)
+
+// azureMockFixture is a recorded Azure ResourceManager response (--mock-azure)
+type azureMockFixture struct {
+	Request    string            `json:"request"`
+	StatusCode int               `json:"statusCode"`
+	Header     map[string]string `json:"header"`
+	Body       json.RawMessage   `json:"body"`
+}
+
+// azureMockServer serves recorded fixtures or records the responses of the real endpoint
+type azureMockServer struct {
+	path             string
+	endpoint         string
+	upstreamEndpoint string
+	record           bool
+
+	// subscription and tenant ids (lowercase) of the recorded tenant
+	redactIds     map[string]bool
+	redactIdsLock sync.Mutex
+}
+
+// initAzureMock starts an in-process Azure ResourceManager endpoint and points all clients to it
+// (--mock-azure serves fixtures, --mock-azure-record records the responses of the real endpoint)
+func initAzureMock() {
+	if err := os.MkdirAll(opts.Mock.Path, 0700); err != nil {
+		log.Panic(err)
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		log.Panic(err)
+	}
+
+	server := azureMockServer{
+		path:             opts.Mock.Path,
+		endpoint:         fmt.Sprintf("http://%s/", listener.Addr().String()),
+		upstreamEndpoint: azureEnvironment.ResourceManagerEndpoint,
+		record:           opts.Mock.Record,
+		redactIds:        map[string]bool{},
+	}
+	for _, id := range append([]string{to.String(opts.Azure.Tenant)}, opts.Azure.Subscription...) {
+		if azureMockGuidRegexp.MatchString(id) {
+			server.redactIds[strings.ToLower(id)] = true
+			if server.record {
+				log.Infof("recording %v as %v", id, azureMockPlaceholderId(id))
+			}
+		}
+	}
+
+	go func() {
+		log.Fatal(http.Serve(listener, &server))
+	}()
+
+	if server.record {
+		log.Infof("recording Azure ResourceManager fixtures to %v", server.path)
+	} else {
+		log.Infof("serving Azure ResourceManager fixtures from %v", server.path)
+	}
+
+	azureEnvironment.ResourceManagerEndpoint = server.endpoint
+}
+
+func (s *azureMockServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	requestPath := r.URL.Path + "?" + r.URL.Query().Encode()
+
+	requestBody, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// fixtures are identified by the redacted request, fixtures are served for the placeholder subscription ids
+	keyBody := requestBody
+	if s.record {
+		s.collectRedactIds([]byte(requestPath))
+		s.collectRedactIds(requestBody)
+		requestPath = string(s.redact([]byte(requestPath)))
+		keyBody = s.redact(requestBody)
+	}
+
+	fixtureKey := r.Method + " " + requestPath
+
+	// requests with body (eg. cost queries) are identified by the body
+	if len(keyBody) > 0 {
+		bodyHash := sha256.Sum256(keyBody)
+		fixtureKey += " " + hex.EncodeToString(bodyHash[:])[0:16]
+	}
+
+	fixtureHash := sha256.Sum256([]byte(strings.ToLower(fixtureKey)))
+	fixturePath := filepath.Join(s.path, hex.EncodeToString(fixtureHash[:])[0:16]+".json")
+
+	var fixture *azureMockFixture
+	if s.record {
+		fixture, err = s.recordFixture(r, requestBody, fixtureKey, fixturePath)
+	} else {
+		fixture, err = s.loadFixture(fixturePath)
+	}
+
+	if err != nil {
+		log.WithField("request", fixtureKey).Warnf("mock: %v", err)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprintf(w, `{"error":{"code":"MockFixtureNotFound","message":%q}}`, err.Error()) //nolint:errcheck
+		return
+	}
+
+	for name, value := range fixture.Header {
+		w.Header().Set(name, value)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(fixture.StatusCode)
+	w.Write(bytes.ReplaceAll(fixture.Body, []byte(AzureMockEndpointPlaceholder), []byte(s.endpoint))) //nolint:errcheck
+}
+
+func (s *azureMockServer) loadFixture(fixturePath string) (*azureMockFixture, error) {
+	content, err := ioutil.ReadFile(fixturePath)
+	if err != nil {
+		return nil, fmt.Errorf("no fixture recorded: %v", err)
+	}
+
+	fixture := azureMockFixture{}
+	if err := json.Unmarshal(content, &fixture); err != nil {
+		return nil, fmt.Errorf("invalid fixture %v: %v", fixturePath, err)
+	}
+
+	return &fixture, nil
+}
+
+// recordFixture forwards the request to the real endpoint and saves the sanitized response
+func (s *azureMockServer) recordFixture(r *http.Request, requestBody []byte, fixtureKey, fixturePath string) (*azureMockFixture, error) {
+	upstreamRequest, err := http.NewRequestWithContext(r.Context(), r.Method, strings.TrimSuffix(s.upstreamEndpoint, "/")+r.URL.RequestURI(), bytes.NewReader(requestBody))
+	if err != nil {
+		return nil, err
+	}
+	upstreamRequest.Header = r.Header.Clone()
+
+	resp, err := http.DefaultClient.Do(upstreamRequest)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close() //nolint:errcheck
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	s.collectRedactIds(body)
+
+	var content interface{}
+	if len(body) > 0 {
+		if err := json.Unmarshal(body, &content); err != nil {
+			return nil, fmt.Errorf("response is not json: %v", err)
+		}
+	}
+
+	body, err = json.Marshal(azureMockSanitize("", content))
+	if err != nil {
+		return nil, err
+	}
+
+	fixture := azureMockFixture{
+		Request:    fixtureKey,
+		StatusCode: resp.StatusCode,
+		Header:     map[string]string{},
+		Body:       s.redact(bytes.ReplaceAll(body, []byte(s.upstreamEndpoint), []byte(AzureMockEndpointPlaceholder))),
+	}
+
+	// ratelimit headers are exported as metrics
+	for name := range resp.Header {
+		if strings.HasPrefix(strings.ToLower(name), "x-ms-ratelimit-") {
+			fixture.Header[name] = resp.Header.Get(name)
+		}
+	}
+
+	fixtureContent, err := json.MarshalIndent(fixture, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	if err := ioutil.WriteFile(fixturePath, fixtureContent, 0600); err != nil {
+		return nil, err
+	}
+
+	return &fixture, nil
+}
+
+// azureMockSanitize redacts string values of sensitive json fields (eg. keys, secrets and connection strings)
+func azureMockSanitize(fieldName string, content interface{}) interface{} {
+	switch val := content.(type) {
+	case map[string]interface{}:
+		for name, fieldValue := range val {
+			val[name] = azureMockSanitize(name, fieldValue)
+		}
+	case []interface{}:
+		for i, item := range val {
+			val[i] = azureMockSanitize(fieldName, item)
+		}
+	case string:
+		if fieldName != "" && azureMockSanitizeRegexp.MatchString(fieldName) {
+			return "REDACTED"
+		}
+	}
+
+	return content
+}
+
+// collectRedactIds remembers the subscription ids (of resource ids and urls) and the subscription/tenant id fields
+// of the content, the ids are replaced in all following fixtures
+func (s *azureMockServer) collectRedactIds(content []byte) {
+	s.redactIdsLock.Lock()
+	defer s.redactIdsLock.Unlock()
+
+	for _, match := range azureMockSubscriptionIdRegexp.FindAllSubmatch(content, -1) {
+		s.redactIds[strings.ToLower(string(match[1]))] = true
+	}
+	for _, match := range azureMockIdFieldRegexp.FindAllSubmatch(content, -1) {
+		s.redactIds[strings.ToLower(string(match[1]))] = true
+	}
+}
+
+// redact replaces the remembered subscription and tenant ids with placeholder ids, the placeholder is derived
+// from the id so the ids are replaced consistently in all fixtures (and recordings)
+func (s *azureMockServer) redact(content []byte) []byte {
+	s.redactIdsLock.Lock()
+	defer s.redactIdsLock.Unlock()
+
+	return azureMockGuidRegexp.ReplaceAllFunc(content, func(id []byte) []byte {
+		if !s.redactIds[strings.ToLower(string(id))] {
+			return id
+		}
+		return []byte(azureMockPlaceholderId(string(id)))
+	})
+}
+
+// azureMockPlaceholderId returns the placeholder id (eg. 00000000-0000-0000-0000-1a2b3c4d5e6f) of a subscription or tenant id
+func azureMockPlaceholderId(id string) string {
+	idHash := sha256.Sum256([]byte(strings.ToLower(id)))
+	return "00000000-0000-0000-0000-" + hex.EncodeToString(idHash[:])[0:12]
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"github.com/Azure/azure-sdk-for-go/profiles/latest/resources/mgmt/resources"
+	"github.com/Azure/azure-sdk-for-go/profiles/latest/resources/mgmt/subscriptions"
+	"github.com/Azure/go-autorest/autorest/to"
+	"strings"
+)
+
+// AzureResourceCache is the generic resource list of a subscription, shared by the Resource collector
+// (collector store) so other collectors don't need to list all resources again
+type AzureResourceCache struct {
+	list []*AzureResourceCacheEntry
+
+	// by lowercase resource id
+	resourceIdIndex map[string]*AzureResourceCacheEntry
+}
+
+type AzureResourceCacheEntry struct {
+	ID       string
+	Name     string
+	Type     string
+	Location string
+	Tags     map[string]*string
+}
+
+func NewAzureResourceCache() *AzureResourceCache {
+	return &AzureResourceCache{
+		resourceIdIndex: map[string]*AzureResourceCacheEntry{},
+	}
+}
+
+// azureResourceCacheGet returns the resources of the subscription shared by the Resource collector or lists them
+func azureResourceCacheGet(ctx context.Context, subscription subscriptions.Subscription) (*AzureResourceCache, error) {
+	if cache, exists := collectorStore.Get(collectorStoreResources, *subscription.SubscriptionID); exists {
+		return cache.(*AzureResourceCache), nil
+	}
+
+	client := resources.NewClientWithBaseURI(azureEnvironment.ResourceManagerEndpoint, *subscription.SubscriptionID)
+	client.Authorizer = AzureAuthorizer
+	client.ResponseInspector = azureResponseInspector(&subscription)
+
+	list, err := client.ListComplete(ctx, "", "", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	cache := NewAzureResourceCache()
+	for list.NotDone() {
+		cache.Add(list.Value())
+
+		if list.NextWithContext(ctx) != nil {
+			break
+		}
+	}
+
+	return cache, nil
+}
+
+func (c *AzureResourceCache) Add(resource resources.GenericResourceExpanded) {
+	entry := &AzureResourceCacheEntry{
+		ID:       to.String(resource.ID),
+		Name:     to.String(resource.Name),
+		Type:     to.String(resource.Type),
+		Location: to.String(resource.Location),
+		Tags:     resource.Tags,
+	}
+
+	c.list = append(c.list, entry)
+	c.resourceIdIndex[strings.ToLower(entry.ID)] = entry
+}
+
+// List returns all resources
+func (c *AzureResourceCache) List() []*AzureResourceCacheEntry {
+	return c.list
+}
+
+// ListByType returns the resources of the resource type (case insensitive)
+func (c *AzureResourceCache) ListByType(resourceType string) []*AzureResourceCacheEntry {
+	ret := []*AzureResourceCacheEntry{}
+	for _, entry := range c.list {
+		if strings.EqualFold(entry.Type, resourceType) {
+			ret = append(ret, entry)
+		}
+	}
+	return ret
+}
+
+// Get returns the resource by resource id (case insensitive)
+func (c *AzureResourceCache) Get(resourceId string) (*AzureResourceCacheEntry, bool) {
+	entry, exists := c.resourceIdIndex[strings.ToLower(resourceId)]
+	return entry, exists
+}
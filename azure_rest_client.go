@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/Azure/azure-sdk-for-go/profiles/latest/resources/mgmt/subscriptions"
+	"github.com/Azure/go-autorest/autorest"
+	"github.com/Azure/go-autorest/autorest/azure"
+	"net/http"
+)
+
+// AzureRestClient is a minimal ARM client for resource types which are not available in the used Azure SDK version
+type AzureRestClient struct {
+	autorest.Client
+	BaseURI string
+}
+
+type azureRestListResult struct {
+	Value    []json.RawMessage `json:"value"`
+	NextLink *string           `json:"nextLink"`
+}
+
+func NewAzureRestClient(subscription *subscriptions.Subscription) AzureRestClient {
+	client := AzureRestClient{
+		Client:  autorest.NewClientWithUserAgent("azure-resourcemanager-exporter"),
+		BaseURI: azureEnvironment.ResourceManagerEndpoint,
+	}
+	client.Authorizer = AzureAuthorizer
+	client.ResponseInspector = azureResponseInspector(subscription)
+
+	return client
+}
+
+// Get fetches one ARM resource (path is eg. the resource id) and unmarshals it into result
+func (c *AzureRestClient) Get(ctx context.Context, path string, apiVersion string, result interface{}) error {
+	return c.GetWithQuery(ctx, path, apiVersion, nil, result)
+}
+
+// GetWithQuery fetches one ARM resource with additional query parameters (eg. $expand) and unmarshals it into result
+func (c *AzureRestClient) GetWithQuery(ctx context.Context, path string, apiVersion string, query map[string]interface{}, result interface{}) error {
+	queryParameters := map[string]interface{}{"api-version": apiVersion}
+	for name, value := range query {
+		queryParameters[name] = value
+	}
+
+	req, err := autorest.Prepare(
+		(&http.Request{}).WithContext(ctx),
+		autorest.AsGet(),
+		autorest.WithBaseURL(c.BaseURI),
+		autorest.WithPath(path),
+		autorest.WithQueryParameters(queryParameters),
+	)
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.Send(req, azure.DoRetryWithRegistration(c.Client))
+	if err != nil {
+		return err
+	}
+
+	return autorest.Respond(
+		resp,
+		azure.WithErrorUnlessStatusCode(http.StatusOK),
+		autorest.ByUnmarshallingJSON(result),
+		autorest.ByClosing(),
+	)
+}
+
+// List fetches all pages of an ARM list call and returns the raw list items
+func (c *AzureRestClient) List(ctx context.Context, path string, apiVersion string) (list []json.RawMessage, err error) {
+	req, err := autorest.Prepare(
+		(&http.Request{}).WithContext(ctx),
+		autorest.AsGet(),
+		autorest.WithBaseURL(c.BaseURI),
+		autorest.WithPath(path),
+		autorest.WithQueryParameters(map[string]interface{}{"api-version": apiVersion}),
+	)
+
+	for req != nil && err == nil {
+		var resp *http.Response
+		resp, err = c.Send(req, azure.DoRetryWithRegistration(c.Client))
+		if err != nil {
+			return
+		}
+
+		result := azureRestListResult{}
+		err = autorest.Respond(
+			resp,
+			azure.WithErrorUnlessStatusCode(http.StatusOK),
+			autorest.ByUnmarshallingJSON(&result),
+			autorest.ByClosing(),
+		)
+		if err != nil {
+			return
+		}
+
+		list = append(list, result.Value...)
+
+		req = nil
+		if result.NextLink != nil && *result.NextLink != "" {
+			req, err = autorest.Prepare(
+				(&http.Request{}).WithContext(ctx),
+				autorest.AsGet(),
+				autorest.WithBaseURL(*result.NextLink),
+			)
+		}
+	}
+
+	return
+}
+
+// azureRestIsNotFound checks if the error was caused by a missing resource (HTTP 404)
+func azureRestIsNotFound(err error) bool {
+	var requestErr *azure.RequestError
+	if errors.As(err, &requestErr) {
+		return requestErr.StatusCode == http.StatusNotFound
+	}
+
+	return false
+}
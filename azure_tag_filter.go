@@ -4,7 +4,9 @@ import (
 	"github.com/Azure/go-autorest/autorest/to"
 	"github.com/prometheus/client_golang/prometheus"
 	"log"
+	"math"
 	"regexp"
+	"strconv"
 	"strings"
 )
 
@@ -16,6 +18,9 @@ type AzureTagFilter struct {
 	tags []AzureTagFilterTag
 
 	prometheusLabels []string
+
+	// inherit missing tags from resourcegroup and subscription
+	tagInheritance bool
 }
 
 type AzureTagFilterTag struct {
@@ -52,6 +57,10 @@ func NewAzureTagFilter(prefix string, tags []string) AzureTagFilter {
 	return ret
 }
 
+func (t *AzureTagFilter) enableTagInheritance() {
+	t.tagInheritance = true
+}
+
 func (t *AzureTagFilter) filterTags(tags map[string]*string, usePrometheusName bool) (filteredTags map[string]string) {
 	filteredTags = map[string]string{}
 
@@ -92,12 +101,38 @@ func (t *AzureTagFilter) filterTags(tags map[string]*string, usePrometheusName b
 }
 
 func (t *AzureTagFilter) appendPrometheusLabel(labels prometheus.Labels, tags map[string]*string) prometheus.Labels {
+	if t.tagInheritance {
+		tags = azureHierarchy.inheritTags(labels, tags)
+	}
+
 	for tagName, tagValue := range t.filterTags(tags, true) {
 		labels[tagName] = tagValue
 	}
+
 	return labels
 }
 
 func (t *AzureTagFilter) azureTagNameToPrometheusTagName(name string) string {
 	return azureTagNameToPrometheusNameRegExp.ReplaceAllLiteralString(name, "_")
 }
+
+// azureTagNumericValues returns the values of the tags (case insensitive) which can be parsed as float,
+// tags with missing or non numeric values are skipped
+func azureTagNumericValues(tagNames []string, tags map[string]*string) (values map[string]float64) {
+	values = map[string]float64{}
+
+	for _, filterTagName := range tagNames {
+		for tagName, tagValue := range tags {
+			if !strings.EqualFold(filterTagName, tagName) {
+				continue
+			}
+
+			// NaN and Inf (eg. tag value "inf") are no numeric values
+			if value, err := strconv.ParseFloat(strings.TrimSpace(to.String(tagValue)), 64); err == nil && !math.IsNaN(value) && !math.IsInf(value, 0) {
+				values[filterTagName] = value
+			}
+		}
+	}
+
+	return
+}
@@ -0,0 +1,220 @@
+package main
+
+import (
+	"fmt"
+	"github.com/Azure/azure-sdk-for-go/profiles/latest/resources/mgmt/subscriptions"
+	"github.com/Azure/go-autorest/autorest/to"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	BlackoutTargetAll        = "all"
+	BlackoutTargetCollection = "collection"
+	BlackoutTargetPortscan   = "portscan"
+
+	// windows are checked minute by minute, long windows should use a less frequent schedule instead
+	BlackoutWindowMaxDuration = 7 * 24 * time.Hour
+)
+
+// blackoutWindow pauses collection and/or port scanning (--blackout-window), the window starts at every
+// match of the cron schedule and lasts for the duration
+type blackoutWindow struct {
+	expression     string
+	schedule       cronSchedule
+	duration       time.Duration
+	subscriptionId string
+	collection     bool
+	portscan       bool
+}
+
+// cronSchedule is a standard cron schedule (minute hour day-of-month month day-of-week)
+type cronSchedule struct {
+	minute     map[int]bool
+	hour       map[int]bool
+	dayOfMonth map[int]bool
+	month      map[int]bool
+	dayOfWeek  map[int]bool
+
+	// day-of-month and day-of-week are combined with OR if both are restricted
+	dayOfMonthAny bool
+	dayOfWeekAny  bool
+}
+
+// parseBlackoutWindow parses a blackout window (format: cron|duration[|subscriptionID[|target]])
+func parseBlackoutWindow(window string) (blackoutWindow, error) {
+	ret := blackoutWindow{expression: window, collection: true, portscan: true}
+
+	parts := strings.Split(window, "|")
+	if len(parts) < 2 || len(parts) > 4 {
+		return ret, fmt.Errorf("has to be format \"cron|duration[|subscriptionID[|target]]\"")
+	}
+
+	schedule, err := parseCronSchedule(parts[0])
+	if err != nil {
+		return ret, err
+	}
+	ret.schedule = schedule
+
+	ret.duration, err = time.ParseDuration(strings.TrimSpace(parts[1]))
+	if err != nil {
+		return ret, err
+	}
+	if ret.duration <= 0 || ret.duration > BlackoutWindowMaxDuration {
+		return ret, fmt.Errorf("duration has to be between 0 and %v", BlackoutWindowMaxDuration)
+	}
+
+	if len(parts) >= 3 {
+		if subscriptionId := strings.ToLower(strings.TrimSpace(parts[2])); subscriptionId != "*" {
+			ret.subscriptionId = subscriptionId
+		}
+	}
+
+	if len(parts) >= 4 {
+		switch strings.ToLower(strings.TrimSpace(parts[3])) {
+		case BlackoutTargetAll, "":
+		case BlackoutTargetCollection:
+			ret.portscan = false
+		case BlackoutTargetPortscan:
+			ret.collection = false
+		default:
+			return ret, fmt.Errorf("invalid target \"%v\" (all, collection or portscan)", parts[3])
+		}
+	}
+
+	return ret, nil
+}
+
+// parseCronSchedule parses a cron schedule with 5 fields (supports *, lists, ranges and steps)
+func parseCronSchedule(expression string) (schedule cronSchedule, err error) {
+	fields := strings.Fields(expression)
+	if len(fields) != 5 {
+		return schedule, fmt.Errorf("cron schedule \"%v\" has to have 5 fields (minute hour day-of-month month day-of-week)", expression)
+	}
+
+	if schedule.minute, err = parseCronField(fields[0], 0, 59); err != nil {
+		return
+	}
+	if schedule.hour, err = parseCronField(fields[1], 0, 23); err != nil {
+		return
+	}
+	if schedule.dayOfMonth, err = parseCronField(fields[2], 1, 31); err != nil {
+		return
+	}
+	if schedule.month, err = parseCronField(fields[3], 1, 12); err != nil {
+		return
+	}
+	if schedule.dayOfWeek, err = parseCronField(fields[4], 0, 7); err != nil {
+		return
+	}
+
+	// sunday is 0 and 7
+	if schedule.dayOfWeek[7] {
+		schedule.dayOfWeek[0] = true
+	}
+
+	schedule.dayOfMonthAny = strings.HasPrefix(fields[2], "*")
+	schedule.dayOfWeekAny = strings.HasPrefix(fields[4], "*")
+
+	return
+}
+
+func parseCronField(field string, min, max int) (map[int]bool, error) {
+	values := map[int]bool{}
+
+	for _, item := range strings.Split(field, ",") {
+		step := 1
+		if parts := strings.SplitN(item, "/", 2); len(parts) == 2 {
+			var err error
+			if step, err = strconv.Atoi(parts[1]); err != nil || step <= 0 {
+				return nil, fmt.Errorf("invalid step in cron field \"%v\"", field)
+			}
+			item = parts[0]
+		}
+
+		first, last := min, max
+		if item != "*" {
+			parts := strings.SplitN(item, "-", 2)
+
+			var err error
+			if first, err = strconv.Atoi(parts[0]); err != nil {
+				return nil, fmt.Errorf("invalid value in cron field \"%v\"", field)
+			}
+
+			last = first
+			if len(parts) == 2 {
+				if last, err = strconv.Atoi(parts[1]); err != nil {
+					return nil, fmt.Errorf("invalid range in cron field \"%v\"", field)
+				}
+			} else if step > 1 {
+				// "5/15" means starting at 5
+				last = max
+			}
+		}
+
+		if first < min || last > max || first > last {
+			return nil, fmt.Errorf("cron field \"%v\" out of range (%v-%v)", field, min, max)
+		}
+
+		for i := first; i <= last; i += step {
+			values[i] = true
+		}
+	}
+
+	return values, nil
+}
+
+// matches checks if the schedule matches the time (minute precision)
+func (c cronSchedule) matches(t time.Time) bool {
+	if !c.minute[t.Minute()] || !c.hour[t.Hour()] || !c.month[int(t.Month())] {
+		return false
+	}
+
+	dayOfMonthMatch := c.dayOfMonth[t.Day()]
+	dayOfWeekMatch := c.dayOfWeek[int(t.Weekday())]
+
+	switch {
+	case c.dayOfMonthAny && c.dayOfWeekAny:
+		return true
+	case c.dayOfMonthAny:
+		return dayOfWeekMatch
+	case c.dayOfWeekAny:
+		return dayOfMonthMatch
+	default:
+		return dayOfMonthMatch || dayOfWeekMatch
+	}
+}
+
+// active checks if the window has been started within the last duration
+func (w blackoutWindow) active(t time.Time) bool {
+	windowStart := t.Truncate(time.Minute)
+	for start := windowStart; t.Sub(start) < w.duration; start = start.Add(-time.Minute) {
+		if w.schedule.matches(start) {
+			return true
+		}
+	}
+	return false
+}
+
+// blackoutActive checks if collection or port scanning (target) of the subscription is paused by a blackout window
+func blackoutActive(subscription subscriptions.Subscription, target string) (bool, string) {
+	now := time.Now()
+	subscriptionId := strings.ToLower(to.String(subscription.SubscriptionID))
+
+	for _, window := range blackoutWindows {
+		if window.subscriptionId != "" && window.subscriptionId != subscriptionId {
+			continue
+		}
+
+		if (target == BlackoutTargetCollection && !window.collection) || (target == BlackoutTargetPortscan && !window.portscan) {
+			continue
+		}
+
+		if window.active(now) {
+			return true, window.expression
+		}
+	}
+
+	return false, ""
+}
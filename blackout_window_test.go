@@ -0,0 +1,166 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseCronSchedule(t *testing.T) {
+	testCases := []struct {
+		expression string
+		wantErr    bool
+		matches    []time.Time
+		notMatches []time.Time
+	}{
+		{
+			expression: "0 22 * * 5",
+			matches:    []time.Time{time.Date(2021, 10, 1, 22, 0, 0, 0, time.UTC)},
+			notMatches: []time.Time{time.Date(2021, 10, 1, 22, 1, 0, 0, time.UTC), time.Date(2021, 10, 2, 22, 0, 0, 0, time.UTC)},
+		},
+		{
+			expression: "*/15 * * * *",
+			matches:    []time.Time{time.Date(2021, 10, 1, 3, 0, 0, 0, time.UTC), time.Date(2021, 10, 1, 3, 45, 0, 0, time.UTC)},
+			notMatches: []time.Time{time.Date(2021, 10, 1, 3, 5, 0, 0, time.UTC)},
+		},
+		{
+			// step with start value
+			expression: "5/15 * * * *",
+			matches:    []time.Time{time.Date(2021, 10, 1, 3, 5, 0, 0, time.UTC), time.Date(2021, 10, 1, 3, 50, 0, 0, time.UTC)},
+			notMatches: []time.Time{time.Date(2021, 10, 1, 3, 0, 0, 0, time.UTC)},
+		},
+		{
+			expression: "0 8-10,14 * * *",
+			matches:    []time.Time{time.Date(2021, 10, 1, 9, 0, 0, 0, time.UTC), time.Date(2021, 10, 1, 14, 0, 0, 0, time.UTC)},
+			notMatches: []time.Time{time.Date(2021, 10, 1, 11, 0, 0, 0, time.UTC)},
+		},
+		{
+			// sunday is 0 and 7
+			expression: "0 0 * * 7",
+			matches:    []time.Time{time.Date(2021, 10, 3, 0, 0, 0, 0, time.UTC)},
+			notMatches: []time.Time{time.Date(2021, 10, 4, 0, 0, 0, 0, time.UTC)},
+		},
+		{
+			// day-of-month and day-of-week are combined with OR
+			expression: "0 0 1 * 1",
+			matches:    []time.Time{time.Date(2021, 10, 4, 0, 0, 0, 0, time.UTC), time.Date(2021, 12, 1, 0, 0, 0, 0, time.UTC)},
+			notMatches: []time.Time{time.Date(2021, 10, 5, 0, 0, 0, 0, time.UTC)},
+		},
+		{
+			expression: "0 0 1 1 *",
+			matches:    []time.Time{time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)},
+			notMatches: []time.Time{time.Date(2022, 2, 1, 0, 0, 0, 0, time.UTC)},
+		},
+		{expression: "0 0 * *", wantErr: true},
+		{expression: "0 0 * * * *", wantErr: true},
+		{expression: "60 * * * *", wantErr: true},
+		{expression: "* 24 * * *", wantErr: true},
+		{expression: "* * 0 * *", wantErr: true},
+		{expression: "* * * 13 *", wantErr: true},
+		{expression: "* * * * 8", wantErr: true},
+		{expression: "10-5 * * * *", wantErr: true},
+		{expression: "*/0 * * * *", wantErr: true},
+		{expression: "a * * * *", wantErr: true},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.expression, func(t *testing.T) {
+			schedule, err := parseCronSchedule(testCase.expression)
+			if testCase.wantErr {
+				if err == nil {
+					t.Fatalf("expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			for _, matchTime := range testCase.matches {
+				if !schedule.matches(matchTime) {
+					t.Errorf("expected match at %v", matchTime)
+				}
+			}
+			for _, matchTime := range testCase.notMatches {
+				if schedule.matches(matchTime) {
+					t.Errorf("unexpected match at %v", matchTime)
+				}
+			}
+		})
+	}
+}
+
+func TestParseBlackoutWindow(t *testing.T) {
+	testCases := []struct {
+		window         string
+		wantErr        bool
+		subscriptionId string
+		collection     bool
+		portscan       bool
+	}{
+		{window: "0 22 * * 5|48h", collection: true, portscan: true},
+		{window: "0 22 * * 5|48h|*|all", collection: true, portscan: true},
+		{window: "0 22 * * 5|1h|*|collection", collection: true},
+		{window: "0 22 * * 5|1h|ABCDEF|portscan", subscriptionId: "abcdef", portscan: true},
+		{window: "0 22 * * 5", wantErr: true},
+		{window: "0 22 * * 5|1h|*|all|foo", wantErr: true},
+		{window: "0 22 * * 5|0s", wantErr: true},
+		{window: "0 22 * * 5|169h", wantErr: true},
+		{window: "0 22 * * 5|foo", wantErr: true},
+		{window: "0 22 * * 5|1h|*|foo", wantErr: true},
+		{window: "0 22 * *|1h", wantErr: true},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.window, func(t *testing.T) {
+			window, err := parseBlackoutWindow(testCase.window)
+			if testCase.wantErr {
+				if err == nil {
+					t.Fatalf("expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if window.subscriptionId != testCase.subscriptionId {
+				t.Errorf("subscriptionId: expected %q, got %q", testCase.subscriptionId, window.subscriptionId)
+			}
+			if window.collection != testCase.collection {
+				t.Errorf("collection: expected %v, got %v", testCase.collection, window.collection)
+			}
+			if window.portscan != testCase.portscan {
+				t.Errorf("portscan: expected %v, got %v", testCase.portscan, window.portscan)
+			}
+		})
+	}
+}
+
+func TestBlackoutWindowActive(t *testing.T) {
+	// every friday 22:00 for 48h
+	window, err := parseBlackoutWindow("0 22 * * 5|48h")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	testCases := []struct {
+		name   string
+		time   time.Time
+		active bool
+	}{
+		{name: "before start", time: time.Date(2021, 10, 1, 21, 59, 59, 0, time.UTC), active: false},
+		{name: "start", time: time.Date(2021, 10, 1, 22, 0, 0, 0, time.UTC), active: true},
+		{name: "within", time: time.Date(2021, 10, 2, 12, 30, 0, 0, time.UTC), active: true},
+		{name: "last minute", time: time.Date(2021, 10, 3, 21, 59, 0, 0, time.UTC), active: true},
+		{name: "end", time: time.Date(2021, 10, 3, 22, 0, 0, 0, time.UTC), active: false},
+		{name: "other day", time: time.Date(2021, 10, 5, 22, 0, 0, 0, time.UTC), active: false},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			if active := window.active(testCase.time); active != testCase.active {
+				t.Errorf("expected active %v at %v, got %v", testCase.active, testCase.time, active)
+			}
+		})
+	}
+}
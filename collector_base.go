@@ -1,11 +1,22 @@
 package main
 
 import (
+	"fmt"
 	"github.com/Azure/azure-sdk-for-go/profiles/latest/resources/mgmt/subscriptions"
+	"github.com/prometheus/client_golang/prometheus"
 	log "github.com/sirupsen/logrus"
+	"os"
+	"strings"
+	"sync"
 	"time"
 )
 
+var (
+	// conflicts of --metrics.const-label with the labels of the metrics (metricsConstLabelsCheck)
+	metricsConstLabelErrors     []error
+	metricsConstLabelErrorsLock sync.Mutex
+)
+
 type CollectorBase struct {
 	Name       string
 	scrapeTime *time.Duration
@@ -19,6 +30,9 @@ type CollectorBase struct {
 	logger *log.Entry
 
 	isHidden bool
+
+	// collection paused by the admin api
+	isDisabled bool
 }
 
 func (c *CollectorBase) Init() {
@@ -34,10 +48,45 @@ func (c *CollectorBase) GetScrapeTime() *time.Duration {
 	return c.scrapeTime
 }
 
+// GetAzureSubscriptions returns the subscriptions to collect (may change with --config-watch)
+func (c *CollectorBase) GetAzureSubscriptions() []subscriptions.Subscription {
+	dynamicConfigLock.RLock()
+	defer dynamicConfigLock.RUnlock()
+	return c.AzureSubscriptions
+}
+
 func (c *CollectorBase) SetIsHidden(v bool) {
 	c.isHidden = v
 }
 
+// SetIsDisabled pauses (or resumes) the scheduled collection runs
+func (c *CollectorBase) SetIsDisabled(v bool) {
+	dynamicConfigLock.Lock()
+	defer dynamicConfigLock.Unlock()
+	c.isDisabled = v
+}
+
+func (c *CollectorBase) IsDisabled() bool {
+	dynamicConfigLock.RLock()
+	defer dynamicConfigLock.RUnlock()
+	return c.isDisabled
+}
+
+// newGaugeVec creates a gauge vec with the configured const labels and help overrides of the collector
+func (c *CollectorBase) newGaugeVec(gaugeOpts prometheus.GaugeOpts, labelNames []string) *prometheus.GaugeVec {
+	return prometheus.NewGaugeVec(metricsGaugeOpts(c.Name, gaugeOpts, labelNames), labelNames)
+}
+
+// newCounterVec creates a counter vec with the configured const labels and help overrides of the collector
+func (c *CollectorBase) newCounterVec(counterOpts prometheus.CounterOpts, labelNames []string) *prometheus.CounterVec {
+	return prometheus.NewCounterVec(prometheus.CounterOpts(metricsGaugeOpts(c.Name, prometheus.GaugeOpts(counterOpts), labelNames)), labelNames)
+}
+
+// MustRegister registers the metrics in the registry of the collector, published after each collection run
+func (c *CollectorBase) MustRegister(collectors ...prometheus.Collector) {
+	metricsCollectorRegisterer(c.Name).MustRegister(collectors...)
+}
+
 func (c *CollectorBase) collectionStart() {
 	c.collectionStartTime = time.Now()
 
@@ -61,3 +110,80 @@ func (c *CollectorBase) sleepUntilNextCollection() {
 	}
 	time.Sleep(*c.GetScrapeTime())
 }
+
+// metricsNewGaugeVec creates a gauge vec of the exporter (without collector) with metricsGaugeOpts
+func metricsNewGaugeVec(gaugeOpts prometheus.GaugeOpts, labelNames []string) *prometheus.GaugeVec {
+	return prometheus.NewGaugeVec(metricsGaugeOpts("", gaugeOpts, labelNames), labelNames)
+}
+
+// metricsNewCounterVec creates a counter vec of the exporter (without collector) with metricsGaugeOpts
+func metricsNewCounterVec(counterOpts prometheus.CounterOpts, labelNames []string) *prometheus.CounterVec {
+	return prometheus.NewCounterVec(prometheus.CounterOpts(metricsGaugeOpts("", prometheus.GaugeOpts(counterOpts), labelNames)), labelNames)
+}
+
+// metricsGaugeOpts applies --metrics.help and the --metrics.const-label labels for all and the named collector,
+// const labels which are also labels of the metric are not applied and reported by metricsConstLabelsCheck
+func metricsGaugeOpts(collectorName string, gaugeOpts prometheus.GaugeOpts, labelNames []string) prometheus.GaugeOpts {
+	if help, exists := metricsHelpOverride[gaugeOpts.Name]; exists {
+		gaugeOpts.Help = help
+	}
+
+	constLabels := prometheus.Labels{}
+	for labelName, labelValue := range gaugeOpts.ConstLabels {
+		constLabels[labelName] = labelValue
+	}
+	configuredLabels := prometheus.Labels{}
+	for labelName, labelValue := range metricsConstLabels[""] {
+		configuredLabels[labelName] = labelValue
+	}
+	if collectorName != "" {
+		for labelName, labelValue := range metricsConstLabels[strings.ToLower(collectorName)] {
+			configuredLabels[labelName] = labelValue
+		}
+	}
+
+	for labelName, labelValue := range configuredLabels {
+		if metricsLabelNamesContain(labelNames, labelName) {
+			metricsConstLabelErrorsLock.Lock()
+			metricsConstLabelErrors = append(metricsConstLabelErrors, fmt.Errorf("label \"%v\" of \"--metrics.const-label\" is already a label of metric \"%v\", use another label name", labelName, gaugeOpts.Name))
+			metricsConstLabelErrorsLock.Unlock()
+			continue
+		}
+		constLabels[labelName] = labelValue
+	}
+
+	if len(constLabels) > 0 {
+		gaugeOpts.ConstLabels = constLabels
+	}
+
+	if collectorName != "" {
+		metricsCollectorRegister(collectorName, gaugeOpts.Name)
+	}
+
+	return gaugeOpts
+}
+
+func metricsLabelNamesContain(labelNames []string, labelName string) bool {
+	for _, name := range labelNames {
+		if name == labelName {
+			return true
+		}
+	}
+	return false
+}
+
+// metricsConstLabelsCheck stops the exporter if --metrics.const-label labels conflict with labels of the metrics
+// created so far (called after the setup of the metrics)
+func metricsConstLabelsCheck() {
+	metricsConstLabelErrorsLock.Lock()
+	defer metricsConstLabelErrorsLock.Unlock()
+
+	if len(metricsConstLabelErrors) == 0 {
+		return
+	}
+
+	for _, err := range metricsConstLabelErrors {
+		fmt.Fprintf(os.Stderr, "%v%v\n", "[ERROR] ", err.Error())
+	}
+	os.Exit(1)
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"strings"
+	"testing"
+)
+
+func TestMetricsGaugeOptsConstLabels(t *testing.T) {
+	savedConstLabels := metricsConstLabels
+	defer func() {
+		metricsConstLabels = savedConstLabels
+		metricsConstLabelErrors = nil
+	}()
+
+	metricsConstLabels = map[string]prometheus.Labels{
+		"":         {"team": "platform"},
+		"resource": {"location": "westeurope", "owner": "resources"},
+	}
+
+	testCases := []struct {
+		name          string
+		collectorName string
+		labelNames    []string
+		constLabels   prometheus.Labels
+		errors        []string
+	}{
+		{
+			name:        "exporter metric",
+			labelNames:  []string{"subscriptionID"},
+			constLabels: prometheus.Labels{"team": "platform"},
+		},
+		{
+			name:          "collector metric",
+			collectorName: "Resource",
+			labelNames:    []string{"resourceID"},
+			constLabels:   prometheus.Labels{"team": "platform", "location": "westeurope", "owner": "resources"},
+		},
+		{
+			name:          "collector label of the metric",
+			collectorName: "Resource",
+			labelNames:    []string{"resourceID", "location"},
+			constLabels:   prometheus.Labels{"team": "platform", "owner": "resources"},
+			errors:        []string{`label "location" of "--metrics.const-label" is already a label of metric "azurerm_test"`},
+		},
+		{
+			name:          "global label of the metric",
+			collectorName: "Storage",
+			labelNames:    []string{"team"},
+			constLabels:   prometheus.Labels{},
+			errors:        []string{`label "team" of "--metrics.const-label" is already a label of metric "azurerm_test"`},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			metricsConstLabelErrors = nil
+
+			gaugeOpts := metricsGaugeOpts(testCase.collectorName, prometheus.GaugeOpts{Name: "azurerm_test"}, testCase.labelNames)
+			if len(gaugeOpts.ConstLabels) != len(testCase.constLabels) {
+				t.Errorf("expected const labels %v, got %v", testCase.constLabels, gaugeOpts.ConstLabels)
+			}
+			for labelName, labelValue := range testCase.constLabels {
+				if value := gaugeOpts.ConstLabels[labelName]; value != labelValue {
+					t.Errorf("%v: expected %q, got %q", labelName, labelValue, value)
+				}
+			}
+
+			if len(metricsConstLabelErrors) != len(testCase.errors) {
+				t.Fatalf("expected errors %v, got %v", testCase.errors, metricsConstLabelErrors)
+			}
+			for i, expectedError := range testCase.errors {
+				if !strings.Contains(metricsConstLabelErrors[i].Error(), expectedError) {
+					t.Errorf("expected error containing %q, got %q", expectedError, metricsConstLabelErrors[i].Error())
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,279 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/Azure/azure-sdk-for-go/profiles/latest/resources/mgmt/subscriptions"
+	"github.com/Azure/go-autorest/autorest/to"
+	"github.com/Azure/go-autorest/tracing"
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// prefix of the errors of aborted and skipped list calls (recovered collector panics only have the message)
+	collectorBudgetErrorPrefix = "scrape budget"
+)
+
+var (
+	// max duration of list calls per resource type or provider namespace (lowercase, --scrape-budget)
+	collectorBudgets map[string]time.Duration
+
+	// budget overruns per subscription and resource type (lowercase "subscriptionId/resourceType")
+	collectorBudgetStates     = map[string]*collectorBudgetState{}
+	collectorBudgetStatesLock sync.Mutex
+)
+
+type collectorBudgetState struct {
+	// consecutive list calls exceeding the budget
+	overruns int
+
+	// list calls of the resource type are skipped until this time after --scrape-budget-overruns overruns
+	skippedUntil time.Time
+}
+
+// collectorBudgetTracer hooks into the http transport of all Azure clients (go-autorest tracing)
+// to apply --scrape-budget to each list call
+type collectorBudgetTracer struct{}
+
+func (t collectorBudgetTracer) NewTransport(base *http.Transport) http.RoundTripper {
+	return &collectorBudgetTransport{base: base}
+}
+
+func (t collectorBudgetTracer) StartSpan(ctx context.Context, name string) context.Context {
+	return ctx
+}
+
+func (t collectorBudgetTracer) EndSpan(ctx context.Context, httpStatusCode int, err error) {}
+
+// collectorBudgetTransport aborts list calls (GET requests) exceeding the budget of the resource type
+// and fails list calls of skipped resource types without sending them
+type collectorBudgetTransport struct {
+	base http.RoundTripper
+}
+
+// collectorBudgetBody releases the budget context when the response is read
+type collectorBudgetBody struct {
+	io.ReadCloser
+
+	ctx            context.Context
+	cancel         context.CancelFunc
+	subscriptionId string
+	resourceType   string
+	budget         time.Duration
+	once           sync.Once
+}
+
+// initCollectorBudgets registers the transport for --scrape-budget, must be called before the first Azure request
+func initCollectorBudgets() {
+	if len(collectorBudgets) > 0 {
+		tracing.Register(collectorBudgetTracer{})
+	}
+}
+
+func (t *collectorBudgetTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method != http.MethodGet {
+		return t.base.RoundTrip(req)
+	}
+
+	subscriptionId, resourceType, budget := collectorBudgetLookup(req.URL.Path)
+	if budget <= 0 {
+		return t.base.RoundTrip(req)
+	}
+
+	if skippedUntil, skipped := collectorBudgetSkipped(subscriptionId, resourceType); skipped {
+		return nil, fmt.Errorf("%v: list calls of %v skipped until %v, budget of %v exceeded repeatedly", collectorBudgetErrorPrefix, resourceType, skippedUntil.Format(time.RFC3339), budget)
+	}
+
+	ctx, cancel := context.WithTimeout(req.Context(), budget)
+	resp, err := t.base.RoundTrip(req.WithContext(ctx))
+	if err != nil {
+		exceeded := errors.Is(ctx.Err(), context.DeadlineExceeded)
+		cancel()
+		collectorBudgetResult(subscriptionId, resourceType, budget, exceeded)
+		if exceeded {
+			return nil, fmt.Errorf("%v: list call of %v aborted, budget of %v exceeded", collectorBudgetErrorPrefix, resourceType, budget)
+		}
+		return nil, err
+	}
+
+	resp.Body = &collectorBudgetBody{
+		ReadCloser:     resp.Body,
+		ctx:            ctx,
+		cancel:         cancel,
+		subscriptionId: subscriptionId,
+		resourceType:   resourceType,
+		budget:         budget,
+	}
+	return resp, nil
+}
+
+func (b *collectorBudgetBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if err != nil && err != io.EOF && errors.Is(b.ctx.Err(), context.DeadlineExceeded) {
+		return n, fmt.Errorf("%v: list call of %v aborted, budget of %v exceeded", collectorBudgetErrorPrefix, b.resourceType, b.budget)
+	}
+	return n, err
+}
+
+func (b *collectorBudgetBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(func() {
+		exceeded := errors.Is(b.ctx.Err(), context.DeadlineExceeded)
+		b.cancel()
+		collectorBudgetResult(b.subscriptionId, b.resourceType, b.budget, exceeded)
+	})
+	return err
+}
+
+// collectorBudgetLookup returns the subscription and the resource type (or provider namespace) with budget
+// of a request path (eg. /subscriptions/xxx/providers/Microsoft.Storage/storageAccounts)
+func collectorBudgetLookup(path string) (subscriptionId, resourceType string, budget time.Duration) {
+	path = strings.ToLower(path)
+
+	subscriptionId = extractSubscriptionIdFromAzureId(path)
+	if subscriptionId == "" {
+		return "", "", 0
+	}
+
+	// nested resources (eg. diagnostic settings of a resource) are listed with the last provider
+	providerIndex := strings.LastIndex(path, "/providers/")
+	if providerIndex < 0 {
+		return "", "", 0
+	}
+	parts := strings.Split(strings.TrimPrefix(path[providerIndex:], "/providers/"), "/")
+
+	if len(parts) >= 2 {
+		if budget, exists := collectorBudgets[parts[0]+"/"+parts[1]]; exists {
+			return subscriptionId, parts[0] + "/" + parts[1], budget
+		}
+	}
+
+	if budget, exists := collectorBudgets[parts[0]]; exists {
+		return subscriptionId, parts[0], budget
+	}
+
+	return "", "", 0
+}
+
+// collectorBudgetSkipped checks if list calls of the resource type are skipped because they repeatedly exceeded the budget
+func collectorBudgetSkipped(subscriptionId, resourceType string) (time.Time, bool) {
+	collectorBudgetStatesLock.Lock()
+	defer collectorBudgetStatesLock.Unlock()
+
+	state, exists := collectorBudgetStates[subscriptionId+"/"+resourceType]
+	if !exists || !time.Now().Before(state.skippedUntil) {
+		return time.Time{}, false
+	}
+	return state.skippedUntil, true
+}
+
+// collectorBudgetResult records if a list call of the resource type exceeded the budget, after --scrape-budget-overruns
+// consecutive overruns the list calls of the resource type are skipped for --scrape-budget-backoff
+func collectorBudgetResult(subscriptionId, resourceType string, budget time.Duration, exceeded bool) {
+	collectorBudgetStatesLock.Lock()
+	defer collectorBudgetStatesLock.Unlock()
+
+	stateKey := subscriptionId + "/" + resourceType
+	if _, exists := collectorBudgetStates[stateKey]; !exists {
+		collectorBudgetStates[stateKey] = &collectorBudgetState{}
+	}
+	state := collectorBudgetStates[stateKey]
+
+	logger := log.WithFields(log.Fields{
+		"azureSubscription": subscriptionId,
+		"resourceType":      resourceType,
+	})
+
+	skippedUntil := float64(0)
+	if exceeded {
+		state.overruns++
+		logger.Warnf("list call exceeded budget of %v (%v/%v)", budget, state.overruns, opts.Scrape.BudgetOverruns)
+
+		if state.overruns >= opts.Scrape.BudgetOverruns {
+			state.overruns = 0
+			state.skippedUntil = time.Now().Add(opts.Scrape.BudgetBackoff)
+			skippedUntil = float64(state.skippedUntil.Unix())
+			logger.Warnf("list calls skipped for %v, budget exceeded repeatedly", opts.Scrape.BudgetBackoff)
+		}
+	} else {
+		state.overruns = 0
+	}
+
+	prometheusMetricCollectorSkipped.With(prometheus.Labels{
+		"subscriptionID": subscriptionId,
+		"resourceType":   resourceType,
+	}).Set(skippedUntil)
+}
+
+// collectSubscriptionBudget collects the subscription with --scrape-budget, collections failed by aborted or skipped
+// list calls keep the metrics of the previous run. The callbacks are held back until the collection is finished,
+// streaming runs apply them directly and replace the partial metrics when the run is published
+func (m *CollectorGeneral) collectSubscriptionBudget(ctx context.Context, logger *log.Entry, callback chan<- func(), subscription subscriptions.Subscription) bool {
+	if m.streaming() {
+		if !m.collectWithinBudget(ctx, logger, callback, subscription) {
+			m.keepPreviousRun(callback, subscription)
+			return false
+		}
+		return true
+	}
+
+	var callbackList []func()
+	recordChannel := make(chan func())
+	recordFinished := make(chan struct{})
+
+	go func() {
+		defer close(recordFinished)
+		for recordCallback := range recordChannel {
+			callbackList = append(callbackList, recordCallback)
+		}
+	}()
+
+	success := false
+	func() {
+		defer close(recordChannel)
+		success = m.collectWithinBudget(ctx, logger, recordChannel, subscription)
+	}()
+	<-recordFinished
+
+	if !success {
+		m.keepPreviousRun(callback, subscription)
+		return false
+	}
+
+	for _, recordCallback := range callbackList {
+		callback <- recordCallback
+	}
+
+	m.collectionResultsLock.Lock()
+	defer m.collectionResultsLock.Unlock()
+	if m.blackoutCallbacks == nil {
+		m.blackoutCallbacks = map[string][]func(){}
+	}
+	m.blackoutCallbacks[to.String(subscription.SubscriptionID)] = callbackList
+
+	return true
+}
+
+// collectWithinBudget collects the subscription, list calls aborted or skipped by the budget fail the collection
+// (other failures are handled by collectSubscription)
+func (m *CollectorGeneral) collectWithinBudget(ctx context.Context, logger *log.Entry, callback chan<- func(), subscription subscriptions.Subscription) (success bool) {
+	defer func() {
+		if r := recover(); r != nil {
+			if !azureErrorIsBudget(r) {
+				panic(r)
+			}
+			logger.Warnf("collection failed, keeping metrics of the previous run: %v", r)
+			success = false
+		}
+	}()
+
+	m.Processor.Collect(ctx, logger, callback, subscription)
+	return true
+}
@@ -13,12 +13,24 @@ type CollectorCustom struct {
 func (m *CollectorCustom) Run(scrapeTime time.Duration) {
 	m.SetScrapeTime(scrapeTime)
 
+	// custom collectors (exporter, portscan) are not part of --profile-collection and --e2e
+	if opts.ProfileCollection || opts.E2e.Enabled {
+		return
+	}
+
 	m.Processor.Setup(m)
+	metricsConstLabelsCheck()
+	if generateDashboardsMode {
+		return
+	}
+
 	go func() {
 		for {
-			go func() {
-				m.Collect()
-			}()
+			if !m.IsDisabled() {
+				go func() {
+					m.Collect()
+				}()
+			}
 			m.sleepUntilNextCollection()
 		}
 	}()
@@ -28,5 +40,8 @@ func (m *CollectorCustom) Collect() {
 	ctx := context.Background()
 	m.collectionStart()
 	m.Processor.Collect(ctx, m.logger)
+	metricsApply(func() {
+		metricsPublish(m.Name, m.collectionStartTime)
+	})
 	m.collectionFinish()
 }
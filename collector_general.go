@@ -4,14 +4,51 @@ import (
 	"context"
 	"github.com/Azure/azure-sdk-for-go/profiles/latest/resources/mgmt/subscriptions"
 	"github.com/Azure/go-autorest/autorest/to"
+	"github.com/prometheus/client_golang/prometheus"
 	log "github.com/sirupsen/logrus"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
 type CollectorGeneral struct {
 	CollectorBase
 	Processor CollectorProcessorGeneralInterface
+
+	// collection results (success) of the last runs and collection time of the last run per subscription
+	collectionResults     map[string][]bool
+	collectionDurations   map[string]time.Duration
+	collectionResultsLock sync.Mutex
+
+	// callbacks of the last successful run per subscription, replayed while a blackout window is active
+	// or while other subscriptions are collected (admin api, event grid), so callbacks must only set
+	// metrics (counters are increased by the processor outside of the callbacks). Not recorded by streaming
+	// runs, they publish the metrics of the previous run of these subscriptions again (runKeptSubscriptions)
+	blackoutCallbacks map[string][]func()
+
+	// subscriptions (lowercase) of the running streaming run keeping the metrics of the previous run
+	runKeptSubscriptions map[string]bool
+
+	// subscriptions denied access (403) in the first run and the time of the next permission probe,
+	// skipped with --scrape-soft-fail until --scrape-soft-fail-retry
+	softFailDisabled map[string]time.Time
+
+	// collection state per subscription, dependent collectors wait for running and first collections
+	subscriptionState     map[string]*collectorSubscriptionState
+	subscriptionStateLock sync.Mutex
+
+	// runs are not overlapping, the metrics of the processor (working buffer of the collector registry) are
+	// reset, applied and published by one run at a time, scheduled runs are skipped while a run is running
+	runLock    sync.Mutex
+	runRunning int32
+}
+
+type collectorSubscriptionState struct {
+	// closed when the running (or the first) collection of the subscription is finished
+	finished  chan struct{}
+	running   bool
+	collected bool
 }
 
 func (m *CollectorGeneral) Run(scrapeTime time.Duration) {
@@ -22,17 +59,47 @@ func (m *CollectorGeneral) Run(scrapeTime time.Duration) {
 	}
 
 	m.Processor.Setup(m)
+	metricsConstLabelsCheck()
+	if opts.ProfileCollection || opts.E2e.Enabled || generateDashboardsMode {
+		// collection is triggered by collectionProfile() or e2eRun(), generate-dashboards only needs the metric metadata
+		return
+	}
+
 	go func() {
 		for {
-			go func() {
-				m.Collect()
-			}()
+			if !m.IsDisabled() {
+				if atomic.LoadInt32(&m.runRunning) == 1 {
+					m.logger.Warnf("previous collection still running, skipping scheduled collection")
+				} else {
+					go func() {
+						m.Collect()
+					}()
+				}
+			}
 			m.sleepUntilNextCollection()
 		}
 	}()
 }
 
 func (m *CollectorGeneral) Collect() {
+	m.collect("")
+}
+
+// CollectSubscription collects one subscription immediately, the metrics of the other subscriptions are kept
+func (m *CollectorGeneral) CollectSubscription(subscriptionId string) {
+	m.collect(subscriptionId)
+}
+
+// collect runs a collection of all subscriptions or only of one subscription (other subscriptions are replayed),
+// waits for the running run of the collector
+func (m *CollectorGeneral) collect(onlySubscriptionId string) {
+	m.runLock.Lock()
+	atomic.StoreInt32(&m.runRunning, 1)
+	defer func() {
+		atomic.StoreInt32(&m.runRunning, 0)
+		m.runLock.Unlock()
+	}()
+
 	var wg sync.WaitGroup
 	var wgCallback sync.WaitGroup
 
@@ -42,39 +109,409 @@ func (m *CollectorGeneral) Collect() {
 
 	m.collectionStart()
 
-	for _, subscription := range m.AzureSubscriptions {
+	m.collectionResultsLock.Lock()
+	m.runKeptSubscriptions = map[string]bool{}
+	m.collectionResultsLock.Unlock()
+
+	parallelSubscriptions, serialSubscriptions := m.collectionPlan()
+
+	for _, subscription := range parallelSubscriptions {
 		wg.Add(1)
 		go func(ctx context.Context, callback chan<- func(), subscription subscriptions.Subscription) {
 			defer wg.Done()
-			contextLogger := m.logger.WithFields(log.Fields{
-				"azureSubscription": to.String(subscription.SubscriptionID),
-			})
-			m.Processor.Collect(ctx, contextLogger, callbackChannel, subscription)
+			m.collectSubscription(ctx, callback, subscription, onlySubscriptionId)
 		}(ctx, callbackChannel, subscription)
 	}
 
+	// large subscriptions are collected one after another
+	if len(serialSubscriptions) > 0 {
+		wg.Add(1)
+		go func(ctx context.Context, callback chan<- func()) {
+			defer wg.Done()
+			for _, subscription := range serialSubscriptions {
+				m.collectSubscription(ctx, callback, subscription, onlySubscriptionId)
+			}
+		}(ctx, callbackChannel)
+	}
+
 	// collect metrics (callbacks) and proceses them
 	wgCallback.Add(1)
-	go func() {
-		defer wgCallback.Done()
-		var callbackList []func()
-		for callback := range callbackChannel {
-			callbackList = append(callbackList, callback)
-		}
+	if m.streaming() {
+		// streaming: apply metrics as soon as they are collected instead of keeping all metrics of the run in memory,
+		// scrapes see the run when it's published
+		metricsApply(func() {
+			m.Processor.Reset()
+		})
 
-		// reset metric values
-		m.Processor.Reset()
+		go func() {
+			defer wgCallback.Done()
+			for callback := range callbackChannel {
+				metricsApply(callback)
+			}
 
-		// process callbacks (set metrics)
-		for _, callback := range callbackList {
-			callback()
-		}
-	}()
+			m.collectionResultsLock.Lock()
+			keptSubscriptions := m.runKeptSubscriptions
+			m.collectionResultsLock.Unlock()
+
+			metricsApply(func() {
+				metricsPublishKeeping(m.Name, m.collectionStartTime, keptSubscriptions)
+			})
+		}()
+	} else {
+		go func() {
+			defer wgCallback.Done()
+			var callbackList []func()
+			for callback := range callbackChannel {
+				callbackList = append(callbackList, callback)
+			}
+
+			// apply metrics of this run at once and publish them, scrapes see either the previous or the new run
+			metricsApply(func() {
+				// reset metric values
+				m.Processor.Reset()
+
+				// process callbacks (set metrics)
+				for _, callback := range callbackList {
+					callback()
+				}
+
+				metricsPublish(m.Name, m.collectionStartTime)
+			})
+		}()
+	}
 
 	// wait for all funcs
 	wg.Wait()
 	close(callbackChannel)
 	wgCallback.Wait()
 
+	snapshotWrite(m.Name, m.collectionStartTime)
+
 	m.collectionFinish()
 }
+
+func (m *CollectorGeneral) collectSubscription(ctx context.Context, callback chan<- func(), subscription subscriptions.Subscription, onlySubscriptionId string) {
+	startTime := time.Now()
+	contextLogger := m.logger.WithFields(log.Fields{
+		"azureSubscription": to.String(subscription.SubscriptionID),
+	})
+
+	m.subscriptionCollectionStart(subscription)
+	defer m.subscriptionCollectionFinish(subscription)
+
+	if m.isSoftFailDisabled(subscription) {
+		return
+	}
+
+	// only one subscription is collected, keep the metrics of the last run
+	if onlySubscriptionId != "" && !strings.EqualFold(to.String(subscription.SubscriptionID), onlySubscriptionId) {
+		m.keepPreviousRun(callback, subscription)
+		return
+	}
+
+	// collection paused, keep the metrics of the last run
+	if isBlackout, window := blackoutActive(subscription, BlackoutTargetCollection); isBlackout {
+		contextLogger.Debugf("collection paused by blackout window \"%v\"", window)
+		m.keepPreviousRun(callback, subscription)
+		return
+	}
+
+	// failed subscriptions (eg. missing permissions) must not stop the collection of other subscriptions
+	defer func() {
+		m.collectionDurationSet(subscription, time.Since(startTime))
+
+		if r := recover(); r != nil {
+			permissionMissing := azureErrorIsForbidden(r)
+			if permissionMissing {
+				contextLogger.Warnf("metrics collection skipped, permission missing: %v", r)
+			} else {
+				contextLogger.Errorf("metrics collection failed: %v", r)
+			}
+			m.collectionResult(subscription, false, permissionMissing)
+		}
+	}()
+
+	m.waitForDependencies(contextLogger, subscription)
+
+	if len(collectorBudgets) > 0 {
+		if !m.collectSubscriptionBudget(ctx, contextLogger, callback, subscription) {
+			m.collectionResult(subscription, false, false)
+			return
+		}
+	} else if !m.streaming() && (len(blackoutWindows) > 0 || opts.Admin.Token != "" || opts.EventGrid.Token != "") {
+		m.collectSubscriptionRecorded(ctx, contextLogger, callback, subscription)
+	} else {
+		m.Processor.Collect(ctx, contextLogger, callback, subscription)
+	}
+	m.collectionResult(subscription, true, false)
+}
+
+// streaming checks if the metrics are applied while collecting (--scrape-streaming or the processor streams)
+func (m *CollectorGeneral) streaming() bool {
+	if processor, ok := m.Processor.(CollectorProcessorStreamingInterface); ok && processor.Streaming() {
+		return true
+	}
+	return opts.Scrape.Streaming
+}
+
+// collectSubscriptionRecorded collects the subscription and remembers the callbacks for blackout windows
+func (m *CollectorGeneral) collectSubscriptionRecorded(ctx context.Context, logger *log.Entry, callback chan<- func(), subscription subscriptions.Subscription) {
+	var callbackList []func()
+	recordChannel := make(chan func())
+	recordFinished := make(chan struct{})
+
+	go func() {
+		defer close(recordFinished)
+		for recordCallback := range recordChannel {
+			callbackList = append(callbackList, recordCallback)
+			callback <- recordCallback
+		}
+	}()
+
+	success := false
+	defer func() {
+		close(recordChannel)
+		<-recordFinished
+
+		if success {
+			m.collectionResultsLock.Lock()
+			defer m.collectionResultsLock.Unlock()
+
+			if m.blackoutCallbacks == nil {
+				m.blackoutCallbacks = map[string][]func(){}
+			}
+			m.blackoutCallbacks[to.String(subscription.SubscriptionID)] = callbackList
+		}
+	}()
+
+	m.Processor.Collect(ctx, logger, recordChannel, subscription)
+	success = true
+}
+
+// waitForDependencies waits until the collectors of the processor dependencies finished the collection of the
+// subscription, disabled dependencies and dependencies whose last collection of the subscription failed are skipped
+// (the processor lists the resources itself), all dependencies share one deadline
+func (m *CollectorGeneral) waitForDependencies(logger *log.Entry, subscription subscriptions.Subscription) {
+	processor, ok := m.Processor.(CollectorProcessorDependencyInterface)
+	if !ok || opts.ProfileCollection {
+		return
+	}
+
+	<-collectorsInitialized
+
+	deadline := time.Now().Add(collectorDependencyTimeout)
+	for _, dependencyName := range processor.Dependencies() {
+		dependency, exists := collectorGeneralList[dependencyName]
+		if !exists {
+			continue
+		}
+
+		if dependency.lastCollectionFailed(subscription) {
+			logger.Debugf("dependency %v failed the last collection, collecting without shared data", dependencyName)
+			continue
+		}
+
+		if !dependency.waitForSubscription(subscription, time.Until(deadline)) {
+			logger.Warnf("dependencies didn't finish the collection within %v, collecting without shared data of %v", collectorDependencyTimeout, dependencyName)
+		}
+	}
+}
+
+// HasDependents checks if enabled collectors depend on this collector (and use the shared lists of the collector store)
+func (m *CollectorGeneral) HasDependents() bool {
+	<-collectorsInitialized
+
+	for _, collector := range collectorGeneralList {
+		if processor, ok := collector.Processor.(CollectorProcessorDependencyInterface); ok {
+			for _, dependencyName := range processor.Dependencies() {
+				if strings.EqualFold(dependencyName, m.Name) {
+					return true
+				}
+			}
+		}
+	}
+
+	return false
+}
+
+func (m *CollectorGeneral) subscriptionStateGet(subscription subscriptions.Subscription) *collectorSubscriptionState {
+	subscriptionId := strings.ToLower(to.String(subscription.SubscriptionID))
+
+	if m.subscriptionState == nil {
+		m.subscriptionState = map[string]*collectorSubscriptionState{}
+	}
+
+	if _, exists := m.subscriptionState[subscriptionId]; !exists {
+		m.subscriptionState[subscriptionId] = &collectorSubscriptionState{
+			finished: make(chan struct{}),
+		}
+	}
+
+	return m.subscriptionState[subscriptionId]
+}
+
+func (m *CollectorGeneral) subscriptionCollectionStart(subscription subscriptions.Subscription) {
+	m.subscriptionStateLock.Lock()
+	defer m.subscriptionStateLock.Unlock()
+
+	state := m.subscriptionStateGet(subscription)
+	if state.running {
+		return
+	}
+
+	// previous collection finished, dependent collectors wait for this collection
+	if state.collected {
+		state.finished = make(chan struct{})
+	}
+	state.running = true
+}
+
+func (m *CollectorGeneral) subscriptionCollectionFinish(subscription subscriptions.Subscription) {
+	m.subscriptionStateLock.Lock()
+	defer m.subscriptionStateLock.Unlock()
+
+	state := m.subscriptionStateGet(subscription)
+	if !state.running {
+		return
+	}
+
+	state.running = false
+	state.collected = true
+	close(state.finished)
+}
+
+// waitForSubscription waits until the running (or the first) collection of the subscription is finished
+func (m *CollectorGeneral) waitForSubscription(subscription subscriptions.Subscription, timeout time.Duration) bool {
+	m.subscriptionStateLock.Lock()
+	state := m.subscriptionStateGet(subscription)
+	if state.collected && !state.running {
+		m.subscriptionStateLock.Unlock()
+		return true
+	}
+	finished := state.finished
+	m.subscriptionStateLock.Unlock()
+
+	select {
+	case <-finished:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
+
+// lastCollectionFailed checks if the last collection of the subscription failed (no shared lists are expected)
+func (m *CollectorGeneral) lastCollectionFailed(subscription subscriptions.Subscription) bool {
+	m.collectionResultsLock.Lock()
+	defer m.collectionResultsLock.Unlock()
+
+	results := m.collectionResults[to.String(subscription.SubscriptionID)]
+	return len(results) > 0 && !results[len(results)-1]
+}
+
+// keepPreviousRun keeps the metrics of the last run of the subscription, streaming runs publish the metrics of the
+// previous run again (no callbacks are recorded), other runs replay the recorded callbacks
+func (m *CollectorGeneral) keepPreviousRun(callback chan<- func(), subscription subscriptions.Subscription) {
+	if m.streaming() {
+		m.collectionResultsLock.Lock()
+		defer m.collectionResultsLock.Unlock()
+		m.runKeptSubscriptions[strings.ToLower(to.String(subscription.SubscriptionID))] = true
+		return
+	}
+
+	for _, blackoutCallback := range m.blackoutCallbacksGet(subscription) {
+		callback <- blackoutCallback
+	}
+}
+
+func (m *CollectorGeneral) blackoutCallbacksGet(subscription subscriptions.Subscription) []func() {
+	m.collectionResultsLock.Lock()
+	defer m.collectionResultsLock.Unlock()
+	return m.blackoutCallbacks[to.String(subscription.SubscriptionID)]
+}
+
+// collectionResult records the result of a subscription collection and updates the success ratio of the last runs
+// and the permission gap (403) of the subscription
+func (m *CollectorGeneral) collectionResult(subscription subscriptions.Subscription, success, permissionMissing bool) {
+	subscriptionId := to.String(subscription.SubscriptionID)
+
+	m.collectionResultsLock.Lock()
+	defer m.collectionResultsLock.Unlock()
+
+	if m.collectionResults == nil {
+		m.collectionResults = map[string][]bool{}
+	}
+
+	// the first run probes the permissions, subscriptions without access are skipped until the next probe
+	_, softFailDisabled := m.softFailDisabled[subscriptionId]
+	if opts.Scrape.SoftFail && permissionMissing && (len(m.collectionResults[subscriptionId]) == 0 || softFailDisabled) {
+		if m.softFailDisabled == nil {
+			m.softFailDisabled = map[string]time.Time{}
+		}
+		m.softFailDisabled[subscriptionId] = time.Now().Add(opts.Scrape.SoftFailRetry)
+
+		m.logger.WithField("azureSubscription", subscriptionId).Infof("collector disabled for subscription, access denied (retry in %v)", opts.Scrape.SoftFailRetry)
+		prometheusMetricCollectorDisabled.With(prometheus.Labels{
+			"collector":      m.Name,
+			"subscriptionID": subscriptionId,
+		}).Set(1)
+	} else if softFailDisabled && !permissionMissing {
+		m.softFailEnable(subscriptionId)
+	}
+
+	results := append(m.collectionResults[subscriptionId], success)
+	if opts.Metrics.SuccessRatioRuns > 0 && len(results) > opts.Metrics.SuccessRatioRuns {
+		results = results[len(results)-opts.Metrics.SuccessRatioRuns:]
+	}
+	m.collectionResults[subscriptionId] = results
+
+	successCount := 0
+	for _, result := range results {
+		if result {
+			successCount++
+		}
+	}
+
+	prometheusMetricCollectorSuccessRatio.With(prometheus.Labels{
+		"collector":      m.Name,
+		"subscriptionID": subscriptionId,
+	}).Set(float64(successCount) / float64(len(results)))
+
+	permissionMissingValue := float64(0)
+	if permissionMissing {
+		permissionMissingValue = 1
+	}
+	prometheusMetricPermissionMissing.With(prometheus.Labels{
+		"collector":      m.Name,
+		"subscriptionID": subscriptionId,
+	}).Set(permissionMissingValue)
+}
+
+// isSoftFailDisabled checks if the subscription is skipped by --scrape-soft-fail (until the next permission probe)
+func (m *CollectorGeneral) isSoftFailDisabled(subscription subscriptions.Subscription) bool {
+	m.collectionResultsLock.Lock()
+	defer m.collectionResultsLock.Unlock()
+
+	retryTime, exists := m.softFailDisabled[to.String(subscription.SubscriptionID)]
+	return exists && time.Now().Before(retryTime)
+}
+
+// SoftFailReset collects the subscriptions disabled by --scrape-soft-fail again with the next run (admin api)
+func (m *CollectorGeneral) SoftFailReset() {
+	m.collectionResultsLock.Lock()
+	defer m.collectionResultsLock.Unlock()
+
+	for subscriptionId := range m.softFailDisabled {
+		m.softFailEnable(subscriptionId)
+	}
+}
+
+// softFailEnable collects the subscription disabled by --scrape-soft-fail again (must hold collectionResultsLock)
+func (m *CollectorGeneral) softFailEnable(subscriptionId string) {
+	delete(m.softFailDisabled, subscriptionId)
+
+	m.logger.WithField("azureSubscription", subscriptionId).Infof("collector enabled for subscription")
+	prometheusMetricCollectorDisabled.With(prometheus.Labels{
+		"collector":      m.Name,
+		"subscriptionID": subscriptionId,
+	}).Set(0)
+}
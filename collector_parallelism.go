@@ -0,0 +1,107 @@
+package main
+
+import (
+	"github.com/Azure/azure-sdk-for-go/profiles/latest/resources/mgmt/subscriptions"
+	"github.com/Azure/go-autorest/autorest/to"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	// remaining subscription read requests (x-ms-ratelimit-remaining-subscription-reads) of the last response
+	azureRateLimitRead     = map[string]int64{}
+	azureRateLimitReadLock sync.RWMutex
+
+	// resource count of the last run of the Resource collector per subscription
+	azureResourceCount     = map[string]int64{}
+	azureResourceCountLock sync.RWMutex
+)
+
+func azureRateLimitReadSet(subscriptionId string, remaining int64) {
+	azureRateLimitReadLock.Lock()
+	defer azureRateLimitReadLock.Unlock()
+	azureRateLimitRead[strings.ToLower(subscriptionId)] = remaining
+}
+
+func azureRateLimitReadGet(subscriptionId string) (remaining int64, exists bool) {
+	azureRateLimitReadLock.RLock()
+	defer azureRateLimitReadLock.RUnlock()
+	remaining, exists = azureRateLimitRead[strings.ToLower(subscriptionId)]
+	return
+}
+
+func azureResourceCountSet(subscriptionId string, count int64) {
+	azureResourceCountLock.Lock()
+	defer azureResourceCountLock.Unlock()
+	azureResourceCount[strings.ToLower(subscriptionId)] = count
+}
+
+func azureResourceCountGet(subscriptionId string) (count int64, exists bool) {
+	azureResourceCountLock.RLock()
+	defer azureResourceCountLock.RUnlock()
+	count, exists = azureResourceCount[strings.ToLower(subscriptionId)]
+	return
+}
+
+// collectionPlan splits the subscriptions into subscriptions collected in parallel and
+// subscriptions collected serially (large subscriptions based on the resource count observed by the
+// Resource collector or, without resource count, the previous collection time and subscriptions
+// running out of read requests)
+func (m *CollectorGeneral) collectionPlan() (parallel, serial []subscriptions.Subscription) {
+	if !opts.Scrape.ParallelAuto {
+		return m.GetAzureSubscriptions(), nil
+	}
+
+	m.collectionResultsLock.Lock()
+	defer m.collectionResultsLock.Unlock()
+
+	// size of the subscription for the order of the serial run
+	size := func(subscriptionId string) float64 {
+		if count, exists := azureResourceCountGet(subscriptionId); exists {
+			return float64(count)
+		}
+		return m.collectionDurations[subscriptionId].Seconds()
+	}
+
+	for _, subscription := range m.GetAzureSubscriptions() {
+		subscriptionId := to.String(subscription.SubscriptionID)
+
+		isLarge := m.collectionDurations[subscriptionId] > opts.Scrape.ParallelDuration
+		if count, exists := azureResourceCountGet(subscriptionId); exists {
+			isLarge = count > opts.Scrape.ParallelResources
+		}
+		if remaining, exists := azureRateLimitReadGet(subscriptionId); exists && remaining < opts.Scrape.ParallelRateLimit {
+			isLarge = true
+		}
+
+		if isLarge {
+			serial = append(serial, subscription)
+		} else {
+			parallel = append(parallel, subscription)
+		}
+	}
+
+	// start with the largest subscription so the serial run doesn't end with a long tail
+	sort.SliceStable(serial, func(i, j int) bool {
+		return size(to.String(serial[i].SubscriptionID)) > size(to.String(serial[j].SubscriptionID))
+	})
+
+	if len(serial) > 0 {
+		m.logger.Debugf("collecting %v subscriptions in parallel and %v serially", len(parallel), len(serial))
+	}
+
+	return
+}
+
+// collectionDurationSet remembers the collection time of a subscription for the next collection plan
+func (m *CollectorGeneral) collectionDurationSet(subscription subscriptions.Subscription, duration time.Duration) {
+	m.collectionResultsLock.Lock()
+	defer m.collectionResultsLock.Unlock()
+
+	if m.collectionDurations == nil {
+		m.collectionDurations = map[string]time.Duration{}
+	}
+	m.collectionDurations[to.String(subscription.SubscriptionID)] = duration
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"github.com/prometheus/client_golang/prometheus"
 	log "github.com/sirupsen/logrus"
 )
 
@@ -33,3 +34,11 @@ func NewCollectorCustom(name string, processor CollectorProcessorCustomInterface
 func (c *CollectorProcessorCustom) logger() *log.Entry {
 	return c.CollectorReference.logger
 }
+
+func (c *CollectorProcessorCustom) newGaugeVec(gaugeOpts prometheus.GaugeOpts, labelNames []string) *prometheus.GaugeVec {
+	return c.CollectorReference.newGaugeVec(gaugeOpts, labelNames)
+}
+
+func (c *CollectorProcessorCustom) MustRegister(collectors ...prometheus.Collector) {
+	c.CollectorReference.MustRegister(collectors...)
+}
@@ -3,6 +3,7 @@ package main
 import (
 	"context"
 	"github.com/Azure/azure-sdk-for-go/profiles/latest/resources/mgmt/subscriptions"
+	"github.com/prometheus/client_golang/prometheus"
 	log "github.com/sirupsen/logrus"
 )
 
@@ -12,6 +13,18 @@ type CollectorProcessorGeneralInterface interface {
 	Collect(ctx context.Context, contextLogger *log.Entry, callback chan<- func(), subscription subscriptions.Subscription)
 }
 
+// CollectorProcessorStreamingInterface is implemented by processors with large results (eg. all resources),
+// the metrics are applied while collecting (as with --scrape-streaming) instead of keeping the run in memory
+type CollectorProcessorStreamingInterface interface {
+	Streaming() bool
+}
+
+// CollectorProcessorCacheInterface is implemented by processors caching Azure lists across runs,
+// FlushCache drops the caches (admin cache flush) and the next run fetches the lists again
+type CollectorProcessorCacheInterface interface {
+	FlushCache()
+}
+
 type CollectorProcessorGeneral struct {
 	CollectorProcessorGeneralInterface
 	CollectorReference *CollectorGeneral
@@ -35,3 +48,21 @@ func NewCollectorGeneral(name string, processor CollectorProcessorGeneralInterfa
 func (c *CollectorProcessorGeneral) logger() *log.Entry {
 	return c.CollectorReference.logger
 }
+
+func (c *CollectorProcessorGeneral) newGaugeVec(gaugeOpts prometheus.GaugeOpts, labelNames []string) *prometheus.GaugeVec {
+	return c.CollectorReference.newGaugeVec(gaugeOpts, labelNames)
+}
+
+func (c *CollectorProcessorGeneral) newCounterVec(counterOpts prometheus.CounterOpts, labelNames []string) *prometheus.CounterVec {
+	return c.CollectorReference.newCounterVec(counterOpts, labelNames)
+}
+
+func (c *CollectorProcessorGeneral) MustRegister(collectors ...prometheus.Collector) {
+	c.CollectorReference.MustRegister(collectors...)
+}
+
+// storeSet shares the list of the subscription with dependent collectors (collector store),
+// the entry expires if not refreshed within two runs
+func (c *CollectorProcessorGeneral) storeSet(key string, subscription subscriptions.Subscription, value interface{}) {
+	collectorStore.Set(key, *subscription.SubscriptionID, value, 2*(*c.CollectorReference.GetScrapeTime()))
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/Azure/go-autorest/autorest/to"
+	log "github.com/sirupsen/logrus"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"sync/atomic"
+	"text/tabwriter"
+	"time"
+)
+
+var (
+	// api calls and bytes transferred (--profile-collection)
+	collectionProfileRequests int64
+	collectionProfileBytes    int64
+)
+
+type collectionProfileBody struct {
+	io.ReadCloser
+}
+
+func (b collectionProfileBody) Read(p []byte) (n int, err error) {
+	n, err = b.ReadCloser.Read(p)
+	atomic.AddInt64(&collectionProfileBytes, int64(n))
+	return
+}
+
+// collectionProfileResponse counts the api call and the bytes of the response body (counted while the body is read)
+func collectionProfileResponse(r *http.Response) {
+	atomic.AddInt64(&collectionProfileRequests, 1)
+	if r.Body != nil {
+		r.Body = collectionProfileBody{r.Body}
+	}
+}
+
+// collectionProfile runs one collection of all general collectors, one subscription after another
+// so api calls can be attributed to the collector and subscription, and prints the results
+func collectionProfile() {
+	ctx := context.Background()
+
+	collectorNameList := []string{}
+	for collectorName := range collectorGeneralList {
+		collectorNameList = append(collectorNameList, collectorName)
+	}
+	sort.Strings(collectorNameList)
+
+	writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(writer, "COLLECTOR\tSUBSCRIPTION\tAPI CALLS\tBYTES\tDURATION")
+
+	for _, collectorName := range collectorNameList {
+		collector := collectorGeneralList[collectorName]
+
+		collectorRequests, collectorBytes, collectorDuration := int64(0), int64(0), time.Duration(0)
+		for _, subscription := range collector.GetAzureSubscriptions() {
+			atomic.StoreInt64(&collectionProfileRequests, 0)
+			atomic.StoreInt64(&collectionProfileBytes, 0)
+
+			// metrics are not needed, callbacks are dropped
+			callbackChannel := make(chan func())
+			go func() {
+				for range callbackChannel {
+				}
+			}()
+
+			startTime := time.Now()
+			collector.collectSubscription(ctx, callbackChannel, subscription, "")
+			duration := time.Since(startTime)
+			close(callbackChannel)
+
+			requests := atomic.LoadInt64(&collectionProfileRequests)
+			bytes := atomic.LoadInt64(&collectionProfileBytes)
+			collectorRequests += requests
+			collectorBytes += bytes
+			collectorDuration += duration
+
+			fmt.Fprintf(writer, "%v\t%v\t%v\t%v\t%v\n", collectorName, to.String(subscription.SubscriptionID), requests, bytes, duration.Round(time.Millisecond))
+		}
+
+		fmt.Fprintf(writer, "%v\t%v\t%v\t%v\t%v\n", collectorName, "*total*", collectorRequests, collectorBytes, collectorDuration.Round(time.Millisecond))
+	}
+
+	if err := writer.Flush(); err != nil {
+		log.Error(err)
+	}
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	collectorStoreNetworkInterfaces = "network/interfaces"
+	collectorStoreSecurityGroups    = "network/securitygroups"
+	collectorStoreResources         = "resources/list"
+	collectorStoreServicePrincipals = "authorization/serviceprincipals"
+
+	// wait time for all dependencies (collectors sharing lists by the collector store) of a subscription before a
+	// dependent collector collects the subscription without the shared lists
+	collectorDependencyTimeout = 5 * time.Minute
+)
+
+var (
+	collectorStore = CollectorStore{
+		entries: map[string]collectorStoreEntry{},
+	}
+
+	// closed when all collectors are created (collectorGeneralList is complete)
+	collectorsInitialized = make(chan struct{})
+)
+
+// CollectorProcessorDependencyInterface is implemented by processors which use lists shared by other collectors,
+// the collection of a subscription waits until the dependencies (collector names) collected the subscription
+type CollectorProcessorDependencyInterface interface {
+	Dependencies() []string
+}
+
+// CollectorStore shares lists (eg. network interfaces) fetched by one collector with dependent collectors,
+// entries are kept per subscription and expire if not refreshed by the next runs of the collector
+type CollectorStore struct {
+	lock    sync.RWMutex
+	entries map[string]collectorStoreEntry
+}
+
+type collectorStoreEntry struct {
+	value  interface{}
+	expiry time.Time
+}
+
+func (s *CollectorStore) Set(key, subscriptionId string, value interface{}, ttl time.Duration) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.entries[collectorStoreKey(key, subscriptionId)] = collectorStoreEntry{
+		value:  value,
+		expiry: time.Now().Add(ttl),
+	}
+}
+
+func (s *CollectorStore) Get(key, subscriptionId string) (interface{}, bool) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	entry, exists := s.entries[collectorStoreKey(key, subscriptionId)]
+	if !exists || time.Now().After(entry.expiry) {
+		return nil, false
+	}
+
+	return entry.value, true
+}
+
+// Flush drops all entries, dependent collectors use their own lists until the next runs of the collectors set them again
+func (s *CollectorStore) Flush() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.entries = map[string]collectorStoreEntry{}
+}
+
+func collectorStoreKey(key, subscriptionId string) string {
+	return key + ":" + strings.ToLower(subscriptionId)
+}
@@ -1,6 +1,8 @@
 package config
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	log "github.com/sirupsen/logrus"
 	"time"
@@ -23,27 +25,83 @@ type (
 			Location          []string `long:"azure-location"                 env:"AZURE_LOCATION"            env-delim:" "  description:"Azure locations"                                  default:"westeurope" default:"northeurope"` //nolint:staticcheck
 			ResourceGroupTags []string `long:"azure-resourcegroup-tag"        env:"AZURE_RESOURCEGROUP_TAG"   env-delim:" "  description:"Azure ResourceGroup tags"                         default:"owner"`
 			ResourceTags      []string `long:"azure-resource-tag"             env:"AZURE_RESOURCE_TAG"        env-delim:" "  description:"Azure Resource tags"                              default:"owner"`
+			SubscriptionTags  []string `long:"azure-subscription-tag"         env:"AZURE_SUBSCRIPTION_TAG"    env-delim:" "  description:"Azure Subscription tags"`
+			TagInheritance    bool     `long:"azure-tag-inheritance"          env:"AZURE_TAG_INHERITANCE"                    description:"Inherit missing resource tags from ResourceGroup and Subscription tags"`
+
+			// numeric tag values
+			ResourceGroupTagValues []string `long:"azure-resourcegroup-tag-value"  env:"AZURE_RESOURCEGROUP_TAG_VALUE"  env-delim:" "  description:"Azure ResourceGroup tags exported as numeric values (azurerm_resourcegroup_tag_value)"`
+			ResourceTagValues      []string `long:"azure-resource-tag-value"       env:"AZURE_RESOURCE_TAG_VALUE"       env-delim:" "  description:"Azure Resource tags exported as numeric values (azurerm_resource_tag_value)"`
 		}
 
 		// scrape times
 		Scrape struct {
-			Time               time.Duration  `long:"scrape-time"                    env:"SCRAPE_TIME"                    description:"Default scrape time (time.duration)"                      default:"5m"`
-			TimeRateLimitRead  *time.Duration `long:"scrape-ratelimit-read"          env:"SCRAPE_RATELIMIT_READ"          description:"Scrape time for ratelimit read metrics (time.duration)"   default:"2m"`
-			TimeRateLimitWrite *time.Duration `long:"scrape-ratelimit-write"         env:"SCRAPE_RATELIMIT_WRITE"         description:"Scrape time for ratelimit write metrics (time.duration)"  default:"5m"`
-			TimeExporter       *time.Duration `long:"scrape-time-exporter"           env:"SCRAPE_TIME_EXPORTER"           description:"Scrape time for exporter metrics (time.duration)"         default:"10s"`
-			TimeGeneral        *time.Duration `long:"scrape-time-general"            env:"SCRAPE_TIME_GENERAL"            description:"Scrape time for general metrics (time.duration)"`
-			TimeResource       *time.Duration `long:"scrape-time-resource"           env:"SCRAPE_TIME_RESOURCE"           description:"Scrape time for resource metrics  (time.duration)"`
-			TimeQuota          *time.Duration `long:"scrape-time-quota"              env:"SCRAPE_TIME_QUOTA"              description:"Scrape time for quota metrics  (time.duration)"`
-			TimeSecurity       *time.Duration `long:"scrape-time-security"           env:"SCRAPE_TIME_SECURITY"           description:"Scrape time for Security metrics (time.duration)"`
-			TimeResourceHealth *time.Duration `long:"scrape-time-resourcehealth"     env:"SCRAPE_TIME_RESOURCEHEALTH"     description:"Scrape time for ResourceHealth metrics (time.duration)"`
-			TimeIam            *time.Duration `long:"scrape-time-iam"                env:"SCRAPE_TIME_IAM"                description:"Scrape time for IAM metrics (time.duration)"`
-			TimeGraph          *time.Duration `long:"scrape-time-graph"              env:"SCRAPE_TIME_GRAPH"              description:"Scrape time for Graph metrics (time.duration)"`
-			TimeCosts          *time.Duration `long:"scrape-time-costs"              env:"SCRAPE_TIME_COSTS"              description:"Scrape time for costs/consumtion metrics (time.duration; BETA)" default:"0"`
+			Time                 time.Duration  `long:"scrape-time"                    env:"SCRAPE_TIME"                    description:"Default scrape time (time.duration)"                      default:"5m"`
+			TimeRateLimitRead    *time.Duration `long:"scrape-ratelimit-read"          env:"SCRAPE_RATELIMIT_READ"          description:"Scrape time for ratelimit read metrics (time.duration)"   default:"2m"`
+			TimeRateLimitWrite   *time.Duration `long:"scrape-ratelimit-write"         env:"SCRAPE_RATELIMIT_WRITE"         description:"Scrape time for ratelimit write metrics (time.duration)"  default:"5m"`
+			TimeExporter         *time.Duration `long:"scrape-time-exporter"           env:"SCRAPE_TIME_EXPORTER"           description:"Scrape time for exporter metrics (time.duration)"         default:"10s"`
+			TimeGeneral          *time.Duration `long:"scrape-time-general"            env:"SCRAPE_TIME_GENERAL"            description:"Scrape time for general metrics (time.duration)"`
+			TimeResource         *time.Duration `long:"scrape-time-resource"           env:"SCRAPE_TIME_RESOURCE"           description:"Scrape time for resource metrics  (time.duration)"`
+			TimeQuota            *time.Duration `long:"scrape-time-quota"              env:"SCRAPE_TIME_QUOTA"              description:"Scrape time for quota metrics  (time.duration)"`
+			TimeSecurity         *time.Duration `long:"scrape-time-security"           env:"SCRAPE_TIME_SECURITY"           description:"Scrape time for Security metrics (time.duration)"`
+			TimeResourceHealth   *time.Duration `long:"scrape-time-resourcehealth"     env:"SCRAPE_TIME_RESOURCEHEALTH"     description:"Scrape time for ResourceHealth metrics (time.duration)"`
+			TimeIam              *time.Duration `long:"scrape-time-iam"                env:"SCRAPE_TIME_IAM"                description:"Scrape time for IAM metrics (time.duration)"`
+			TimeGraph            *time.Duration `long:"scrape-time-graph"              env:"SCRAPE_TIME_GRAPH"              description:"Scrape time for Graph metrics (time.duration)"`
+			TimeCosts            *time.Duration `long:"scrape-time-costs"              env:"SCRAPE_TIME_COSTS"              description:"Scrape time for costs/consumtion metrics (time.duration; BETA)" default:"0"`
+			TimeDeleted          *time.Duration `long:"scrape-time-deleted"            env:"SCRAPE_TIME_DELETED"            description:"Scrape time for deleted/soft-deleted resource metrics (time.duration)" default:"0"`
+			TimeVirtualWan       *time.Duration `long:"scrape-time-virtualwan"         env:"SCRAPE_TIME_VIRTUALWAN"         description:"Scrape time for Virtual WAN metrics (time.duration)" default:"0"`
+			TimeSpringApps       *time.Duration `long:"scrape-time-springapps"         env:"SCRAPE_TIME_SPRINGAPPS"         description:"Scrape time for Spring Apps metrics (time.duration)" default:"0"`
+			TimeServiceFabric    *time.Duration `long:"scrape-time-servicefabric"      env:"SCRAPE_TIME_SERVICEFABRIC"      description:"Scrape time for Service Fabric metrics (time.duration)" default:"0"`
+			TimeMessaging        *time.Duration `long:"scrape-time-messaging"          env:"SCRAPE_TIME_MESSAGING"          description:"Scrape time for messaging (Notification Hubs, Communication Services, Event Hubs, Service Bus) metrics (time.duration)" default:"0"`
+			TimeMonitor          *time.Duration `long:"scrape-time-monitor"            env:"SCRAPE_TIME_MONITOR"            description:"Scrape time for Azure Monitor metrics (time.duration)" default:"0"`
+			TimeAlertCoverage    *time.Duration `long:"scrape-time-alertcoverage"      env:"SCRAPE_TIME_ALERTCOVERAGE"      description:"Scrape time for alert coverage metrics (time.duration)" default:"0"`
+			TimePolicy           *time.Duration `long:"scrape-time-policy"             env:"SCRAPE_TIME_POLICY"             description:"Scrape time for Policy metrics (time.duration)" default:"0"`
+			TimeDeploymentStack  *time.Duration `long:"scrape-time-deploymentstack"    env:"SCRAPE_TIME_DEPLOYMENTSTACK"    description:"Scrape time for deployment stack and blueprint assignment metrics (time.duration)" default:"0"`
+			TimePublicIp         *time.Duration `long:"scrape-time-publicip"           env:"SCRAPE_TIME_PUBLICIP"           description:"Scrape time for public IP reverse DNS and CIDR check metrics (time.duration)" default:"0"`
+			TimeNetwork          *time.Duration `long:"scrape-time-network"            env:"SCRAPE_TIME_NETWORK"            description:"Scrape time for network (NIC, ASG, NSG, LoadBalancer, custom IP prefix, Bastion) metrics (time.duration)" default:"0"`
+			TimeExpressRoute     *time.Duration `long:"scrape-time-expressroute"       env:"SCRAPE_TIME_EXPRESSROUTE"       description:"Scrape time for ExpressRoute Direct metrics (time.duration)" default:"0"`
+			TimeFirewall         *time.Duration `long:"scrape-time-firewall"           env:"SCRAPE_TIME_FIREWALL"           description:"Scrape time for Firewall Policy and IP Group metrics (time.duration)" default:"0"`
+			TimeVirtualMachine   *time.Duration `long:"scrape-time-virtualmachine"     env:"SCRAPE_TIME_VIRTUALMACHINE"     description:"Scrape time for virtual machine metrics (time.duration)" default:"0"`
+			TimeHybridBenefit    *time.Duration `long:"scrape-time-hybridbenefit"      env:"SCRAPE_TIME_HYBRIDBENEFIT"      description:"Scrape time for Azure Hybrid Benefit metrics (time.duration)" default:"0"`
+			TimeZone             *time.Duration `long:"scrape-time-zone"               env:"SCRAPE_TIME_ZONE"               description:"Scrape time for availability zone metrics (time.duration)" default:"0"`
+			TimeKeyVault         *time.Duration `long:"scrape-time-keyvault"           env:"SCRAPE_TIME_KEYVAULT"           description:"Scrape time for KeyVault metrics (time.duration)" default:"0"`
+			TimeStorage          *time.Duration `long:"scrape-time-storage"            env:"SCRAPE_TIME_STORAGE"            description:"Scrape time for StorageAccount metrics (time.duration)" default:"0"`
+			TimeExposure         *time.Duration `long:"scrape-time-exposure"           env:"SCRAPE_TIME_EXPOSURE"           description:"Scrape time for public exposure metrics (time.duration)" default:"0"`
+			TimeDevOps           *time.Duration `long:"scrape-time-devops"             env:"SCRAPE_TIME_DEVOPS"             description:"Scrape time for Azure DevOps agent pool and parallel job metrics (time.duration)" default:"0"`
+			TimeDependency       *time.Duration `long:"scrape-time-dependency"         env:"SCRAPE_TIME_DEPENDENCY"         description:"Scrape time for resource dependency (edge) metrics (time.duration)" default:"0"`
+			TimeCompute          *time.Duration `long:"scrape-time-compute"            env:"SCRAPE_TIME_COMPUTE"            description:"Scrape time for compute (virtual machine and scale set inventory) metrics (time.duration)" default:"0"`
+			TimeDisk             *time.Duration `long:"scrape-time-disk"               env:"SCRAPE_TIME_DISK"               description:"Scrape time for managed disk metrics (time.duration)" default:"0"`
+			TimeAppService       *time.Duration `long:"scrape-time-appservice"         env:"SCRAPE_TIME_APPSERVICE"         description:"Scrape time for App Service plan and web app metrics (time.duration)" default:"0"`
+			TimeAppGateway       *time.Duration `long:"scrape-time-appgateway"         env:"SCRAPE_TIME_APPGATEWAY"         description:"Scrape time for Application Gateway metrics (time.duration)" default:"0"`
+			TimeOrphaned         *time.Duration `long:"scrape-time-orphaned"           env:"SCRAPE_TIME_ORPHANED"           description:"Scrape time for orphaned resource (unattached disks, NICs, public IPs, empty availability sets, unused NSGs) metrics (time.duration)" default:"0"`
+			TimeReservation      *time.Duration `long:"scrape-time-reservation"        env:"SCRAPE_TIME_RESERVATION"        description:"Scrape time for reservation and savings plan metrics (time.duration)" default:"0"`
+			TimeSql              *time.Duration `long:"scrape-time-sql"                env:"SCRAPE_TIME_SQL"                description:"Scrape time for SQL database backup retention metrics (time.duration)" default:"0"`
+			TimeCosmosDb         *time.Duration `long:"scrape-time-cosmosdb"           env:"SCRAPE_TIME_COSMOSDB"           description:"Scrape time for CosmosDB throughput metrics (time.duration)" default:"0"`
+			TimeServicePrincipal *time.Duration `long:"scrape-time-serviceprincipal"   env:"SCRAPE_TIME_SERVICEPRINCIPAL"   description:"Scrape time for service principal credential expiry metrics (Microsoft Graph) (time.duration)" default:"0"`
+			TimeSiteRecovery     *time.Duration `long:"scrape-time-siterecovery"       env:"SCRAPE_TIME_SITERECOVERY"       description:"Scrape time for Site Recovery replication health metrics (time.duration)" default:"0"`
+			TimeCdn              *time.Duration `long:"scrape-time-cdn"                env:"SCRAPE_TIME_CDN"                description:"Scrape time for Front Door and CDN custom domain certificate metrics (time.duration)" default:"0"`
+
+			Streaming         bool          `long:"scrape-streaming"               env:"SCRAPE_STREAMING"               description:"Apply metrics while collecting (page by page) to reduce memory usage, always enabled for the Resource collector (scrapes see the run when it is finished)"`
+			ParallelAuto      bool          `long:"scrape-parallel-auto"           env:"SCRAPE_PARALLEL_AUTO"           description:"Auto-tune parallelism: collect large or rate limited subscriptions serially, others in parallel"`
+			ParallelResources int64         `long:"scrape-parallel-resources"      env:"SCRAPE_PARALLEL_RESOURCES"      description:"Subscriptions with more resources (observed by the Resource collector) are treated as large" default:"20000"`
+			ParallelDuration  time.Duration `long:"scrape-parallel-duration"       env:"SCRAPE_PARALLEL_DURATION"       description:"Subscriptions with a longer previous collection time are treated as large if the resource count is unknown (time.duration)" default:"1m"`
+			ParallelRateLimit int64         `long:"scrape-parallel-ratelimit"      env:"SCRAPE_PARALLEL_RATELIMIT"      description:"Subscriptions with fewer remaining read requests are treated as rate limited" default:"2000"`
+			SoftFail          bool          `long:"scrape-soft-fail"               env:"SCRAPE_SOFT_FAIL"               description:"Disable collectors for subscriptions denied access (403) in the first collection run instead of failing every run"`
+			SoftFailRetry     time.Duration `long:"scrape-soft-fail-retry"         env:"SCRAPE_SOFT_FAIL_RETRY"         description:"Time until subscriptions disabled by --scrape-soft-fail are collected again to probe the permissions (time.duration)" default:"6h"`
+			Budget            []string      `long:"scrape-budget"                  env:"SCRAPE_BUDGET"                  env-delim:" "  description:"Max duration of a list call per resource type or provider namespace (format: ResourceType=duration, eg. 'Microsoft.Storage=2m'), collections with aborted list calls keep the metrics of the previous run"`
+			BudgetOverruns    int           `long:"scrape-budget-overruns"         env:"SCRAPE_BUDGET_OVERRUNS"         description:"Consecutive list calls exceeding --scrape-budget until the resource type is skipped for the subscription" default:"3"`
+			BudgetBackoff     time.Duration `long:"scrape-budget-backoff"          env:"SCRAPE_BUDGET_BACKOFF"          description:"Time the list calls of a resource type are skipped for the subscription after repeatedly exceeding --scrape-budget (time.duration)" default:"1h"`
 		}
 
 		// graph settings
 		Graph struct {
 			ApplicationFilter string `long:"graph-application-filter"    env:"GRAPH_APPLICATION_FILTER"               description:"Graph application filter query eg: startswith(displayName,'A')"`
+			MicrosoftEndpoint string `long:"graph-microsoft-endpoint"    env:"GRAPH_MICROSOFT_ENDPOINT"               description:"Microsoft Graph endpoint for service principal credentials" default:"https://graph.microsoft.com/"`
+		}
+
+		// azure devops settings
+		DevOps struct {
+			Organization string `long:"devops-organization"         env:"DEVOPS_ORGANIZATION"                    description:"Azure DevOps organization url (eg. https://dev.azure.com/myorg)"`
+			AccessToken  string `long:"devops-access-token"         env:"DEVOPS_ACCESS_TOKEN"                    description:"Azure DevOps personal access token (Azure AD authentication is used if empty)" json:"-"`
 		}
 
 		// costs
@@ -51,6 +109,55 @@ type (
 		Costs struct {
 			Timeframe []string `long:"costs-timeframe" env:"COSTS_TIMEFRAME"  env-delim:" " description:"Timeframe for cost reportings" default:"MonthToDate" default:"YearToDate"`                                                                                                                                                                                                                                                                                                                                                                                                                                                                                                                                       //nolint:staticcheck
 			Dimension []string `long:"costs-dimension" env:"COSTS_DIMENSION"  env-delim:" " description:"Dimensions for detailed cost metrics (eg 'ResourceGroup','ResourceGroupName','ResourceLocation','ConsumedService','ResourceType','ResourceId','MeterId','BillingMonth','MeterCategory','MeterSubcategory','Meter','AccountName','DepartmentName','SubscriptionId','SubscriptionName','ServiceName','ServiceTier','EnrollmentAccountName','BillingAccountId','ResourceGuid','BillingPeriod','InvoiceNumber','ChargeType','PublisherType','ReservationId','ReservationName','Frequency','PartNumber','CostAllocationRuleName','MarkupRuleName','PricingModel')" default:"ResourceType" default:"ResourceLocation"` //nolint:staticcheck
+			Untagged  bool     `long:"costs-untagged"  env:"COSTS_UNTAGGED"                  description:"Export actual cost of untagged resources (azurerm_costmanagement_untagged_cost)"`
+		}
+
+		// azure monitor metrics
+		Monitor struct {
+			Metrics  []string      `long:"monitor-metric"    env:"MONITOR_METRIC"    env-delim:" " description:"Azure Monitor metrics to export (format: resourceType:metricName[:aggregation], eg 'Microsoft.Storage/storageAccounts:UsedCapacity:average')"`
+			Interval string        `long:"monitor-interval"  env:"MONITOR_INTERVAL"                description:"Azure Monitor metric interval (ISO8601 duration)" default:"PT5M"`
+			Timespan time.Duration `long:"monitor-timespan"  env:"MONITOR_TIMESPAN"                description:"Azure Monitor metric query timespan (time.duration)" default:"15m"`
+		}
+
+		// resource settings
+		Resource struct {
+			ActivityLog  bool          `long:"resource-activitylog"          env:"RESOURCE_ACTIVITYLOG"                     description:"Collect resources incrementally: between full collections only resourcegroups with write/delete events in the Activity Log are listed again"`
+			FullInterval time.Duration `long:"resource-full-interval"        env:"RESOURCE_FULL_INTERVAL"                   description:"Interval of the full resource collection with --resource-activitylog (time.duration)" default:"6h"`
+		}
+
+		// quota settings
+		Quota struct {
+			FileShares bool `long:"quota-fileshares"              env:"QUOTA_FILESHARES"                         description:"Collect Azure Files share quota and usage (one request per share)"`
+		}
+
+		// public ip checks
+		PublicIp struct {
+			ReverseDns bool     `long:"publicip-reversedns"           env:"PUBLICIP_REVERSEDNS"                      description:"Resolve reverse DNS for public IPs"`
+			AllowCidr  []string `long:"publicip-allow-cidr"           env:"PUBLICIP_ALLOW_CIDR"       env-delim:" "  description:"Approved CIDR prefixes for public IPs (IPs outside are reported as unexpected)"`
+			DenyCidr   []string `long:"publicip-deny-cidr"            env:"PUBLICIP_DENY_CIDR"        env-delim:" "  description:"Denied CIDR prefixes for public IPs (eg. threat lists, IPs inside are reported as unexpected)"`
+			Region     bool     `long:"publicip-region"               env:"PUBLICIP_REGION"                          description:"Resolve the Azure region of public IP address blocks (Azure service tags) and report region mismatches"`
+		}
+
+		// virtual machine settings
+		VirtualMachine struct {
+			ImageEol             []string `long:"vm-image-eol"                  env:"VM_IMAGE_EOL"              env-delim:" "  description:"End-of-support VM images (format: publisher:offer:sku, wildcards allowed, eg 'Canonical:UbuntuServer:18.04*')"`
+			CiRunnerTag          string   `long:"vm-cirunner-tag"               env:"VM_CIRUNNER_TAG"                          description:"Tag marking virtual machine scale sets as CI runner pools (tag value is used as runner type, eg. 'github' or 'azuredevops')"`
+			AutoscaleRequiredTag string   `long:"vm-autoscale-required-tag"     env:"VM_AUTOSCALE_REQUIRED_TAG"                description:"Tag marking virtual machine scale sets which require enabled autoscale (format: name[=value], eg. 'environment=prod')"`
+		}
+
+		// policy settings
+		Policy struct {
+			GuestConfiguration bool `long:"policy-guestconfiguration"     env:"POLICY_GUESTCONFIGURATION"                description:"Collect guest configuration (Azure Policy guest assignment) compliance of virtual machines and Arc machines"`
+		}
+
+		// keyvault settings
+		KeyVault struct {
+			Expiry bool `long:"keyvault-expiry"               env:"KEYVAULT_EXPIRY"                          description:"Export expiry of KeyVault secrets, keys and certificates (data-plane access with list permissions needed)"`
+		}
+
+		// storage settings
+		Storage struct {
+			FileShares bool `long:"storage-fileshares"            env:"STORAGE_FILESHARES"                       description:"Collect Azure Files share inventory with quota and usage (one request per share)"`
 		}
 
 		// portscan settings
@@ -61,10 +168,72 @@ type (
 			Threads   int           `long:"portscan-threads"              env:"PORTSCAN_THREADS"                         description:"Portscan threads (concurrent port scans per IP)"  default:"1000"`
 			Timeout   int           `long:"portscan-timeout"              env:"PORTSCAN_TIMEOUT"                         description:"Portscan timeout (seconds)"                       default:"5"`
 			PortRange []string      `long:"portscan-range"                env:"PORTSCAN_RANGE"            env-delim:" "  description:"Portscan port range (first-last)"                 default:"1-65535"`
+
+			// findings sink (Log Analytics, Logs Ingestion API)
+			Logs struct {
+				Endpoint string `long:"portscan-logs-endpoint"        env:"PORTSCAN_LOGS_ENDPOINT"                   description:"Send portscan findings to Log Analytics (eg. Microsoft Sentinel) with the Logs Ingestion API of this data collection endpoint (eg. 'https://dce-xxxx.westeurope-1.ingest.monitor.azure.com')"`
+				Dcr      string `long:"portscan-logs-dcr"             env:"PORTSCAN_LOGS_DCR"                        description:"Immutable id of the data collection rule for portscan findings (eg. 'dcr-xxxx')"`
+				Stream   string `long:"portscan-logs-stream"          env:"PORTSCAN_LOGS_STREAM"                     description:"Stream of the data collection rule for portscan findings"  default:"Custom-AzureRmPortscan_CL"`
+			}
 		}
 
 		Metrics struct {
-			ResourceIdLowercase bool `long:"metrics.resourceid.lowercase"   env:"METRIC_RESOURCEID_LOWERCASE"       description:"Publish lowercase Azure Resoruce ID in metrics"`
+			ResourceIdLowercase bool     `long:"metrics.resourceid.lowercase"   env:"METRIC_RESOURCEID_LOWERCASE"       description:"Publish lowercase Azure Resoruce ID in metrics"`
+			HierarchyLabels     bool     `long:"metrics.hierarchy-labels"       env:"METRIC_HIERARCHY_LABELS"           description:"Add subscription name and management group path labels to all metrics with a subscriptionID label"`
+			ConstLabels         []string `long:"metrics.const-label"            env:"METRIC_CONST_LABEL"                env-delim:" "  description:"Constant labels for metrics (format: [collector:]name=value, eg. 'team=platform' or 'Resource:collector=resources', labels of the exporter metrics like collector or subscriptionID are only allowed per collector, startup fails if a label is also a label of a metric)"`
+			HelpOverride        []string `long:"metrics.help"                   env:"METRIC_HELP"                       env-delim:";"  description:"Override help text of metrics (format: metric=help text, env var is separated by ';')"`
+			Timestamps          bool     `long:"metrics.timestamps"             env:"METRIC_TIMESTAMPS"                 description:"Attach the collection time as timestamp to samples of collector metrics (enables OpenMetrics format)"`
+			ManagedPrometheus   bool     `long:"metrics.managed-prometheus"     env:"METRIC_MANAGED_PROMETHEUS"         description:"Azure Monitor managed Prometheus compatibility (no colons in metric names, no OpenMetrics format)"`
+			NamePrefix          string   `long:"metrics.name-prefix"            env:"METRIC_NAME_PREFIX"                description:"Prefix for all metric names (eg. 'microsoft_')"`
+			Cluster             string   `long:"metrics.cluster"                env:"METRIC_CLUSTER"                    description:"Add cluster label to all metrics (eg. for Azure Monitor managed Prometheus)"`
+			SuccessRatioRuns    int      `long:"metrics.successratio.runs"      env:"METRIC_SUCCESSRATIO_RUNS"          description:"Number of collection runs used for the collector success ratio metric" default:"10"`
+			DisableRuntime      bool     `long:"metrics.disable-runtime"        env:"METRIC_DISABLE_RUNTIME"            description:"Disable Go runtime and process metrics (go_*, process_*)"`
+			Groups              []string `long:"metrics.group"                  env:"METRIC_GROUP"                      env-delim:";"  description:"Serve metrics of collector groups on /metrics/<group>, optionally on a separate address (format: group=Collector,Collector[@bind], eg. 'security=Security,IAM@:8081', env var is separated by ';')"`
+			Allow               []string `long:"metrics.allow"                  env:"METRIC_ALLOW"                      env-delim:" "  description:"Only export matching metrics of a collector (format: [collector:]metric, wildcards allowed, eg. 'Quota:azurerm_quota_*')"`
+			Deny                []string `long:"metrics.deny"                   env:"METRIC_DENY"                       env-delim:" "  description:"Drop matching metrics of a collector (format: [collector:]metric, wildcards allowed, eg. 'Quota:azurerm_quota_info')"`
+		}
+
+		// config watch
+		ConfigWatch struct {
+			Path     string        `long:"config-watch"                  env:"CONFIG_WATCH"                             description:"Watch json config file (eg. mounted Kubernetes ConfigMap) for subscriptions, public ip cidrs and portscan settings and apply changes without restart"`
+			Interval time.Duration `long:"config-watch-interval"         env:"CONFIG_WATCH_INTERVAL"                    description:"Config watch interval (time.duration)" default:"30s"`
+		}
+
+		// federation
+		Federation struct {
+			Peers   []string      `long:"federation-peer"               env:"FEDERATION_PEER"           env-delim:" "  description:"Sibling exporter instances merged into /federate (eg. 'http://exporter-shard-2:8080')"`
+			Timeout time.Duration `long:"federation-timeout"            env:"FEDERATION_TIMEOUT"                       description:"Timeout for fetching metrics from federation peers (time.duration)" default:"30s"`
+		}
+
+		// subscription mapping
+		SubscriptionMapping string `long:"subscription-mapping"          env:"SUBSCRIPTION_MAPPING"                     description:"Json file with labels (eg. alias, team, environment) added to all metrics of a subscription (format: {\"subscriptionID\": {\"label\": \"value\"}})"`
+
+		// blackout windows
+		BlackoutWindows []string `long:"blackout-window"               env:"BLACKOUT_WINDOW"           env-delim:";"  description:"Pause collection and/or port scanning (format: cron|duration[|subscriptionID[|target]], target: all, collection or portscan, eg. '0 22 * * 5|48h|*|all', env var is separated by ';')"`
+
+		// admin api
+		Admin struct {
+			Token string `long:"admin-token"                   env:"ADMIN_TOKEN"                              description:"Enable the admin api (/admin/...) to control collectors at runtime, authenticated by this bearer token" json:"-"`
+		}
+
+		// event grid webhook
+		EventGrid struct {
+			Token      string        `long:"eventgrid-token"               env:"EVENTGRID_TOKEN"                          description:"Enable the Event Grid webhook (/eventgrid, authenticated by this bearer token) for subscription system topics, resource events trigger a collection of the changed subscription (Resource collector: changed resourcegroups)" json:"-"`
+			Collectors []string      `long:"eventgrid-collector"           env:"EVENTGRID_COLLECTOR"       env-delim:" "  description:"Collectors collected on Event Grid resource events" default:"Resource"`
+			Delay      time.Duration `long:"eventgrid-delay"               env:"EVENTGRID_DELAY"                          description:"Delay of the collection after the last event of a subscription, further events postpone the collection (time.duration)" default:"30s"`
+			MaxDelay   time.Duration `long:"eventgrid-max-delay"           env:"EVENTGRID_MAX_DELAY"                      description:"Max delay of the collection after the first event of a subscription (time.duration)" default:"5m"`
+		}
+
+		// inventory snapshots
+		Snapshot struct {
+			Url        string   `long:"snapshot-url"                  env:"SNAPSHOT_URL"                             description:"Upload the metrics of each collection run as gzipped NDJSON blobs to this Azure Blob container url (eg. 'https://account.blob.core.windows.net/inventory', SAS token as query or Azure AD authentication)" json:"-"`
+			Collectors []string `long:"snapshot-collector"            env:"SNAPSHOT_COLLECTOR"        env-delim:" "  description:"Collectors written to snapshots (default: all)"`
+		}
+
+		// azure mocking
+		Mock struct {
+			Path   string `long:"mock-azure"                    env:"MOCK_AZURE"                               description:"Serve Azure ResourceManager responses from recorded fixtures of this directory instead of the live API (eg. for testing dashboards and alert rules)"`
+			Record bool   `long:"mock-azure-record"             env:"MOCK_AZURE_RECORD"                        description:"Record sanitized responses of the live Azure ResourceManager API as fixtures to the --mock-azure directory"`
 		}
 
 		// caching
@@ -73,7 +242,21 @@ type (
 		}
 
 		// general options
-		ServerBind string `long:"bind"     env:"SERVER_BIND"   description:"Server address"     default:":8080"`
+		ServerBind        string `long:"bind"                 env:"SERVER_BIND"          description:"Server address"     default:":8080"`
+		ProfileCollection bool   `long:"profile-collection"   env:"PROFILE_COLLECTION"   description:"Run one collection of all enabled collectors (one subscription after another), print API calls, bytes transferred and wall time per collector and subscription and exit"`
+
+		// end-to-end test mode
+		E2e struct {
+			Enabled bool   `long:"e2e"                  env:"E2E"                  description:"Run one collection of all enabled collectors against the (sandbox) subscriptions, check the exported metric families and exit (exit code 1 if a check failed)"`
+			Expect  string `long:"e2e-expect"           env:"E2E_EXPECT"           description:"Json file with expected metric families for --e2e (format: {\"metric\": {\"minSamples\": 1, \"min\": 0, \"max\": 1}})"`
+		}
+
+		// generate-dashboards command
+		GenerateDashboards struct {
+			Output     string `long:"output"      description:"Output directory for the Grafana dashboard json and the Prometheus alert rules yaml" default:"."`
+			Datasource string `long:"datasource"  description:"Default Prometheus datasource of the dashboard"                                    default:"Prometheus"`
+			ExpiryDays int    `long:"expiry-days" description:"Alert for expiry metrics (eg. certificates, secrets) expiring within days"            default:"30"`
+		} `command:"generate-dashboards" description:"Generate a Grafana dashboard and Prometheus alert rules for the enabled collectors (from the metric registry) and exit"`
 	}
 )
 
@@ -84,3 +267,9 @@ func (o *Opts) GetJson() []byte {
 	}
 	return jsonBytes
 }
+
+// GetHash returns a short hash of the configuration (eg. to detect config drift between replicas)
+func (o *Opts) GetHash() string {
+	hash := sha256.Sum256(o.GetJson())
+	return hex.EncodeToString(hash[:])[0:12]
+}
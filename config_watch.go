@@ -0,0 +1,196 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/Azure/azure-sdk-for-go/profiles/latest/resources/mgmt/subscriptions"
+	"github.com/Azure/go-autorest/autorest/to"
+	log "github.com/sirupsen/logrus"
+	"io/ioutil"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	// guards settings which can be changed by --config-watch at runtime
+	// (subscriptions, public ip cidr lists, portscan settings)
+	dynamicConfigLock sync.RWMutex
+
+	// content of the applied config file
+	configWatchContent []byte
+)
+
+// configWatchConfig is the (json) config file watched by --config-watch, eg. mounted from a Kubernetes ConfigMap.
+// Unset settings keep the values of the command line/env vars.
+type configWatchConfig struct {
+	Subscriptions *[]string `json:"subscriptions"`
+
+	PublicIp struct {
+		AllowCidr *[]string `json:"allowCidr"`
+		DenyCidr  *[]string `json:"denyCidr"`
+	} `json:"publicIp"`
+
+	Portscan struct {
+		Range    *[]string `json:"range"`
+		Parallel *int      `json:"parallel"`
+		Threads  *int      `json:"threads"`
+		Timeout  *int      `json:"timeout"`
+	} `json:"portscan"`
+}
+
+// initConfigWatch applies the config file on startup (before the collectors are started)
+func initConfigWatch() {
+	content, err := ioutil.ReadFile(opts.ConfigWatch.Path)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	if err := configWatchApply(content); err != nil {
+		log.Panic(err)
+	}
+	configWatchContent = content
+}
+
+// startConfigWatch watches the config file for changes and applies them to the running collectors
+func startConfigWatch() {
+	go func() {
+		for {
+			time.Sleep(opts.ConfigWatch.Interval)
+
+			// ConfigMap volumes are updated by swapping the files, so the content is compared instead of the modification time
+			content, err := ioutil.ReadFile(opts.ConfigWatch.Path)
+			if err != nil {
+				log.WithField("config", opts.ConfigWatch.Path).Error(err)
+				continue
+			}
+
+			if bytes.Equal(configWatchContent, content) {
+				continue
+			}
+
+			log.WithField("config", opts.ConfigWatch.Path).Info("config changed, applying")
+			if err := configWatchApply(content); err != nil {
+				log.WithField("config", opts.ConfigWatch.Path).Errorf("failed to apply config, keeping previous config: %v", err)
+			}
+			configWatchContent = content
+		}
+	}()
+}
+
+// configWatchApply parses and validates the config and applies it to the running collectors
+func configWatchApply(content []byte) error {
+	if err := configWatchValidate(content); err != nil {
+		return err
+	}
+
+	config := configWatchConfig{}
+	if err := json.Unmarshal(content, &config); err != nil {
+		return fmt.Errorf("failed to parse config: %v", err)
+	}
+
+	// subscriptions are fetched before locking, collectors must not wait for Azure API calls
+	var subscriptionList []subscriptions.Subscription
+	if config.Subscriptions != nil {
+		if len(*config.Subscriptions) == 0 {
+			return fmt.Errorf("subscription list is empty")
+		}
+
+		subscriptionsClient := subscriptions.NewClientWithBaseURI(azureEnvironment.ResourceManagerEndpoint)
+		subscriptionsClient.Authorizer = AzureAuthorizer
+
+		for _, subId := range *config.Subscriptions {
+			result, err := subscriptionsClient.Get(context.Background(), subId)
+			if err != nil {
+				return err
+			}
+			subscriptionList = append(subscriptionList, result)
+		}
+	}
+
+	// hierarchy (names, tags, management group paths) of added subscriptions is loaded before locking as well
+	var hierarchyCache *AzureHierarchyCache
+	if subscriptionList != nil && (opts.Metrics.HierarchyLabels || opts.Azure.TagInheritance) {
+		dynamicConfigLock.RLock()
+		knownSubscriptions := map[string]bool{}
+		for _, subscription := range AzureSubscriptions {
+			knownSubscriptions[strings.ToLower(to.String(subscription.SubscriptionID))] = true
+		}
+		dynamicConfigLock.RUnlock()
+
+		addedSubscriptions := []subscriptions.Subscription{}
+		for _, subscription := range subscriptionList {
+			if !knownSubscriptions[strings.ToLower(to.String(subscription.SubscriptionID))] {
+				addedSubscriptions = append(addedSubscriptions, subscription)
+			}
+		}
+
+		if len(addedSubscriptions) > 0 {
+			var err error
+			if hierarchyCache, err = loadAzureHierarchy(context.Background(), addedSubscriptions); err != nil {
+				return fmt.Errorf("failed to load hierarchy of added subscriptions: %v", err)
+			}
+			if opts.Metrics.HierarchyLabels {
+				azureHierarchy.refreshManagementGroups(context.Background(), true)
+			}
+		}
+	}
+
+	dynamicConfigLock.Lock()
+	defer dynamicConfigLock.Unlock()
+
+	previousPublicIp, previousPortscan, previousPortRange := opts.PublicIp, opts.Portscan, portscanPortRange
+	previousAllowList, previousDenyList := publicIpAllowList, publicIpDenyList
+
+	if config.PublicIp.AllowCidr != nil {
+		opts.PublicIp.AllowCidr = *config.PublicIp.AllowCidr
+	}
+	if config.PublicIp.DenyCidr != nil {
+		opts.PublicIp.DenyCidr = *config.PublicIp.DenyCidr
+	}
+	if config.Portscan.Range != nil {
+		opts.Portscan.PortRange = *config.Portscan.Range
+	}
+	if config.Portscan.Parallel != nil {
+		opts.Portscan.Parallel = *config.Portscan.Parallel
+	}
+	if config.Portscan.Threads != nil {
+		opts.Portscan.Threads = *config.Portscan.Threads
+	}
+	if config.Portscan.Timeout != nil {
+		opts.Portscan.Timeout = *config.Portscan.Timeout
+	}
+
+	err := argparserParsePublicIpCidrs()
+	if err == nil {
+		err = argparserParsePortrange()
+	}
+	if err == nil && (opts.Portscan.Parallel <= 0 || opts.Portscan.Threads <= 0 || opts.Portscan.Timeout <= 0) {
+		err = fmt.Errorf("portscan parallel, threads and timeout must be greater than zero")
+	}
+	if err != nil {
+		// rollback
+		opts.PublicIp, opts.Portscan, portscanPortRange = previousPublicIp, previousPortscan, previousPortRange
+		publicIpAllowList, publicIpDenyList = previousAllowList, previousDenyList
+		return err
+	}
+
+	if subscriptionList != nil {
+		AzureSubscriptions = subscriptionList
+		for _, collector := range collectorGeneralList {
+			collector.AzureSubscriptions = subscriptionList
+		}
+		for _, collector := range collectorCustomList {
+			collector.AzureSubscriptions = subscriptionList
+		}
+		log.Infof("using %v subscriptions", len(subscriptionList))
+
+		if hierarchyCache != nil {
+			azureHierarchy.merge(hierarchyCache)
+		}
+	}
+
+	return nil
+}
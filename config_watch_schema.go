@@ -0,0 +1,297 @@
+package main
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+var (
+	// JSON schema of the --config-watch file (subset of draft-07: type, properties, additionalProperties,
+	// items, minItems, minimum, pattern and the formats cidr, portrange and regex)
+	//go:embed config_watch.schema.json
+	configWatchSchemaJson []byte
+
+	configWatchSchema = mustParseConfigSchema(configWatchSchemaJson)
+)
+
+type configSchema struct {
+	Type                 string                   `json:"type"`
+	Properties           map[string]*configSchema `json:"properties"`
+	AdditionalProperties *bool                    `json:"additionalProperties"`
+	Items                *configSchema            `json:"items"`
+	MinItems             *int                     `json:"minItems"`
+	Minimum              *float64                 `json:"minimum"`
+	Pattern              string                   `json:"pattern"`
+	Format               string                   `json:"format"`
+
+	pattern *regexp.Regexp
+}
+
+// configSchemaValidator walks the json tokens of a config file and collects all schema violations with line numbers
+type configSchemaValidator struct {
+	content []byte
+	decoder *json.Decoder
+	errors  []string
+}
+
+func mustParseConfigSchema(content []byte) *configSchema {
+	schema := &configSchema{}
+	if err := json.Unmarshal(content, schema); err != nil {
+		panic(fmt.Sprintf("invalid config schema: %v", err))
+	}
+	schema.compile()
+	return schema
+}
+
+func (s *configSchema) compile() {
+	if s.Pattern != "" {
+		s.pattern = regexp.MustCompile(s.Pattern)
+	}
+	for _, property := range s.Properties {
+		property.compile()
+	}
+	if s.Items != nil {
+		s.Items.compile()
+	}
+}
+
+// configWatchValidate validates the config file against the schema, all violations are reported together
+// (eg. "line 4: publicIp.alowCidr: unknown key, did you mean \"allowCidr\"?")
+func configWatchValidate(content []byte) error {
+	validator := configSchemaValidator{
+		content: content,
+		decoder: json.NewDecoder(bytes.NewReader(content)),
+	}
+
+	if err := validator.value(configWatchSchema, ""); err != nil {
+		var syntaxError *json.SyntaxError
+		if errors.As(err, &syntaxError) {
+			return fmt.Errorf("invalid config: line %v: %v", validator.line(syntaxError.Offset), err)
+		}
+		if errors.Is(err, io.EOF) {
+			err = io.ErrUnexpectedEOF
+		}
+		return fmt.Errorf("invalid config: %v", err)
+	}
+
+	if len(validator.errors) > 0 {
+		return fmt.Errorf("invalid config:\n  %v", strings.Join(validator.errors, "\n  "))
+	}
+
+	return nil
+}
+
+// value validates the next json value, values without schema (unknown keys) are skipped
+func (v *configSchemaValidator) value(schema *configSchema, path string) error {
+	line := v.line(v.decoder.InputOffset())
+
+	token, err := v.decoder.Token()
+	if err != nil {
+		return err
+	}
+
+	switch value := token.(type) {
+	case json.Delim:
+		switch value {
+		case '{':
+			if !v.checkType(schema, "object", line, path) {
+				schema = nil
+			}
+			return v.object(schema, path)
+		case '[':
+			if !v.checkType(schema, "array", line, path) {
+				schema = nil
+			}
+			return v.array(schema, path, line)
+		}
+	case string:
+		if v.checkType(schema, "string", line, path) {
+			v.checkString(schema, value, line, path)
+		}
+	case float64:
+		valueType := "number"
+		if value == math.Trunc(value) {
+			valueType = "integer"
+		}
+		if v.checkType(schema, valueType, line, path) && schema != nil && schema.Minimum != nil && value < *schema.Minimum {
+			v.errorf(line, path, "must be at least %v", *schema.Minimum)
+		}
+	case bool:
+		v.checkType(schema, "boolean", line, path)
+	case nil:
+		v.checkType(schema, "null", line, path)
+	}
+
+	return nil
+}
+
+func (v *configSchemaValidator) object(schema *configSchema, path string) error {
+	for v.decoder.More() {
+		line := v.line(v.decoder.InputOffset())
+
+		token, err := v.decoder.Token()
+		if err != nil {
+			return err
+		}
+		key, _ := token.(string)
+
+		keyPath := key
+		if path != "" {
+			keyPath = path + "." + key
+		}
+
+		var propertySchema *configSchema
+		if schema != nil {
+			if property, exists := schema.Properties[key]; exists {
+				propertySchema = property
+			} else if schema.AdditionalProperties != nil && !*schema.AdditionalProperties {
+				if suggestion := configSchemaSuggestKey(key, schema.Properties); suggestion != "" {
+					v.errorf(line, keyPath, "unknown key, did you mean \"%v\"?", suggestion)
+				} else {
+					v.errorf(line, keyPath, "unknown key")
+				}
+			}
+		}
+
+		if err := v.value(propertySchema, keyPath); err != nil {
+			return err
+		}
+	}
+
+	// closing '}'
+	_, err := v.decoder.Token()
+	return err
+}
+
+func (v *configSchemaValidator) array(schema *configSchema, path string, line int) error {
+	var itemSchema *configSchema
+	if schema != nil {
+		itemSchema = schema.Items
+	}
+
+	count := 0
+	for v.decoder.More() {
+		if err := v.value(itemSchema, fmt.Sprintf("%v[%v]", path, count)); err != nil {
+			return err
+		}
+		count++
+	}
+
+	if schema != nil && schema.MinItems != nil && count < *schema.MinItems {
+		v.errorf(line, path, "must have at least %v items", *schema.MinItems)
+	}
+
+	// closing ']'
+	_, err := v.decoder.Token()
+	return err
+}
+
+// checkType reports values not matching the schema type, integers are also valid numbers
+func (v *configSchemaValidator) checkType(schema *configSchema, valueType string, line int, path string) bool {
+	if schema == nil {
+		return false
+	}
+
+	if schema.Type == "" || schema.Type == valueType || (schema.Type == "number" && valueType == "integer") {
+		return true
+	}
+
+	v.errorf(line, path, "must be %v, got %v", schema.Type, valueType)
+	return false
+}
+
+func (v *configSchemaValidator) checkString(schema *configSchema, value string, line int, path string) {
+	if schema.pattern != nil && !schema.pattern.MatchString(value) {
+		v.errorf(line, path, "invalid value \"%v\" (must match %v)", value, schema.Pattern)
+	}
+
+	switch schema.Format {
+	case "cidr":
+		if _, _, err := net.ParseCIDR(value); err != nil {
+			v.errorf(line, path, "invalid cidr \"%v\"", value)
+		}
+	case "portrange":
+		if _, err := parsePortrange(value); err != nil {
+			v.errorf(line, path, "invalid port range: %v", err)
+		}
+	case "regex":
+		if _, err := regexp.Compile(value); err != nil {
+			v.errorf(line, path, "invalid regex: %v", err)
+		}
+	}
+}
+
+func (v *configSchemaValidator) errorf(line int, path string, format string, args ...interface{}) {
+	v.errors = append(v.errors, fmt.Sprintf("line %v: %v: %v", line, path, fmt.Sprintf(format, args...)))
+}
+
+// line returns the line number of the next token after the offset (whitespace and separators are skipped)
+func (v *configSchemaValidator) line(offset int64) int {
+	if offset > int64(len(v.content)) {
+		offset = int64(len(v.content))
+	}
+
+	for offset < int64(len(v.content)) && strings.ContainsRune(" \t\r\n,:", rune(v.content[offset])) {
+		offset++
+	}
+
+	return bytes.Count(v.content[:offset], []byte("\n")) + 1
+}
+
+// configSchemaSuggestKey returns the known key most similar to the unknown key (typos and wrong case)
+func configSchemaSuggestKey(key string, properties map[string]*configSchema) string {
+	keyList := []string{}
+	for property := range properties {
+		keyList = append(keyList, property)
+	}
+	sort.Strings(keyList)
+
+	suggestion := ""
+	bestDistance := 3
+	for _, property := range keyList {
+		if distance := levenshteinDistance(strings.ToLower(key), strings.ToLower(property)); distance < bestDistance {
+			suggestion = property
+			bestDistance = distance
+		}
+	}
+
+	return suggestion
+}
+
+func levenshteinDistance(a, b string) int {
+	previous := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		current := make([]int, len(b)+1)
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = minInt(minInt(previous[j]+1, current[j-1]+1), previous[j-1]+cost)
+		}
+		previous = current
+	}
+
+	return previous[len(b)]
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestConfigWatchValidate(t *testing.T) {
+	testCases := []struct {
+		name    string
+		content string
+		errors  []string
+	}{
+		{
+			name: "valid",
+			content: `{
+  "subscriptions": ["00000000-0000-0000-0000-000000000000"],
+  "publicIp": {"allowCidr": ["10.0.0.0/8"], "denyCidr": []},
+  "portscan": {"range": ["22-443"], "parallel": 2, "threads": 100, "timeout": 5}
+}`,
+		},
+		{
+			name:    "empty object",
+			content: `{}`,
+		},
+		{
+			name: "unknown key with suggestion",
+			content: `{
+  "publicIp": {
+    "alowCidr": []
+  }
+}`,
+			errors: []string{`line 3: publicIp.alowCidr: unknown key, did you mean "allowCidr"?`},
+		},
+		{
+			name:    "unknown key",
+			content: `{"foobar": true}`,
+			errors:  []string{`line 1: foobar: unknown key`},
+		},
+		{
+			name:    "wrong type",
+			content: `{"portscan": {"parallel": "2"}}`,
+			errors:  []string{`line 1: portscan.parallel: must be integer, got string`},
+		},
+		{
+			name:    "number instead of integer",
+			content: `{"portscan": {"threads": 1.5}}`,
+			errors:  []string{`line 1: portscan.threads: must be integer, got number`},
+		},
+		{
+			name:    "minimum",
+			content: `{"portscan": {"timeout": 0}}`,
+			errors:  []string{`line 1: portscan.timeout: must be at least 1`},
+		},
+		{
+			name:    "min items",
+			content: `{"subscriptions": []}`,
+			errors:  []string{`line 1: subscriptions: must have at least 1 items`},
+		},
+		{
+			name:    "pattern",
+			content: `{"subscriptions": ["foo"]}`,
+			errors:  []string{`line 1: subscriptions[0]: invalid value "foo"`},
+		},
+		{
+			name:    "cidr",
+			content: `{"publicIp": {"denyCidr": ["10.0.0.0/33"]}}`,
+			errors:  []string{`line 1: publicIp.denyCidr[0]: invalid cidr "10.0.0.0/33"`},
+		},
+		{
+			name:    "portrange",
+			content: `{"portscan": {"range": ["foo"]}}`,
+			errors:  []string{`line 1: portscan.range[0]: invalid port range`},
+		},
+		{
+			name: "all violations reported",
+			content: `{
+  "subscriptions": "00000000-0000-0000-0000-000000000000",
+  "portscan": {"parallel": 0}
+}`,
+			errors: []string{
+				`line 2: subscriptions: must be array, got string`,
+				`line 3: portscan.parallel: must be at least 1`,
+			},
+		},
+		{
+			name:    "syntax error",
+			content: "{\n  \"portscan\": }",
+			errors:  []string{`invalid config: line 2:`},
+		},
+		{
+			name:    "truncated",
+			content: `{"portscan": {`,
+			errors:  []string{`invalid config: line 1: unexpected end of JSON input`},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			err := configWatchValidate([]byte(testCase.content))
+			if len(testCase.errors) == 0 {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("expected error")
+			}
+
+			for _, expectedError := range testCase.errors {
+				if !strings.Contains(err.Error(), expectedError) {
+					t.Errorf("expected error containing %q, got %q", expectedError, err.Error())
+				}
+			}
+		})
+	}
+}
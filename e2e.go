@@ -0,0 +1,213 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	log "github.com/sirupsen/logrus"
+	"io/ioutil"
+	"math"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+)
+
+// e2eExpectation is the expected state of a metric family after the collection (--e2e-expect)
+type e2eExpectation struct {
+	MinSamples *int     `json:"minSamples"`
+	Min        *float64 `json:"min"`
+	Max        *float64 `json:"max"`
+}
+
+type e2eResult struct {
+	check   string
+	failed  bool
+	details string
+}
+
+// e2eRun runs one collection of all enabled general collectors against the configured (sandbox) subscriptions,
+// checks the collected metric families and exits with exit code 1 if a check failed
+func e2eRun() {
+	expectations := map[string]e2eExpectation{}
+	if opts.E2e.Expect != "" {
+		content, err := ioutil.ReadFile(opts.E2e.Expect)
+		if err != nil {
+			log.Panic(err)
+		}
+		if err := json.Unmarshal(content, &expectations); err != nil {
+			log.Panicf("failed to parse %v: %v", opts.E2e.Expect, err)
+		}
+	}
+
+	collectorNameList := []string{}
+	for collectorName := range collectorGeneralList {
+		collectorNameList = append(collectorNameList, collectorName)
+	}
+	sort.Strings(collectorNameList)
+
+	// collectors run in parallel, dependent collectors wait for the shared lists
+	var wg sync.WaitGroup
+	for _, collectorName := range collectorNameList {
+		wg.Add(1)
+		go func(collector *CollectorGeneral) {
+			defer wg.Done()
+			collector.Collect()
+		}(collectorGeneralList[collectorName])
+	}
+	wg.Wait()
+
+	metricFamilies, err := metricsGather(prometheus.DefaultGatherer, false)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	metricFamilyMap := map[string]*dto.MetricFamily{}
+	for _, metricFamily := range metricFamilies {
+		metricFamilyMap[metricFamily.GetName()] = metricFamily
+	}
+
+	results := []e2eResult{}
+	for _, collectorName := range collectorNameList {
+		results = append(results, e2eCheckCollector(collectorGeneralList[collectorName], metricFamilies)...)
+	}
+
+	metricNameList := []string{}
+	for metricName := range expectations {
+		metricNameList = append(metricNameList, metricName)
+	}
+	sort.Strings(metricNameList)
+
+	for _, metricName := range metricNameList {
+		results = append(results, e2eCheckExpectation(metricName, expectations[metricName], metricFamilyMap[metricName]))
+	}
+
+	writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(writer, "CHECK\tRESULT\tDETAILS")
+
+	failedCount := 0
+	for _, result := range results {
+		status := "ok"
+		if result.failed {
+			status = "FAILED"
+			failedCount++
+		}
+		fmt.Fprintf(writer, "%v\t%v\t%v\n", result.check, status, result.details)
+	}
+
+	if err := writer.Flush(); err != nil {
+		log.Error(err)
+	}
+
+	if failedCount > 0 {
+		log.Errorf("e2e: %v of %v checks failed", failedCount, len(results))
+		os.Exit(1)
+	}
+
+	log.Infof("e2e: all %v checks passed", len(results))
+}
+
+// e2eCheckCollector checks that the collector collected all subscriptions and exported samples with finite values
+func e2eCheckCollector(collector *CollectorGeneral, metricFamilies []*dto.MetricFamily) []e2eResult {
+	results := []e2eResult{}
+
+	collector.collectionResultsLock.Lock()
+	failedSubscriptions := []string{}
+	for subscriptionId, collectionResults := range collector.collectionResults {
+		if len(collectionResults) == 0 || !collectionResults[len(collectionResults)-1] {
+			failedSubscriptions = append(failedSubscriptions, subscriptionId)
+		}
+	}
+	collector.collectionResultsLock.Unlock()
+	sort.Strings(failedSubscriptions)
+
+	collectionResult := e2eResult{check: collector.Name + ": collection", details: fmt.Sprintf("%v subscriptions", len(collector.GetAzureSubscriptions()))}
+	if len(failedSubscriptions) > 0 {
+		collectionResult.failed = true
+		collectionResult.details = "failed subscriptions: " + strings.Join(failedSubscriptions, ", ")
+	}
+	results = append(results, collectionResult)
+
+	sampleCount, invalidSamples := 0, []string{}
+	for _, metricFamily := range metricFamilies {
+		if metricsCollectorByMetric[metricFamily.GetName()] != strings.ToLower(collector.Name) {
+			continue
+		}
+
+		for _, metric := range metricFamily.Metric {
+			sampleCount++
+			if value := e2eMetricValue(metric); math.IsNaN(value) || math.IsInf(value, 0) {
+				invalidSamples = append(invalidSamples, metricFamily.GetName())
+			}
+		}
+	}
+
+	samplesResult := e2eResult{check: collector.Name + ": samples", details: fmt.Sprintf("%v samples", sampleCount)}
+	switch {
+	case sampleCount == 0:
+		samplesResult.failed = true
+		samplesResult.details = "no samples exported"
+	case len(invalidSamples) > 0:
+		samplesResult.failed = true
+		samplesResult.details = fmt.Sprintf("%v samples without finite value: %v", len(invalidSamples), strings.Join(invalidSamples, ", "))
+	}
+	results = append(results, samplesResult)
+
+	return results
+}
+
+// e2eCheckExpectation checks a metric family against the expectation of --e2e-expect (default: at least one sample)
+func e2eCheckExpectation(metricName string, expectation e2eExpectation, metricFamily *dto.MetricFamily) e2eResult {
+	result := e2eResult{check: metricName}
+
+	minSamples := 1
+	if expectation.MinSamples != nil {
+		minSamples = *expectation.MinSamples
+	}
+
+	sampleCount := 0
+	if metricFamily != nil {
+		sampleCount = len(metricFamily.Metric)
+	}
+	result.details = fmt.Sprintf("%v samples", sampleCount)
+
+	if sampleCount < minSamples {
+		result.failed = true
+		result.details = fmt.Sprintf("%v samples, expected at least %v", sampleCount, minSamples)
+		return result
+	}
+
+	if metricFamily == nil {
+		return result
+	}
+
+	for _, metric := range metricFamily.Metric {
+		value := e2eMetricValue(metric)
+		if (expectation.Min != nil && value < *expectation.Min) || (expectation.Max != nil && value > *expectation.Max) {
+			result.failed = true
+			result.details = fmt.Sprintf("value %v out of range (min: %v, max: %v)", value, e2eBoundString(expectation.Min), e2eBoundString(expectation.Max))
+			return result
+		}
+	}
+
+	return result
+}
+
+func e2eMetricValue(metric *dto.Metric) float64 {
+	switch {
+	case metric.Gauge != nil:
+		return metric.Gauge.GetValue()
+	case metric.Counter != nil:
+		return metric.Counter.GetValue()
+	}
+	return 0
+}
+
+func e2eBoundString(bound *float64) string {
+	if bound == nil {
+		return "-"
+	}
+	return fmt.Sprintf("%v", *bound)
+}
@@ -0,0 +1,257 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	eventGridHeaderEventType      = "aeg-event-type"
+	eventGridEventTypeValidation  = "Microsoft.EventGrid.SubscriptionValidationEvent"
+	eventGridEventTypeResourceKey = "Microsoft.Resources."
+
+	// Event Grid delivers batches up to 1 MB
+	eventGridMaxBodySize = 1024 * 1024
+)
+
+var (
+	// subscriptions waiting for the debounced collection and subscriptions being collected
+	eventGridPending     = map[string]*eventGridPendingCollection{}
+	eventGridRunning     = map[string]bool{}
+	eventGridPendingLock sync.Mutex
+
+	prometheusMetricEventGridEvents *prometheus.CounterVec
+)
+
+// eventGridPendingCollection is the debounced collection of a subscription
+type eventGridPendingCollection struct {
+	timer *time.Timer
+
+	// first event, further events postpone the collection until --eventgrid-max-delay
+	firstEvent time.Time
+
+	// changed resource groups, only these are listed again by collectors implementing eventGridResourceGroupsInterface
+	resourceGroups map[string]bool
+}
+
+// eventGridResourceGroupsInterface is implemented by processors which collect only the changed resource groups of
+// the events, other processors collect the whole subscription
+type eventGridResourceGroupsInterface interface {
+	EventGridResourceGroups(subscriptionId string, resourceGroups []string)
+}
+
+// eventGridEvent is an event of an Azure subscription system topic (Event Grid schema)
+type eventGridEvent struct {
+	ID        string `json:"id"`
+	Topic     string `json:"topic"`
+	Subject   string `json:"subject"`
+	EventType string `json:"eventType"`
+
+	Data struct {
+		ValidationCode string `json:"validationCode"`
+		SubscriptionId string `json:"subscriptionId"`
+		ResourceUri    string `json:"resourceUri"`
+	} `json:"data"`
+}
+
+// initEventGrid checks the collectors triggered by Event Grid events and registers the event metrics
+func initEventGrid() {
+	for _, collectorName := range opts.EventGrid.Collectors {
+		if eventGridCollector(collectorName) == nil {
+			log.WithField("collector", collectorName).Warnf("eventgrid: collector not enabled or not collected per subscription, events are ignored")
+		}
+	}
+
+	prometheusMetricEventGridEvents = metricsNewCounterVec(
+		prometheus.CounterOpts{
+			Name: "azurerm_eventgrid_events_total",
+			Help: "Azure Event Grid resource events received by the webhook (--eventgrid-token)",
+		},
+		[]string{
+			"subscriptionID",
+			"eventType",
+		},
+	)
+	prometheus.MustRegister(prometheusMetricEventGridEvents)
+	metricsConstLabelsCheck()
+}
+
+// eventGridHandler handles the Event Grid webhook (/eventgrid, token as "Authorization: Bearer <token>" header)
+// of subscription system topics, resource events trigger a collection of the changed subscription after
+// --eventgrid-delay without further events
+func eventGridHandler(w http.ResponseWriter, r *http.Request) {
+	// the token is not accepted as query parameter, urls end up in access logs and the event subscription
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if subtle.ConstantTimeCompare([]byte(token), []byte(opts.EventGrid.Token)) != 1 {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	logger := log.WithFields(log.Fields{"eventgrid": r.Header.Get(eventGridHeaderEventType), "remoteAddr": r.RemoteAddr})
+
+	var eventList []eventGridEvent
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, eventGridMaxBodySize)).Decode(&eventList); err != nil {
+		logger.Warnf("eventgrid: invalid request: %v", err)
+		http.Error(w, "invalid request", http.StatusBadRequest)
+		return
+	}
+
+	for _, event := range eventList {
+		switch {
+		case event.EventType == eventGridEventTypeValidation:
+			// handshake when the event subscription is created
+			logger.Infof("eventgrid: validated event subscription of topic %v", event.Topic)
+			adminWriteJson(w, map[string]string{"validationResponse": event.Data.ValidationCode})
+			return
+		case strings.HasPrefix(event.EventType, eventGridEventTypeResourceKey):
+			resourceId := event.Data.ResourceUri
+			if resourceId == "" {
+				resourceId = event.Subject
+			}
+
+			subscriptionId := strings.ToLower(event.Data.SubscriptionId)
+			if subscriptionId == "" {
+				subscriptionId = extractSubscriptionIdFromAzureId(resourceId)
+			}
+			if subscriptionId == "" {
+				continue
+			}
+
+			prometheusMetricEventGridEvents.With(prometheus.Labels{
+				"subscriptionID": subscriptionId,
+				"eventType":      event.EventType,
+			}).Inc()
+
+			eventGridSchedule(subscriptionId, extractResourceGroupFromAzureId(resourceId))
+		}
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// eventGridSchedule schedules the collection of the subscription (debounced): each event postpones the collection
+// by --eventgrid-delay, but not longer than --eventgrid-max-delay after the first event
+func eventGridSchedule(subscriptionId, resourceGroup string) {
+	eventGridPendingLock.Lock()
+	defer eventGridPendingLock.Unlock()
+
+	pending, exists := eventGridPending[subscriptionId]
+	if !exists {
+		pending = &eventGridPendingCollection{
+			firstEvent:     time.Now(),
+			resourceGroups: map[string]bool{},
+		}
+		pending.timer = time.AfterFunc(opts.EventGrid.Delay, func() {
+			eventGridCollect(subscriptionId, pending)
+		})
+		eventGridPending[subscriptionId] = pending
+	} else if delay := time.Until(pending.firstEvent.Add(opts.EventGrid.MaxDelay)); delay > 0 {
+		if delay > opts.EventGrid.Delay {
+			delay = opts.EventGrid.Delay
+		}
+		pending.timer.Reset(delay)
+	}
+
+	if resourceGroup != "" {
+		pending.resourceGroups[resourceGroup] = true
+	}
+}
+
+// eventGridCollect collects the subscription with the collectors of --eventgrid-collector,
+// the metrics of the other subscriptions are kept. Only one collection per subscription is running,
+// events received while collecting are collected by the next collection
+func eventGridCollect(subscriptionId string, pending *eventGridPendingCollection) {
+	eventGridPendingLock.Lock()
+	if eventGridPending[subscriptionId] != pending {
+		// already collected (timer was reset while firing)
+		eventGridPendingLock.Unlock()
+		return
+	}
+	if eventGridRunning[subscriptionId] {
+		// wait for the running collection
+		pending.timer.Reset(opts.EventGrid.Delay)
+		eventGridPendingLock.Unlock()
+		return
+	}
+	delete(eventGridPending, subscriptionId)
+	eventGridRunning[subscriptionId] = true
+	eventGridPendingLock.Unlock()
+
+	defer func() {
+		eventGridPendingLock.Lock()
+		delete(eventGridRunning, subscriptionId)
+		eventGridPendingLock.Unlock()
+	}()
+
+	resourceGroupList := []string{}
+	for resourceGroup := range pending.resourceGroups {
+		resourceGroupList = append(resourceGroupList, resourceGroup)
+	}
+	sort.Strings(resourceGroupList)
+
+	var wg sync.WaitGroup
+	for _, collectorName := range opts.EventGrid.Collectors {
+		collector := eventGridCollector(collectorName)
+		if collector == nil || collector.IsDisabled() {
+			continue
+		}
+
+		found := false
+		for _, subscription := range collector.GetAzureSubscriptions() {
+			if strings.EqualFold(*subscription.SubscriptionID, subscriptionId) {
+				found = true
+			}
+		}
+		if !found {
+			continue
+		}
+
+		collector.logger.WithField("azureSubscription", subscriptionId).Infof("eventgrid: collecting subscription, changed resourcegroups: %v", strings.Join(resourceGroupList, ", "))
+		if processor, ok := collector.Processor.(eventGridResourceGroupsInterface); ok {
+			processor.EventGridResourceGroups(subscriptionId, resourceGroupList)
+		}
+
+		wg.Add(1)
+		go func(collector *CollectorGeneral) {
+			defer wg.Done()
+			collector.CollectSubscription(subscriptionId)
+		}(collector)
+	}
+	wg.Wait()
+}
+
+// eventGridCollectsCollector checks if the collector is collected on Event Grid events (--eventgrid-collector)
+func eventGridCollectsCollector(name string) bool {
+	if opts.EventGrid.Token == "" {
+		return false
+	}
+
+	for _, collectorName := range opts.EventGrid.Collectors {
+		if strings.EqualFold(collectorName, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// eventGridCollector returns the (general) collector by name (case insensitive)
+func eventGridCollector(name string) *CollectorGeneral {
+	for collectorName, collector := range collectorGeneralList {
+		if strings.EqualFold(collectorName, name) {
+			return collector
+		}
+	}
+	return nil
+}
@@ -0,0 +1,365 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/Azure/go-autorest/autorest/azure"
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	generateDashboardFile = "azure-resourcemanager-exporter-dashboard.json"
+	generateRulesFile     = "azure-resourcemanager-exporter-rules.yaml"
+
+	// metrics of the exporter itself (collector status, rate limits)
+	generateExporterGroup = "Exporter"
+)
+
+var (
+	// prometheus.Desc has no accessors, the metadata is parsed from the description
+	metricDescRegexp = regexp.MustCompile(`^Desc\{fqName: ("(?:[^"\\]|\\.)*"), help: ("(?:[^"\\]|\\.)*"), constLabels: \{.*\}, variableLabels: \[(.*)\]\}$`)
+)
+
+// metricMetadata is the name, help and the label names of a registered metric
+type metricMetadata struct {
+	Name      string
+	Help      string
+	Labels    []string
+	Collector string
+}
+
+// metricsMetadataRegisterer records the metadata of all metrics registered by the collectors (generate-dashboards)
+type metricsMetadataRegisterer struct {
+	prometheus.Registerer
+
+	metadata *metricsMetadataList
+}
+
+// metricsMetadataList is the recorded metadata, shared by the registerers of all collectors
+type metricsMetadataList struct {
+	list []metricMetadata
+	lock sync.Mutex
+}
+
+func (r *metricsMetadataRegisterer) Register(collector prometheus.Collector) error {
+	if err := r.Registerer.Register(collector); err != nil {
+		return err
+	}
+
+	descChannel := make(chan *prometheus.Desc)
+	go func() {
+		collector.Describe(descChannel)
+		close(descChannel)
+	}()
+
+	r.metadata.lock.Lock()
+	defer r.metadata.lock.Unlock()
+	for desc := range descChannel {
+		match := metricDescRegexp.FindStringSubmatch(desc.String())
+		if match == nil {
+			continue
+		}
+
+		name, _ := strconv.Unquote(match[1])
+		help, _ := strconv.Unquote(match[2])
+		r.metadata.list = append(r.metadata.list, metricMetadata{Name: name, Help: help, Labels: strings.Fields(match[3])})
+	}
+
+	return nil
+}
+
+func (r *metricsMetadataRegisterer) MustRegister(collectors ...prometheus.Collector) {
+	for _, collector := range collectors {
+		if err := r.Register(collector); err != nil {
+			panic(err)
+		}
+	}
+}
+
+// generateDashboards sets up the enabled collectors (without collecting) and writes a Grafana dashboard and
+// Prometheus alert rules generated from the metadata of the registered metrics
+func generateDashboards() {
+	var err error
+	azureEnvironment, err = azure.EnvironmentFromName(*opts.Azure.Environment)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	metadata := &metricsMetadataList{}
+	prometheus.DefaultRegisterer = &metricsMetadataRegisterer{Registerer: prometheus.DefaultRegisterer, metadata: metadata}
+	collectorRegisterer := metricsCollectorRegisterer
+	metricsCollectorRegisterer = func(collectorName string) prometheus.Registerer {
+		return &metricsMetadataRegisterer{Registerer: collectorRegisterer(collectorName), metadata: metadata}
+	}
+	initMetricCollector()
+
+	collectorNames := map[string]string{}
+	for collectorName := range collectorGeneralList {
+		collectorNames[strings.ToLower(collectorName)] = collectorName
+	}
+	for collectorName := range collectorCustomList {
+		collectorNames[strings.ToLower(collectorName)] = collectorName
+	}
+
+	groups := map[string][]metricMetadata{}
+	for _, metadata := range metadata.list {
+		if !metricsFilterAllowed(metadata.Name) {
+			continue
+		}
+
+		metadata.Collector = generateExporterGroup
+		if collectorName, exists := collectorNames[metricsCollectorByMetric[metadata.Name]]; exists {
+			metadata.Collector = collectorName
+		}
+		metadata.Name = metricsExportName(metadata.Name)
+
+		groups[metadata.Collector] = append(groups[metadata.Collector], metadata)
+	}
+
+	groupNames := []string{}
+	for groupName := range groups {
+		groupNames = append(groupNames, groupName)
+	}
+	sort.Strings(groupNames)
+
+	dashboard, err := json.MarshalIndent(generateGrafanaDashboard(groupNames, groups), "", "  ")
+	if err != nil {
+		log.Panic(err)
+	}
+	generateWriteFile(generateDashboardFile, dashboard)
+	generateWriteFile(generateRulesFile, generateAlertRules(groupNames, groups))
+}
+
+func generateWriteFile(fileName string, content []byte) {
+	path := filepath.Join(opts.GenerateDashboards.Output, fileName)
+	if err := ioutil.WriteFile(path, content, 0644); err != nil { // #nosec
+		log.Panic(err)
+	}
+	log.Infof("written %v", path)
+}
+
+// generateGrafanaDashboard builds a dashboard with one row per collector and one panel per metric,
+// the panels are filtered by subscription and the tag labels (--azure.resource-tag) of the metrics
+func generateGrafanaDashboard(groupNames []string, groups map[string][]metricMetadata) map[string]interface{} {
+	// first metric with the label is used for the label values of the variable
+	variableMetrics := map[string]string{}
+	variableNames := []string{}
+	for _, groupName := range groupNames {
+		for _, metadata := range groups[groupName] {
+			for _, label := range metadata.Labels {
+				if label != "subscriptionID" && !strings.HasPrefix(label, AZURE_RESOURCE_TAG_PREFIX) {
+					continue
+				}
+				if _, exists := variableMetrics[label]; !exists {
+					variableMetrics[label] = metadata.Name
+					variableNames = append(variableNames, label)
+				}
+			}
+		}
+	}
+	if subscriptionMetric := metricsExportName("azurerm_subscription_info"); variableMetrics["subscriptionID"] != "" {
+		for _, metadata := range groups["General"] {
+			if metadata.Name == subscriptionMetric {
+				variableMetrics["subscriptionID"] = subscriptionMetric
+			}
+		}
+	}
+	sort.Strings(variableNames)
+
+	variables := []interface{}{
+		map[string]interface{}{
+			"name":    "datasource",
+			"label":   "Datasource",
+			"type":    "datasource",
+			"query":   "prometheus",
+			"current": map[string]interface{}{"text": opts.GenerateDashboards.Datasource, "value": opts.GenerateDashboards.Datasource},
+		},
+	}
+	for _, label := range variableNames {
+		variables = append(variables, map[string]interface{}{
+			"name":       label,
+			"label":      label,
+			"type":       "query",
+			"datasource": "$datasource",
+			"query":      fmt.Sprintf("label_values(%v, %v)", variableMetrics[label], label),
+			"refresh":    2,
+			"multi":      true,
+			"includeAll": true,
+			"allValue":   ".*",
+			"current":    map[string]interface{}{"text": "All", "value": "$__all"},
+		})
+	}
+
+	panels := []interface{}{}
+	panelId, posY := 1, 0
+	for _, groupName := range groupNames {
+		panels = append(panels, map[string]interface{}{
+			"id":        panelId,
+			"type":      "row",
+			"title":     groupName,
+			"collapsed": false,
+			"gridPos":   map[string]int{"h": 1, "w": 24, "x": 0, "y": posY},
+			"panels":    []interface{}{},
+		})
+		panelId++
+		posY++
+
+		for i, metadata := range groups[groupName] {
+			panels = append(panels, generateGrafanaPanel(panelId, metadata, map[string]int{"h": 8, "w": 12, "x": (i % 2) * 12, "y": posY + (i/2)*8}))
+			panelId++
+		}
+		posY += (len(groups[groupName]) + 1) / 2 * 8
+	}
+
+	return map[string]interface{}{
+		"title":         "Azure ResourceManager",
+		"uid":           "azure-resourcemanager-exporter",
+		"tags":          []string{"azure", "azure-resourcemanager-exporter"},
+		"schemaVersion": 27,
+		"editable":      true,
+		"time":          map[string]string{"from": "now-24h", "to": "now"},
+		"templating":    map[string]interface{}{"list": variables},
+		"panels":        panels,
+	}
+}
+
+// generateGrafanaPanel builds the panel of a metric: info metrics as table, expiry timestamps as days until expiry
+// and other metrics as time series per subscription
+func generateGrafanaPanel(panelId int, metadata metricMetadata, gridPos map[string]int) map[string]interface{} {
+	selector := generateSelector(metadata, func(label string) bool {
+		return label == "subscriptionID" || strings.HasPrefix(label, AZURE_RESOURCE_TAG_PREFIX)
+	}, func(label string) string {
+		return fmt.Sprintf("%v=~\"$%v\"", label, label)
+	})
+
+	panel := map[string]interface{}{
+		"id":          panelId,
+		"title":       metadata.Name,
+		"description": metadata.Help,
+		"datasource":  "$datasource",
+		"gridPos":     gridPos,
+	}
+
+	switch {
+	case strings.HasSuffix(metadata.Name, "_info"):
+		panel["type"] = "table"
+		panel["targets"] = []interface{}{
+			map[string]interface{}{"refId": "A", "expr": metadata.Name + selector, "instant": true, "format": "table"},
+		}
+	case strings.HasSuffix(metadata.Name, "_expiry"):
+		panel["type"] = "table"
+		panel["title"] = metadata.Name + " (days until expiry)"
+		panel["targets"] = []interface{}{
+			map[string]interface{}{"refId": "A", "expr": fmt.Sprintf("(%v%v - time()) / 86400", metadata.Name, selector), "instant": true, "format": "table"},
+		}
+	default:
+		expr := metadata.Name + selector
+		if generateHasLabel(metadata, "subscriptionID") {
+			expr = fmt.Sprintf("sum by (subscriptionID) (%v)", expr)
+		}
+		panel["type"] = "timeseries"
+		panel["targets"] = []interface{}{
+			map[string]interface{}{"refId": "A", "expr": expr, "legendFormat": "{{subscriptionID}}"},
+		}
+	}
+
+	return panel
+}
+
+// generateAlertRules builds Prometheus alert rules for the collector status and for metrics with expiry timestamps
+func generateAlertRules(groupNames []string, groups map[string][]metricMetadata) []byte {
+	quote := func(value string) string {
+		ret, _ := json.Marshal(value)
+		return string(ret)
+	}
+
+	type alertRule struct {
+		name, expr, duration, severity, summary string
+	}
+
+	rules := map[string][]alertRule{}
+	for _, groupName := range groupNames {
+		for _, metadata := range groups[groupName] {
+			switch {
+			case metadata.Name == metricsExportName("azurerm_collector_success_ratio"):
+				rules[groupName] = append(rules[groupName], alertRule{"AzureRmCollectorFailing", metadata.Name + " < 1", "1h", "warning", "Collector {{ $labels.collector }} failed for subscription {{ $labels.subscriptionID }}"})
+			case metadata.Name == metricsExportName("azurerm_collector_permission_missing"):
+				rules[groupName] = append(rules[groupName], alertRule{"AzureRmCollectorPermissionMissing", metadata.Name + " == 1", "1h", "warning", "Collector {{ $labels.collector }} was denied access for subscription {{ $labels.subscriptionID }}"})
+			case metadata.Name == metricsExportName("azurerm_collector_skipped"):
+				rules[groupName] = append(rules[groupName], alertRule{"AzureRmCollectorSkipped", metadata.Name + " > 0", "0m", "warning", "List calls of {{ $labels.resourceType }} are skipped for subscription {{ $labels.subscriptionID }} (budget exceeded)"})
+			case strings.HasSuffix(metadata.Name, "_expiry"):
+				rules[groupName] = append(rules[groupName], alertRule{
+					"AzureRm" + generateCamelCase(strings.TrimSuffix(strings.TrimPrefix(metadata.Name, metricsExportName("azurerm_")), "_expiry")) + "Expiring",
+					fmt.Sprintf("(%v - time()) < %v * 86400 and %v > 0", metadata.Name, opts.GenerateDashboards.ExpiryDays, metadata.Name),
+					"0m",
+					"warning",
+					metadata.Help + " expires in {{ $value | humanizeDuration }}",
+				})
+			}
+		}
+	}
+
+	content := bytes.Buffer{}
+	content.WriteString("# generated by azure-resourcemanager-exporter generate-dashboards\n")
+	content.WriteString("groups:\n")
+	for _, groupName := range groupNames {
+		if len(rules[groupName]) == 0 {
+			continue
+		}
+
+		content.WriteString(fmt.Sprintf("  - name: %v\n    rules:\n", quote("azure-resourcemanager-exporter-"+strings.ToLower(groupName))))
+		for _, rule := range rules[groupName] {
+			content.WriteString(fmt.Sprintf("      - alert: %v\n", rule.name))
+			content.WriteString(fmt.Sprintf("        expr: %v\n", quote(rule.expr)))
+			content.WriteString(fmt.Sprintf("        for: %v\n", rule.duration))
+			content.WriteString(fmt.Sprintf("        labels:\n          severity: %v\n", rule.severity))
+			content.WriteString(fmt.Sprintf("        annotations:\n          summary: %v\n", quote(rule.summary)))
+		}
+	}
+
+	return content.Bytes()
+}
+
+// generateSelector builds the label selector of the metric for the labels matching the filter
+func generateSelector(metadata metricMetadata, filter func(label string) bool, matcher func(label string) string) string {
+	matchers := []string{}
+	for _, label := range metadata.Labels {
+		if filter(label) {
+			matchers = append(matchers, matcher(label))
+		}
+	}
+
+	if len(matchers) == 0 {
+		return ""
+	}
+	return "{" + strings.Join(matchers, ",") + "}"
+}
+
+func generateHasLabel(metadata metricMetadata, labelName string) bool {
+	for _, label := range metadata.Labels {
+		if label == labelName {
+			return true
+		}
+	}
+	return false
+}
+
+// generateCamelCase converts a metric name part (eg. keyvault_certificate) to camel case (KeyvaultCertificate)
+func generateCamelCase(value string) string {
+	parts := strings.Split(value, "_")
+	for i, part := range parts {
+		if part != "" {
+			parts[i] = strings.ToUpper(part[:1]) + part[1:]
+		}
+	}
+	return strings.Join(parts, "")
+}
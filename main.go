@@ -12,6 +12,7 @@ import (
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	log "github.com/sirupsen/logrus"
 	"github.com/webdevops/azure-resourcemanager-exporter/config"
+	"net"
 	"net/http"
 	"os"
 	"path"
@@ -36,15 +37,42 @@ var (
 
 	azureResourceGroupTags AzureTagFilter
 	azureResourceTags      AzureTagFilter
+	azureSubscriptionTags  AzureTagFilter
 	azureEnvironment       azure.Environment
 	portscanPortRange      []Portrange
+	monitorMetricList      []MonitorMetric
+	publicIpAllowList      []*net.IPNet
+	publicIpDenyList       []*net.IPNet
+	metricsConstLabels     map[string]prometheus.Labels
+	metricsHelpOverride    map[string]string
+	metricsGroups          []metricsGroup
+	metricsAllowList       map[string][]string
+	metricsDenyList        map[string][]string
+	blackoutWindows        []blackoutWindow
+
+	// labels of --subscription-mapping per subscription (lowercase id) and sorted label names
+	subscriptionMappingLabels     map[string]map[string]string
+	subscriptionMappingLabelNames []string
 
 	collectorGeneralList map[string]*CollectorGeneral
 	collectorCustomList  map[string]*CollectorCustom
 
-	prometheusMetricApiQuota *prometheus.GaugeVec
+	// collectors are only set up for the metric metadata (generate-dashboards command)
+	generateDashboardsMode bool
+
+	prometheusMetricApiQuota              *prometheus.GaugeVec
+	prometheusMetricCollectorSuccessRatio *prometheus.GaugeVec
+	prometheusMetricPermissionMissing     *prometheus.GaugeVec
+	prometheusMetricCollectorDisabled     *prometheus.GaugeVec
+	prometheusMetricCollectorSkipped      *prometheus.GaugeVec
+
+	portrangeRegexp        = regexp.MustCompile("^(?P<first>[0-9]+)(-(?P<last>[0-9]+))?$")
+	metricsLabelNameRegexp = regexp.MustCompile("^[a-zA-Z_][a-zA-Z0-9_]*$")
+	monitorMetricRegexp    = regexp.MustCompile("^(?P<resourceType>[^:/]+/[^:]+):(?P<metric>[^:]+)(:(?P<aggregation>[a-zA-Z]+))?$")
 
-	portrangeRegexp = regexp.MustCompile("^(?P<first>[0-9]+)(-(?P<last>[0-9]+))?$")
+	// labels of the exporter metrics (azurerm_collector_*, azurerm_ratelimit, ...) and the common resource labels,
+	// --metrics.const-label for all collectors must not use these names (duplicate labels fail the registration)
+	metricsReservedLabelNames = []string{"collector", "subscriptionID", "resourceID", "resourceGroup", "resourceType", "scope", "type", "eventType", "version", "commit", "goVersion", "configHash"}
 
 	// Git version information
 	gitCommit = "<unknown>"
@@ -56,18 +84,82 @@ type Portrange struct {
 	LastPort  int
 }
 
+type MonitorMetric struct {
+	ResourceType string
+	Metric       string
+	Aggregation  string
+}
+
 func main() {
 	initArgparser()
 
 	log.Infof("starting azure-resourcemanager-exporter v%s (%s; %s; by %v)", gitTag, gitCommit, runtime.Version(), Author)
 	log.Info(string(opts.GetJson()))
 
+	if generateDashboardsMode {
+		log.Infof("generating dashboard and alert rules")
+		generateDashboards()
+		return
+	}
+
+	// --scrape-budget hooks into the transport of the Azure clients, so it's registered before the first request
+	initCollectorBudgets()
+
 	log.Infof("init Azure connection")
 	initAzureConnection()
 
+	if opts.ConfigWatch.Path != "" {
+		log.Infof("init config from %v", opts.ConfigWatch.Path)
+		initConfigWatch()
+	}
+
+	if opts.Metrics.HierarchyLabels || opts.Azure.TagInheritance {
+		log.Infof("init Azure hierarchy cache")
+		initAzureHierarchy()
+	}
+
+	if opts.Snapshot.Url != "" {
+		log.Infof("init inventory snapshot writer")
+		initSnapshotWriter()
+	}
+
+	if opts.Portscan.Enabled && opts.Portscan.Logs.Endpoint != "" {
+		log.Infof("init portscan findings sinks")
+		initPortscannerSinks()
+	}
+
 	log.Infof("starting metrics collection")
 	initMetricCollector()
 
+	// validate --metrics.group against the enabled collectors
+	if err := argparserValidateMetricsGroups(); err != nil {
+		fmt.Fprintf(os.Stderr, "%v%v\n", "[ERROR] ", err.Error())
+		fmt.Println()
+		argparser.WriteHelp(os.Stdout)
+		os.Exit(1)
+	}
+
+	if opts.ConfigWatch.Path != "" && !opts.ProfileCollection && !opts.E2e.Enabled {
+		startConfigWatch()
+	}
+
+	if opts.EventGrid.Token != "" && !opts.ProfileCollection && !opts.E2e.Enabled {
+		log.Infof("init Event Grid webhook")
+		initEventGrid()
+	}
+
+	if opts.ProfileCollection {
+		log.Infof("profiling metrics collection")
+		collectionProfile()
+		return
+	}
+
+	if opts.E2e.Enabled {
+		log.Infof("running e2e checks")
+		e2eRun()
+		return
+	}
+
 	log.Infof("starting http server on %s", opts.ServerBind)
 	startHttpServer()
 }
@@ -75,6 +167,7 @@ func main() {
 // init argparser and parse/validate arguments
 func initArgparser() {
 	argparser = flags.NewParser(&opts, flags.Default)
+	argparser.SubcommandsOptional = true
 	_, err := argparser.Parse()
 
 	// check if there is an parse error
@@ -88,6 +181,8 @@ func initArgparser() {
 		}
 	}
 
+	generateDashboardsMode = argparser.Active != nil && argparser.Active.Name == "generate-dashboards"
+
 	// verbose level
 	if opts.Logger.Verbose {
 		log.SetLevel(log.DebugLevel)
@@ -130,6 +225,89 @@ func initArgparser() {
 		}
 	}
 
+	if len(opts.Monitor.Metrics) > 0 {
+		// parse --monitor-metric
+		err := argparserParseMonitorMetrics()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v%v\n", "[ERROR] ", err.Error())
+			fmt.Println()
+			argparser.WriteHelp(os.Stdout)
+			os.Exit(1)
+		}
+	}
+
+	// parse --publicip-allow-cidr and --publicip-deny-cidr
+	if err := argparserParsePublicIpCidrs(); err != nil {
+		fmt.Fprintf(os.Stderr, "%v%v\n", "[ERROR] ", err.Error())
+		fmt.Println()
+		argparser.WriteHelp(os.Stdout)
+		os.Exit(1)
+	}
+
+	// parse --vm-image-eol
+	if err := argparserParseVmImageEol(); err != nil {
+		fmt.Fprintf(os.Stderr, "%v%v\n", "[ERROR] ", err.Error())
+		fmt.Println()
+		argparser.WriteHelp(os.Stdout)
+		os.Exit(1)
+	}
+
+	// parse --scrape-budget
+	if err := argparserParseCollectorBudgets(); err != nil {
+		fmt.Fprintf(os.Stderr, "%v%v\n", "[ERROR] ", err.Error())
+		fmt.Println()
+		argparser.WriteHelp(os.Stdout)
+		os.Exit(1)
+	}
+
+	// parse --metrics.const-label and --metrics.help
+	if err := argparserParseMetricsOverrides(); err != nil {
+		fmt.Fprintf(os.Stderr, "%v%v\n", "[ERROR] ", err.Error())
+		fmt.Println()
+		argparser.WriteHelp(os.Stdout)
+		os.Exit(1)
+	}
+
+	// parse --metrics.group
+	if err := argparserParseMetricsGroups(); err != nil {
+		fmt.Fprintf(os.Stderr, "%v%v\n", "[ERROR] ", err.Error())
+		fmt.Println()
+		argparser.WriteHelp(os.Stdout)
+		os.Exit(1)
+	}
+
+	// parse --metrics.allow and --metrics.deny
+	if err := argparserParseMetricsFilter(); err != nil {
+		fmt.Fprintf(os.Stderr, "%v%v\n", "[ERROR] ", err.Error())
+		fmt.Println()
+		argparser.WriteHelp(os.Stdout)
+		os.Exit(1)
+	}
+
+	// parse --blackout-window
+	if err := argparserParseBlackoutWindows(); err != nil {
+		fmt.Fprintf(os.Stderr, "%v%v\n", "[ERROR] ", err.Error())
+		fmt.Println()
+		argparser.WriteHelp(os.Stdout)
+		os.Exit(1)
+	}
+
+	// parse --subscription-mapping
+	if err := argparserParseSubscriptionMapping(); err != nil {
+		fmt.Fprintf(os.Stderr, "%v%v\n", "[ERROR] ", err.Error())
+		fmt.Println()
+		argparser.WriteHelp(os.Stdout)
+		os.Exit(1)
+	}
+
+	// --mock-azure-record needs the fixture directory
+	if opts.Mock.Record && opts.Mock.Path == "" {
+		fmt.Fprintf(os.Stderr, "%v%v\n", "[ERROR] ", "--mock-azure-record needs a fixture directory (--mock-azure)")
+		fmt.Println()
+		argparser.WriteHelp(os.Stdout)
+		os.Exit(1)
+	}
+
 	if opts.Cache.Path != "" {
 		cacheDirectory := filepath.Dir(opts.Cache.Path)
 		if _, err := os.Stat(cacheDirectory); os.IsNotExist(err) {
@@ -186,19 +364,150 @@ func initArgparser() {
 		opts.Scrape.TimeGraph = &opts.Scrape.Time
 	}
 
+	if opts.Scrape.TimeDeleted == nil {
+		opts.Scrape.TimeDeleted = &opts.Scrape.Time
+	}
+
+	if opts.Scrape.TimeVirtualWan == nil {
+		opts.Scrape.TimeVirtualWan = &opts.Scrape.Time
+	}
+
+	if opts.Scrape.TimeSpringApps == nil {
+		opts.Scrape.TimeSpringApps = &opts.Scrape.Time
+	}
+
+	if opts.Scrape.TimeServiceFabric == nil {
+		opts.Scrape.TimeServiceFabric = &opts.Scrape.Time
+	}
+
+	if opts.Scrape.TimeMessaging == nil {
+		opts.Scrape.TimeMessaging = &opts.Scrape.Time
+	}
+
+	if opts.Scrape.TimeMonitor == nil {
+		opts.Scrape.TimeMonitor = &opts.Scrape.Time
+	}
+
+	if opts.Scrape.TimeAlertCoverage == nil {
+		opts.Scrape.TimeAlertCoverage = &opts.Scrape.Time
+	}
+
+	if opts.Scrape.TimePolicy == nil {
+		opts.Scrape.TimePolicy = &opts.Scrape.Time
+	}
+
+	if opts.Scrape.TimeDeploymentStack == nil {
+		opts.Scrape.TimeDeploymentStack = &opts.Scrape.Time
+	}
+
+	if opts.Scrape.TimePublicIp == nil {
+		opts.Scrape.TimePublicIp = &opts.Scrape.Time
+	}
+
+	if opts.Scrape.TimeNetwork == nil {
+		opts.Scrape.TimeNetwork = &opts.Scrape.Time
+	}
+
+	if opts.Scrape.TimeExpressRoute == nil {
+		opts.Scrape.TimeExpressRoute = &opts.Scrape.Time
+	}
+
+	if opts.Scrape.TimeFirewall == nil {
+		opts.Scrape.TimeFirewall = &opts.Scrape.Time
+	}
+
+	if opts.Scrape.TimeVirtualMachine == nil {
+		opts.Scrape.TimeVirtualMachine = &opts.Scrape.Time
+	}
+
+	if opts.Scrape.TimeHybridBenefit == nil {
+		opts.Scrape.TimeHybridBenefit = &opts.Scrape.Time
+	}
+
+	if opts.Scrape.TimeZone == nil {
+		opts.Scrape.TimeZone = &opts.Scrape.Time
+	}
+
+	if opts.Scrape.TimeKeyVault == nil {
+		opts.Scrape.TimeKeyVault = &opts.Scrape.Time
+	}
+
+	if opts.Scrape.TimeStorage == nil {
+		opts.Scrape.TimeStorage = &opts.Scrape.Time
+	}
+
+	if opts.Scrape.TimeExposure == nil {
+		opts.Scrape.TimeExposure = &opts.Scrape.Time
+	}
+
+	if opts.Scrape.TimeDevOps == nil {
+		opts.Scrape.TimeDevOps = &opts.Scrape.Time
+	}
+
+	if opts.Scrape.TimeDependency == nil {
+		opts.Scrape.TimeDependency = &opts.Scrape.Time
+	}
+
+	if opts.Scrape.TimeCompute == nil {
+		opts.Scrape.TimeCompute = &opts.Scrape.Time
+	}
+
+	if opts.Scrape.TimeDisk == nil {
+		opts.Scrape.TimeDisk = &opts.Scrape.Time
+	}
+
+	if opts.Scrape.TimeAppService == nil {
+		opts.Scrape.TimeAppService = &opts.Scrape.Time
+	}
+
+	if opts.Scrape.TimeAppGateway == nil {
+		opts.Scrape.TimeAppGateway = &opts.Scrape.Time
+	}
+
+	if opts.Scrape.TimeOrphaned == nil {
+		opts.Scrape.TimeOrphaned = &opts.Scrape.Time
+	}
+
+	if opts.Scrape.TimeReservation == nil {
+		opts.Scrape.TimeReservation = &opts.Scrape.Time
+	}
+
+	if opts.Scrape.TimeSql == nil {
+		opts.Scrape.TimeSql = &opts.Scrape.Time
+	}
+
+	if opts.Scrape.TimeCosmosDb == nil {
+		opts.Scrape.TimeCosmosDb = &opts.Scrape.Time
+	}
+
+	if opts.Scrape.TimeServicePrincipal == nil {
+		opts.Scrape.TimeServicePrincipal = &opts.Scrape.Time
+	}
+
+	if opts.Scrape.TimeSiteRecovery == nil {
+		opts.Scrape.TimeSiteRecovery = &opts.Scrape.Time
+	}
+
+	if opts.Scrape.TimeCdn == nil {
+		opts.Scrape.TimeCdn = &opts.Scrape.Time
+	}
+
 	azureResourceGroupTags = NewAzureTagFilter(AZURE_RESOURCE_TAG_PREFIX, opts.Azure.ResourceGroupTags)
 	azureResourceTags = NewAzureTagFilter(AZURE_RESOURCE_TAG_PREFIX, opts.Azure.ResourceTags)
+	azureSubscriptionTags = NewAzureTagFilter(AZURE_RESOURCE_TAG_PREFIX, opts.Azure.SubscriptionTags)
+
+	if opts.Azure.TagInheritance {
+		azureResourceGroupTags.enableTagInheritance()
+		azureResourceTags.enableTagInheritance()
+	}
 
 	// check deprecated env vars
 	deprecatedEnvVars := map[string]string{
 		"SCRAPE_TIME_CONTAINERREGISTRY": "not supported anymore",
 		"SCRAPE_TIME_CONTAINERINSTANCE": "not supported anymore",
 		"SCRAPE_TIME_EVENTHUB":          "not supported anymore",
-		"SCRAPE_TIME_STORAGE":           "not supported anymore",
-		"SCRAPE_TIME_COMPUTE":           "not supported anymore",
-		"SCRAPE_TIME_NETWORK":           "not supported anymore",
 		"SCRAPE_TIME_DATABASE":          "not supported anymore",
-		"SCRAPE_TIME_COMPUTING":         "deprecated, please use SCRAPE_TIME_COMPUTE",
+		"SCRAPE_TIME_COMPUTING":         "renamed, please use SCRAPE_TIME_COMPUTE (--scrape-time-compute) instead",
 	}
 	for envVar, reason := range deprecatedEnvVars {
 		if os.Getenv(envVar) != "" {
@@ -212,12 +521,28 @@ func initAzureConnection() {
 	var err error
 	ctx := context.Background()
 
-	// setup azure authorizer
-	AzureAuthorizer, err = auth.NewAuthorizerFromEnvironment()
+	azureEnvironment, err = azure.EnvironmentFromName(*opts.Azure.Environment)
 	if err != nil {
 		log.Panic(err)
 	}
-	subscriptionsClient := subscriptions.NewClient()
+
+	// setup azure authorizer
+	if opts.Mock.Path != "" && !opts.Mock.Record {
+		// fixtures don't need authentication
+		AzureAuthorizer = autorest.NullAuthorizer{}
+	} else {
+		AzureAuthorizer, err = auth.NewAuthorizerFromEnvironment()
+		if err != nil {
+			log.Panic(err)
+		}
+	}
+
+	// --mock-azure
+	if opts.Mock.Path != "" {
+		initAzureMock()
+	}
+
+	subscriptionsClient := subscriptions.NewClientWithBaseURI(azureEnvironment.ResourceManagerEndpoint)
 	subscriptionsClient.Authorizer = AzureAuthorizer
 
 	if len(opts.Azure.Subscription) == 0 {
@@ -242,11 +567,6 @@ func initAzureConnection() {
 			AzureSubscriptions = append(AzureSubscriptions, result)
 		}
 	}
-
-	azureEnvironment, err = azure.EnvironmentFromName(*opts.Azure.Environment)
-	if err != nil {
-		log.Panic(err)
-	}
 }
 
 func initMetricCollector() {
@@ -254,7 +574,7 @@ func initMetricCollector() {
 	collectorGeneralList = map[string]*CollectorGeneral{}
 	collectorCustomList = map[string]*CollectorCustom{}
 
-	prometheusMetricApiQuota = prometheus.NewGaugeVec(
+	prometheusMetricApiQuota = metricsNewGaugeVec(
 		prometheus.GaugeOpts{
 			Name: "azurerm_ratelimit",
 			Help: "Azure ResourceManager ratelimit",
@@ -267,6 +587,80 @@ func initMetricCollector() {
 	)
 	prometheus.MustRegister(prometheusMetricApiQuota)
 
+	prometheusMetricCollectorSuccessRatio = metricsNewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_collector_success_ratio",
+			Help: "Azure ResourceManager collector success ratio (successful collections/attempts of the last runs)",
+		},
+		[]string{
+			"collector",
+			"subscriptionID",
+		},
+	)
+	prometheus.MustRegister(prometheusMetricCollectorSuccessRatio)
+
+	prometheusMetricBuildInfo := metricsNewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_exporter_build_info",
+			Help: "Azure ResourceManager exporter build information (version, commit, go version and hash of the startup config)",
+		},
+		[]string{
+			"version",
+			"commit",
+			"goVersion",
+			"configHash",
+		},
+	)
+	prometheus.MustRegister(prometheusMetricBuildInfo)
+	prometheusMetricBuildInfo.With(prometheus.Labels{
+		"version":    gitTag,
+		"commit":     gitCommit,
+		"goVersion":  runtime.Version(),
+		"configHash": opts.GetHash(),
+	}).Set(1)
+
+	if opts.Metrics.DisableRuntime {
+		prometheus.Unregister(prometheus.NewGoCollector())
+		prometheus.Unregister(prometheus.NewProcessCollector(prometheus.ProcessCollectorOpts{}))
+	}
+
+	prometheusMetricPermissionMissing = metricsNewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_collector_permission_missing",
+			Help: "Azure ResourceManager collector was denied access (403) for the subscription in the last run",
+		},
+		[]string{
+			"collector",
+			"subscriptionID",
+		},
+	)
+	prometheus.MustRegister(prometheusMetricPermissionMissing)
+
+	prometheusMetricCollectorDisabled = metricsNewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_collector_disabled",
+			Help: "Azure ResourceManager collector disabled for the subscription by --scrape-soft-fail (access denied in the first run)",
+		},
+		[]string{
+			"collector",
+			"subscriptionID",
+		},
+	)
+	prometheus.MustRegister(prometheusMetricCollectorDisabled)
+
+	prometheusMetricCollectorSkipped = metricsNewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_collector_skipped",
+			Help: "Azure ResourceManager list calls of the resource type skipped for the subscription until this timestamp after repeatedly exceeding --scrape-budget (0 if collected)",
+		},
+		[]string{
+			"subscriptionID",
+			"resourceType",
+		},
+	)
+	prometheus.MustRegister(prometheusMetricCollectorSkipped)
+	metricsConstLabelsCheck()
+
 	collectorName = "General"
 	if opts.Scrape.TimeGeneral.Seconds() > 0 {
 		collectorGeneralList[collectorName] = NewCollectorGeneral(collectorName, &MetricsCollectorAzureRmGeneral{})
@@ -338,6 +732,262 @@ func initMetricCollector() {
 		log.WithField("collector", collectorName).Infof("collector disabled")
 	}
 
+	collectorName = "Deleted"
+	if opts.Scrape.TimeDeleted.Seconds() > 0 {
+		collectorGeneralList[collectorName] = NewCollectorGeneral(collectorName, &MetricsCollectorAzureRmDeleted{})
+		collectorGeneralList[collectorName].Run(*opts.Scrape.TimeDeleted)
+	} else {
+		log.WithField("collector", collectorName).Infof("collector disabled")
+	}
+
+	collectorName = "VirtualWan"
+	if opts.Scrape.TimeVirtualWan.Seconds() > 0 {
+		collectorGeneralList[collectorName] = NewCollectorGeneral(collectorName, &MetricsCollectorAzureRmVirtualWan{})
+		collectorGeneralList[collectorName].Run(*opts.Scrape.TimeVirtualWan)
+	} else {
+		log.WithField("collector", collectorName).Infof("collector disabled")
+	}
+
+	collectorName = "SpringApps"
+	if opts.Scrape.TimeSpringApps.Seconds() > 0 {
+		collectorGeneralList[collectorName] = NewCollectorGeneral(collectorName, &MetricsCollectorAzureRmSpringApps{})
+		collectorGeneralList[collectorName].Run(*opts.Scrape.TimeSpringApps)
+	} else {
+		log.WithField("collector", collectorName).Infof("collector disabled")
+	}
+
+	collectorName = "ServiceFabric"
+	if opts.Scrape.TimeServiceFabric.Seconds() > 0 {
+		collectorGeneralList[collectorName] = NewCollectorGeneral(collectorName, &MetricsCollectorAzureRmServiceFabric{})
+		collectorGeneralList[collectorName].Run(*opts.Scrape.TimeServiceFabric)
+	} else {
+		log.WithField("collector", collectorName).Infof("collector disabled")
+	}
+
+	collectorName = "Messaging"
+	if opts.Scrape.TimeMessaging.Seconds() > 0 {
+		collectorGeneralList[collectorName] = NewCollectorGeneral(collectorName, &MetricsCollectorAzureRmMessaging{})
+		collectorGeneralList[collectorName].Run(*opts.Scrape.TimeMessaging)
+	} else {
+		log.WithField("collector", collectorName).Infof("collector disabled")
+	}
+
+	collectorName = "Monitor"
+	if opts.Scrape.TimeMonitor.Seconds() > 0 {
+		collectorGeneralList[collectorName] = NewCollectorGeneral(collectorName, &MetricsCollectorAzureRmMonitor{})
+		collectorGeneralList[collectorName].Run(*opts.Scrape.TimeMonitor)
+	} else {
+		log.WithField("collector", collectorName).Infof("collector disabled")
+	}
+
+	collectorName = "AlertCoverage"
+	if opts.Scrape.TimeAlertCoverage.Seconds() > 0 {
+		collectorGeneralList[collectorName] = NewCollectorGeneral(collectorName, &MetricsCollectorAzureRmAlertCoverage{})
+		collectorGeneralList[collectorName].Run(*opts.Scrape.TimeAlertCoverage)
+	} else {
+		log.WithField("collector", collectorName).Infof("collector disabled")
+	}
+
+	collectorName = "Policy"
+	if opts.Scrape.TimePolicy.Seconds() > 0 {
+		collectorGeneralList[collectorName] = NewCollectorGeneral(collectorName, &MetricsCollectorAzureRmPolicy{})
+		collectorGeneralList[collectorName].Run(*opts.Scrape.TimePolicy)
+	} else {
+		log.WithField("collector", collectorName).Infof("collector disabled")
+	}
+
+	collectorName = "DeploymentStack"
+	if opts.Scrape.TimeDeploymentStack.Seconds() > 0 {
+		collectorGeneralList[collectorName] = NewCollectorGeneral(collectorName, &MetricsCollectorAzureRmDeploymentStack{})
+		collectorGeneralList[collectorName].Run(*opts.Scrape.TimeDeploymentStack)
+	} else {
+		log.WithField("collector", collectorName).Infof("collector disabled")
+	}
+
+	collectorName = "PublicIp"
+	if opts.Scrape.TimePublicIp.Seconds() > 0 {
+		collectorGeneralList[collectorName] = NewCollectorGeneral(collectorName, &MetricsCollectorAzureRmPublicIp{})
+		collectorGeneralList[collectorName].Run(*opts.Scrape.TimePublicIp)
+	} else {
+		log.WithField("collector", collectorName).Infof("collector disabled")
+	}
+
+	collectorName = "Network"
+	if opts.Scrape.TimeNetwork.Seconds() > 0 {
+		collectorGeneralList[collectorName] = NewCollectorGeneral(collectorName, &MetricsCollectorAzureRmNetwork{})
+		collectorGeneralList[collectorName].Run(*opts.Scrape.TimeNetwork)
+	} else {
+		log.WithField("collector", collectorName).Infof("collector disabled")
+	}
+
+	collectorName = "ExpressRoute"
+	if opts.Scrape.TimeExpressRoute.Seconds() > 0 {
+		collectorGeneralList[collectorName] = NewCollectorGeneral(collectorName, &MetricsCollectorAzureRmExpressRoute{})
+		collectorGeneralList[collectorName].Run(*opts.Scrape.TimeExpressRoute)
+	} else {
+		log.WithField("collector", collectorName).Infof("collector disabled")
+	}
+
+	collectorName = "Firewall"
+	if opts.Scrape.TimeFirewall.Seconds() > 0 {
+		collectorGeneralList[collectorName] = NewCollectorGeneral(collectorName, &MetricsCollectorAzureRmFirewall{})
+		collectorGeneralList[collectorName].Run(*opts.Scrape.TimeFirewall)
+	} else {
+		log.WithField("collector", collectorName).Infof("collector disabled")
+	}
+
+	collectorName = "VirtualMachine"
+	if opts.Scrape.TimeVirtualMachine.Seconds() > 0 {
+		collectorGeneralList[collectorName] = NewCollectorGeneral(collectorName, &MetricsCollectorAzureRmVirtualMachine{})
+		collectorGeneralList[collectorName].Run(*opts.Scrape.TimeVirtualMachine)
+	} else {
+		log.WithField("collector", collectorName).Infof("collector disabled")
+	}
+
+	collectorName = "HybridBenefit"
+	if opts.Scrape.TimeHybridBenefit.Seconds() > 0 {
+		collectorGeneralList[collectorName] = NewCollectorGeneral(collectorName, &MetricsCollectorAzureRmHybridBenefit{})
+		collectorGeneralList[collectorName].Run(*opts.Scrape.TimeHybridBenefit)
+	} else {
+		log.WithField("collector", collectorName).Infof("collector disabled")
+	}
+
+	collectorName = "Zone"
+	if opts.Scrape.TimeZone.Seconds() > 0 {
+		collectorGeneralList[collectorName] = NewCollectorGeneral(collectorName, &MetricsCollectorAzureRmZone{})
+		collectorGeneralList[collectorName].Run(*opts.Scrape.TimeZone)
+	} else {
+		log.WithField("collector", collectorName).Infof("collector disabled")
+	}
+
+	collectorName = "KeyVault"
+	if opts.Scrape.TimeKeyVault.Seconds() > 0 {
+		collectorGeneralList[collectorName] = NewCollectorGeneral(collectorName, &MetricsCollectorAzureRmKeyVault{})
+		collectorGeneralList[collectorName].Run(*opts.Scrape.TimeKeyVault)
+	} else {
+		log.WithField("collector", collectorName).Infof("collector disabled")
+	}
+
+	collectorName = "Storage"
+	if opts.Scrape.TimeStorage.Seconds() > 0 {
+		collectorGeneralList[collectorName] = NewCollectorGeneral(collectorName, &MetricsCollectorAzureRmStorage{})
+		collectorGeneralList[collectorName].Run(*opts.Scrape.TimeStorage)
+	} else {
+		log.WithField("collector", collectorName).Infof("collector disabled")
+	}
+
+	collectorName = "Exposure"
+	if opts.Scrape.TimeExposure.Seconds() > 0 {
+		collectorGeneralList[collectorName] = NewCollectorGeneral(collectorName, &MetricsCollectorAzureRmExposure{})
+		collectorGeneralList[collectorName].Run(*opts.Scrape.TimeExposure)
+	} else {
+		log.WithField("collector", collectorName).Infof("collector disabled")
+	}
+
+	collectorName = "DevOps"
+	if opts.Scrape.TimeDevOps.Seconds() > 0 && opts.DevOps.Organization != "" {
+		collectorCustomList[collectorName] = NewCollectorCustom(collectorName, &MetricsCollectorDevOps{})
+		collectorCustomList[collectorName].Run(*opts.Scrape.TimeDevOps)
+	} else {
+		log.WithField("collector", collectorName).Infof("collector disabled")
+	}
+
+	collectorName = "Dependency"
+	if opts.Scrape.TimeDependency.Seconds() > 0 {
+		collectorGeneralList[collectorName] = NewCollectorGeneral(collectorName, &MetricsCollectorAzureRmDependency{})
+		collectorGeneralList[collectorName].Run(*opts.Scrape.TimeDependency)
+	} else {
+		log.WithField("collector", collectorName).Infof("collector disabled")
+	}
+
+	collectorName = "Compute"
+	if opts.Scrape.TimeCompute.Seconds() > 0 {
+		collectorGeneralList[collectorName] = NewCollectorGeneral(collectorName, &MetricsCollectorAzureRmCompute{})
+		collectorGeneralList[collectorName].Run(*opts.Scrape.TimeCompute)
+	} else {
+		log.WithField("collector", collectorName).Infof("collector disabled")
+	}
+
+	collectorName = "Disk"
+	if opts.Scrape.TimeDisk.Seconds() > 0 {
+		collectorGeneralList[collectorName] = NewCollectorGeneral(collectorName, &MetricsCollectorAzureRmDisk{})
+		collectorGeneralList[collectorName].Run(*opts.Scrape.TimeDisk)
+	} else {
+		log.WithField("collector", collectorName).Infof("collector disabled")
+	}
+
+	collectorName = "AppService"
+	if opts.Scrape.TimeAppService.Seconds() > 0 {
+		collectorGeneralList[collectorName] = NewCollectorGeneral(collectorName, &MetricsCollectorAzureRmAppService{})
+		collectorGeneralList[collectorName].Run(*opts.Scrape.TimeAppService)
+	} else {
+		log.WithField("collector", collectorName).Infof("collector disabled")
+	}
+
+	collectorName = "AppGateway"
+	if opts.Scrape.TimeAppGateway.Seconds() > 0 {
+		collectorGeneralList[collectorName] = NewCollectorGeneral(collectorName, &MetricsCollectorAzureRmAppGateway{})
+		collectorGeneralList[collectorName].Run(*opts.Scrape.TimeAppGateway)
+	} else {
+		log.WithField("collector", collectorName).Infof("collector disabled")
+	}
+
+	collectorName = "Orphaned"
+	if opts.Scrape.TimeOrphaned.Seconds() > 0 {
+		collectorGeneralList[collectorName] = NewCollectorGeneral(collectorName, &MetricsCollectorAzureRmOrphaned{})
+		collectorGeneralList[collectorName].Run(*opts.Scrape.TimeOrphaned)
+	} else {
+		log.WithField("collector", collectorName).Infof("collector disabled")
+	}
+
+	collectorName = "Reservation"
+	if opts.Scrape.TimeReservation.Seconds() > 0 {
+		collectorCustomList[collectorName] = NewCollectorCustom(collectorName, &MetricsCollectorAzureRmReservation{})
+		collectorCustomList[collectorName].Run(*opts.Scrape.TimeReservation)
+	} else {
+		log.WithField("collector", collectorName).Infof("collector disabled")
+	}
+
+	collectorName = "Sql"
+	if opts.Scrape.TimeSql.Seconds() > 0 {
+		collectorGeneralList[collectorName] = NewCollectorGeneral(collectorName, &MetricsCollectorAzureRmSql{})
+		collectorGeneralList[collectorName].Run(*opts.Scrape.TimeSql)
+	} else {
+		log.WithField("collector", collectorName).Infof("collector disabled")
+	}
+
+	collectorName = "CosmosDb"
+	if opts.Scrape.TimeCosmosDb.Seconds() > 0 {
+		collectorGeneralList[collectorName] = NewCollectorGeneral(collectorName, &MetricsCollectorAzureRmCosmosDb{})
+		collectorGeneralList[collectorName].Run(*opts.Scrape.TimeCosmosDb)
+	} else {
+		log.WithField("collector", collectorName).Infof("collector disabled")
+	}
+
+	collectorName = "ServicePrincipal"
+	if opts.Scrape.TimeServicePrincipal.Seconds() > 0 {
+		collectorGeneralList[collectorName] = NewCollectorGeneral(collectorName, &MetricsCollectorAzureRmServicePrincipal{})
+		collectorGeneralList[collectorName].Run(*opts.Scrape.TimeServicePrincipal)
+	} else {
+		log.WithField("collector", collectorName).Infof("collector disabled")
+	}
+
+	collectorName = "SiteRecovery"
+	if opts.Scrape.TimeSiteRecovery.Seconds() > 0 {
+		collectorGeneralList[collectorName] = NewCollectorGeneral(collectorName, &MetricsCollectorAzureRmSiteRecovery{})
+		collectorGeneralList[collectorName].Run(*opts.Scrape.TimeSiteRecovery)
+	} else {
+		log.WithField("collector", collectorName).Infof("collector disabled")
+	}
+
+	collectorName = "Cdn"
+	if opts.Scrape.TimeCdn.Seconds() > 0 {
+		collectorGeneralList[collectorName] = NewCollectorGeneral(collectorName, &MetricsCollectorAzureRmCdn{})
+		collectorGeneralList[collectorName].Run(*opts.Scrape.TimeCdn)
+	} else {
+		log.WithField("collector", collectorName).Infof("collector disabled")
+	}
+
 	collectorName = "GraphApps"
 	if opts.Scrape.TimeGraph.Seconds() > 0 {
 		collectorCustomList[collectorName] = NewCollectorCustom(collectorName, &MetricsCollectorGraphApps{})
@@ -363,11 +1013,69 @@ func initMetricCollector() {
 		log.WithField("collector", collectorName).Infof("collector disabled")
 	}
 
+	// dependent collectors can lookup their dependencies now
+	close(collectorsInitialized)
 }
 
 // start and handle prometheus handler
 func startHttpServer() {
-	http.Handle("/metrics", promhttp.Handler())
+	http.Handle("/metrics", promhttp.InstrumentMetricHandler(
+		prometheus.DefaultRegisterer,
+		promhttp.HandlerFor(metricsRegistryGatherer{Gatherer: prometheus.DefaultGatherer}, promhttp.HandlerOpts{EnableOpenMetrics: opts.Metrics.Timestamps && !opts.Metrics.ManagedPrometheus}),
+	))
+
+	// collector groups (--metrics.group), served on the main or a separate address
+	groupServeMux := map[string]*http.ServeMux{}
+	for _, group := range metricsGroups {
+		bind := group.bind
+		if bind == "" {
+			bind = opts.ServerBind
+		}
+
+		serveMux := http.DefaultServeMux
+		if bind != opts.ServerBind {
+			if _, exists := groupServeMux[bind]; !exists {
+				groupServeMux[bind] = http.NewServeMux()
+			}
+			serveMux = groupServeMux[bind]
+		}
+
+		log.Infof("serving collector group \"%v\" on %v/metrics/%v", group.name, bind, group.name)
+		serveMux.Handle("/metrics/"+group.name, promhttp.HandlerFor(
+			metricsRegistryGatherer{Gatherer: prometheus.DefaultGatherer, collectors: group.collectors},
+			promhttp.HandlerOpts{EnableOpenMetrics: opts.Metrics.Timestamps && !opts.Metrics.ManagedPrometheus},
+		))
+	}
+	for bind, serveMux := range groupServeMux {
+		go func(bind string, serveMux *http.ServeMux) {
+			log.Infof("starting http server on %s", bind)
+			log.Fatal(http.ListenAndServe(bind, serveMux))
+		}(bind, serveMux)
+	}
+
+	// aggregated metrics of this and the sibling (sharded) instances
+	if len(opts.Federation.Peers) > 0 {
+		federationGatherers := prometheus.Gatherers{metricsRegistryGatherer{Gatherer: prometheus.DefaultGatherer}}
+		for _, peer := range opts.Federation.Peers {
+			federationGatherers = append(federationGatherers, newFederationPeerGatherer(peer))
+		}
+
+		http.Handle("/federate", promhttp.HandlerFor(federationGatherers, promhttp.HandlerOpts{
+			ErrorLog:      log.StandardLogger(),
+			ErrorHandling: promhttp.ContinueOnError,
+		}))
+	}
+
+	// runtime collector control
+	if opts.Admin.Token != "" {
+		http.HandleFunc("/admin/", adminApiHandler)
+	}
+
+	// event driven collection
+	if opts.EventGrid.Token != "" {
+		http.HandleFunc("/eventgrid", eventGridHandler)
+	}
+
 	log.Fatal(http.ListenAndServe(opts.ServerBind, nil))
 }
 
@@ -392,6 +1100,16 @@ func azureResponseInspector(subscription *subscriptions.Subscription) autorest.R
 			apiQuotaMetric(r, "x-ms-ratelimit-remaining-subscription-resource-requests", prometheus.Labels{"subscriptionID": subscriptionId, "scope": "subscription", "type": "resource-requests"})
 			apiQuotaMetric(r, "x-ms-ratelimit-remaining-subscription-resource-entities-read", prometheus.Labels{"subscriptionID": subscriptionId, "scope": "subscription", "type": "resource-entities-read"})
 
+			// api calls and response size for --profile-collection
+			if opts.ProfileCollection {
+				collectionProfileResponse(r)
+			}
+
+			// remaining reads for parallelism auto-tuning
+			if v, err := strconv.ParseInt(r.Header.Get("x-ms-ratelimit-remaining-subscription-reads"), 10, 64); err == nil && subscriptionId != "" {
+				azureRateLimitReadSet(subscriptionId, v)
+			}
+
 			// tenant rate limits
 			apiQuotaMetric(r, "x-ms-ratelimit-remaining-tenant-reads", prometheus.Labels{"subscriptionID": subscriptionId, "scope": "tenant", "type": "read"})
 			apiQuotaMetric(r, "x-ms-ratelimit-remaining-tenant-writes", prometheus.Labels{"subscriptionID": subscriptionId, "scope": "tenant", "type": "write"})
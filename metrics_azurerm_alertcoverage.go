@@ -0,0 +1,170 @@
+package main
+
+import (
+	"context"
+	"github.com/Azure/azure-sdk-for-go/profiles/latest/resources/mgmt/subscriptions"
+	"github.com/Azure/azure-sdk-for-go/services/preview/monitor/mgmt/2019-06-01/insights"
+	"github.com/Azure/go-autorest/autorest/to"
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+	prometheusCommon "github.com/webdevops/go-prometheus-common"
+	"strings"
+)
+
+type (
+	MetricsCollectorAzureRmAlertCoverage struct {
+		CollectorProcessorGeneral
+
+		prometheus struct {
+			coverage *prometheus.GaugeVec
+		}
+	}
+
+	azureAlertRuleTarget struct {
+		// lowercase resource id (or resourcegroup/subscription scope)
+		scope string
+
+		// lowercase resource type (only for multi-resource metric alerts)
+		resourceType string
+	}
+)
+
+func (m *MetricsCollectorAzureRmAlertCoverage) Setup(collector *CollectorGeneral) {
+	m.CollectorReference = collector
+
+	m.prometheus.coverage = m.newGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_resource_alert_coverage",
+			Help: "Azure Resource alert coverage (1 if at least one enabled alert rule targets the resource)",
+		},
+		append(
+			[]string{
+				"resourceID",
+				"resourceName",
+				"subscriptionID",
+				"resourceGroup",
+				"provider",
+				"resourceType",
+			},
+			azureResourceTags.prometheusLabels...,
+		),
+	)
+	m.MustRegister(m.prometheus.coverage)
+}
+
+// Dependencies uses the resource list of the Resource collector
+func (m *MetricsCollectorAzureRmAlertCoverage) Dependencies() []string {
+	return []string{"Resource"}
+}
+
+func (m *MetricsCollectorAzureRmAlertCoverage) Reset() {
+	m.prometheus.coverage.Reset()
+}
+
+func (m *MetricsCollectorAzureRmAlertCoverage) Collect(ctx context.Context, logger *log.Entry, callback chan<- func(), subscription subscriptions.Subscription) {
+	targetList := m.collectAlertRuleTargets(ctx, logger, subscription)
+
+	resourceCache, err := azureResourceCacheGet(ctx, subscription)
+	if err != nil {
+		logger.Panic(err)
+	}
+
+	coverageMetric := prometheusCommon.NewMetricsList()
+
+	for _, val := range resourceCache.List() {
+		resourceId := strings.ToLower(val.ID)
+		resourceType := strings.ToLower(val.Type)
+
+		covered := false
+		for _, target := range targetList {
+			if target.resourceType != "" && target.resourceType != resourceType {
+				continue
+			}
+
+			if resourceId == target.scope || strings.HasPrefix(resourceId, target.scope+"/") {
+				covered = true
+				break
+			}
+		}
+
+		labels := prometheus.Labels{
+			"resourceID":     toResourceId(&val.ID),
+			"resourceName":   val.Name,
+			"subscriptionID": to.String(subscription.SubscriptionID),
+			"resourceGroup":  extractResourceGroupFromAzureId(val.ID),
+			"provider":       extractProviderFromAzureId(val.ID),
+			"resourceType":   resourceType,
+		}
+		labels = azureResourceTags.appendPrometheusLabel(labels, val.Tags)
+
+		if covered {
+			coverageMetric.Add(labels, 1)
+		} else {
+			coverageMetric.Add(labels, 0)
+		}
+	}
+
+	callback <- func() {
+		coverageMetric.GaugeSet(m.prometheus.coverage)
+	}
+}
+
+// Collect targets (scopes) of all enabled metric and log alert rules
+func (m *MetricsCollectorAzureRmAlertCoverage) collectAlertRuleTargets(ctx context.Context, logger *log.Entry, subscription subscriptions.Subscription) (targetList []azureAlertRuleTarget) {
+	metricAlertClient := insights.NewMetricAlertsClientWithBaseURI(azureEnvironment.ResourceManagerEndpoint, *subscription.SubscriptionID)
+	metricAlertClient.Authorizer = AzureAuthorizer
+	metricAlertClient.ResponseInspector = azureResponseInspector(&subscription)
+
+	metricAlertList, err := metricAlertClient.ListBySubscription(ctx)
+	if err != nil {
+		logger.Panic(err)
+	}
+
+	if metricAlertList.Value != nil {
+		for _, alertRule := range *metricAlertList.Value {
+			if alertRule.MetricAlertProperties == nil || !to.Bool(alertRule.Enabled) || alertRule.Scopes == nil {
+				continue
+			}
+
+			for _, scope := range *alertRule.Scopes {
+				targetList = append(targetList, azureAlertRuleTarget{
+					scope:        strings.ToLower(strings.TrimSuffix(scope, "/")),
+					resourceType: strings.ToLower(to.String(alertRule.TargetResourceType)),
+				})
+			}
+		}
+	}
+
+	logAlertClient := insights.NewScheduledQueryRulesClientWithBaseURI(azureEnvironment.ResourceManagerEndpoint, *subscription.SubscriptionID)
+	logAlertClient.Authorizer = AzureAuthorizer
+	logAlertClient.ResponseInspector = azureResponseInspector(&subscription)
+
+	logAlertList, err := logAlertClient.ListBySubscription(ctx, "")
+	if err != nil {
+		logger.Panic(err)
+	}
+
+	if logAlertList.Value != nil {
+		for _, alertRule := range *logAlertList.Value {
+			if alertRule.LogSearchRule == nil || alertRule.Enabled != insights.True || alertRule.Source == nil {
+				continue
+			}
+
+			if alertRule.Source.DataSourceID != nil {
+				targetList = append(targetList, azureAlertRuleTarget{
+					scope: strings.ToLower(strings.TrimSuffix(*alertRule.Source.DataSourceID, "/")),
+				})
+			}
+
+			if alertRule.Source.AuthorizedResources != nil {
+				for _, scope := range *alertRule.Source.AuthorizedResources {
+					targetList = append(targetList, azureAlertRuleTarget{
+						scope: strings.ToLower(strings.TrimSuffix(scope, "/")),
+					})
+				}
+			}
+		}
+	}
+
+	return
+}
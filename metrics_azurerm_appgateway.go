@@ -0,0 +1,373 @@
+package main
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/base64"
+	"errors"
+	"github.com/Azure/azure-sdk-for-go/profiles/latest/network/mgmt/network"
+	"github.com/Azure/azure-sdk-for-go/profiles/latest/resources/mgmt/subscriptions"
+	"github.com/Azure/go-autorest/autorest/to"
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+	prometheusCommon "github.com/webdevops/go-prometheus-common"
+	"strings"
+)
+
+type MetricsCollectorAzureRmAppGateway struct {
+	CollectorProcessorGeneral
+
+	prometheus struct {
+		appGateway               *prometheus.GaugeVec
+		appGatewayCapacity       *prometheus.GaugeVec
+		appGatewayBackendHealth  *prometheus.GaugeVec
+		appGatewaySslCertificate *prometheus.GaugeVec
+	}
+}
+
+func (m *MetricsCollectorAzureRmAppGateway) Setup(collector *CollectorGeneral) {
+	m.CollectorReference = collector
+
+	m.prometheus.appGateway = m.newGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_appgateway_info",
+			Help: "Azure Application Gateway information",
+		},
+		append(
+			[]string{
+				"resourceID",
+				"subscriptionID",
+				"resourceGroup",
+				"name",
+				"location",
+				"skuName",
+				"skuTier",
+				"operationalState",
+				"wafEnabled",
+				"wafMode",
+				"firewallPolicyID",
+			},
+			azureResourceTags.prometheusLabels...,
+		),
+	)
+	m.MustRegister(m.prometheus.appGateway)
+
+	m.prometheus.appGatewayCapacity = m.newGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_appgateway_capacity",
+			Help: "Azure Application Gateway capacity (fixed capacity or autoscale minimum and maximum)",
+		},
+		[]string{
+			"resourceID",
+			"subscriptionID",
+			"type",
+		},
+	)
+	m.MustRegister(m.prometheus.appGatewayCapacity)
+
+	m.prometheus.appGatewayBackendHealth = m.newGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_appgateway_backend_health",
+			Help: "Azure Application Gateway backend server count by health",
+		},
+		[]string{
+			"resourceID",
+			"subscriptionID",
+			"backendPool",
+			"health",
+		},
+	)
+	m.MustRegister(m.prometheus.appGatewayBackendHealth)
+
+	m.prometheus.appGatewaySslCertificate = m.newGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_appgateway_ssl_certificate_expiry",
+			Help: "Azure Application Gateway listener ssl certificate expiry time",
+		},
+		[]string{
+			"resourceID",
+			"subscriptionID",
+			"certificate",
+			"listener",
+			"subject",
+			"keyVaultSecretID",
+		},
+	)
+	m.MustRegister(m.prometheus.appGatewaySslCertificate)
+}
+
+func (m *MetricsCollectorAzureRmAppGateway) Reset() {
+	m.prometheus.appGateway.Reset()
+	m.prometheus.appGatewayCapacity.Reset()
+	m.prometheus.appGatewayBackendHealth.Reset()
+	m.prometheus.appGatewaySslCertificate.Reset()
+}
+
+// Collect Azure Application Gateways with capacity, waf, backend health and listener certificates
+func (m *MetricsCollectorAzureRmAppGateway) Collect(ctx context.Context, logger *log.Entry, callback chan<- func(), subscription subscriptions.Subscription) {
+	client := network.NewApplicationGatewaysClientWithBaseURI(azureEnvironment.ResourceManagerEndpoint, *subscription.SubscriptionID)
+	client.Authorizer = AzureAuthorizer
+	client.ResponseInspector = azureResponseInspector(&subscription)
+
+	list, err := client.ListAllComplete(ctx)
+	if err != nil {
+		logger.Panic(err)
+	}
+
+	infoMetric := prometheusCommon.NewMetricsList()
+	capacityMetric := prometheusCommon.NewMetricsList()
+	backendHealthMetric := prometheusCommon.NewMetricsList()
+	sslCertificateMetric := prometheusCommon.NewMetricsList()
+
+	for list.NotDone() {
+		val := list.Value()
+		resourceId := toResourceId(val.ID)
+
+		infoLabels := prometheus.Labels{
+			"resourceID":       resourceId,
+			"subscriptionID":   to.String(subscription.SubscriptionID),
+			"resourceGroup":    extractResourceGroupFromAzureId(to.String(val.ID)),
+			"name":             to.String(val.Name),
+			"location":         strings.ToLower(to.String(val.Location)),
+			"skuName":          "",
+			"skuTier":          "",
+			"operationalState": "",
+			"wafEnabled":       "false",
+			"wafMode":          "",
+			"firewallPolicyID": "",
+		}
+
+		addCapacity := func(capacityType string, value *int32) {
+			if value == nil {
+				return
+			}
+
+			capacityMetric.Add(prometheus.Labels{
+				"resourceID":     resourceId,
+				"subscriptionID": to.String(subscription.SubscriptionID),
+				"type":           capacityType,
+			}, float64(*value))
+		}
+
+		if val.ApplicationGatewayPropertiesFormat != nil {
+			infoLabels["operationalState"] = string(val.OperationalState)
+
+			if val.Sku != nil {
+				infoLabels["skuName"] = string(val.Sku.Name)
+				infoLabels["skuTier"] = string(val.Sku.Tier)
+				addCapacity("capacity", val.Sku.Capacity)
+			}
+
+			if val.AutoscaleConfiguration != nil {
+				addCapacity("autoscaleMinimum", val.AutoscaleConfiguration.MinCapacity)
+				addCapacity("autoscaleMaximum", val.AutoscaleConfiguration.MaxCapacity)
+			}
+
+			if val.WebApplicationFirewallConfiguration != nil && to.Bool(val.WebApplicationFirewallConfiguration.Enabled) {
+				infoLabels["wafEnabled"] = "true"
+				infoLabels["wafMode"] = string(val.WebApplicationFirewallConfiguration.FirewallMode)
+			}
+
+			// waf mode of waf policies is configured on the policy
+			if val.FirewallPolicy != nil {
+				infoLabels["wafEnabled"] = "true"
+				infoLabels["firewallPolicyID"] = toResourceId(val.FirewallPolicy.ID)
+			}
+
+			m.collectSslCertificates(logger, sslCertificateMetric, subscription, val)
+
+			// backend health is only available for running gateways
+			if val.OperationalState == network.ApplicationGatewayOperationalStateRunning {
+				m.collectBackendHealth(ctx, logger, client, backendHealthMetric, subscription, val)
+			}
+		}
+
+		infoLabels = azureResourceTags.appendPrometheusLabel(infoLabels, val.Tags)
+		infoMetric.AddInfo(infoLabels)
+
+		if list.NextWithContext(ctx) != nil {
+			break
+		}
+	}
+
+	callback <- func() {
+		infoMetric.GaugeSet(m.prometheus.appGateway)
+		capacityMetric.GaugeSet(m.prometheus.appGatewayCapacity)
+		backendHealthMetric.GaugeSet(m.prometheus.appGatewayBackendHealth)
+		sslCertificateMetric.GaugeSet(m.prometheus.appGatewaySslCertificate)
+	}
+}
+
+// collectSslCertificates exports the expiry of ssl certificates bound to listeners,
+// certificates referenced from KeyVault without public certificate data are skipped (see --keyvault-expiry)
+func (m *MetricsCollectorAzureRmAppGateway) collectSslCertificates(logger *log.Entry, metricList *prometheusCommon.MetricList, subscription subscriptions.Subscription, appGateway network.ApplicationGateway) {
+	if appGateway.SslCertificates == nil || appGateway.HTTPListeners == nil {
+		return
+	}
+
+	listenerByCertificate := map[string][]string{}
+	for _, listener := range *appGateway.HTTPListeners {
+		if listener.ApplicationGatewayHTTPListenerPropertiesFormat != nil && listener.SslCertificate != nil {
+			certificateId := strings.ToLower(to.String(listener.SslCertificate.ID))
+			listenerByCertificate[certificateId] = append(listenerByCertificate[certificateId], to.String(listener.Name))
+		}
+	}
+
+	for _, sslCertificate := range *appGateway.SslCertificates {
+		listeners := listenerByCertificate[strings.ToLower(to.String(sslCertificate.ID))]
+		if len(listeners) == 0 || sslCertificate.ApplicationGatewaySslCertificatePropertiesFormat == nil {
+			continue
+		}
+
+		publicCertData := to.String(sslCertificate.PublicCertData)
+		if publicCertData == "" {
+			continue
+		}
+
+		certificate, err := parseAppGatewayPublicCertData(publicCertData)
+		if err != nil {
+			logger.WithField("appGateway", to.String(appGateway.Name)).WithField("certificate", to.String(sslCertificate.Name)).Error(err)
+			continue
+		}
+
+		for _, listener := range listeners {
+			metricList.Add(prometheus.Labels{
+				"resourceID":       toResourceId(appGateway.ID),
+				"subscriptionID":   to.String(subscription.SubscriptionID),
+				"certificate":      to.String(sslCertificate.Name),
+				"listener":         listener,
+				"subject":          certificate.Subject.CommonName,
+				"keyVaultSecretID": to.String(sslCertificate.KeyVaultSecretID),
+			}, float64(certificate.NotAfter.Unix()))
+		}
+	}
+}
+
+// collectBackendHealth exports the number of backend servers by health for each backend pool
+func (m *MetricsCollectorAzureRmAppGateway) collectBackendHealth(ctx context.Context, logger *log.Entry, client network.ApplicationGatewaysClient, metricList *prometheusCommon.MetricList, subscription subscriptions.Subscription, appGateway network.ApplicationGateway) {
+	contextLogger := logger.WithField("appGateway", to.String(appGateway.Name))
+
+	future, err := client.BackendHealth(ctx, extractResourceGroupFromAzureId(to.String(appGateway.ID)), to.String(appGateway.Name), "")
+	if err != nil {
+		contextLogger.Error(err)
+		return
+	}
+
+	if err := future.WaitForCompletionRef(ctx, client.Client); err != nil {
+		contextLogger.Error(err)
+		return
+	}
+
+	backendHealth, err := future.Result(client)
+	if err != nil {
+		contextLogger.Error(err)
+		return
+	}
+
+	if backendHealth.BackendAddressPools == nil {
+		return
+	}
+
+	for _, pool := range *backendHealth.BackendAddressPools {
+		poolName := ""
+		if pool.BackendAddressPool != nil {
+			poolName = to.String(pool.BackendAddressPool.Name)
+		}
+
+		// servers are listed once per http setting, count each address only once
+		serverHealth := map[string]string{}
+		if pool.BackendHTTPSettingsCollection != nil {
+			for _, httpSettings := range *pool.BackendHTTPSettingsCollection {
+				if httpSettings.Servers == nil {
+					continue
+				}
+
+				for _, server := range *httpSettings.Servers {
+					address := to.String(server.Address)
+					if health, exists := serverHealth[address]; exists && health != string(network.ApplicationGatewayBackendHealthServerHealthUp) {
+						continue
+					}
+					serverHealth[address] = string(server.Health)
+				}
+			}
+		}
+
+		healthCount := map[string]int{
+			string(network.ApplicationGatewayBackendHealthServerHealthUp):   0,
+			string(network.ApplicationGatewayBackendHealthServerHealthDown): 0,
+		}
+		for _, health := range serverHealth {
+			healthCount[health]++
+		}
+
+		for health, count := range healthCount {
+			metricList.Add(prometheus.Labels{
+				"resourceID":     toResourceId(appGateway.ID),
+				"subscriptionID": to.String(subscription.SubscriptionID),
+				"backendPool":    poolName,
+				"health":         strings.ToLower(health),
+			}, float64(count))
+		}
+	}
+}
+
+// parseAppGatewayPublicCertData parses the public certificate data of an Application Gateway ssl certificate
+// (base64 PKCS#7 certificate chain) and returns the leaf certificate
+func parseAppGatewayPublicCertData(publicCertData string) (*x509.Certificate, error) {
+	data, err := base64.StdEncoding.DecodeString(publicCertData)
+	if err != nil {
+		return nil, err
+	}
+
+	certificates, err := x509.ParseCertificates(data)
+	if err != nil {
+		// PKCS#7 ContentInfo -> [0] SignedData -> [0] certificates
+		var contentInfo, content, signedData asn1.RawValue
+		var contentType asn1.ObjectIdentifier
+
+		if _, err := asn1.Unmarshal(data, &contentInfo); err != nil {
+			return nil, err
+		}
+
+		rest, err := asn1.Unmarshal(contentInfo.Bytes, &contentType)
+		if err != nil {
+			return nil, err
+		}
+
+		if _, err := asn1.Unmarshal(rest, &content); err != nil {
+			return nil, err
+		}
+
+		if _, err := asn1.Unmarshal(content.Bytes, &signedData); err != nil {
+			return nil, err
+		}
+
+		rest = signedData.Bytes
+		for len(rest) > 0 && certificates == nil {
+			var element asn1.RawValue
+			if rest, err = asn1.Unmarshal(rest, &element); err != nil {
+				return nil, err
+			}
+
+			if element.Class == asn1.ClassContextSpecific && element.Tag == 0 {
+				if certificates, err = x509.ParseCertificates(element.Bytes); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+
+	if len(certificates) == 0 {
+		return nil, errors.New("no certificate found in public certificate data")
+	}
+
+	// leaf certificate is the first non CA certificate of the chain
+	for _, certificate := range certificates {
+		if !certificate.IsCA {
+			return certificate, nil
+		}
+	}
+
+	return certificates[0], nil
+}
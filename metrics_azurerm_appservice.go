@@ -0,0 +1,255 @@
+package main
+
+import (
+	"context"
+	"github.com/Azure/azure-sdk-for-go/profiles/latest/resources/mgmt/subscriptions"
+	"github.com/Azure/azure-sdk-for-go/profiles/latest/web/mgmt/web"
+	"github.com/Azure/go-autorest/autorest/to"
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+	prometheusCommon "github.com/webdevops/go-prometheus-common"
+	"strings"
+)
+
+type MetricsCollectorAzureRmAppService struct {
+	CollectorProcessorGeneral
+
+	prometheus struct {
+		appServicePlan         *prometheus.GaugeVec
+		appServicePlanCapacity *prometheus.GaugeVec
+		webApp                 *prometheus.GaugeVec
+	}
+}
+
+func (m *MetricsCollectorAzureRmAppService) Setup(collector *CollectorGeneral) {
+	m.CollectorReference = collector
+
+	m.prometheus.appServicePlan = m.newGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_appserviceplan_info",
+			Help: "Azure App Service plan information",
+		},
+		append(
+			[]string{
+				"resourceID",
+				"subscriptionID",
+				"resourceGroup",
+				"name",
+				"location",
+				"kind",
+				"skuName",
+				"skuTier",
+				"zoneRedundant",
+			},
+			azureResourceTags.prometheusLabels...,
+		),
+	)
+	m.MustRegister(m.prometheus.appServicePlan)
+
+	m.prometheus.appServicePlanCapacity = m.newGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_appserviceplan_capacity",
+			Help: "Azure App Service plan capacity (workers, maximum workers and number of sites)",
+		},
+		[]string{
+			"resourceID",
+			"subscriptionID",
+			"type",
+		},
+	)
+	m.MustRegister(m.prometheus.appServicePlanCapacity)
+
+	m.prometheus.webApp = m.newGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_webapp_info",
+			Help: "Azure App Service web app information",
+		},
+		append(
+			[]string{
+				"resourceID",
+				"subscriptionID",
+				"resourceGroup",
+				"name",
+				"location",
+				"kind",
+				"appServicePlanID",
+				"runtimeStack",
+				"httpsOnly",
+				"ftpsState",
+				"minTlsVersion",
+				"state",
+			},
+			azureResourceTags.prometheusLabels...,
+		),
+	)
+	m.MustRegister(m.prometheus.webApp)
+}
+
+func (m *MetricsCollectorAzureRmAppService) Reset() {
+	m.prometheus.appServicePlan.Reset()
+	m.prometheus.appServicePlanCapacity.Reset()
+	m.prometheus.webApp.Reset()
+}
+
+func (m *MetricsCollectorAzureRmAppService) Collect(ctx context.Context, logger *log.Entry, callback chan<- func(), subscription subscriptions.Subscription) {
+	m.collectAppServicePlans(ctx, logger, callback, subscription)
+	m.collectWebApps(ctx, logger, callback, subscription)
+}
+
+// Collect Azure App Service plans with sku and capacity
+func (m *MetricsCollectorAzureRmAppService) collectAppServicePlans(ctx context.Context, logger *log.Entry, callback chan<- func(), subscription subscriptions.Subscription) {
+	client := web.NewAppServicePlansClientWithBaseURI(azureEnvironment.ResourceManagerEndpoint, *subscription.SubscriptionID)
+	client.Authorizer = AzureAuthorizer
+	client.ResponseInspector = azureResponseInspector(&subscription)
+
+	list, err := client.ListComplete(ctx, nil)
+	if err != nil {
+		logger.Panic(err)
+	}
+
+	infoMetric := prometheusCommon.NewMetricsList()
+	capacityMetric := prometheusCommon.NewMetricsList()
+
+	for list.NotDone() {
+		val := list.Value()
+		resourceId := toResourceId(val.ID)
+
+		infoLabels := prometheus.Labels{
+			"resourceID":     resourceId,
+			"subscriptionID": to.String(subscription.SubscriptionID),
+			"resourceGroup":  extractResourceGroupFromAzureId(to.String(val.ID)),
+			"name":           to.String(val.Name),
+			"location":       strings.ToLower(to.String(val.Location)),
+			"kind":           to.String(val.Kind),
+			"skuName":        "",
+			"skuTier":        "",
+			"zoneRedundant":  "",
+		}
+
+		addCapacity := func(capacityType string, value *int32) {
+			if value == nil {
+				return
+			}
+
+			capacityMetric.Add(prometheus.Labels{
+				"resourceID":     resourceId,
+				"subscriptionID": to.String(subscription.SubscriptionID),
+				"type":           capacityType,
+			}, float64(*value))
+		}
+
+		if val.Sku != nil {
+			infoLabels["skuName"] = to.String(val.Sku.Name)
+			infoLabels["skuTier"] = to.String(val.Sku.Tier)
+			addCapacity("workers", val.Sku.Capacity)
+		}
+
+		if val.AppServicePlanProperties != nil {
+			infoLabels["zoneRedundant"] = boolToString(to.Bool(val.ZoneRedundant))
+			addCapacity("maxWorkers", val.MaximumNumberOfWorkers)
+			addCapacity("sites", val.NumberOfSites)
+		}
+
+		infoLabels = azureResourceTags.appendPrometheusLabel(infoLabels, val.Tags)
+		infoMetric.AddInfo(infoLabels)
+
+		if list.NextWithContext(ctx) != nil {
+			break
+		}
+	}
+
+	callback <- func() {
+		infoMetric.GaugeSet(m.prometheus.appServicePlan)
+		capacityMetric.GaugeSet(m.prometheus.appServicePlanCapacity)
+	}
+}
+
+// Collect Azure App Service web apps with runtime and security relevant configuration
+func (m *MetricsCollectorAzureRmAppService) collectWebApps(ctx context.Context, logger *log.Entry, callback chan<- func(), subscription subscriptions.Subscription) {
+	client := web.NewAppsClientWithBaseURI(azureEnvironment.ResourceManagerEndpoint, *subscription.SubscriptionID)
+	client.Authorizer = AzureAuthorizer
+	client.ResponseInspector = azureResponseInspector(&subscription)
+
+	list, err := client.ListComplete(ctx)
+	if err != nil {
+		logger.Panic(err)
+	}
+
+	infoMetric := prometheusCommon.NewMetricsList()
+
+	for list.NotDone() {
+		val := list.Value()
+
+		infoLabels := prometheus.Labels{
+			"resourceID":       toResourceId(val.ID),
+			"subscriptionID":   to.String(subscription.SubscriptionID),
+			"resourceGroup":    extractResourceGroupFromAzureId(to.String(val.ID)),
+			"name":             to.String(val.Name),
+			"location":         strings.ToLower(to.String(val.Location)),
+			"kind":             to.String(val.Kind),
+			"appServicePlanID": "",
+			"runtimeStack":     "",
+			"httpsOnly":        "",
+			"ftpsState":        "",
+			"minTlsVersion":    "",
+			"state":            "",
+		}
+
+		if val.SiteProperties != nil {
+			infoLabels["appServicePlanID"] = toResourceId(val.ServerFarmID)
+			infoLabels["httpsOnly"] = boolToString(to.Bool(val.HTTPSOnly))
+			infoLabels["state"] = strings.ToLower(to.String(val.State))
+		}
+
+		// site config is not included in the list response
+		siteConfig, err := client.GetConfiguration(ctx, extractResourceGroupFromAzureId(to.String(val.ID)), to.String(val.Name))
+		if err != nil {
+			logger.WithField("appService", to.String(val.Name)).Error(err)
+		} else if siteConfig.SiteConfig != nil {
+			infoLabels["runtimeStack"] = webAppRuntimeStack(siteConfig.SiteConfig)
+			infoLabels["ftpsState"] = string(siteConfig.FtpsState)
+			infoLabels["minTlsVersion"] = string(siteConfig.MinTLSVersion)
+		}
+
+		infoLabels = azureResourceTags.appendPrometheusLabel(infoLabels, val.Tags)
+		infoMetric.AddInfo(infoLabels)
+
+		if list.NextWithContext(ctx) != nil {
+			break
+		}
+	}
+
+	callback <- func() {
+		infoMetric.GaugeSet(m.prometheus.webApp)
+	}
+}
+
+// webAppRuntimeStack returns the runtime stack of a web app (eg. "NODE|18-lts" for linux, "dotnet|v6.0" for windows)
+func webAppRuntimeStack(siteConfig *web.SiteConfig) string {
+	if linuxFxVersion := to.String(siteConfig.LinuxFxVersion); linuxFxVersion != "" {
+		return linuxFxVersion
+	}
+
+	if windowsFxVersion := to.String(siteConfig.WindowsFxVersion); windowsFxVersion != "" {
+		return windowsFxVersion
+	}
+
+	// windows web apps without container, first configured stack wins
+	for _, stack := range []struct {
+		name    string
+		version *string
+	}{
+		{"java", siteConfig.JavaVersion},
+		{"node", siteConfig.NodeVersion},
+		{"php", siteConfig.PhpVersion},
+		{"python", siteConfig.PythonVersion},
+		{"powershell", siteConfig.PowerShellVersion},
+		{"dotnet", siteConfig.NetFrameworkVersion},
+	} {
+		if version := to.String(stack.version); version != "" {
+			return stack.name + "|" + version
+		}
+	}
+
+	return ""
+}
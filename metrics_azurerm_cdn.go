@@ -0,0 +1,267 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/Azure/azure-sdk-for-go/profiles/latest/resources/mgmt/subscriptions"
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+	prometheusCommon "github.com/webdevops/go-prometheus-common"
+	"strings"
+	"time"
+)
+
+type MetricsCollectorAzureRmCdn struct {
+	CollectorProcessorGeneral
+
+	prometheus struct {
+		customDomain             *prometheus.GaugeVec
+		customDomainAutoRotation *prometheus.GaugeVec
+		customDomainExpiry       *prometheus.GaugeVec
+	}
+}
+
+// azureCdnProfile is a Front Door (Standard/Premium) or classic CDN profile (Microsoft.Cdn)
+type azureCdnProfile struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	Sku  *struct {
+		Name string `json:"name"`
+	} `json:"sku"`
+}
+
+// azureCdnCustomDomain is a Front Door (Standard/Premium) custom domain or a classic CDN endpoint custom domain
+type azureCdnCustomDomain struct {
+	ID         string `json:"id"`
+	Properties struct {
+		HostName string `json:"hostName"`
+
+		// Front Door (Standard/Premium)
+		TlsSettings *struct {
+			CertificateType string `json:"certificateType"`
+			Secret          *struct {
+				ID string `json:"id"`
+			} `json:"secret"`
+		} `json:"tlsSettings"`
+		DomainValidationState string `json:"domainValidationState"`
+		DeploymentStatus      string `json:"deploymentStatus"`
+
+		// classic CDN
+		CustomHttpsProvisioningState string `json:"customHttpsProvisioningState"`
+		CustomHttpsParameters        *struct {
+			CertificateSource           string `json:"certificateSource"`
+			CertificateSourceParameters *struct {
+				SecretVersion string `json:"secretVersion"`
+			} `json:"certificateSourceParameters"`
+		} `json:"customHttpsParameters"`
+	} `json:"properties"`
+}
+
+// azureCdnSecret is a Front Door (Standard/Premium) certificate (managed or customer certificate from Key Vault)
+type azureCdnSecret struct {
+	ID         string `json:"id"`
+	Properties struct {
+		Parameters *struct {
+			Type             string     `json:"type"`
+			Subject          string     `json:"subject"`
+			ExpirationDate   *time.Time `json:"expirationDate"`
+			UseLatestVersion bool       `json:"useLatestVersion"`
+		} `json:"parameters"`
+	} `json:"properties"`
+}
+
+func (m *MetricsCollectorAzureRmCdn) Setup(collector *CollectorGeneral) {
+	m.CollectorReference = collector
+
+	m.prometheus.customDomain = m.newGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_cdn_customdomain_info",
+			Help: "Azure Front Door (Standard/Premium) and CDN custom domain information",
+		},
+		[]string{
+			"subscriptionID",
+			"resourceID",
+			"resourceGroup",
+			"profileName",
+			"profileSku",
+			"hostname",
+			"certificateType",
+			"validationState",
+			"provisioningState",
+		},
+	)
+	m.MustRegister(m.prometheus.customDomain)
+
+	m.prometheus.customDomainAutoRotation = m.newGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_cdn_customdomain_autorotation",
+			Help: "Azure Front Door and CDN custom domain certificate is auto-rotated (managed certificate or latest Key Vault version)",
+		},
+		[]string{
+			"subscriptionID",
+			"resourceID",
+		},
+	)
+	m.MustRegister(m.prometheus.customDomainAutoRotation)
+
+	m.prometheus.customDomainExpiry = m.newGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_cdn_customdomain_certificate_expiry",
+			Help: "Azure Front Door (Standard/Premium) custom domain certificate expiry timestamp",
+		},
+		[]string{
+			"subscriptionID",
+			"resourceID",
+			"subject",
+		},
+	)
+	m.MustRegister(m.prometheus.customDomainExpiry)
+}
+
+func (m *MetricsCollectorAzureRmCdn) Reset() {
+	m.prometheus.customDomain.Reset()
+	m.prometheus.customDomainAutoRotation.Reset()
+	m.prometheus.customDomainExpiry.Reset()
+}
+
+func (m *MetricsCollectorAzureRmCdn) Collect(ctx context.Context, logger *log.Entry, callback chan<- func(), subscription subscriptions.Subscription) {
+	client := NewAzureRestClient(&subscription)
+
+	profileList, err := client.List(ctx, fmt.Sprintf("/subscriptions/%s/providers/Microsoft.Cdn/profiles", *subscription.SubscriptionID), AzureFrontDoorApiVersion)
+	if err != nil {
+		logger.Panic(err)
+	}
+
+	listResources := func(path string, handle func(row json.RawMessage) error) {
+		list, err := client.List(ctx, path, AzureFrontDoorApiVersion)
+		if err != nil {
+			logger.WithField("cdn", path).Error(err)
+			return
+		}
+
+		for _, row := range list {
+			if err := handle(row); err != nil {
+				logger.WithField("cdn", path).Error(err)
+			}
+		}
+	}
+
+	infoMetric := prometheusCommon.NewMetricsList()
+	autoRotationMetric := prometheusCommon.NewMetricsList()
+	expiryMetric := prometheusCommon.NewMetricsList()
+
+	for _, row := range profileList {
+		profile := azureCdnProfile{}
+		if err := json.Unmarshal(row, &profile); err != nil {
+			logger.Error(err)
+			continue
+		}
+
+		profileSku := ""
+		if profile.Sku != nil {
+			profileSku = profile.Sku.Name
+		}
+
+		addCustomDomain := func(customDomain azureCdnCustomDomain, certificateType, validationState, provisioningState string) {
+			infoMetric.AddInfo(prometheus.Labels{
+				"subscriptionID":    *subscription.SubscriptionID,
+				"resourceID":        toResourceId(&customDomain.ID),
+				"resourceGroup":     extractResourceGroupFromAzureId(profile.ID),
+				"profileName":       profile.Name,
+				"profileSku":        profileSku,
+				"hostname":          customDomain.Properties.HostName,
+				"certificateType":   certificateType,
+				"validationState":   validationState,
+				"provisioningState": provisioningState,
+			})
+		}
+
+		if strings.HasSuffix(profileSku, "_AzureFrontDoor") {
+			// certificates by (lowercase) secret id
+			secretMap := map[string]azureCdnSecret{}
+			listResources(profile.ID+"/secrets", func(row json.RawMessage) error {
+				secret := azureCdnSecret{}
+				if err := json.Unmarshal(row, &secret); err != nil {
+					return err
+				}
+				secretMap[strings.ToLower(secret.ID)] = secret
+				return nil
+			})
+
+			listResources(profile.ID+"/customDomains", func(row json.RawMessage) error {
+				customDomain := azureCdnCustomDomain{}
+				if err := json.Unmarshal(row, &customDomain); err != nil {
+					return err
+				}
+
+				certificateType := ""
+				if tlsSettings := customDomain.Properties.TlsSettings; tlsSettings != nil {
+					certificateType = tlsSettings.CertificateType
+
+					var secret *azureCdnSecret
+					if tlsSettings.Secret != nil {
+						if val, exists := secretMap[strings.ToLower(tlsSettings.Secret.ID)]; exists && val.Properties.Parameters != nil {
+							secret = &val
+						}
+					}
+
+					// managed certificates are renewed by Front Door, customer certificates only with the latest Key Vault version
+					autoRotation := strings.HasSuffix(certificateType, "ManagedCertificate") || (secret != nil && secret.Properties.Parameters.UseLatestVersion)
+					autoRotationMetric.AddBool(prometheus.Labels{
+						"subscriptionID": *subscription.SubscriptionID,
+						"resourceID":     toResourceId(&customDomain.ID),
+					}, autoRotation)
+
+					if secret != nil && secret.Properties.Parameters.ExpirationDate != nil {
+						expiryMetric.AddTime(prometheus.Labels{
+							"subscriptionID": *subscription.SubscriptionID,
+							"resourceID":     toResourceId(&customDomain.ID),
+							"subject":        secret.Properties.Parameters.Subject,
+						}, *secret.Properties.Parameters.ExpirationDate)
+					}
+				}
+
+				addCustomDomain(customDomain, certificateType, customDomain.Properties.DomainValidationState, customDomain.Properties.DeploymentStatus)
+				return nil
+			})
+		} else {
+			// classic CDN custom domains are configured per endpoint, the certificate expiry is not available
+			listResources(profile.ID+"/endpoints", func(row json.RawMessage) error {
+				endpoint := azureCdnProfile{}
+				if err := json.Unmarshal(row, &endpoint); err != nil {
+					return err
+				}
+
+				listResources(endpoint.ID+"/customDomains", func(row json.RawMessage) error {
+					customDomain := azureCdnCustomDomain{}
+					if err := json.Unmarshal(row, &customDomain); err != nil {
+						return err
+					}
+
+					certificateType := ""
+					if httpsParameters := customDomain.Properties.CustomHttpsParameters; httpsParameters != nil {
+						certificateType = httpsParameters.CertificateSource
+
+						// CDN managed certificates are renewed by the CDN, Key Vault certificates without version use the latest version
+						autoRotation := strings.EqualFold(certificateType, "Cdn") || httpsParameters.CertificateSourceParameters == nil || httpsParameters.CertificateSourceParameters.SecretVersion == ""
+						autoRotationMetric.AddBool(prometheus.Labels{
+							"subscriptionID": *subscription.SubscriptionID,
+							"resourceID":     toResourceId(&customDomain.ID),
+						}, autoRotation)
+					}
+
+					addCustomDomain(customDomain, certificateType, "", customDomain.Properties.CustomHttpsProvisioningState)
+					return nil
+				})
+				return nil
+			})
+		}
+	}
+
+	callback <- func() {
+		infoMetric.GaugeSet(m.prometheus.customDomain)
+		autoRotationMetric.GaugeSet(m.prometheus.customDomainAutoRotation)
+		expiryMetric.GaugeSet(m.prometheus.customDomainExpiry)
+	}
+}
@@ -0,0 +1,501 @@
+package main
+
+import (
+	"context"
+	"github.com/Azure/azure-sdk-for-go/profiles/latest/compute/mgmt/compute"
+	"github.com/Azure/azure-sdk-for-go/profiles/latest/resources/mgmt/subscriptions"
+	"github.com/Azure/azure-sdk-for-go/services/preview/monitor/mgmt/2019-06-01/insights"
+	"github.com/Azure/go-autorest/autorest/to"
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+	prometheusCommon "github.com/webdevops/go-prometheus-common"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type MetricsCollectorAzureRmCompute struct {
+	CollectorProcessorGeneral
+
+	prometheus struct {
+		vm           *prometheus.GaugeVec
+		vmPowerState *prometheus.GaugeVec
+
+		vmss                        *prometheus.GaugeVec
+		vmssCapacity                *prometheus.GaugeVec
+		vmssRollingUpgrade          *prometheus.GaugeVec
+		vmssRollingUpgradeInstances *prometheus.GaugeVec
+		vmssAutoscaleDrift          *prometheus.GaugeVec
+	}
+}
+
+// vmssAutoscaleSetting is the capacity range of an autoscale setting (over all profiles)
+type vmssAutoscaleSetting struct {
+	enabled bool
+	minimum float64
+	maximum float64
+}
+
+func (m *MetricsCollectorAzureRmCompute) Setup(collector *CollectorGeneral) {
+	m.CollectorReference = collector
+
+	m.prometheus.vm = m.newGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_vm_info",
+			Help: "Azure virtual machine information",
+		},
+		append(
+			[]string{
+				"resourceID",
+				"subscriptionID",
+				"resourceGroup",
+				"vmName",
+				"location",
+				"vmSize",
+				"osType",
+				"zone",
+				"priority",
+			},
+			azureResourceTags.prometheusLabels...,
+		),
+	)
+	m.MustRegister(m.prometheus.vm)
+
+	m.prometheus.vmPowerState = m.newGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_vm_powerstate",
+			Help: "Azure virtual machine power state (eg. running, deallocated, stopped)",
+		},
+		[]string{
+			"resourceID",
+			"subscriptionID",
+			"resourceGroup",
+			"vmName",
+			"powerState",
+		},
+	)
+	m.MustRegister(m.prometheus.vmPowerState)
+
+	m.prometheus.vmss = m.newGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_vmss_info",
+			Help: "Azure virtual machine scale set information",
+		},
+		append(
+			[]string{
+				"resourceID",
+				"subscriptionID",
+				"resourceGroup",
+				"name",
+				"location",
+				"skuName",
+				"skuTier",
+				"upgradeMode",
+				"provisioningState",
+			},
+			azureResourceTags.prometheusLabels...,
+		),
+	)
+	m.MustRegister(m.prometheus.vmss)
+
+	m.prometheus.vmssCapacity = m.newGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_vmss_capacity",
+			Help: "Azure virtual machine scale set capacity (configured sku capacity and current instance count)",
+		},
+		[]string{
+			"resourceID",
+			"subscriptionID",
+			"type",
+		},
+	)
+	m.MustRegister(m.prometheus.vmssCapacity)
+
+	m.prometheus.vmssRollingUpgrade = m.newGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_vmss_rolling_upgrade_status",
+			Help: "Azure virtual machine scale set status of the latest rolling upgrade",
+		},
+		[]string{
+			"resourceID",
+			"subscriptionID",
+			"status",
+		},
+	)
+	m.MustRegister(m.prometheus.vmssRollingUpgrade)
+
+	m.prometheus.vmssRollingUpgradeInstances = m.newGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_vmss_rolling_upgrade_instances",
+			Help: "Azure virtual machine scale set instance count of the latest rolling upgrade per state",
+		},
+		[]string{
+			"resourceID",
+			"subscriptionID",
+			"state",
+		},
+	)
+	m.MustRegister(m.prometheus.vmssRollingUpgradeInstances)
+
+	m.prometheus.vmssAutoscaleDrift = m.newGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_vmss_autoscale_drift",
+			Help: "Azure virtual machine scale set autoscale drift (1 if current capacity is outside of the autoscale range or autoscale is not enabled for scale sets matching --vm-autoscale-required-tag)",
+		},
+		[]string{
+			"resourceID",
+			"subscriptionID",
+			"reason",
+		},
+	)
+	m.MustRegister(m.prometheus.vmssAutoscaleDrift)
+}
+
+func (m *MetricsCollectorAzureRmCompute) Reset() {
+	m.prometheus.vm.Reset()
+	m.prometheus.vmPowerState.Reset()
+	m.prometheus.vmss.Reset()
+	m.prometheus.vmssCapacity.Reset()
+	m.prometheus.vmssRollingUpgrade.Reset()
+	m.prometheus.vmssRollingUpgradeInstances.Reset()
+	m.prometheus.vmssAutoscaleDrift.Reset()
+}
+
+func (m *MetricsCollectorAzureRmCompute) Collect(ctx context.Context, logger *log.Entry, callback chan<- func(), subscription subscriptions.Subscription) {
+	m.collectVirtualMachines(ctx, logger, callback, subscription)
+	m.collectPowerStates(ctx, logger, callback, subscription)
+	m.collectScaleSets(ctx, logger, callback, subscription)
+}
+
+// Collect Azure virtual machine inventory
+func (m *MetricsCollectorAzureRmCompute) collectVirtualMachines(ctx context.Context, logger *log.Entry, callback chan<- func(), subscription subscriptions.Subscription) {
+	client := compute.NewVirtualMachinesClientWithBaseURI(azureEnvironment.ResourceManagerEndpoint, *subscription.SubscriptionID)
+	client.Authorizer = AzureAuthorizer
+	client.ResponseInspector = azureResponseInspector(&subscription)
+
+	list, err := client.ListAllComplete(ctx, "")
+	if err != nil {
+		logger.Panic(err)
+	}
+
+	vmMetric := prometheusCommon.NewMetricsList()
+
+	for list.NotDone() {
+		val := list.Value()
+
+		zoneList := []string{}
+		if val.Zones != nil {
+			zoneList = append(zoneList, *val.Zones...)
+		}
+		sort.Strings(zoneList)
+
+		infoLabels := prometheus.Labels{
+			"resourceID":     toResourceId(val.ID),
+			"subscriptionID": to.String(subscription.SubscriptionID),
+			"resourceGroup":  extractResourceGroupFromAzureId(to.String(val.ID)),
+			"vmName":         to.String(val.Name),
+			"location":       strings.ToLower(to.String(val.Location)),
+			"vmSize":         "",
+			"osType":         "",
+			"zone":           strings.Join(zoneList, ","),
+			"priority":       "regular",
+		}
+
+		if val.VirtualMachineProperties != nil {
+			if val.HardwareProfile != nil {
+				infoLabels["vmSize"] = string(val.HardwareProfile.VMSize)
+			}
+
+			if val.StorageProfile != nil && val.StorageProfile.OsDisk != nil {
+				infoLabels["osType"] = string(val.StorageProfile.OsDisk.OsType)
+			}
+
+			if val.Priority != "" {
+				infoLabels["priority"] = strings.ToLower(string(val.Priority))
+			}
+		}
+
+		infoLabels = azureResourceTags.appendPrometheusLabel(infoLabels, val.Tags)
+		vmMetric.AddInfo(infoLabels)
+
+		if list.NextWithContext(ctx) != nil {
+			break
+		}
+	}
+
+	callback <- func() {
+		vmMetric.GaugeSet(m.prometheus.vm)
+	}
+}
+
+// Collect Azure virtual machine power states from the instance view
+func (m *MetricsCollectorAzureRmCompute) collectPowerStates(ctx context.Context, logger *log.Entry, callback chan<- func(), subscription subscriptions.Subscription) {
+	client := compute.NewVirtualMachinesClientWithBaseURI(azureEnvironment.ResourceManagerEndpoint, *subscription.SubscriptionID)
+	client.Authorizer = AzureAuthorizer
+	client.ResponseInspector = azureResponseInspector(&subscription)
+
+	// statusOnly returns the instance view of all virtual machines with one call
+	list, err := client.ListAllComplete(ctx, "true")
+	if err != nil {
+		logger.Panic(err)
+	}
+
+	powerStateMetric := prometheusCommon.NewMetricsList()
+
+	for list.NotDone() {
+		val := list.Value()
+
+		powerState := "unknown"
+		if val.VirtualMachineProperties != nil && val.InstanceView != nil && val.InstanceView.Statuses != nil {
+			for _, status := range *val.InstanceView.Statuses {
+				if code := to.String(status.Code); strings.HasPrefix(code, "PowerState/") {
+					powerState = strings.ToLower(strings.TrimPrefix(code, "PowerState/"))
+				}
+			}
+		}
+
+		powerStateMetric.AddInfo(prometheus.Labels{
+			"resourceID":     toResourceId(val.ID),
+			"subscriptionID": to.String(subscription.SubscriptionID),
+			"resourceGroup":  extractResourceGroupFromAzureId(to.String(val.ID)),
+			"vmName":         to.String(val.Name),
+			"powerState":     powerState,
+		})
+
+		if list.NextWithContext(ctx) != nil {
+			break
+		}
+	}
+
+	callback <- func() {
+		powerStateMetric.GaugeSet(m.prometheus.vmPowerState)
+	}
+}
+
+// Collect Azure virtual machine scale sets with instance counts and rolling upgrade status
+func (m *MetricsCollectorAzureRmCompute) collectScaleSets(ctx context.Context, logger *log.Entry, callback chan<- func(), subscription subscriptions.Subscription) {
+	client := compute.NewVirtualMachineScaleSetsClientWithBaseURI(azureEnvironment.ResourceManagerEndpoint, *subscription.SubscriptionID)
+	client.Authorizer = AzureAuthorizer
+	client.ResponseInspector = azureResponseInspector(&subscription)
+
+	vmClient := compute.NewVirtualMachineScaleSetVMsClientWithBaseURI(azureEnvironment.ResourceManagerEndpoint, *subscription.SubscriptionID)
+	vmClient.Authorizer = AzureAuthorizer
+	vmClient.ResponseInspector = azureResponseInspector(&subscription)
+
+	upgradeClient := compute.NewVirtualMachineScaleSetRollingUpgradesClientWithBaseURI(azureEnvironment.ResourceManagerEndpoint, *subscription.SubscriptionID)
+	upgradeClient.Authorizer = AzureAuthorizer
+	upgradeClient.ResponseInspector = azureResponseInspector(&subscription)
+
+	autoscaleClient := insights.NewAutoscaleSettingsClientWithBaseURI(azureEnvironment.ResourceManagerEndpoint, *subscription.SubscriptionID)
+	autoscaleClient.Authorizer = AzureAuthorizer
+	autoscaleClient.ResponseInspector = azureResponseInspector(&subscription)
+
+	list, err := client.ListAllComplete(ctx)
+	if err != nil {
+		logger.Panic(err)
+	}
+
+	autoscaleSettings := m.fetchAutoscaleSettings(ctx, logger, autoscaleClient)
+
+	infoMetric := prometheusCommon.NewMetricsList()
+	capacityMetric := prometheusCommon.NewMetricsList()
+	rollingUpgradeMetric := prometheusCommon.NewMetricsList()
+	rollingUpgradeInstancesMetric := prometheusCommon.NewMetricsList()
+	autoscaleDriftMetric := prometheusCommon.NewMetricsList()
+
+	for list.NotDone() {
+		val := list.Value()
+		resourceId := toResourceId(val.ID)
+		resourceGroup := extractResourceGroupFromAzureId(to.String(val.ID))
+
+		infoLabels := prometheus.Labels{
+			"resourceID":        resourceId,
+			"subscriptionID":    to.String(subscription.SubscriptionID),
+			"resourceGroup":     resourceGroup,
+			"name":              to.String(val.Name),
+			"location":          strings.ToLower(to.String(val.Location)),
+			"skuName":           "",
+			"skuTier":           "",
+			"upgradeMode":       "",
+			"provisioningState": "",
+		}
+
+		if val.Sku != nil {
+			infoLabels["skuName"] = to.String(val.Sku.Name)
+			infoLabels["skuTier"] = to.String(val.Sku.Tier)
+
+			capacityMetric.Add(prometheus.Labels{
+				"resourceID":     resourceId,
+				"subscriptionID": to.String(subscription.SubscriptionID),
+				"type":           "configured",
+			}, float64(to.Int64(val.Sku.Capacity)))
+		}
+
+		isRollingUpgrade := false
+		if val.VirtualMachineScaleSetProperties != nil {
+			infoLabels["provisioningState"] = strings.ToLower(to.String(val.ProvisioningState))
+			if val.UpgradePolicy != nil {
+				infoLabels["upgradeMode"] = strings.ToLower(string(val.UpgradePolicy.Mode))
+				isRollingUpgrade = val.UpgradePolicy.Mode == compute.UpgradeModeRolling
+			}
+		}
+
+		infoLabels = azureResourceTags.appendPrometheusLabel(infoLabels, val.Tags)
+		infoMetric.AddInfo(infoLabels)
+
+		vmList, err := vmClient.ListComplete(ctx, resourceGroup, to.String(val.Name), "", "", "")
+		if err != nil {
+			logger.WithField("vmss", to.String(val.ID)).Error(err)
+		} else {
+			instanceCount := float64(0)
+			for vmList.NotDone() {
+				instanceCount++
+
+				if vmList.NextWithContext(ctx) != nil {
+					break
+				}
+			}
+
+			capacityMetric.Add(prometheus.Labels{
+				"resourceID":     resourceId,
+				"subscriptionID": to.String(subscription.SubscriptionID),
+				"type":           "current",
+			}, instanceCount)
+
+			driftReason := ""
+			autoscale, autoscaleExists := autoscaleSettings[strings.ToLower(to.String(val.ID))]
+			switch {
+			case autoscaleExists && autoscale.enabled && instanceCount < autoscale.minimum:
+				driftReason = "belowMinimum"
+			case autoscaleExists && autoscale.enabled && instanceCount > autoscale.maximum:
+				driftReason = "aboveMaximum"
+			case vmssAutoscaleRequired(val.Tags) && !autoscaleExists:
+				driftReason = "autoscaleMissing"
+			case vmssAutoscaleRequired(val.Tags) && !autoscale.enabled:
+				driftReason = "autoscaleDisabled"
+			}
+
+			if autoscaleExists || driftReason != "" {
+				driftValue := float64(0)
+				if driftReason != "" {
+					driftValue = 1
+				}
+
+				autoscaleDriftMetric.Add(prometheus.Labels{
+					"resourceID":     resourceId,
+					"subscriptionID": to.String(subscription.SubscriptionID),
+					"reason":         driftReason,
+				}, driftValue)
+			}
+		}
+
+		if isRollingUpgrade {
+			upgrade, err := upgradeClient.GetLatest(ctx, resourceGroup, to.String(val.Name))
+			if err != nil {
+				// no rolling upgrade executed yet
+				if upgrade.Response.Response == nil || upgrade.Response.StatusCode != http.StatusNotFound {
+					logger.WithField("vmss", to.String(val.ID)).Error(err)
+				}
+			} else if upgrade.RollingUpgradeStatusInfoProperties != nil {
+				if upgrade.RunningStatus != nil {
+					rollingUpgradeMetric.AddInfo(prometheus.Labels{
+						"resourceID":     resourceId,
+						"subscriptionID": to.String(subscription.SubscriptionID),
+						"status":         strings.ToLower(string(upgrade.RunningStatus.Code)),
+					})
+				}
+
+				if progress := upgrade.Progress; progress != nil {
+					for state, count := range map[string]*int32{
+						"successful": progress.SuccessfulInstanceCount,
+						"failed":     progress.FailedInstanceCount,
+						"inProgress": progress.InProgressInstanceCount,
+						"pending":    progress.PendingInstanceCount,
+					} {
+						rollingUpgradeInstancesMetric.Add(prometheus.Labels{
+							"resourceID":     resourceId,
+							"subscriptionID": to.String(subscription.SubscriptionID),
+							"state":          state,
+						}, float64(to.Int32(count)))
+					}
+				}
+			}
+		}
+
+		if list.NextWithContext(ctx) != nil {
+			break
+		}
+	}
+
+	callback <- func() {
+		infoMetric.GaugeSet(m.prometheus.vmss)
+		capacityMetric.GaugeSet(m.prometheus.vmssCapacity)
+		rollingUpgradeMetric.GaugeSet(m.prometheus.vmssRollingUpgrade)
+		rollingUpgradeInstancesMetric.GaugeSet(m.prometheus.vmssRollingUpgradeInstances)
+		autoscaleDriftMetric.GaugeSet(m.prometheus.vmssAutoscaleDrift)
+	}
+}
+
+// fetchAutoscaleSettings returns the capacity range (lowest minimum and highest maximum of all profiles) of the autoscale settings by (lowercase) target resource id
+func (m *MetricsCollectorAzureRmCompute) fetchAutoscaleSettings(ctx context.Context, logger *log.Entry, client insights.AutoscaleSettingsClient) map[string]vmssAutoscaleSetting {
+	ret := map[string]vmssAutoscaleSetting{}
+
+	list, err := client.ListBySubscriptionComplete(ctx)
+	if err != nil {
+		logger.Error(err)
+		return ret
+	}
+
+	for list.NotDone() {
+		val := list.Value()
+
+		if val.AutoscaleSetting != nil && val.Profiles != nil {
+			setting := vmssAutoscaleSetting{
+				enabled: to.Bool(val.Enabled),
+				minimum: -1,
+			}
+
+			for _, profile := range *val.Profiles {
+				if profile.Capacity == nil {
+					continue
+				}
+
+				if minimum, err := strconv.ParseFloat(to.String(profile.Capacity.Minimum), 64); err == nil && (setting.minimum < 0 || minimum < setting.minimum) {
+					setting.minimum = minimum
+				}
+
+				if maximum, err := strconv.ParseFloat(to.String(profile.Capacity.Maximum), 64); err == nil && maximum > setting.maximum {
+					setting.maximum = maximum
+				}
+			}
+
+			if setting.minimum < 0 {
+				setting.minimum = 0
+			}
+
+			ret[strings.ToLower(to.String(val.TargetResourceURI))] = setting
+		}
+
+		if list.NextWithContext(ctx) != nil {
+			break
+		}
+	}
+
+	return ret
+}
+
+// vmssAutoscaleRequired checks if the scale set is tagged as requiring autoscale (--vm-autoscale-required-tag, format: name[=value])
+func vmssAutoscaleRequired(tags map[string]*string) bool {
+	if opts.VirtualMachine.AutoscaleRequiredTag == "" {
+		return false
+	}
+
+	requiredTag := strings.SplitN(opts.VirtualMachine.AutoscaleRequiredTag, "=", 2)
+	for tagName, tagValue := range tags {
+		if strings.EqualFold(tagName, requiredTag[0]) {
+			return len(requiredTag) == 1 || strings.EqualFold(to.String(tagValue), requiredTag[1])
+		}
+	}
+	return false
+}
@@ -0,0 +1,282 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/Azure/azure-sdk-for-go/profiles/latest/resources/mgmt/subscriptions"
+	"github.com/Azure/go-autorest/autorest/to"
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+	prometheusCommon "github.com/webdevops/go-prometheus-common"
+	"strings"
+)
+
+const (
+	// CosmosDB throughput settings are not available in the used Azure SDK version
+	AzureCosmosDbApiVersion = "2023-04-15"
+)
+
+type MetricsCollectorAzureRmCosmosDb struct {
+	CollectorProcessorGeneral
+
+	prometheus struct {
+		account           *prometheus.GaugeVec
+		throughput        *prometheus.GaugeVec
+		throughputMinimum *prometheus.GaugeVec
+	}
+}
+
+// azureCosmosDbApi describes the resource paths of a CosmosDB API (database and container level)
+type azureCosmosDbApi struct {
+	name          string
+	databasePath  string
+	containerPath string
+}
+
+var (
+	azureCosmosDbApiSql       = azureCosmosDbApi{name: "sql", databasePath: "sqlDatabases", containerPath: "containers"}
+	azureCosmosDbApiMongo     = azureCosmosDbApi{name: "mongodb", databasePath: "mongodbDatabases", containerPath: "collections"}
+	azureCosmosDbApiCassandra = azureCosmosDbApi{name: "cassandra", databasePath: "cassandraKeyspaces", containerPath: "tables"}
+	azureCosmosDbApiGremlin   = azureCosmosDbApi{name: "gremlin", databasePath: "gremlinDatabases", containerPath: "graphs"}
+	azureCosmosDbApiTable     = azureCosmosDbApi{name: "table", databasePath: "tables"}
+)
+
+type azureCosmosDbAccount struct {
+	ID       string             `json:"id"`
+	Name     string             `json:"name"`
+	Location string             `json:"location"`
+	Kind     string             `json:"kind"`
+	Tags     map[string]*string `json:"tags"`
+
+	Properties struct {
+		EnableFreeTier bool `json:"enableFreeTier"`
+		Capabilities   []struct {
+			Name string `json:"name"`
+		} `json:"capabilities"`
+	} `json:"properties"`
+}
+
+// azureCosmosDbResource is a CosmosDB database or container
+type azureCosmosDbResource struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+type azureCosmosDbThroughputSettings struct {
+	Properties struct {
+		Resource struct {
+			Throughput        *float64 `json:"throughput"`
+			MinimumThroughput *string  `json:"minimumThroughput"`
+			AutoscaleSettings *struct {
+				MaxThroughput *float64 `json:"maxThroughput"`
+			} `json:"autoscaleSettings"`
+		} `json:"resource"`
+	} `json:"properties"`
+}
+
+func (m *MetricsCollectorAzureRmCosmosDb) Setup(collector *CollectorGeneral) {
+	m.CollectorReference = collector
+
+	m.prometheus.account = m.newGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_cosmosdb_account_info",
+			Help: "Azure CosmosDB account information",
+		},
+		append(
+			[]string{
+				"resourceID",
+				"subscriptionID",
+				"resourceGroup",
+				"name",
+				"location",
+				"kind",
+				"api",
+				"capacityMode",
+				"freeTier",
+			},
+			azureResourceTags.prometheusLabels...,
+		),
+	)
+	m.MustRegister(m.prometheus.account)
+
+	m.prometheus.throughput = m.newGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_cosmosdb_throughput",
+			Help: "Azure CosmosDB provisioned throughput in RU/s (manual throughput or autoscale maximum) per database or container",
+		},
+		[]string{
+			"resourceID",
+			"subscriptionID",
+			"database",
+			"container",
+			"mode",
+		},
+	)
+	m.MustRegister(m.prometheus.throughput)
+
+	m.prometheus.throughputMinimum = m.newGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_cosmosdb_throughput_minimum",
+			Help: "Azure CosmosDB minimum throughput in RU/s which can be provisioned per database or container",
+		},
+		[]string{
+			"resourceID",
+			"subscriptionID",
+			"database",
+			"container",
+		},
+	)
+	m.MustRegister(m.prometheus.throughputMinimum)
+}
+
+func (m *MetricsCollectorAzureRmCosmosDb) Reset() {
+	m.prometheus.account.Reset()
+	m.prometheus.throughput.Reset()
+	m.prometheus.throughputMinimum.Reset()
+}
+
+// Collect Azure CosmosDB accounts and provisioned throughput of databases and containers
+func (m *MetricsCollectorAzureRmCosmosDb) Collect(ctx context.Context, logger *log.Entry, callback chan<- func(), subscription subscriptions.Subscription) {
+	client := NewAzureRestClient(&subscription)
+
+	accountList, err := client.List(ctx, fmt.Sprintf("/subscriptions/%s/providers/Microsoft.DocumentDB/databaseAccounts", *subscription.SubscriptionID), AzureCosmosDbApiVersion)
+	if err != nil {
+		logger.Panic(err)
+	}
+
+	accountMetric := prometheusCommon.NewMetricsList()
+	throughputMetric := prometheusCommon.NewMetricsList()
+	throughputMinimumMetric := prometheusCommon.NewMetricsList()
+
+	for _, row := range accountList {
+		account := azureCosmosDbAccount{}
+		if err := json.Unmarshal(row, &account); err != nil {
+			logger.Error(err)
+			continue
+		}
+
+		accountLogger := logger.WithField("cosmosdbAccount", account.Name)
+		resourceId := toResourceId(&account.ID)
+
+		api := azureCosmosDbApiSql
+		capacityMode := "provisioned"
+		for _, capability := range account.Properties.Capabilities {
+			switch capability.Name {
+			case "EnableMongo":
+				api = azureCosmosDbApiMongo
+			case "EnableCassandra":
+				api = azureCosmosDbApiCassandra
+			case "EnableGremlin":
+				api = azureCosmosDbApiGremlin
+			case "EnableTable":
+				api = azureCosmosDbApiTable
+			case "EnableServerless":
+				capacityMode = "serverless"
+			}
+		}
+
+		infoLabels := prometheus.Labels{
+			"resourceID":     resourceId,
+			"subscriptionID": to.String(subscription.SubscriptionID),
+			"resourceGroup":  extractResourceGroupFromAzureId(account.ID),
+			"name":           account.Name,
+			"location":       strings.ToLower(account.Location),
+			"kind":           strings.ToLower(account.Kind),
+			"api":            api.name,
+			"capacityMode":   capacityMode,
+			"freeTier":       boolToString(account.Properties.EnableFreeTier),
+		}
+		infoLabels = azureResourceTags.appendPrometheusLabel(infoLabels, account.Tags)
+		accountMetric.AddInfo(infoLabels)
+
+		// serverless accounts are billed per request and have no provisioned throughput
+		if capacityMode == "serverless" {
+			continue
+		}
+
+		addThroughput := func(resourcePath, database, container string) {
+			settings := azureCosmosDbThroughputSettings{}
+			if err := client.Get(ctx, resourcePath+"/throughputSettings/default", AzureCosmosDbApiVersion, &settings); err != nil {
+				// no dedicated throughput provisioned (eg. container is using shared database throughput)
+				if !azureRestIsNotFound(err) {
+					accountLogger.WithField("resource", resourcePath).Error(err)
+				}
+				return
+			}
+
+			resource := settings.Properties.Resource
+
+			labels := prometheus.Labels{
+				"resourceID":     resourceId,
+				"subscriptionID": to.String(subscription.SubscriptionID),
+				"database":       database,
+				"container":      container,
+				"mode":           "manual",
+			}
+			throughput := resource.Throughput
+			if resource.AutoscaleSettings != nil && resource.AutoscaleSettings.MaxThroughput != nil {
+				labels["mode"] = "autoscale"
+				throughput = resource.AutoscaleSettings.MaxThroughput
+			}
+			if throughput != nil {
+				throughputMetric.Add(labels, *throughput)
+			}
+
+			if resource.MinimumThroughput != nil {
+				var minimumThroughput float64
+				if _, err := fmt.Sscanf(*resource.MinimumThroughput, "%f", &minimumThroughput); err == nil {
+					throughputMinimumMetric.Add(prometheus.Labels{
+						"resourceID":     resourceId,
+						"subscriptionID": to.String(subscription.SubscriptionID),
+						"database":       database,
+						"container":      container,
+					}, minimumThroughput)
+				}
+			}
+		}
+
+		databaseList, err := client.List(ctx, account.ID+"/"+api.databasePath, AzureCosmosDbApiVersion)
+		if err != nil {
+			accountLogger.Error(err)
+			continue
+		}
+
+		for _, databaseRow := range databaseList {
+			database := azureCosmosDbResource{}
+			if err := json.Unmarshal(databaseRow, &database); err != nil {
+				accountLogger.Error(err)
+				continue
+			}
+
+			addThroughput(database.ID, database.Name, "")
+
+			// table api has no container level below tables
+			if api.containerPath == "" {
+				continue
+			}
+
+			containerList, err := client.List(ctx, database.ID+"/"+api.containerPath, AzureCosmosDbApiVersion)
+			if err != nil {
+				accountLogger.WithField("database", database.Name).Error(err)
+				continue
+			}
+
+			for _, containerRow := range containerList {
+				container := azureCosmosDbResource{}
+				if err := json.Unmarshal(containerRow, &container); err != nil {
+					accountLogger.Error(err)
+					continue
+				}
+
+				addThroughput(container.ID, database.Name, container.Name)
+			}
+		}
+	}
+
+	callback <- func() {
+		accountMetric.GaugeSet(m.prometheus.account)
+		throughputMetric.GaugeSet(m.prometheus.throughput)
+		throughputMinimumMetric.GaugeSet(m.prometheus.throughputMinimum)
+	}
+}
@@ -2,8 +2,8 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
-	"github.com/Azure/azure-sdk-for-go/profiles/latest/consumption/mgmt/consumption"
 	"github.com/Azure/azure-sdk-for-go/profiles/latest/resources/mgmt/subscriptions"
 	"github.com/Azure/azure-sdk-for-go/services/costmanagement/mgmt/2019-10-01/costmanagement"
 	"github.com/Azure/go-autorest/autorest/to"
@@ -13,27 +13,62 @@ import (
 	"strings"
 )
 
+const (
+	// budget forecast spend and forecasted notifications are not available in the used Azure SDK version
+	AzureConsumptionBudgetApiVersion = "2021-10-01"
+)
+
 type MetricsCollectorAzureRmCosts struct {
 	CollectorProcessorGeneral
 
 	prometheus struct {
-		consumptionBudgetInfo    *prometheus.GaugeVec
-		consumptionBudgetLimit   *prometheus.GaugeVec
-		consumptionBudgetCurrent *prometheus.GaugeVec
-		consumptionBudgetUsage   *prometheus.GaugeVec
+		consumptionBudgetInfo         *prometheus.GaugeVec
+		consumptionBudgetLimit        *prometheus.GaugeVec
+		consumptionBudgetCurrent      *prometheus.GaugeVec
+		consumptionBudgetUsage        *prometheus.GaugeVec
+		consumptionBudgetForecast     *prometheus.GaugeVec
+		consumptionBudgetNotification *prometheus.GaugeVec
 
 		costmanagementOverallUsage      *prometheus.GaugeVec
 		costmanagementOverallActualCost *prometheus.GaugeVec
 
 		costmanagementDetailUsage      *prometheus.GaugeVec
 		costmanagementDetailActualCost *prometheus.GaugeVec
+
+		costmanagementUntaggedCost *prometheus.GaugeVec
+
+		costmanagementCost *prometheus.GaugeVec
 	}
 }
 
+// azureConsumptionBudget is a Microsoft.Consumption budget
+type azureConsumptionBudget struct {
+	ID         string `json:"id"`
+	Name       string `json:"name"`
+	Properties struct {
+		Category      string                       `json:"category"`
+		Amount        *float64                     `json:"amount"`
+		TimeGrain     string                       `json:"timeGrain"`
+		CurrentSpend  *azureConsumptionBudgetSpend `json:"currentSpend"`
+		ForecastSpend *azureConsumptionBudgetSpend `json:"forecastSpend"`
+		Notifications map[string]struct {
+			Enabled       bool    `json:"enabled"`
+			Operator      string  `json:"operator"`
+			Threshold     float64 `json:"threshold"`
+			ThresholdType string  `json:"thresholdType"`
+		} `json:"notifications"`
+	} `json:"properties"`
+}
+
+type azureConsumptionBudgetSpend struct {
+	Amount float64 `json:"amount"`
+	Unit   string  `json:"unit"`
+}
+
 func (m *MetricsCollectorAzureRmCosts) Setup(collector *CollectorGeneral) {
 	m.CollectorReference = collector
 
-	m.prometheus.consumptionBudgetInfo = prometheus.NewGaugeVec(
+	m.prometheus.consumptionBudgetInfo = m.newGaugeVec(
 		prometheus.GaugeOpts{
 			Name: "azurerm_consumtion_bugdet_info",
 			Help: "Azure ResourceManager consumtion budget info",
@@ -47,9 +82,9 @@ func (m *MetricsCollectorAzureRmCosts) Setup(collector *CollectorGeneral) {
 			"timeGrain",
 		},
 	)
-	prometheus.MustRegister(m.prometheus.consumptionBudgetInfo)
+	m.MustRegister(m.prometheus.consumptionBudgetInfo)
 
-	m.prometheus.consumptionBudgetLimit = prometheus.NewGaugeVec(
+	m.prometheus.consumptionBudgetLimit = m.newGaugeVec(
 		prometheus.GaugeOpts{
 			Name: "azurerm_consumtion_bugdet_limit",
 			Help: "Azure ResourceManager consumtion budget limit",
@@ -60,9 +95,9 @@ func (m *MetricsCollectorAzureRmCosts) Setup(collector *CollectorGeneral) {
 			"budgetName",
 		},
 	)
-	prometheus.MustRegister(m.prometheus.consumptionBudgetLimit)
+	m.MustRegister(m.prometheus.consumptionBudgetLimit)
 
-	m.prometheus.consumptionBudgetUsage = prometheus.NewGaugeVec(
+	m.prometheus.consumptionBudgetUsage = m.newGaugeVec(
 		prometheus.GaugeOpts{
 			Name: "azurerm_consumtion_bugdet_usage",
 			Help: "Azure ResourceManager consumtion budget usage percentage",
@@ -73,9 +108,9 @@ func (m *MetricsCollectorAzureRmCosts) Setup(collector *CollectorGeneral) {
 			"budgetName",
 		},
 	)
-	prometheus.MustRegister(m.prometheus.consumptionBudgetUsage)
+	m.MustRegister(m.prometheus.consumptionBudgetUsage)
 
-	m.prometheus.consumptionBudgetCurrent = prometheus.NewGaugeVec(
+	m.prometheus.consumptionBudgetCurrent = m.newGaugeVec(
 		prometheus.GaugeOpts{
 			Name: "azurerm_consumtion_bugdet_current",
 			Help: "Azure ResourceManager consumtion budget current",
@@ -87,9 +122,41 @@ func (m *MetricsCollectorAzureRmCosts) Setup(collector *CollectorGeneral) {
 			"unit",
 		},
 	)
-	prometheus.MustRegister(m.prometheus.consumptionBudgetCurrent)
+	m.MustRegister(m.prometheus.consumptionBudgetCurrent)
 
-	m.prometheus.costmanagementOverallUsage = prometheus.NewGaugeVec(
+	m.prometheus.consumptionBudgetForecast = m.newGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_consumtion_bugdet_forecast",
+			Help: "Azure ResourceManager consumtion budget forecasted spend",
+		},
+		[]string{
+			"resourceID",
+			"subscriptionID",
+			"budgetName",
+			"unit",
+		},
+	)
+	m.MustRegister(m.prometheus.consumptionBudgetForecast)
+
+	m.prometheus.consumptionBudgetNotification = m.newGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_consumtion_bugdet_notification",
+			Help: "Azure ResourceManager consumtion budget notification threshold status (1 if threshold is crossed)",
+		},
+		[]string{
+			"resourceID",
+			"subscriptionID",
+			"budgetName",
+			"notification",
+			"enabled",
+			"thresholdType",
+			"operator",
+			"threshold",
+		},
+	)
+	m.MustRegister(m.prometheus.consumptionBudgetNotification)
+
+	m.prometheus.costmanagementOverallUsage = m.newGaugeVec(
 		prometheus.GaugeOpts{
 			Name: "azurerm_costmanagement_overall_usage",
 			Help: "Azure ResourceManager costmanagement overall usage",
@@ -101,9 +168,9 @@ func (m *MetricsCollectorAzureRmCosts) Setup(collector *CollectorGeneral) {
 			"timeframe",
 		},
 	)
-	prometheus.MustRegister(m.prometheus.costmanagementOverallUsage)
+	m.MustRegister(m.prometheus.costmanagementOverallUsage)
 
-	m.prometheus.costmanagementOverallActualCost = prometheus.NewGaugeVec(
+	m.prometheus.costmanagementOverallActualCost = m.newGaugeVec(
 		prometheus.GaugeOpts{
 			Name: "azurerm_costmanagement_overall_actualcost",
 			Help: "Azure ResourceManager costmanagement overall actualcost",
@@ -115,9 +182,9 @@ func (m *MetricsCollectorAzureRmCosts) Setup(collector *CollectorGeneral) {
 			"timeframe",
 		},
 	)
-	prometheus.MustRegister(m.prometheus.costmanagementOverallActualCost)
+	m.MustRegister(m.prometheus.costmanagementOverallActualCost)
 
-	m.prometheus.costmanagementDetailUsage = prometheus.NewGaugeVec(
+	m.prometheus.costmanagementDetailUsage = m.newGaugeVec(
 		prometheus.GaugeOpts{
 			Name: "azurerm_costmanagement_detail_usage",
 			Help: "Azure ResourceManager costmanagement detail usage report by dimensions",
@@ -131,9 +198,9 @@ func (m *MetricsCollectorAzureRmCosts) Setup(collector *CollectorGeneral) {
 			"timeframe",
 		},
 	)
-	prometheus.MustRegister(m.prometheus.costmanagementDetailUsage)
+	m.MustRegister(m.prometheus.costmanagementDetailUsage)
 
-	m.prometheus.costmanagementDetailActualCost = prometheus.NewGaugeVec(
+	m.prometheus.costmanagementDetailActualCost = m.newGaugeVec(
 		prometheus.GaugeOpts{
 			Name: "azurerm_costmanagement_detail_actualcost",
 			Help: "Azure ResourceManager costmanagement detail actualcost report by dimensions",
@@ -147,16 +214,57 @@ func (m *MetricsCollectorAzureRmCosts) Setup(collector *CollectorGeneral) {
 			"timeframe",
 		},
 	)
-	prometheus.MustRegister(m.prometheus.costmanagementDetailActualCost)
+	m.MustRegister(m.prometheus.costmanagementDetailActualCost)
+
+	m.prometheus.costmanagementUntaggedCost = m.newGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_costmanagement_untagged_cost",
+			Help: "Azure ResourceManager costmanagement actualcost of untagged resources",
+		},
+		[]string{
+			"subscriptionID",
+			"currency",
+			"timeframe",
+		},
+	)
+	m.MustRegister(m.prometheus.costmanagementUntaggedCost)
+
+	m.prometheus.costmanagementCost = m.newGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_costmanagement_cost",
+			Help: "Azure ResourceManager costmanagement actualcost by resource group and meter category",
+		},
+		[]string{
+			"subscriptionID",
+			"resourceGroup",
+			"meterCategory",
+			"currency",
+			"timeframe",
+		},
+	)
+	m.MustRegister(m.prometheus.costmanagementCost)
+}
+
+// Dependencies uses the resource list of the Resource collector (untagged costs)
+func (m *MetricsCollectorAzureRmCosts) Dependencies() []string {
+	if opts.Costs.Untagged {
+		return []string{"Resource"}
+	}
+	return nil
 }
 
 func (m *MetricsCollectorAzureRmCosts) Reset() {
 	m.prometheus.consumptionBudgetInfo.Reset()
 	m.prometheus.consumptionBudgetLimit.Reset()
 	m.prometheus.consumptionBudgetCurrent.Reset()
+	m.prometheus.consumptionBudgetUsage.Reset()
+	m.prometheus.consumptionBudgetForecast.Reset()
+	m.prometheus.consumptionBudgetNotification.Reset()
 
 	m.prometheus.costmanagementDetailUsage.Reset()
 	m.prometheus.costmanagementDetailActualCost.Reset()
+	m.prometheus.costmanagementUntaggedCost.Reset()
+	m.prometheus.costmanagementCost.Reset()
 }
 
 func (m *MetricsCollectorAzureRmCosts) Collect(ctx context.Context, logger *log.Entry, callback chan<- func(), subscription subscriptions.Subscription) {
@@ -170,6 +278,7 @@ func (m *MetricsCollectorAzureRmCosts) Collect(ctx context.Context, logger *log.
 			nil,
 			timeframe,
 			m.prometheus.costmanagementOverallUsage,
+			"",
 		)
 
 		m.collectCostManagementMetrics(
@@ -181,6 +290,20 @@ func (m *MetricsCollectorAzureRmCosts) Collect(ctx context.Context, logger *log.
 			nil,
 			timeframe,
 			m.prometheus.costmanagementOverallActualCost,
+			"",
+		)
+
+		meterCategory := "MeterCategory"
+		m.collectCostManagementMetrics(
+			ctx,
+			logger.WithField("costreport", "ActualCost"),
+			callback,
+			subscription,
+			"ActualCost",
+			&meterCategory,
+			timeframe,
+			m.prometheus.costmanagementCost,
+			"meterCategory",
 		)
 
 		for _, val := range opts.Costs.Dimension {
@@ -194,6 +317,7 @@ func (m *MetricsCollectorAzureRmCosts) Collect(ctx context.Context, logger *log.
 				&dimension,
 				timeframe,
 				m.prometheus.costmanagementDetailUsage,
+				"",
 			)
 
 			m.collectCostManagementMetrics(
@@ -205,6 +329,7 @@ func (m *MetricsCollectorAzureRmCosts) Collect(ctx context.Context, logger *log.
 				&dimension,
 				timeframe,
 				m.prometheus.costmanagementDetailActualCost,
+				"",
 			)
 		}
 	}
@@ -216,18 +341,23 @@ func (m *MetricsCollectorAzureRmCosts) Collect(ctx context.Context, logger *log.
 		subscription,
 	)
 
+	if opts.Costs.Untagged {
+		m.collectUntaggedCostMetrics(
+			ctx,
+			logger.WithField("costreport", "Untagged"),
+			callback,
+			subscription,
+		)
+	}
+
 }
 
 func (m *MetricsCollectorAzureRmCosts) collectBugdetMetrics(ctx context.Context, logger *log.Entry, callback chan<- func(), subscription subscriptions.Subscription) {
-	client := consumption.NewBudgetsClientWithBaseURI(azureEnvironment.ResourceManagerEndpoint, *subscription.SubscriptionID)
-	client.Authorizer = AzureAuthorizer
-	client.ResponseInspector = azureResponseInspector(&subscription)
-
-	scope := fmt.Sprintf("/subscriptions/%s/", *subscription.SubscriptionID)
+	client := NewAzureRestClient(&subscription)
 
-	result, err := client.ListComplete(ctx, scope)
+	list, err := client.List(ctx, fmt.Sprintf("/subscriptions/%s/providers/Microsoft.Consumption/budgets", *subscription.SubscriptionID), AzureConsumptionBudgetApiVersion)
 	if err != nil {
-		log.Error(err.Error())
+		logger.Error(err.Error())
 		return
 	}
 
@@ -235,50 +365,101 @@ func (m *MetricsCollectorAzureRmCosts) collectBugdetMetrics(ctx context.Context,
 	limitMetric := prometheusCommon.NewMetricsList()
 	currentMetric := prometheusCommon.NewMetricsList()
 	usageMetric := prometheusCommon.NewMetricsList()
+	forecastMetric := prometheusCommon.NewMetricsList()
+	notificationMetric := prometheusCommon.NewMetricsList()
+
+	for _, row := range list {
+		val := azureConsumptionBudget{}
+		if err := json.Unmarshal(row, &val); err != nil {
+			logger.Error(err)
+			continue
+		}
 
-	for result.NotDone() {
-		val := result.Value()
+		resourceId := toResourceId(&val.ID)
 
 		infoMetric.AddInfo(prometheus.Labels{
-			"resourceID":     toResourceId(val.ID),
+			"resourceID":     resourceId,
 			"subscriptionID": to.String(subscription.SubscriptionID),
-			"budgetName":     to.String(val.Name),
-			"resourceGroup":  extractResourceGroupFromAzureId(to.String(val.ID)),
-			"category":       to.String(val.BudgetProperties.Category),
-			"timeGrain":      string(val.BudgetProperties.TimeGrain),
+			"budgetName":     val.Name,
+			"resourceGroup":  extractResourceGroupFromAzureId(val.ID),
+			"category":       val.Properties.Category,
+			"timeGrain":      val.Properties.TimeGrain,
 		})
 
-		if val.BudgetProperties.Amount != nil {
-			limitAmount, _ := val.BudgetProperties.Amount.Float64()
+		if val.Properties.Amount != nil {
 			limitMetric.Add(prometheus.Labels{
-				"resourceID":     toResourceId(val.ID),
+				"resourceID":     resourceId,
 				"subscriptionID": to.String(subscription.SubscriptionID),
-				"budgetName":     to.String(val.Name),
-			}, limitAmount)
+				"budgetName":     val.Name,
+			}, *val.Properties.Amount)
 		}
 
-		if val.BudgetProperties.CurrentSpend != nil {
-			budgetCurrentSpend, _ := val.BudgetProperties.CurrentSpend.Amount.Float64()
+		if val.Properties.CurrentSpend != nil {
 			currentMetric.Add(prometheus.Labels{
-				"resourceID":     toResourceId(val.ID),
+				"resourceID":     resourceId,
+				"subscriptionID": to.String(subscription.SubscriptionID),
+				"budgetName":     val.Name,
+				"unit":           val.Properties.CurrentSpend.Unit,
+			}, val.Properties.CurrentSpend.Amount)
+		}
+
+		if val.Properties.ForecastSpend != nil {
+			forecastMetric.Add(prometheus.Labels{
+				"resourceID":     resourceId,
 				"subscriptionID": to.String(subscription.SubscriptionID),
-				"budgetName":     to.String(val.Name),
-				"unit":           to.String(val.BudgetProperties.CurrentSpend.Unit),
-			}, budgetCurrentSpend)
+				"budgetName":     val.Name,
+				"unit":           val.Properties.ForecastSpend.Unit,
+			}, val.Properties.ForecastSpend.Amount)
 		}
 
-		if val.BudgetProperties.Amount != nil && val.BudgetProperties.CurrentSpend != nil {
-			budgetCurrentSpend, _ := val.BudgetProperties.CurrentSpend.Amount.Float64()
-			limitAmount, _ := val.BudgetProperties.Amount.Float64()
+		if val.Properties.Amount != nil && val.Properties.CurrentSpend != nil {
 			usageMetric.Add(prometheus.Labels{
-				"resourceID":     toResourceId(val.ID),
+				"resourceID":     resourceId,
 				"subscriptionID": to.String(subscription.SubscriptionID),
-				"budgetName":     to.String(val.Name),
-			}, budgetCurrentSpend/limitAmount)
+				"budgetName":     val.Name,
+			}, val.Properties.CurrentSpend.Amount/(*val.Properties.Amount))
 		}
 
-		if result.NextWithContext(ctx) != nil {
-			break
+		// notification thresholds are percentages of the budget amount, forecasted notifications use the forecast spend
+		for notificationName, notification := range val.Properties.Notifications {
+			spend := val.Properties.CurrentSpend
+			if strings.EqualFold(notification.ThresholdType, "Forecasted") {
+				spend = val.Properties.ForecastSpend
+			}
+
+			thresholdCrossed := false
+			if spend != nil && val.Properties.Amount != nil && *val.Properties.Amount > 0 {
+				spendPercentage := spend.Amount / *val.Properties.Amount * 100
+				switch strings.ToLower(notification.Operator) {
+				case "equalto":
+					thresholdCrossed = spendPercentage == notification.Threshold
+				case "greaterthan":
+					thresholdCrossed = spendPercentage > notification.Threshold
+				case "greaterthanorequalto":
+					thresholdCrossed = spendPercentage >= notification.Threshold
+				}
+			}
+
+			thresholdType := notification.ThresholdType
+			if thresholdType == "" {
+				thresholdType = "Actual"
+			}
+
+			notificationValue := float64(0)
+			if thresholdCrossed {
+				notificationValue = 1
+			}
+
+			notificationMetric.Add(prometheus.Labels{
+				"resourceID":     resourceId,
+				"subscriptionID": to.String(subscription.SubscriptionID),
+				"budgetName":     val.Name,
+				"notification":   notificationName,
+				"enabled":        boolToString(notification.Enabled),
+				"thresholdType":  thresholdType,
+				"operator":       notification.Operator,
+				"threshold":      fmt.Sprintf("%v", notification.Threshold),
+			}, notificationValue)
 		}
 	}
 
@@ -287,10 +468,14 @@ func (m *MetricsCollectorAzureRmCosts) collectBugdetMetrics(ctx context.Context,
 		limitMetric.GaugeSet(m.prometheus.consumptionBudgetLimit)
 		currentMetric.GaugeSet(m.prometheus.consumptionBudgetCurrent)
 		usageMetric.GaugeSet(m.prometheus.consumptionBudgetUsage)
+		forecastMetric.GaugeSet(m.prometheus.consumptionBudgetForecast)
+		notificationMetric.GaugeSet(m.prometheus.consumptionBudgetNotification)
 	}
 }
 
-func (m *MetricsCollectorAzureRmCosts) collectCostManagementMetrics(ctx context.Context, logger *log.Entry, callback chan<- func(), subscription subscriptions.Subscription, costType string, dimension *string, timeframe string, metric *prometheus.GaugeVec) {
+// collectCostManagementMetrics queries the costs grouped by resource group and the optional dimension, the dimension is exported
+// as dimensionName/dimensionValue labels or as value of dimensionLabel if set
+func (m *MetricsCollectorAzureRmCosts) collectCostManagementMetrics(ctx context.Context, logger *log.Entry, callback chan<- func(), subscription subscriptions.Subscription, costType string, dimension *string, timeframe string, metric *prometheus.GaugeVec, dimensionLabel string) {
 	client := costmanagement.NewQueryClientWithBaseURI(azureEnvironment.ResourceManagerEndpoint, *subscription.SubscriptionID)
 	client.Authorizer = AzureAuthorizer
 	client.ResponseInspector = azureResponseInspector(&subscription)
@@ -396,8 +581,12 @@ func (m *MetricsCollectorAzureRmCosts) collectCostManagementMetrics(ctx context.
 		}
 
 		if dimension != nil {
-			labels["dimensionName"] = *dimension
-			labels["dimensionValue"] = row[columnNumberDimension].(string)
+			if dimensionLabel != "" {
+				labels[dimensionLabel] = row[columnNumberDimension].(string)
+			} else {
+				labels["dimensionName"] = *dimension
+				labels["dimensionValue"] = row[columnNumberDimension].(string)
+			}
 		}
 
 		costMetric.Add(labels, usage)
@@ -407,3 +596,117 @@ func (m *MetricsCollectorAzureRmCosts) collectCostManagementMetrics(ctx context.
 		costMetric.GaugeSet(metric)
 	}
 }
+
+// collectUntaggedCostMetrics sums the actual cost of resources without tags, costs of resources which don't exist anymore
+// (tags unknown) are not included
+func (m *MetricsCollectorAzureRmCosts) collectUntaggedCostMetrics(ctx context.Context, logger *log.Entry, callback chan<- func(), subscription subscriptions.Subscription) {
+	client := costmanagement.NewQueryClientWithBaseURI(azureEnvironment.ResourceManagerEndpoint, *subscription.SubscriptionID)
+	client.Authorizer = AzureAuthorizer
+	client.ResponseInspector = azureResponseInspector(&subscription)
+
+	scope := fmt.Sprintf("/subscriptions/%s/", *subscription.SubscriptionID)
+
+	// untagged resources (lowercase resource id)
+	untaggedResources := map[string]bool{}
+	resourceCache, err := azureResourceCacheGet(ctx, subscription)
+	if err != nil {
+		logger.Error(err)
+		return
+	}
+
+	for _, val := range resourceCache.List() {
+		if len(val.Tags) == 0 {
+			untaggedResources[strings.ToLower(val.ID)] = true
+		}
+	}
+
+	untaggedCostMetric := prometheusCommon.NewMetricsList()
+
+	for _, timeframe := range opts.Costs.Timeframe {
+		queryGrouping := []costmanagement.QueryGrouping{
+			{
+				Name: to.StringPtr("ResourceId"),
+				Type: "Dimension",
+			},
+		}
+
+		params := costmanagement.QueryDefinition{}
+		params.Type = to.StringPtr("ActualCost")
+		params.Timeframe = costmanagement.TimeframeType(timeframe)
+		params.Dataset = &costmanagement.QueryDataset{}
+		params.Dataset.Grouping = &queryGrouping
+		params.Dataset.Granularity = "None"
+		params.Dataset.Aggregation = map[string]*costmanagement.QueryAggregation{}
+
+		params.Dataset.Aggregation["PreTaxCost"] = &costmanagement.QueryAggregation{
+			Name:     to.StringPtr("PreTaxCost"),
+			Function: to.StringPtr("Sum"),
+		}
+
+		list, err := client.Usage(ctx, scope, params)
+		if err != nil {
+			logger.Error(err)
+			continue
+		}
+
+		if list.Columns == nil || list.Rows == nil {
+			// no result
+			logger.Warnln("got invalid response (no columns or rows)")
+			continue
+		}
+
+		columnNumberCost := -1
+		columnNumberResourceId := -1
+		columnNumberCurrency := -1
+
+		for num, col := range *list.Columns {
+			if col.Name == nil {
+				continue
+			}
+
+			switch strings.ToLower(*col.Name) {
+			case "pretaxcost":
+				columnNumberCost = num
+			case "resourceid":
+				columnNumberResourceId = num
+			case "currency":
+				columnNumberCurrency = num
+			}
+		}
+
+		if columnNumberCost == -1 || columnNumberResourceId == -1 || columnNumberCurrency == -1 {
+			logger.Warnln("unable to detect columns")
+			continue
+		}
+
+		untaggedCost := map[string]float64{}
+		for _, row := range *list.Rows {
+			resourceId, _ := row[columnNumberResourceId].(string)
+			currency, _ := row[columnNumberCurrency].(string)
+
+			if _, exists := untaggedCost[currency]; !exists {
+				untaggedCost[currency] = 0
+			}
+
+			if !untaggedResources[strings.ToLower(resourceId)] {
+				continue
+			}
+
+			if v, ok := row[columnNumberCost].(float64); ok {
+				untaggedCost[currency] += v
+			}
+		}
+
+		for currency, cost := range untaggedCost {
+			untaggedCostMetric.Add(prometheus.Labels{
+				"subscriptionID": to.String(subscription.SubscriptionID),
+				"currency":       currency,
+				"timeframe":      timeframe,
+			}, cost)
+		}
+	}
+
+	callback <- func() {
+		untaggedCostMetric.GaugeSet(m.prometheus.costmanagementUntaggedCost)
+	}
+}
@@ -0,0 +1,365 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/Azure/azure-sdk-for-go/profiles/latest/keyvault/mgmt/keyvault"
+	"github.com/Azure/azure-sdk-for-go/profiles/latest/recoveryservices/mgmt/backup"
+	"github.com/Azure/azure-sdk-for-go/profiles/latest/recoveryservices/mgmt/recoveryservices"
+	"github.com/Azure/azure-sdk-for-go/profiles/latest/resources/mgmt/subscriptions"
+	"github.com/Azure/azure-sdk-for-go/profiles/latest/storage/mgmt/storage"
+	"github.com/Azure/go-autorest/autorest/to"
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+	prometheusCommon "github.com/webdevops/go-prometheus-common"
+	"time"
+)
+
+type MetricsCollectorAzureRmDeleted struct {
+	CollectorProcessorGeneral
+
+	prometheus struct {
+		keyvaultInfo              *prometheus.GaugeVec
+		keyvaultStatus            *prometheus.GaugeVec
+		storageContainerInfo      *prometheus.GaugeVec
+		storageContainerStatus    *prometheus.GaugeVec
+		backupProtectedItemInfo   *prometheus.GaugeVec
+		backupProtectedItemStatus *prometheus.GaugeVec
+	}
+}
+
+func (m *MetricsCollectorAzureRmDeleted) Setup(collector *CollectorGeneral) {
+	m.CollectorReference = collector
+
+	m.prometheus.keyvaultInfo = m.newGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_deleted_keyvault_info",
+			Help: "Azure ResourceManager soft-deleted KeyVault information",
+		},
+		append(
+			[]string{
+				"resourceID",
+				"subscriptionID",
+				"resourceGroup",
+				"vaultName",
+				"location",
+			},
+			azureResourceTags.prometheusLabels...,
+		),
+	)
+	m.MustRegister(m.prometheus.keyvaultInfo)
+
+	m.prometheus.keyvaultStatus = m.newGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_deleted_keyvault_status",
+			Help: "Azure ResourceManager soft-deleted KeyVault status (deletion and scheduled purge date)",
+		},
+		[]string{
+			"resourceID",
+			"subscriptionID",
+			"type",
+		},
+	)
+	m.MustRegister(m.prometheus.keyvaultStatus)
+
+	m.prometheus.storageContainerInfo = m.newGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_deleted_storage_container_info",
+			Help: "Azure ResourceManager soft-deleted storage blob container information",
+		},
+		[]string{
+			"resourceID",
+			"subscriptionID",
+			"resourceGroup",
+			"storageAccount",
+			"containerName",
+		},
+	)
+	m.MustRegister(m.prometheus.storageContainerInfo)
+
+	m.prometheus.storageContainerStatus = m.newGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_deleted_storage_container_status",
+			Help: "Azure ResourceManager soft-deleted storage blob container status (deletion and scheduled purge date)",
+		},
+		[]string{
+			"resourceID",
+			"subscriptionID",
+			"type",
+		},
+	)
+	m.MustRegister(m.prometheus.storageContainerStatus)
+
+	m.prometheus.backupProtectedItemInfo = m.newGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_deleted_backup_protecteditem_info",
+			Help: "Azure ResourceManager soft-deleted RecoveryServices backup item information",
+		},
+		[]string{
+			"resourceID",
+			"subscriptionID",
+			"resourceGroup",
+			"vaultName",
+			"sourceResourceID",
+			"workloadType",
+		},
+	)
+	m.MustRegister(m.prometheus.backupProtectedItemInfo)
+
+	m.prometheus.backupProtectedItemStatus = m.newGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_deleted_backup_protecteditem_status",
+			Help: "Azure ResourceManager soft-deleted RecoveryServices backup item status (deletion and scheduled purge date)",
+		},
+		[]string{
+			"resourceID",
+			"subscriptionID",
+			"type",
+		},
+	)
+	m.MustRegister(m.prometheus.backupProtectedItemStatus)
+}
+
+func (m *MetricsCollectorAzureRmDeleted) Reset() {
+	m.prometheus.keyvaultInfo.Reset()
+	m.prometheus.keyvaultStatus.Reset()
+	m.prometheus.storageContainerInfo.Reset()
+	m.prometheus.storageContainerStatus.Reset()
+	m.prometheus.backupProtectedItemInfo.Reset()
+	m.prometheus.backupProtectedItemStatus.Reset()
+}
+
+func (m *MetricsCollectorAzureRmDeleted) Collect(ctx context.Context, logger *log.Entry, callback chan<- func(), subscription subscriptions.Subscription) {
+	m.collectDeletedKeyVaults(ctx, logger, callback, subscription)
+	m.collectDeletedStorageContainers(ctx, logger, callback, subscription)
+	m.collectDeletedBackupProtectedItems(ctx, logger, callback, subscription)
+}
+
+// Collect soft-deleted KeyVaults
+func (m *MetricsCollectorAzureRmDeleted) collectDeletedKeyVaults(ctx context.Context, logger *log.Entry, callback chan<- func(), subscription subscriptions.Subscription) {
+	client := keyvault.NewVaultsClientWithBaseURI(azureEnvironment.ResourceManagerEndpoint, *subscription.SubscriptionID)
+	client.Authorizer = AzureAuthorizer
+	client.ResponseInspector = azureResponseInspector(&subscription)
+
+	list, err := client.ListDeletedComplete(ctx)
+	if err != nil {
+		logger.Panic(err)
+	}
+
+	infoMetric := prometheusCommon.NewMetricsList()
+	statusMetric := prometheusCommon.NewMetricsList()
+
+	for list.NotDone() {
+		val := list.Value()
+
+		if val.Properties != nil {
+			resourceId := toResourceId(val.Properties.VaultID)
+
+			infoLabels := prometheus.Labels{
+				"resourceID":     resourceId,
+				"subscriptionID": to.String(subscription.SubscriptionID),
+				"resourceGroup":  extractResourceGroupFromAzureId(to.String(val.Properties.VaultID)),
+				"vaultName":      to.String(val.Name),
+				"location":       to.String(val.Properties.Location),
+			}
+			infoLabels = azureResourceTags.appendPrometheusLabel(infoLabels, val.Properties.Tags)
+			infoMetric.AddInfo(infoLabels)
+
+			if val.Properties.DeletionDate != nil {
+				statusMetric.AddTime(prometheus.Labels{
+					"resourceID":     resourceId,
+					"subscriptionID": to.String(subscription.SubscriptionID),
+					"type":           "deletionDate",
+				}, val.Properties.DeletionDate.ToTime())
+			}
+
+			if val.Properties.ScheduledPurgeDate != nil {
+				statusMetric.AddTime(prometheus.Labels{
+					"resourceID":     resourceId,
+					"subscriptionID": to.String(subscription.SubscriptionID),
+					"type":           "scheduledPurgeDate",
+				}, val.Properties.ScheduledPurgeDate.ToTime())
+			}
+		}
+
+		if list.NextWithContext(ctx) != nil {
+			break
+		}
+	}
+
+	callback <- func() {
+		infoMetric.GaugeSet(m.prometheus.keyvaultInfo)
+		statusMetric.GaugeSet(m.prometheus.keyvaultStatus)
+	}
+}
+
+// Collect soft-deleted storage blob containers
+func (m *MetricsCollectorAzureRmDeleted) collectDeletedStorageContainers(ctx context.Context, logger *log.Entry, callback chan<- func(), subscription subscriptions.Subscription) {
+	accountClient := storage.NewAccountsClientWithBaseURI(azureEnvironment.ResourceManagerEndpoint, *subscription.SubscriptionID)
+	accountClient.Authorizer = AzureAuthorizer
+	accountClient.ResponseInspector = azureResponseInspector(&subscription)
+
+	containerClient := storage.NewBlobContainersClientWithBaseURI(azureEnvironment.ResourceManagerEndpoint, *subscription.SubscriptionID)
+	containerClient.Authorizer = AzureAuthorizer
+	containerClient.ResponseInspector = azureResponseInspector(&subscription)
+
+	accountList, err := accountClient.ListComplete(ctx)
+	if err != nil {
+		logger.Panic(err)
+	}
+
+	infoMetric := prometheusCommon.NewMetricsList()
+	statusMetric := prometheusCommon.NewMetricsList()
+
+	for accountList.NotDone() {
+		account := accountList.Value()
+		accountName := to.String(account.Name)
+		resourceGroup := extractResourceGroupFromAzureId(to.String(account.ID))
+
+		list, err := containerClient.ListComplete(ctx, resourceGroup, accountName, "", "", storage.ListContainersIncludeDeleted)
+		if err != nil {
+			logger.WithField("storageAccount", accountName).Error(err)
+		} else {
+			for list.NotDone() {
+				val := list.Value()
+
+				if val.ContainerProperties != nil && to.Bool(val.ContainerProperties.Deleted) {
+					resourceId := toResourceId(val.ID)
+
+					infoMetric.AddInfo(prometheus.Labels{
+						"resourceID":     resourceId,
+						"subscriptionID": to.String(subscription.SubscriptionID),
+						"resourceGroup":  resourceGroup,
+						"storageAccount": accountName,
+						"containerName":  to.String(val.Name),
+					})
+
+					if val.ContainerProperties.DeletedTime != nil {
+						statusMetric.AddTime(prometheus.Labels{
+							"resourceID":     resourceId,
+							"subscriptionID": to.String(subscription.SubscriptionID),
+							"type":           "deletionDate",
+						}, val.ContainerProperties.DeletedTime.ToTime())
+					}
+
+					if val.ContainerProperties.RemainingRetentionDays != nil {
+						retentionDuration := time.Duration(to.Int32(val.ContainerProperties.RemainingRetentionDays)) * 24 * time.Hour
+						statusMetric.AddTime(prometheus.Labels{
+							"resourceID":     resourceId,
+							"subscriptionID": to.String(subscription.SubscriptionID),
+							"type":           "scheduledPurgeDate",
+						}, time.Now().Add(retentionDuration))
+					}
+				}
+
+				if list.NextWithContext(ctx) != nil {
+					break
+				}
+			}
+		}
+
+		if accountList.NextWithContext(ctx) != nil {
+			break
+		}
+	}
+
+	callback <- func() {
+		infoMetric.GaugeSet(m.prometheus.storageContainerInfo)
+		statusMetric.GaugeSet(m.prometheus.storageContainerStatus)
+	}
+}
+
+// Collect soft-deleted RecoveryServices backup items
+func (m *MetricsCollectorAzureRmDeleted) collectDeletedBackupProtectedItems(ctx context.Context, logger *log.Entry, callback chan<- func(), subscription subscriptions.Subscription) {
+	vaultClient := recoveryservices.NewVaultsClientWithBaseURI(azureEnvironment.ResourceManagerEndpoint, *subscription.SubscriptionID)
+	vaultClient.Authorizer = AzureAuthorizer
+	vaultClient.ResponseInspector = azureResponseInspector(&subscription)
+
+	itemClient := backup.NewProtectedItemsGroupClientWithBaseURI(azureEnvironment.ResourceManagerEndpoint, *subscription.SubscriptionID)
+	itemClient.Authorizer = AzureAuthorizer
+	itemClient.ResponseInspector = azureResponseInspector(&subscription)
+
+	vaultList, err := vaultClient.ListBySubscriptionIDComplete(ctx)
+	if err != nil {
+		logger.Panic(err)
+	}
+
+	infoMetric := prometheusCommon.NewMetricsList()
+	statusMetric := prometheusCommon.NewMetricsList()
+
+	for vaultList.NotDone() {
+		vault := vaultList.Value()
+		vaultName := to.String(vault.Name)
+		resourceGroup := extractResourceGroupFromAzureId(to.String(vault.ID))
+
+		list, err := itemClient.ListComplete(ctx, vaultName, resourceGroup, "", "")
+		if err != nil {
+			logger.WithField("vault", vaultName).Error(err)
+		} else {
+			for list.NotDone() {
+				val := list.Value()
+
+				if item := m.protectedItemProperties(val); item != nil && to.Bool(item.IsScheduledForDeferredDelete) {
+					resourceId := toResourceId(val.ID)
+
+					infoMetric.AddInfo(prometheus.Labels{
+						"resourceID":       resourceId,
+						"subscriptionID":   to.String(subscription.SubscriptionID),
+						"resourceGroup":    resourceGroup,
+						"vaultName":        vaultName,
+						"sourceResourceID": toResourceId(item.SourceResourceID),
+						"workloadType":     string(item.WorkloadType),
+					})
+
+					if item.DeferredDeleteTimeInUTC != nil {
+						statusMetric.AddTime(prometheus.Labels{
+							"resourceID":     resourceId,
+							"subscriptionID": to.String(subscription.SubscriptionID),
+							"type":           "deletionDate",
+						}, item.DeferredDeleteTimeInUTC.ToTime())
+					}
+
+					if remaining, err := parseTimespan(to.String(item.DeferredDeleteTimeRemaining)); err == nil {
+						statusMetric.AddTime(prometheus.Labels{
+							"resourceID":     resourceId,
+							"subscriptionID": to.String(subscription.SubscriptionID),
+							"type":           "scheduledPurgeDate",
+						}, time.Now().Add(remaining))
+					}
+				}
+
+				if list.NextWithContext(ctx) != nil {
+					break
+				}
+			}
+		}
+
+		if vaultList.NextWithContext(ctx) != nil {
+			break
+		}
+	}
+
+	callback <- func() {
+		infoMetric.GaugeSet(m.prometheus.backupProtectedItemInfo)
+		statusMetric.GaugeSet(m.prometheus.backupProtectedItemStatus)
+	}
+}
+
+// protected items are polymorphic, the deferred delete fields are shared by all item types
+func (m *MetricsCollectorAzureRmDeleted) protectedItemProperties(item backup.ProtectedItemResource) *backup.ProtectedItem {
+	if item.Properties == nil {
+		return nil
+	}
+
+	data, err := json.Marshal(item.Properties)
+	if err != nil {
+		return nil
+	}
+
+	ret := backup.ProtectedItem{}
+	if err := json.Unmarshal(data, &ret); err != nil {
+		return nil
+	}
+
+	return &ret
+}
@@ -0,0 +1,379 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/Azure/azure-sdk-for-go/profiles/latest/network/mgmt/network"
+	"github.com/Azure/azure-sdk-for-go/profiles/latest/resources/mgmt/subscriptions"
+	"github.com/Azure/azure-sdk-for-go/profiles/latest/trafficmanager/mgmt/trafficmanager"
+	"github.com/Azure/go-autorest/autorest/to"
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+	prometheusCommon "github.com/webdevops/go-prometheus-common"
+	"strings"
+)
+
+const (
+	// Front Door Standard/Premium (Microsoft.Cdn) is not available in the used Azure SDK version
+	AzureFrontDoorApiVersion = "2021-06-01"
+)
+
+type MetricsCollectorAzureRmDependency struct {
+	CollectorProcessorGeneral
+
+	prometheus struct {
+		resourceDependency   *prometheus.GaugeVec
+		globalEndpointOrigin *prometheus.GaugeVec
+	}
+}
+
+// azureFrontDoorResource is a Front Door (Standard/Premium) profile, endpoint, route or origin
+type azureFrontDoorResource struct {
+	ID  string `json:"id"`
+	Sku *struct {
+		Name string `json:"name"`
+	} `json:"sku"`
+	Properties struct {
+		HostName    string `json:"hostName"`
+		OriginGroup *struct {
+			ID string `json:"id"`
+		} `json:"originGroup"`
+		AzureOrigin *struct {
+			ID string `json:"id"`
+		} `json:"azureOrigin"`
+	} `json:"properties"`
+}
+
+type azureGlobalEndpointAddFunc func(endpointType, endpointId, endpointHostname, originId, originHostname string)
+
+func (m *MetricsCollectorAzureRmDependency) Setup(collector *CollectorGeneral) {
+	m.CollectorReference = collector
+
+	m.prometheus.resourceDependency = m.newGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_resource_dependency_info",
+			Help: "Azure resource dependency (edge between source and target resource)",
+		},
+		[]string{
+			"subscriptionID",
+			"sourceID",
+			"sourceType",
+			"targetID",
+			"targetType",
+			"relation",
+		},
+	)
+	m.MustRegister(m.prometheus.resourceDependency)
+
+	m.prometheus.globalEndpointOrigin = m.newGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_global_endpoint_origin_info",
+			Help: "Azure Front Door and Traffic Manager endpoint to backend origin mapping",
+		},
+		[]string{
+			"subscriptionID",
+			"type",
+			"endpointID",
+			"endpointHostname",
+			"originID",
+			"originHostname",
+		},
+	)
+	m.MustRegister(m.prometheus.globalEndpointOrigin)
+}
+
+// Dependencies uses the network interfaces of the Network collector and the resource list of the Resource collector
+func (m *MetricsCollectorAzureRmDependency) Dependencies() []string {
+	return []string{"Network", "Resource"}
+}
+
+func (m *MetricsCollectorAzureRmDependency) Reset() {
+	m.prometheus.resourceDependency.Reset()
+	m.prometheus.globalEndpointOrigin.Reset()
+}
+
+func (m *MetricsCollectorAzureRmDependency) Collect(ctx context.Context, logger *log.Entry, callback chan<- func(), subscription subscriptions.Subscription) {
+	dependencyMetric := prometheusCommon.NewMetricsList()
+
+	addDependency := func(sourceId, targetId *string, relation string) {
+		if sourceId == nil || targetId == nil || to.String(targetId) == "" {
+			return
+		}
+
+		dependencyMetric.AddInfo(prometheus.Labels{
+			"subscriptionID": to.String(subscription.SubscriptionID),
+			"sourceID":       toResourceId(sourceId),
+			"sourceType":     extractResourceTypeFromAzureId(to.String(sourceId)),
+			"targetID":       toResourceId(targetId),
+			"targetType":     extractResourceTypeFromAzureId(to.String(targetId)),
+			"relation":       relation,
+		})
+	}
+
+	m.collectResourceGroups(ctx, logger, subscription, addDependency)
+	m.collectPrivateEndpoints(ctx, logger, subscription, addDependency)
+	m.collectLoadBalancerBackends(ctx, logger, subscription, addDependency)
+	m.collectVirtualNetworkPeerings(ctx, logger, subscription, addDependency)
+
+	globalEndpointMetric := prometheusCommon.NewMetricsList()
+
+	// edge endpoints with hostnames (eg. for attribution of portscan results), origins are also dependency edges
+	addGlobalEndpoint := func(endpointType, endpointId, endpointHostname, originId, originHostname string) {
+		globalEndpointMetric.AddInfo(prometheus.Labels{
+			"subscriptionID":   to.String(subscription.SubscriptionID),
+			"type":             endpointType,
+			"endpointID":       toResourceId(&endpointId),
+			"endpointHostname": strings.ToLower(endpointHostname),
+			"originID":         toResourceId(&originId),
+			"originHostname":   strings.ToLower(originHostname),
+		})
+
+		addDependency(&endpointId, &originId, endpointType+"Origin")
+	}
+
+	m.collectTrafficManagerEndpoints(ctx, logger, subscription, addGlobalEndpoint)
+	m.collectFrontDoorOrigins(ctx, logger, subscription, addGlobalEndpoint)
+
+	callback <- func() {
+		dependencyMetric.GaugeSet(m.prometheus.resourceDependency)
+		globalEndpointMetric.GaugeSet(m.prometheus.globalEndpointOrigin)
+	}
+}
+
+// Collect resource to resourcegroup edges
+func (m *MetricsCollectorAzureRmDependency) collectResourceGroups(ctx context.Context, logger *log.Entry, subscription subscriptions.Subscription, addDependency func(*string, *string, string)) {
+	resourceCache, err := azureResourceCacheGet(ctx, subscription)
+	if err != nil {
+		logger.Panic(err)
+	}
+
+	for _, val := range resourceCache.List() {
+		if resourceGroup := resourceGroupFromResourceIdRegExp.FindString(val.ID); resourceGroup != "" {
+			addDependency(to.StringPtr(val.ID), to.StringPtr(resourceGroup), "resourceGroup")
+		}
+	}
+}
+
+// Collect private endpoint to private link target resource edges
+func (m *MetricsCollectorAzureRmDependency) collectPrivateEndpoints(ctx context.Context, logger *log.Entry, subscription subscriptions.Subscription, addDependency func(*string, *string, string)) {
+	client := network.NewPrivateEndpointsClientWithBaseURI(azureEnvironment.ResourceManagerEndpoint, *subscription.SubscriptionID)
+	client.Authorizer = AzureAuthorizer
+	client.ResponseInspector = azureResponseInspector(&subscription)
+
+	list, err := client.ListBySubscriptionComplete(ctx)
+	if err != nil {
+		logger.Panic(err)
+	}
+
+	for list.NotDone() {
+		val := list.Value()
+
+		if val.PrivateEndpointProperties != nil {
+			for _, connectionList := range []*[]network.PrivateLinkServiceConnection{val.PrivateLinkServiceConnections, val.ManualPrivateLinkServiceConnections} {
+				if connectionList == nil {
+					continue
+				}
+
+				for _, connection := range *connectionList {
+					if connection.PrivateLinkServiceConnectionProperties != nil {
+						addDependency(val.ID, connection.PrivateLinkServiceID, "privateEndpoint")
+					}
+				}
+			}
+		}
+
+		if list.NextWithContext(ctx) != nil {
+			break
+		}
+	}
+}
+
+// Collect load balancer to backend virtual machine (scale set) edges
+func (m *MetricsCollectorAzureRmDependency) collectLoadBalancerBackends(ctx context.Context, logger *log.Entry, subscription subscriptions.Subscription, addDependency func(*string, *string, string)) {
+	client := network.NewLoadBalancersClientWithBaseURI(azureEnvironment.ResourceManagerEndpoint, *subscription.SubscriptionID)
+	client.Authorizer = AzureAuthorizer
+	client.ResponseInspector = azureResponseInspector(&subscription)
+
+	// virtual machine of each network interface (lowercase nic id)
+	interfaceVirtualMachine := map[string]*string{}
+	for _, val := range azureNetworkInterfaceList(ctx, logger, subscription) {
+		if val.InterfacePropertiesFormat != nil && val.VirtualMachine != nil {
+			interfaceVirtualMachine[strings.ToLower(to.String(val.ID))] = val.VirtualMachine.ID
+		}
+	}
+
+	list, err := client.ListAllComplete(ctx)
+	if err != nil {
+		logger.Panic(err)
+	}
+
+	for list.NotDone() {
+		val := list.Value()
+
+		if val.LoadBalancerPropertiesFormat != nil && val.BackendAddressPools != nil {
+			for _, pool := range *val.BackendAddressPools {
+				if pool.BackendAddressPoolPropertiesFormat == nil || pool.BackendIPConfigurations == nil {
+					continue
+				}
+
+				for _, ipConfiguration := range *pool.BackendIPConfigurations {
+					ipConfigurationId := to.String(ipConfiguration.ID)
+					ipConfigurationIdLower := strings.ToLower(ipConfigurationId)
+
+					if index := strings.Index(ipConfigurationIdLower, "/virtualmachines/"); strings.Contains(ipConfigurationIdLower, "/virtualmachinescalesets/") && index >= 0 {
+						// scale set instance, edge to the scale set
+						addDependency(val.ID, to.StringPtr(ipConfigurationId[:index]), "loadBalancerBackend")
+					} else if index := strings.Index(ipConfigurationIdLower, "/ipconfigurations/"); index >= 0 {
+						if vmId, exists := interfaceVirtualMachine[ipConfigurationIdLower[:index]]; exists {
+							addDependency(val.ID, vmId, "loadBalancerBackend")
+						}
+					}
+				}
+			}
+		}
+
+		if list.NextWithContext(ctx) != nil {
+			break
+		}
+	}
+}
+
+// Collect virtual network peering edges
+func (m *MetricsCollectorAzureRmDependency) collectVirtualNetworkPeerings(ctx context.Context, logger *log.Entry, subscription subscriptions.Subscription, addDependency func(*string, *string, string)) {
+	client := network.NewVirtualNetworksClientWithBaseURI(azureEnvironment.ResourceManagerEndpoint, *subscription.SubscriptionID)
+	client.Authorizer = AzureAuthorizer
+	client.ResponseInspector = azureResponseInspector(&subscription)
+
+	list, err := client.ListAllComplete(ctx)
+	if err != nil {
+		logger.Panic(err)
+	}
+
+	for list.NotDone() {
+		val := list.Value()
+
+		if val.VirtualNetworkPropertiesFormat != nil && val.VirtualNetworkPeerings != nil {
+			for _, peering := range *val.VirtualNetworkPeerings {
+				if peering.VirtualNetworkPeeringPropertiesFormat != nil && peering.RemoteVirtualNetwork != nil {
+					addDependency(val.ID, peering.RemoteVirtualNetwork.ID, "vnetPeering")
+				}
+			}
+		}
+
+		if list.NextWithContext(ctx) != nil {
+			break
+		}
+	}
+}
+
+// Collect Traffic Manager profile to endpoint target edges
+func (m *MetricsCollectorAzureRmDependency) collectTrafficManagerEndpoints(ctx context.Context, logger *log.Entry, subscription subscriptions.Subscription, addGlobalEndpoint azureGlobalEndpointAddFunc) {
+	client := trafficmanager.NewProfilesClientWithBaseURI(azureEnvironment.ResourceManagerEndpoint, *subscription.SubscriptionID)
+	client.Authorizer = AzureAuthorizer
+	client.ResponseInspector = azureResponseInspector(&subscription)
+
+	list, err := client.ListBySubscription(ctx)
+	if err != nil {
+		logger.Panic(err)
+	}
+
+	if list.Value == nil {
+		return
+	}
+
+	for _, val := range *list.Value {
+		if val.ProfileProperties == nil || val.Endpoints == nil {
+			continue
+		}
+
+		profileHostname := ""
+		if val.DNSConfig != nil {
+			profileHostname = to.String(val.DNSConfig.Fqdn)
+		}
+
+		for _, endpoint := range *val.Endpoints {
+			if endpoint.EndpointProperties == nil {
+				continue
+			}
+
+			addGlobalEndpoint(
+				"trafficManager",
+				to.String(val.ID),
+				profileHostname,
+				to.String(endpoint.TargetResourceID),
+				to.String(endpoint.Target),
+			)
+		}
+	}
+}
+
+// Collect Front Door (Standard/Premium) endpoint to origin edges (endpoint -> route -> origin group -> origin)
+func (m *MetricsCollectorAzureRmDependency) collectFrontDoorOrigins(ctx context.Context, logger *log.Entry, subscription subscriptions.Subscription, addGlobalEndpoint azureGlobalEndpointAddFunc) {
+	client := NewAzureRestClient(&subscription)
+
+	profileList, err := client.List(ctx, fmt.Sprintf("/subscriptions/%s/providers/Microsoft.Cdn/profiles", *subscription.SubscriptionID), AzureFrontDoorApiVersion)
+	if err != nil {
+		logger.Panic(err)
+	}
+
+	listResources := func(path string) (ret []azureFrontDoorResource) {
+		list, err := client.List(ctx, path, AzureFrontDoorApiVersion)
+		if err != nil {
+			logger.WithField("frontdoor", path).Error(err)
+			return
+		}
+
+		for _, row := range list {
+			resource := azureFrontDoorResource{}
+			if err := json.Unmarshal(row, &resource); err != nil {
+				logger.WithField("frontdoor", path).Error(err)
+				continue
+			}
+			ret = append(ret, resource)
+		}
+		return
+	}
+
+	for _, row := range profileList {
+		profile := azureFrontDoorResource{}
+		if err := json.Unmarshal(row, &profile); err != nil {
+			logger.Error(err)
+			continue
+		}
+
+		// classic CDN profiles don't have endpoints with origin groups
+		if profile.Sku == nil || !strings.HasSuffix(profile.Sku.Name, "_AzureFrontDoor") {
+			continue
+		}
+
+		// origins by (lowercase) origin group id
+		originGroupOrigins := map[string][]azureFrontDoorResource{}
+		for _, originGroup := range listResources(profile.ID + "/originGroups") {
+			originGroupOrigins[strings.ToLower(originGroup.ID)] = listResources(originGroup.ID + "/origins")
+		}
+
+		for _, endpoint := range listResources(profile.ID + "/afdEndpoints") {
+			for _, route := range listResources(endpoint.ID + "/routes") {
+				if route.Properties.OriginGroup == nil {
+					continue
+				}
+
+				for _, origin := range originGroupOrigins[strings.ToLower(route.Properties.OriginGroup.ID)] {
+					originId := ""
+					if origin.Properties.AzureOrigin != nil {
+						originId = origin.Properties.AzureOrigin.ID
+					}
+
+					addGlobalEndpoint(
+						"frontDoor",
+						endpoint.ID,
+						endpoint.Properties.HostName,
+						originId,
+						origin.Properties.HostName,
+					)
+				}
+			}
+		}
+	}
+}
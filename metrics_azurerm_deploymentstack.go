@@ -0,0 +1,249 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/Azure/azure-sdk-for-go/profiles/latest/resources/mgmt/resources"
+	"github.com/Azure/azure-sdk-for-go/profiles/latest/resources/mgmt/subscriptions"
+	"github.com/Azure/azure-sdk-for-go/services/preview/blueprint/mgmt/2018-11-01-preview/blueprint"
+	"github.com/Azure/go-autorest/autorest/to"
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+	prometheusCommon "github.com/webdevops/go-prometheus-common"
+	"strings"
+)
+
+const (
+	// deployment stacks are not available in the used Azure SDK version
+	AzureDeploymentStackApiVersion = "2022-08-01-preview"
+)
+
+type (
+	MetricsCollectorAzureRmDeploymentStack struct {
+		CollectorProcessorGeneral
+
+		prometheus struct {
+			deploymentStack          *prometheus.GaugeVec
+			deploymentStackResources *prometheus.GaugeVec
+			blueprintAssignment      *prometheus.GaugeVec
+		}
+	}
+
+	azureDeploymentStack struct {
+		ID         *string `json:"id"`
+		Name       *string `json:"name"`
+		Properties *struct {
+			ProvisioningState *string `json:"provisioningState"`
+			DenySettings      *struct {
+				Mode               *string `json:"mode"`
+				ApplyToChildScopes *bool   `json:"applyToChildScopes"`
+			} `json:"denySettings"`
+			ActionOnUnmanage *struct {
+				Resources      *string `json:"resources"`
+				ResourceGroups *string `json:"resourceGroups"`
+			} `json:"actionOnUnmanage"`
+			Resources *[]struct {
+				ID *string `json:"id"`
+			} `json:"resources"`
+		} `json:"properties"`
+	}
+)
+
+func (m *MetricsCollectorAzureRmDeploymentStack) Setup(collector *CollectorGeneral) {
+	m.CollectorReference = collector
+
+	m.prometheus.deploymentStack = m.newGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_deploymentstack_info",
+			Help: "Azure deployment stack information",
+		},
+		[]string{
+			"resourceID",
+			"subscriptionID",
+			"resourceGroup",
+			"name",
+			"provisioningState",
+			"denySettingsMode",
+			"denySettingsApplyToChildScopes",
+			"actionOnUnmanageResources",
+			"actionOnUnmanageResourceGroups",
+		},
+	)
+	m.MustRegister(m.prometheus.deploymentStack)
+
+	m.prometheus.deploymentStackResources = m.newGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_deploymentstack_resources",
+			Help: "Azure deployment stack managed resource count",
+		},
+		[]string{
+			"resourceID",
+			"subscriptionID",
+		},
+	)
+	m.MustRegister(m.prometheus.deploymentStackResources)
+
+	m.prometheus.blueprintAssignment = m.newGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_blueprint_assignment_info",
+			Help: "Azure blueprint assignment information",
+		},
+		[]string{
+			"resourceID",
+			"subscriptionID",
+			"name",
+			"displayName",
+			"blueprintID",
+			"provisioningState",
+			"lockMode",
+		},
+	)
+	m.MustRegister(m.prometheus.blueprintAssignment)
+}
+
+func (m *MetricsCollectorAzureRmDeploymentStack) Reset() {
+	m.prometheus.deploymentStack.Reset()
+	m.prometheus.deploymentStackResources.Reset()
+	m.prometheus.blueprintAssignment.Reset()
+}
+
+func (m *MetricsCollectorAzureRmDeploymentStack) Collect(ctx context.Context, logger *log.Entry, callback chan<- func(), subscription subscriptions.Subscription) {
+	m.collectDeploymentStacks(ctx, logger, callback, subscription)
+	m.collectBlueprintAssignments(ctx, logger, callback, subscription)
+}
+
+// Collect Azure deployment stacks (subscription and resourcegroup scope)
+func (m *MetricsCollectorAzureRmDeploymentStack) collectDeploymentStacks(ctx context.Context, logger *log.Entry, callback chan<- func(), subscription subscriptions.Subscription) {
+	client := NewAzureRestClient(&subscription)
+
+	groupClient := resources.NewGroupsClientWithBaseURI(azureEnvironment.ResourceManagerEndpoint, *subscription.SubscriptionID)
+	groupClient.Authorizer = AzureAuthorizer
+	groupClient.ResponseInspector = azureResponseInspector(&subscription)
+
+	scopeList := []string{
+		fmt.Sprintf("/subscriptions/%s", *subscription.SubscriptionID),
+	}
+
+	resourceGroupList, err := groupClient.ListComplete(ctx, "", nil)
+	if err != nil {
+		logger.Panic(err)
+	}
+
+	for resourceGroupList.NotDone() {
+		scopeList = append(scopeList, to.String(resourceGroupList.Value().ID))
+
+		if resourceGroupList.NextWithContext(ctx) != nil {
+			break
+		}
+	}
+
+	infoMetric := prometheusCommon.NewMetricsList()
+	resourcesMetric := prometheusCommon.NewMetricsList()
+
+	for _, scope := range scopeList {
+		list, err := client.List(ctx, scope+"/providers/Microsoft.Resources/deploymentStacks", AzureDeploymentStackApiVersion)
+		if err != nil {
+			logger.WithField("scope", scope).Error(err)
+			continue
+		}
+
+		for _, row := range list {
+			val := azureDeploymentStack{}
+			if err := json.Unmarshal(row, &val); err != nil {
+				logger.Error(err)
+				continue
+			}
+
+			resourceId := toResourceId(val.ID)
+
+			infoLabels := prometheus.Labels{
+				"resourceID":                     resourceId,
+				"subscriptionID":                 to.String(subscription.SubscriptionID),
+				"resourceGroup":                  extractResourceGroupFromAzureId(to.String(val.ID)),
+				"name":                           to.String(val.Name),
+				"provisioningState":              "",
+				"denySettingsMode":               "",
+				"denySettingsApplyToChildScopes": "",
+				"actionOnUnmanageResources":      "",
+				"actionOnUnmanageResourceGroups": "",
+			}
+
+			if val.Properties != nil {
+				infoLabels["provisioningState"] = strings.ToLower(to.String(val.Properties.ProvisioningState))
+
+				if val.Properties.DenySettings != nil {
+					infoLabels["denySettingsMode"] = to.String(val.Properties.DenySettings.Mode)
+					infoLabels["denySettingsApplyToChildScopes"] = boolToString(to.Bool(val.Properties.DenySettings.ApplyToChildScopes))
+				}
+
+				if val.Properties.ActionOnUnmanage != nil {
+					infoLabels["actionOnUnmanageResources"] = to.String(val.Properties.ActionOnUnmanage.Resources)
+					infoLabels["actionOnUnmanageResourceGroups"] = to.String(val.Properties.ActionOnUnmanage.ResourceGroups)
+				}
+
+				if val.Properties.Resources != nil {
+					resourcesMetric.Add(prometheus.Labels{
+						"resourceID":     resourceId,
+						"subscriptionID": to.String(subscription.SubscriptionID),
+					}, float64(len(*val.Properties.Resources)))
+				}
+			}
+
+			infoMetric.AddInfo(infoLabels)
+		}
+	}
+
+	callback <- func() {
+		infoMetric.GaugeSet(m.prometheus.deploymentStack)
+		resourcesMetric.GaugeSet(m.prometheus.deploymentStackResources)
+	}
+}
+
+// Collect Azure blueprint assignments (legacy)
+func (m *MetricsCollectorAzureRmDeploymentStack) collectBlueprintAssignments(ctx context.Context, logger *log.Entry, callback chan<- func(), subscription subscriptions.Subscription) {
+	client := blueprint.NewAssignmentsClientWithBaseURI(azureEnvironment.ResourceManagerEndpoint)
+	client.Authorizer = AzureAuthorizer
+	client.ResponseInspector = azureResponseInspector(&subscription)
+
+	list, err := client.ListComplete(ctx, fmt.Sprintf("subscriptions/%s", *subscription.SubscriptionID))
+	if err != nil {
+		logger.Panic(err)
+	}
+
+	infoMetric := prometheusCommon.NewMetricsList()
+
+	for list.NotDone() {
+		val := list.Value()
+
+		infoLabels := prometheus.Labels{
+			"resourceID":        toResourceId(val.ID),
+			"subscriptionID":    to.String(subscription.SubscriptionID),
+			"name":              to.String(val.Name),
+			"displayName":       "",
+			"blueprintID":       "",
+			"provisioningState": "",
+			"lockMode":          "",
+		}
+
+		if val.AssignmentProperties != nil {
+			infoLabels["displayName"] = to.String(val.DisplayName)
+			infoLabels["blueprintID"] = toResourceId(val.BlueprintID)
+			infoLabels["provisioningState"] = strings.ToLower(string(val.ProvisioningState))
+
+			if val.Locks != nil {
+				infoLabels["lockMode"] = string(val.Locks.Mode)
+			}
+		}
+
+		infoMetric.AddInfo(infoLabels)
+
+		if list.NextWithContext(ctx) != nil {
+			break
+		}
+	}
+
+	callback <- func() {
+		infoMetric.GaugeSet(m.prometheus.blueprintAssignment)
+	}
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"context"
+	"github.com/Azure/azure-sdk-for-go/profiles/latest/compute/mgmt/compute"
+	"github.com/Azure/azure-sdk-for-go/profiles/latest/resources/mgmt/subscriptions"
+	"github.com/Azure/go-autorest/autorest/to"
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+	prometheusCommon "github.com/webdevops/go-prometheus-common"
+	"sort"
+	"strings"
+)
+
+type MetricsCollectorAzureRmDisk struct {
+	CollectorProcessorGeneral
+
+	prometheus struct {
+		disk       *prometheus.GaugeVec
+		diskSize   *prometheus.GaugeVec
+		diskStatus *prometheus.GaugeVec
+	}
+}
+
+func (m *MetricsCollectorAzureRmDisk) Setup(collector *CollectorGeneral) {
+	m.CollectorReference = collector
+
+	m.prometheus.disk = m.newGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_disk_info",
+			Help: "Azure managed disk information",
+		},
+		append(
+			[]string{
+				"resourceID",
+				"subscriptionID",
+				"resourceGroup",
+				"name",
+				"location",
+				"skuName",
+				"encryptionType",
+				"zone",
+			},
+			azureResourceTags.prometheusLabels...,
+		),
+	)
+	m.MustRegister(m.prometheus.disk)
+
+	m.prometheus.diskSize = m.newGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_disk_size_gb",
+			Help: "Azure managed disk size in GB",
+		},
+		[]string{
+			"resourceID",
+			"subscriptionID",
+		},
+	)
+	m.MustRegister(m.prometheus.diskSize)
+
+	m.prometheus.diskStatus = m.newGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_disk_status",
+			Help: "Azure managed disk attachment status (1 if attached to a virtual machine, 0 if unattached)",
+		},
+		[]string{
+			"resourceID",
+			"subscriptionID",
+			"diskState",
+			"managedBy",
+		},
+	)
+	m.MustRegister(m.prometheus.diskStatus)
+}
+
+func (m *MetricsCollectorAzureRmDisk) Reset() {
+	m.prometheus.disk.Reset()
+	m.prometheus.diskSize.Reset()
+	m.prometheus.diskStatus.Reset()
+}
+
+// Collect Azure managed disks with size and attachment status (eg. for detection of orphaned disks)
+func (m *MetricsCollectorAzureRmDisk) Collect(ctx context.Context, logger *log.Entry, callback chan<- func(), subscription subscriptions.Subscription) {
+	client := compute.NewDisksClientWithBaseURI(azureEnvironment.ResourceManagerEndpoint, *subscription.SubscriptionID)
+	client.Authorizer = AzureAuthorizer
+	client.ResponseInspector = azureResponseInspector(&subscription)
+
+	list, err := client.ListComplete(ctx)
+	if err != nil {
+		logger.Panic(err)
+	}
+
+	infoMetric := prometheusCommon.NewMetricsList()
+	sizeMetric := prometheusCommon.NewMetricsList()
+	statusMetric := prometheusCommon.NewMetricsList()
+
+	for list.NotDone() {
+		val := list.Value()
+		resourceId := toResourceId(val.ID)
+
+		zoneList := []string{}
+		if val.Zones != nil {
+			zoneList = append(zoneList, *val.Zones...)
+		}
+		sort.Strings(zoneList)
+
+		infoLabels := prometheus.Labels{
+			"resourceID":     resourceId,
+			"subscriptionID": to.String(subscription.SubscriptionID),
+			"resourceGroup":  extractResourceGroupFromAzureId(to.String(val.ID)),
+			"name":           to.String(val.Name),
+			"location":       strings.ToLower(to.String(val.Location)),
+			"skuName":        "",
+			"encryptionType": "",
+			"zone":           strings.Join(zoneList, ","),
+		}
+
+		if val.Sku != nil {
+			infoLabels["skuName"] = string(val.Sku.Name)
+		}
+
+		statusLabels := prometheus.Labels{
+			"resourceID":     resourceId,
+			"subscriptionID": to.String(subscription.SubscriptionID),
+			"diskState":      "",
+			"managedBy":      toResourceId(val.ManagedBy),
+		}
+
+		if val.DiskProperties != nil {
+			if val.Encryption != nil {
+				infoLabels["encryptionType"] = string(val.Encryption.Type)
+			}
+
+			statusLabels["diskState"] = strings.ToLower(string(val.DiskState))
+
+			sizeMetric.Add(prometheus.Labels{
+				"resourceID":     resourceId,
+				"subscriptionID": to.String(subscription.SubscriptionID),
+			}, float64(to.Int32(val.DiskSizeGB)))
+		}
+
+		infoLabels = azureResourceTags.appendPrometheusLabel(infoLabels, val.Tags)
+		infoMetric.AddInfo(infoLabels)
+
+		// disks attached to (possibly deallocated) virtual machines are managed by the vm
+		attached := float64(0)
+		if to.String(val.ManagedBy) != "" {
+			attached = 1
+		}
+		statusMetric.Add(statusLabels, attached)
+
+		if list.NextWithContext(ctx) != nil {
+			break
+		}
+	}
+
+	callback <- func() {
+		infoMetric.GaugeSet(m.prometheus.disk)
+		sizeMetric.GaugeSet(m.prometheus.diskSize)
+		statusMetric.GaugeSet(m.prometheus.diskStatus)
+	}
+}
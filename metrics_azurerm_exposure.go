@@ -0,0 +1,260 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/Azure/azure-sdk-for-go/profiles/latest/resources/mgmt/subscriptions"
+	"github.com/Azure/azure-sdk-for-go/profiles/latest/web/mgmt/web"
+	"github.com/Azure/azure-sdk-for-go/services/preview/sql/mgmt/v5.0/sql"
+	"github.com/Azure/go-autorest/autorest/to"
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+	prometheusCommon "github.com/webdevops/go-prometheus-common"
+	"strings"
+)
+
+const (
+	// publicNetworkAccess is not available for storage accounts and key vaults in the used Azure SDK version
+	AzureExposureStorageApiVersion  = "2021-09-01"
+	AzureExposureKeyVaultApiVersion = "2021-10-01"
+)
+
+type MetricsCollectorAzureRmExposure struct {
+	CollectorProcessorGeneral
+
+	prometheus struct {
+		publicExposure *prometheus.GaugeVec
+	}
+}
+
+// azureExposureResource is a storage account or key vault with its network settings
+type azureExposureResource struct {
+	ID         string `json:"id"`
+	Name       string `json:"name"`
+	Properties *struct {
+		PublicNetworkAccess string `json:"publicNetworkAccess"`
+
+		// storage account
+		NetworkAcls *struct {
+			DefaultAction string `json:"defaultAction"`
+		} `json:"networkAcls"`
+		AllowBlobPublicAccess *bool `json:"allowBlobPublicAccess"`
+	} `json:"properties"`
+}
+
+func (m *MetricsCollectorAzureRmExposure) Setup(collector *CollectorGeneral) {
+	m.CollectorReference = collector
+
+	m.prometheus.publicExposure = m.newGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_resource_public_exposure",
+			Help: "Azure resource is reachable from public networks (1 if exposed, reason lists the network settings)",
+		},
+		[]string{
+			"resourceID",
+			"subscriptionID",
+			"resourceGroup",
+			"name",
+			"resourceType",
+			"reason",
+		},
+	)
+	m.MustRegister(m.prometheus.publicExposure)
+}
+
+func (m *MetricsCollectorAzureRmExposure) Reset() {
+	m.prometheus.publicExposure.Reset()
+}
+
+func (m *MetricsCollectorAzureRmExposure) Collect(ctx context.Context, logger *log.Entry, callback chan<- func(), subscription subscriptions.Subscription) {
+	exposureMetric := prometheusCommon.NewMetricsList()
+
+	addResource := func(resourceId, name *string, resourceType string, reasons []string) {
+		labels := prometheus.Labels{
+			"resourceID":     toResourceId(resourceId),
+			"subscriptionID": to.String(subscription.SubscriptionID),
+			"resourceGroup":  extractResourceGroupFromAzureId(to.String(resourceId)),
+			"name":           to.String(name),
+			"resourceType":   resourceType,
+			"reason":         strings.Join(reasons, ","),
+		}
+
+		if len(reasons) > 0 {
+			exposureMetric.Add(labels, 1)
+		} else {
+			exposureMetric.Add(labels, 0)
+		}
+	}
+
+	m.collectStorageAccounts(ctx, logger, subscription, addResource)
+	m.collectSqlServers(ctx, logger, subscription, addResource)
+	m.collectKeyVaults(ctx, logger, subscription, addResource)
+	m.collectAppServices(ctx, logger, subscription, addResource)
+
+	callback <- func() {
+		exposureMetric.GaugeSet(m.prometheus.publicExposure)
+	}
+}
+
+// storage accounts are exposed if public network access is not disabled and the network default action allows all networks,
+// anonymous blob access is reported additionally
+func (m *MetricsCollectorAzureRmExposure) collectStorageAccounts(ctx context.Context, logger *log.Entry, subscription subscriptions.Subscription, addResource func(*string, *string, string, []string)) {
+	for _, val := range m.listResources(ctx, logger, subscription, "Microsoft.Storage/storageAccounts", AzureExposureStorageApiVersion) {
+		reasons := []string{}
+		if val.Properties != nil && !strings.EqualFold(val.Properties.PublicNetworkAccess, "Disabled") {
+			if val.Properties.NetworkAcls == nil || strings.EqualFold(val.Properties.NetworkAcls.DefaultAction, "Allow") {
+				reasons = append(reasons, "networkDefaultAllow")
+			}
+
+			// anonymous blob access defaults to allowed
+			if val.Properties.AllowBlobPublicAccess == nil || *val.Properties.AllowBlobPublicAccess {
+				reasons = append(reasons, "blobPublicAccess")
+			}
+		}
+
+		addResource(&val.ID, &val.Name, "storageAccount", reasons)
+	}
+}
+
+// sql servers are exposed if the public endpoint is enabled and any firewall rule allows access
+func (m *MetricsCollectorAzureRmExposure) collectSqlServers(ctx context.Context, logger *log.Entry, subscription subscriptions.Subscription, addResource func(*string, *string, string, []string)) {
+	client := sql.NewServersClientWithBaseURI(azureEnvironment.ResourceManagerEndpoint, *subscription.SubscriptionID)
+	client.Authorizer = AzureAuthorizer
+	client.ResponseInspector = azureResponseInspector(&subscription)
+
+	firewallClient := sql.NewFirewallRulesClientWithBaseURI(azureEnvironment.ResourceManagerEndpoint, *subscription.SubscriptionID)
+	firewallClient.Authorizer = AzureAuthorizer
+	firewallClient.ResponseInspector = azureResponseInspector(&subscription)
+
+	list, err := client.ListComplete(ctx, "")
+	if err != nil {
+		logger.Panic(err)
+	}
+
+	for list.NotDone() {
+		val := list.Value()
+
+		reasons := []string{}
+		if val.ServerProperties != nil && val.PublicNetworkAccess != sql.ServerNetworkAccessFlagDisabled {
+			firewallList, err := firewallClient.ListByServerComplete(ctx, extractResourceGroupFromAzureId(to.String(val.ID)), to.String(val.Name))
+			if err != nil {
+				logger.WithField("sqlServer", to.String(val.Name)).Error(err)
+			} else {
+				firewallRuleCount := 0
+				for firewallList.NotDone() {
+					rule := firewallList.Value()
+					firewallRuleCount++
+
+					if rule.ServerFirewallRuleProperties != nil && to.String(rule.StartIPAddress) == "0.0.0.0" && to.String(rule.EndIPAddress) == "255.255.255.255" {
+						reasons = append(reasons, "firewallAllowAll")
+					}
+
+					if firewallList.NextWithContext(ctx) != nil {
+						break
+					}
+				}
+
+				if firewallRuleCount > 0 {
+					reasons = append([]string{"publicNetworkAccess"}, reasons...)
+				}
+			}
+		}
+
+		addResource(val.ID, val.Name, "sqlServer", reasons)
+
+		if list.NextWithContext(ctx) != nil {
+			break
+		}
+	}
+}
+
+// key vaults are exposed if public network access is not disabled and the network default action allows all networks
+func (m *MetricsCollectorAzureRmExposure) collectKeyVaults(ctx context.Context, logger *log.Entry, subscription subscriptions.Subscription, addResource func(*string, *string, string, []string)) {
+	for _, val := range m.listResources(ctx, logger, subscription, "Microsoft.KeyVault/vaults", AzureExposureKeyVaultApiVersion) {
+		reasons := []string{}
+		if val.Properties != nil && !strings.EqualFold(val.Properties.PublicNetworkAccess, "Disabled") {
+			if val.Properties.NetworkAcls == nil || strings.EqualFold(val.Properties.NetworkAcls.DefaultAction, "Allow") {
+				reasons = append(reasons, "networkDefaultAllow")
+			}
+		}
+
+		addResource(&val.ID, &val.Name, "keyVault", reasons)
+	}
+}
+
+// listResources lists all resources of the type in the subscription with the network settings
+func (m *MetricsCollectorAzureRmExposure) listResources(ctx context.Context, logger *log.Entry, subscription subscriptions.Subscription, resourceType string, apiVersion string) (ret []azureExposureResource) {
+	client := NewAzureRestClient(&subscription)
+
+	list, err := client.List(ctx, fmt.Sprintf("/subscriptions/%s/providers/%s", *subscription.SubscriptionID, resourceType), apiVersion)
+	if err != nil {
+		logger.Panic(err)
+	}
+
+	for _, row := range list {
+		resource := azureExposureResource{}
+		if err := json.Unmarshal(row, &resource); err != nil {
+			logger.WithField("resourceType", resourceType).Error(err)
+			continue
+		}
+		ret = append(ret, resource)
+	}
+
+	return
+}
+
+// app services are exposed if public network access is not disabled and no access restriction limits the sources
+func (m *MetricsCollectorAzureRmExposure) collectAppServices(ctx context.Context, logger *log.Entry, subscription subscriptions.Subscription, addResource func(*string, *string, string, []string)) {
+	client := web.NewAppsClientWithBaseURI(azureEnvironment.ResourceManagerEndpoint, *subscription.SubscriptionID)
+	client.Authorizer = AzureAuthorizer
+	client.ResponseInspector = azureResponseInspector(&subscription)
+
+	list, err := client.ListComplete(ctx)
+	if err != nil {
+		logger.Panic(err)
+	}
+
+	for list.NotDone() {
+		val := list.Value()
+
+		siteConfig, err := client.GetConfiguration(ctx, extractResourceGroupFromAzureId(to.String(val.ID)), to.String(val.Name))
+		if err != nil {
+			logger.WithField("appService", to.String(val.Name)).Error(err)
+		} else {
+			reasons := []string{}
+			if siteConfig.SiteConfig != nil && !strings.EqualFold(to.String(siteConfig.PublicNetworkAccess), "Disabled") {
+				if appServiceAccessRestrictionsAllowAll(siteConfig.IPSecurityRestrictions) {
+					reasons = append(reasons, "accessRestrictionAllowAll")
+				}
+			}
+
+			addResource(val.ID, val.Name, "appService", reasons)
+		}
+
+		if list.NextWithContext(ctx) != nil {
+			break
+		}
+	}
+}
+
+// appServiceAccessRestrictionsAllowAll checks if the access restrictions allow any source
+// (no restrictions means the implicit "Allow all" rule)
+func appServiceAccessRestrictionsAllowAll(restrictions *[]web.IPSecurityRestriction) bool {
+	if restrictions == nil || len(*restrictions) == 0 {
+		return true
+	}
+
+	for _, restriction := range *restrictions {
+		if !strings.EqualFold(to.String(restriction.Action), "Allow") || restriction.VnetSubnetResourceID != nil {
+			continue
+		}
+
+		switch strings.ToLower(to.String(restriction.IPAddress)) {
+		case "any", "0.0.0.0/0", "::/0":
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"github.com/Azure/azure-sdk-for-go/profiles/latest/network/mgmt/network"
+	"github.com/Azure/azure-sdk-for-go/profiles/latest/resources/mgmt/subscriptions"
+	"github.com/Azure/go-autorest/autorest/to"
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+	prometheusCommon "github.com/webdevops/go-prometheus-common"
+	"strings"
+)
+
+type MetricsCollectorAzureRmExpressRoute struct {
+	CollectorProcessorGeneral
+
+	prometheus struct {
+		port          *prometheus.GaugeVec
+		portBandwidth *prometheus.GaugeVec
+		portCircuits  *prometheus.GaugeVec
+	}
+}
+
+func (m *MetricsCollectorAzureRmExpressRoute) Setup(collector *CollectorGeneral) {
+	m.CollectorReference = collector
+
+	m.prometheus.port = m.newGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_expressrouteport_info",
+			Help: "Azure ExpressRoute Direct port information",
+		},
+		append(
+			[]string{
+				"resourceID",
+				"subscriptionID",
+				"resourceGroup",
+				"name",
+				"location",
+				"peeringLocation",
+				"encapsulation",
+				"provisioningState",
+			},
+			azureResourceTags.prometheusLabels...,
+		),
+	)
+	m.MustRegister(m.prometheus.port)
+
+	m.prometheus.portBandwidth = m.newGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_expressrouteport_bandwidth_gbps",
+			Help: "Azure ExpressRoute Direct port bandwidth in Gbps",
+		},
+		[]string{
+			"resourceID",
+			"subscriptionID",
+			"type",
+		},
+	)
+	m.MustRegister(m.prometheus.portBandwidth)
+
+	m.prometheus.portCircuits = m.newGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_expressrouteport_circuits",
+			Help: "Azure ExpressRoute Direct port allocated circuit count",
+		},
+		[]string{
+			"resourceID",
+			"subscriptionID",
+		},
+	)
+	m.MustRegister(m.prometheus.portCircuits)
+}
+
+func (m *MetricsCollectorAzureRmExpressRoute) Reset() {
+	m.prometheus.port.Reset()
+	m.prometheus.portBandwidth.Reset()
+	m.prometheus.portCircuits.Reset()
+}
+
+func (m *MetricsCollectorAzureRmExpressRoute) Collect(ctx context.Context, logger *log.Entry, callback chan<- func(), subscription subscriptions.Subscription) {
+	client := network.NewExpressRoutePortsClientWithBaseURI(azureEnvironment.ResourceManagerEndpoint, *subscription.SubscriptionID)
+	client.Authorizer = AzureAuthorizer
+	client.ResponseInspector = azureResponseInspector(&subscription)
+
+	list, err := client.ListComplete(ctx)
+	if err != nil {
+		logger.Panic(err)
+	}
+
+	infoMetric := prometheusCommon.NewMetricsList()
+	bandwidthMetric := prometheusCommon.NewMetricsList()
+	circuitsMetric := prometheusCommon.NewMetricsList()
+
+	for list.NotDone() {
+		val := list.Value()
+		resourceId := toResourceId(val.ID)
+
+		infoLabels := prometheus.Labels{
+			"resourceID":        resourceId,
+			"subscriptionID":    to.String(subscription.SubscriptionID),
+			"resourceGroup":     extractResourceGroupFromAzureId(to.String(val.ID)),
+			"name":              to.String(val.Name),
+			"location":          to.String(val.Location),
+			"peeringLocation":   "",
+			"encapsulation":     "",
+			"provisioningState": "",
+		}
+
+		if val.ExpressRoutePortPropertiesFormat != nil {
+			infoLabels["peeringLocation"] = to.String(val.PeeringLocation)
+			infoLabels["encapsulation"] = string(val.Encapsulation)
+			infoLabels["provisioningState"] = strings.ToLower(string(val.ProvisioningState))
+
+			if val.BandwidthInGbps != nil {
+				bandwidthMetric.Add(prometheus.Labels{
+					"resourceID":     resourceId,
+					"subscriptionID": to.String(subscription.SubscriptionID),
+					"type":           "port",
+				}, float64(*val.BandwidthInGbps))
+			}
+
+			if val.ProvisionedBandwidthInGbps != nil {
+				bandwidthMetric.Add(prometheus.Labels{
+					"resourceID":     resourceId,
+					"subscriptionID": to.String(subscription.SubscriptionID),
+					"type":           "provisioned",
+				}, *val.ProvisionedBandwidthInGbps)
+			}
+
+			circuitCount := 0
+			if val.Circuits != nil {
+				circuitCount = len(*val.Circuits)
+			}
+
+			circuitsMetric.Add(prometheus.Labels{
+				"resourceID":     resourceId,
+				"subscriptionID": to.String(subscription.SubscriptionID),
+			}, float64(circuitCount))
+		}
+
+		infoLabels = azureResourceTags.appendPrometheusLabel(infoLabels, val.Tags)
+		infoMetric.AddInfo(infoLabels)
+
+		if list.NextWithContext(ctx) != nil {
+			break
+		}
+	}
+
+	callback <- func() {
+		infoMetric.GaugeSet(m.prometheus.port)
+		bandwidthMetric.GaugeSet(m.prometheus.portBandwidth)
+		circuitsMetric.GaugeSet(m.prometheus.portCircuits)
+	}
+}
@@ -0,0 +1,371 @@
+package main
+
+import (
+	"context"
+	"github.com/Azure/azure-sdk-for-go/profiles/latest/network/mgmt/network"
+	"github.com/Azure/azure-sdk-for-go/profiles/latest/resources/mgmt/subscriptions"
+	"github.com/Azure/go-autorest/autorest/to"
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+	prometheusCommon "github.com/webdevops/go-prometheus-common"
+	"strings"
+)
+
+const (
+	// Azure Firewall Policy service limits
+	// see https://docs.microsoft.com/en-us/azure/azure-resource-manager/management/azure-subscription-service-limits#azure-firewall-limits
+	AzureFirewallPolicyRuleCollectionGroupLimit = 100
+	AzureFirewallPolicyRuleLimit                = 60000
+)
+
+type MetricsCollectorAzureRmFirewall struct {
+	CollectorProcessorGeneral
+
+	prometheus struct {
+		firewallPolicy             *prometheus.GaugeVec
+		firewallPolicyLimitCurrent *prometheus.GaugeVec
+		firewallPolicyLimitLimit   *prometheus.GaugeVec
+		firewallPolicyLimitUsage   *prometheus.GaugeVec
+
+		ipGroup      *prometheus.GaugeVec
+		ipGroupCidrs *prometheus.GaugeVec
+		ipGroupRules *prometheus.GaugeVec
+	}
+}
+
+func (m *MetricsCollectorAzureRmFirewall) Setup(collector *CollectorGeneral) {
+	m.CollectorReference = collector
+
+	m.prometheus.firewallPolicy = m.newGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_firewallpolicy_info",
+			Help: "Azure Firewall Policy information",
+		},
+		append(
+			[]string{
+				"resourceID",
+				"subscriptionID",
+				"resourceGroup",
+				"name",
+				"location",
+				"skuTier",
+				"threatIntelMode",
+				"basePolicyID",
+				"provisioningState",
+			},
+			azureResourceTags.prometheusLabels...,
+		),
+	)
+	m.MustRegister(m.prometheus.firewallPolicy)
+
+	m.prometheus.firewallPolicyLimitCurrent = m.newGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_firewallpolicy_limit_current",
+			Help: "Azure Firewall Policy current value (rule collection groups and rules)",
+		},
+		[]string{
+			"resourceID",
+			"subscriptionID",
+			"limit",
+		},
+	)
+	m.MustRegister(m.prometheus.firewallPolicyLimitCurrent)
+
+	m.prometheus.firewallPolicyLimitLimit = m.newGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_firewallpolicy_limit_limit",
+			Help: "Azure Firewall Policy service limit (rule collection groups and rules)",
+		},
+		[]string{
+			"resourceID",
+			"subscriptionID",
+			"limit",
+		},
+	)
+	m.MustRegister(m.prometheus.firewallPolicyLimitLimit)
+
+	m.prometheus.firewallPolicyLimitUsage = m.newGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_firewallpolicy_limit_usage",
+			Help: "Azure Firewall Policy service limit usage in percent",
+		},
+		[]string{
+			"resourceID",
+			"subscriptionID",
+			"limit",
+		},
+	)
+	m.MustRegister(m.prometheus.firewallPolicyLimitUsage)
+
+	m.prometheus.ipGroup = m.newGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_ipgroup_info",
+			Help: "Azure IP Group information",
+		},
+		append(
+			[]string{
+				"resourceID",
+				"subscriptionID",
+				"resourceGroup",
+				"name",
+				"location",
+				"provisioningState",
+			},
+			azureResourceTags.prometheusLabels...,
+		),
+	)
+	m.MustRegister(m.prometheus.ipGroup)
+
+	m.prometheus.ipGroupCidrs = m.newGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_ipgroup_cidrs",
+			Help: "Azure IP Group member CIDR count",
+		},
+		[]string{
+			"resourceID",
+			"subscriptionID",
+		},
+	)
+	m.MustRegister(m.prometheus.ipGroupCidrs)
+
+	m.prometheus.ipGroupRules = m.newGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_ipgroup_firewallpolicy_rules",
+			Help: "Azure IP Group count of referencing Firewall Policy rules",
+		},
+		[]string{
+			"resourceID",
+			"subscriptionID",
+		},
+	)
+	m.MustRegister(m.prometheus.ipGroupRules)
+}
+
+func (m *MetricsCollectorAzureRmFirewall) Reset() {
+	m.prometheus.firewallPolicy.Reset()
+	m.prometheus.firewallPolicyLimitCurrent.Reset()
+	m.prometheus.firewallPolicyLimitLimit.Reset()
+	m.prometheus.firewallPolicyLimitUsage.Reset()
+	m.prometheus.ipGroup.Reset()
+	m.prometheus.ipGroupCidrs.Reset()
+	m.prometheus.ipGroupRules.Reset()
+}
+
+func (m *MetricsCollectorAzureRmFirewall) Collect(ctx context.Context, logger *log.Entry, callback chan<- func(), subscription subscriptions.Subscription) {
+	ipGroupRuleCount := m.collectFirewallPolicies(ctx, logger, callback, subscription)
+	m.collectIpGroups(ctx, logger, callback, subscription, ipGroupRuleCount)
+}
+
+// Collect Azure Firewall Policies and their rule collection groups, returns the count of referencing rules per IP Group
+func (m *MetricsCollectorAzureRmFirewall) collectFirewallPolicies(ctx context.Context, logger *log.Entry, callback chan<- func(), subscription subscriptions.Subscription) (ipGroupRuleCount map[string]float64) {
+	client := network.NewFirewallPoliciesClientWithBaseURI(azureEnvironment.ResourceManagerEndpoint, *subscription.SubscriptionID)
+	client.Authorizer = AzureAuthorizer
+	client.ResponseInspector = azureResponseInspector(&subscription)
+
+	ruleCollectionGroupClient := network.NewFirewallPolicyRuleCollectionGroupsClientWithBaseURI(azureEnvironment.ResourceManagerEndpoint, *subscription.SubscriptionID)
+	ruleCollectionGroupClient.Authorizer = AzureAuthorizer
+	ruleCollectionGroupClient.ResponseInspector = azureResponseInspector(&subscription)
+
+	list, err := client.ListAllComplete(ctx)
+	if err != nil {
+		logger.Panic(err)
+	}
+
+	infoMetric := prometheusCommon.NewMetricsList()
+	limitCurrentMetric := prometheusCommon.NewMetricsList()
+	limitLimitMetric := prometheusCommon.NewMetricsList()
+	limitUsageMetric := prometheusCommon.NewMetricsList()
+	ipGroupRuleCount = map[string]float64{}
+
+	for list.NotDone() {
+		val := list.Value()
+		resourceId := toResourceId(val.ID)
+
+		infoLabels := prometheus.Labels{
+			"resourceID":        resourceId,
+			"subscriptionID":    to.String(subscription.SubscriptionID),
+			"resourceGroup":     extractResourceGroupFromAzureId(to.String(val.ID)),
+			"name":              to.String(val.Name),
+			"location":          to.String(val.Location),
+			"skuTier":           "",
+			"threatIntelMode":   "",
+			"basePolicyID":      "",
+			"provisioningState": "",
+		}
+
+		if val.FirewallPolicyPropertiesFormat != nil {
+			infoLabels["threatIntelMode"] = string(val.ThreatIntelMode)
+			infoLabels["provisioningState"] = strings.ToLower(string(val.ProvisioningState))
+
+			if val.Sku != nil {
+				infoLabels["skuTier"] = string(val.Sku.Tier)
+			}
+
+			if val.BasePolicy != nil {
+				infoLabels["basePolicyID"] = toResourceId(val.BasePolicy.ID)
+			}
+		}
+
+		infoLabels = azureResourceTags.appendPrometheusLabel(infoLabels, val.Tags)
+		infoMetric.AddInfo(infoLabels)
+
+		ruleCollectionGroupList, err := ruleCollectionGroupClient.ListComplete(ctx, extractResourceGroupFromAzureId(to.String(val.ID)), to.String(val.Name))
+		if err != nil {
+			logger.WithField("firewallPolicy", to.String(val.Name)).Error(err)
+		} else {
+			limitCurrent := map[string]float64{
+				"ruleCollectionGroups": 0,
+				"rules":                0,
+			}
+			limitLimit := map[string]float64{
+				"ruleCollectionGroups": AzureFirewallPolicyRuleCollectionGroupLimit,
+				"rules":                AzureFirewallPolicyRuleLimit,
+			}
+
+			for ruleCollectionGroupList.NotDone() {
+				ruleCollectionGroup := ruleCollectionGroupList.Value()
+				limitCurrent["ruleCollectionGroups"]++
+
+				if ruleCollectionGroup.FirewallPolicyRuleCollectionGroupProperties != nil && ruleCollectionGroup.RuleCollections != nil {
+					for _, ruleCollection := range *ruleCollectionGroup.RuleCollections {
+						for _, rule := range firewallPolicyRuleCollectionRules(ruleCollection) {
+							limitCurrent["rules"]++
+
+							for ipGroupId := range firewallPolicyRuleIpGroups(rule) {
+								ipGroupRuleCount[ipGroupId]++
+							}
+						}
+					}
+				}
+
+				if ruleCollectionGroupList.NextWithContext(ctx) != nil {
+					break
+				}
+			}
+
+			for limitName, currentValue := range limitCurrent {
+				labels := prometheus.Labels{
+					"resourceID":     resourceId,
+					"subscriptionID": to.String(subscription.SubscriptionID),
+					"limit":          limitName,
+				}
+
+				limitCurrentMetric.Add(labels, currentValue)
+				limitLimitMetric.Add(labels, limitLimit[limitName])
+				limitUsageMetric.Add(labels, currentValue/limitLimit[limitName])
+			}
+		}
+
+		if list.NextWithContext(ctx) != nil {
+			break
+		}
+	}
+
+	callback <- func() {
+		infoMetric.GaugeSet(m.prometheus.firewallPolicy)
+		limitCurrentMetric.GaugeSet(m.prometheus.firewallPolicyLimitCurrent)
+		limitLimitMetric.GaugeSet(m.prometheus.firewallPolicyLimitLimit)
+		limitUsageMetric.GaugeSet(m.prometheus.firewallPolicyLimitUsage)
+	}
+
+	return
+}
+
+// Collect Azure IP Groups
+func (m *MetricsCollectorAzureRmFirewall) collectIpGroups(ctx context.Context, logger *log.Entry, callback chan<- func(), subscription subscriptions.Subscription, ipGroupRuleCount map[string]float64) {
+	client := network.NewIPGroupsClientWithBaseURI(azureEnvironment.ResourceManagerEndpoint, *subscription.SubscriptionID)
+	client.Authorizer = AzureAuthorizer
+	client.ResponseInspector = azureResponseInspector(&subscription)
+
+	list, err := client.ListComplete(ctx)
+	if err != nil {
+		logger.Panic(err)
+	}
+
+	infoMetric := prometheusCommon.NewMetricsList()
+	cidrsMetric := prometheusCommon.NewMetricsList()
+	rulesMetric := prometheusCommon.NewMetricsList()
+
+	for list.NotDone() {
+		val := list.Value()
+		resourceId := toResourceId(val.ID)
+
+		infoLabels := prometheus.Labels{
+			"resourceID":        resourceId,
+			"subscriptionID":    to.String(subscription.SubscriptionID),
+			"resourceGroup":     extractResourceGroupFromAzureId(to.String(val.ID)),
+			"name":              to.String(val.Name),
+			"location":          to.String(val.Location),
+			"provisioningState": "",
+		}
+
+		cidrCount := 0
+		if val.IPGroupPropertiesFormat != nil {
+			infoLabels["provisioningState"] = strings.ToLower(string(val.ProvisioningState))
+
+			if val.IPAddresses != nil {
+				cidrCount = len(*val.IPAddresses)
+			}
+		}
+
+		infoLabels = azureResourceTags.appendPrometheusLabel(infoLabels, val.Tags)
+		infoMetric.AddInfo(infoLabels)
+
+		labels := prometheus.Labels{
+			"resourceID":     resourceId,
+			"subscriptionID": to.String(subscription.SubscriptionID),
+		}
+		cidrsMetric.Add(labels, float64(cidrCount))
+		rulesMetric.Add(labels, ipGroupRuleCount[strings.ToLower(to.String(val.ID))])
+
+		if list.NextWithContext(ctx) != nil {
+			break
+		}
+	}
+
+	callback <- func() {
+		infoMetric.GaugeSet(m.prometheus.ipGroup)
+		cidrsMetric.GaugeSet(m.prometheus.ipGroupCidrs)
+		rulesMetric.GaugeSet(m.prometheus.ipGroupRules)
+	}
+}
+
+// returns the rules of a (polymorphic) firewall policy rule collection
+func firewallPolicyRuleCollectionRules(ruleCollection network.BasicFirewallPolicyRuleCollection) []network.BasicFirewallPolicyRule {
+	if filterRuleCollection, ok := ruleCollection.AsFirewallPolicyFilterRuleCollection(); ok && filterRuleCollection.Rules != nil {
+		return *filterRuleCollection.Rules
+	}
+
+	if natRuleCollection, ok := ruleCollection.AsFirewallPolicyNatRuleCollection(); ok && natRuleCollection.Rules != nil {
+		return *natRuleCollection.Rules
+	}
+
+	return nil
+}
+
+// returns the (lowercase) IP Group ids referenced by a (polymorphic) firewall policy rule
+func firewallPolicyRuleIpGroups(rule network.BasicFirewallPolicyRule) map[string]bool {
+	ret := map[string]bool{}
+	addIpGroups := func(ipGroups *[]string) {
+		if ipGroups != nil {
+			for _, ipGroupId := range *ipGroups {
+				ret[strings.ToLower(ipGroupId)] = true
+			}
+		}
+	}
+
+	if applicationRule, ok := rule.AsApplicationRule(); ok {
+		addIpGroups(applicationRule.SourceIPGroups)
+	}
+
+	if natRule, ok := rule.AsNatRule(); ok {
+		addIpGroups(natRule.SourceIPGroups)
+	}
+
+	if networkRule, ok := rule.AsRule(); ok {
+		addIpGroups(networkRule.SourceIPGroups)
+		addIpGroups(networkRule.DestinationIPGroups)
+	}
+
+	return ret
+}
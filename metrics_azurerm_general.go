@@ -7,40 +7,74 @@ import (
 	"github.com/prometheus/client_golang/prometheus"
 	log "github.com/sirupsen/logrus"
 	prometheusCommon "github.com/webdevops/go-prometheus-common"
+	"strings"
 )
 
 type MetricsCollectorAzureRmGeneral struct {
 	CollectorProcessorGeneral
 
 	prometheus struct {
-		subscription  *prometheus.GaugeVec
-		resourceGroup *prometheus.GaugeVec
+		subscription                      *prometheus.GaugeVec
+		subscriptionState                 *prometheus.GaugeVec
+		subscriptionSpendingLimitDisabled *prometheus.GaugeVec
+		resourceGroup                     *prometheus.GaugeVec
 	}
 }
 
 func (m *MetricsCollectorAzureRmGeneral) Setup(collector *CollectorGeneral) {
 	m.CollectorReference = collector
 
-	m.prometheus.subscription = prometheus.NewGaugeVec(
+	m.prometheus.subscription = m.newGaugeVec(
 		prometheus.GaugeOpts{
 			Name: "azurerm_subscription_info",
 			Help: "Azure ResourceManager subscription",
 		},
+		append(
+			[]string{
+				"resourceID",
+				"subscriptionID",
+				"subscriptionName",
+				"spendingLimit",
+				"quotaID",
+				"offerType",
+				"locationPlacementID",
+			},
+			azureSubscriptionTags.prometheusLabels...,
+		),
+	)
+	m.MustRegister(m.prometheus.subscription)
+
+	m.prometheus.subscriptionState = m.newGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_subscription_state",
+			Help: "Azure ResourceManager subscription state (Enabled, Warned, PastDue, Disabled, Deleted)",
+		},
 		[]string{
-			"resourceID",
 			"subscriptionID",
-			"subscriptionName",
-			"spendingLimit",
-			"quotaID",
-			"locationPlacementID",
+			"state",
 		},
 	)
-	prometheus.MustRegister(m.prometheus.subscription)
+	m.MustRegister(m.prometheus.subscriptionState)
 
+	m.prometheus.subscriptionSpendingLimitDisabled = m.newGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_subscription_spendinglimit_disabled",
+			Help: "Azure ResourceManager subscription with spending limit (subscriptionPolicies.spendingLimit) in state Disabled or Warned, the reason (eg. spending limit reached or credit exhausted) is not exposed by the API",
+		},
+		[]string{
+			"subscriptionID",
+			"spendingLimit",
+			"offerType",
+			"state",
+		},
+	)
+	m.MustRegister(m.prometheus.subscriptionSpendingLimitDisabled)
 }
 
 func (m *MetricsCollectorAzureRmGeneral) Reset() {
 	m.prometheus.subscription.Reset()
+	m.prometheus.subscriptionState.Reset()
+	m.prometheus.subscriptionSpendingLimitDisabled.Reset()
 }
 
 func (m *MetricsCollectorAzureRmGeneral) Collect(ctx context.Context, logger *log.Entry, callback chan<- func(), subscription subscriptions.Subscription) {
@@ -58,17 +92,85 @@ func (m *MetricsCollectorAzureRmGeneral) collectAzureSubscription(ctx context.Co
 		logger.Panic(err)
 	}
 
+	azureHierarchy.setSubscriptionName(to.String(sub.SubscriptionID), to.String(sub.DisplayName))
+	azureHierarchy.setSubscriptionTags(to.String(sub.SubscriptionID), sub.Tags)
+	if opts.Metrics.HierarchyLabels {
+		azureHierarchy.refreshManagementGroups(ctx, false)
+	}
+
 	subscriptionMetric := prometheusCommon.NewMetricsList()
-	subscriptionMetric.AddInfo(prometheus.Labels{
+	subscriptionMetric.AddInfo(azureSubscriptionTags.appendPrometheusLabel(prometheus.Labels{
 		"resourceID":          toResourceId(sub.ID),
 		"subscriptionID":      to.String(sub.SubscriptionID),
 		"subscriptionName":    to.String(sub.DisplayName),
 		"spendingLimit":       string(sub.SubscriptionPolicies.SpendingLimit),
 		"quotaID":             to.String(sub.SubscriptionPolicies.QuotaID),
+		"offerType":           azureSubscriptionOfferType(to.String(sub.SubscriptionPolicies.QuotaID)),
 		"locationPlacementID": to.String(sub.SubscriptionPolicies.LocationPlacementID),
+	}, sub.Tags))
+
+	stateMetric := prometheusCommon.NewMetricsList()
+	stateMetric.AddInfo(prometheus.Labels{
+		"subscriptionID": to.String(sub.SubscriptionID),
+		"state":          string(sub.State),
 	})
 
+	// subscriptions with spending limit (eg. MSDN, sponsored, free trial) are disabled when the limit
+	// is reached or the credit is exhausted, the workloads are deallocated without further notice.
+	// The subscription api only exposes the policy and the state, not the reason of the state
+	spendingLimitMetric := prometheusCommon.NewMetricsList()
+	if sub.SubscriptionPolicies.SpendingLimit != subscriptions.SpendingLimitOff {
+		spendingLimitDisabled := 0.0
+		if sub.State == subscriptions.StateDisabled || sub.State == subscriptions.StateWarned {
+			spendingLimitDisabled = 1
+		}
+
+		spendingLimitMetric.Add(prometheus.Labels{
+			"subscriptionID": to.String(sub.SubscriptionID),
+			"spendingLimit":  string(sub.SubscriptionPolicies.SpendingLimit),
+			"offerType":      azureSubscriptionOfferType(to.String(sub.SubscriptionPolicies.QuotaID)),
+			"state":          string(sub.State),
+		}, spendingLimitDisabled)
+	}
+
 	callback <- func() {
 		subscriptionMetric.GaugeSet(m.prometheus.subscription)
+		stateMetric.GaugeSet(m.prometheus.subscriptionState)
+		spendingLimitMetric.GaugeSet(m.prometheus.subscriptionSpendingLimitDisabled)
+	}
+}
+
+// azureSubscriptionOfferType returns the commercial offer of a subscription based on the quota id
+// (eg. EnterpriseAgreement_2014-09-01), unknown quota ids are returned as "Other"
+func azureSubscriptionOfferType(quotaId string) string {
+	quotaIdPrefix := strings.ToLower(strings.SplitN(quotaId, "_", 2)[0])
+
+	switch quotaIdPrefix {
+	case "":
+		return ""
+	case "enterpriseagreement":
+		return "EA"
+	case "csp":
+		return "CSP"
+	case "payasyougo":
+		return "PAYG"
+	case "msdndevtest":
+		return "Dev/Test"
+	case "msdn":
+		return "MSDN"
+	case "mpn":
+		return "MPN"
+	case "freetrial":
+		return "FreeTrial"
+	case "azurepass":
+		return "AzurePass"
+	case "azureforstudents":
+		return "Students"
+	case "sponsored":
+		return "Sponsored"
+	case "internal":
+		return "Internal"
+	default:
+		return "Other"
 	}
 }
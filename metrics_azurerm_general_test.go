@@ -0,0 +1,36 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestAzureSubscriptionOfferType(t *testing.T) {
+	testCases := []struct {
+		quotaId   string
+		offerType string
+	}{
+		{quotaId: "EnterpriseAgreement_2014-09-01", offerType: "EA"},
+		{quotaId: "CSP_2015-05-01", offerType: "CSP"},
+		{quotaId: "PayAsYouGo_2014-09-01", offerType: "PAYG"},
+		{quotaId: "MSDNDevTest_2014-09-01", offerType: "Dev/Test"},
+		{quotaId: "MSDN_2014-09-01", offerType: "MSDN"},
+		{quotaId: "MPN_2014-09-01", offerType: "MPN"},
+		{quotaId: "FreeTrial_2014-09-01", offerType: "FreeTrial"},
+		{quotaId: "AzurePass_2014-09-01", offerType: "AzurePass"},
+		{quotaId: "AzureForStudents_2018-01-01", offerType: "Students"},
+		{quotaId: "Sponsored_2016-01-01", offerType: "Sponsored"},
+		{quotaId: "Internal_2014-09-01", offerType: "Internal"},
+		{quotaId: "enterpriseagreement_2014-09-01", offerType: "EA"},
+		{quotaId: "EnterpriseAgreement", offerType: "EA"},
+		{quotaId: "Unknown_2020-01-01", offerType: "Other"},
+		{quotaId: "", offerType: ""},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.quotaId, func(t *testing.T) {
+			if offerType := azureSubscriptionOfferType(testCase.quotaId); offerType != testCase.offerType {
+				t.Errorf("expected %q, got %q", testCase.offerType, offerType)
+			}
+		})
+	}
+}
@@ -10,18 +10,25 @@ import (
 	prometheusCommon "github.com/webdevops/go-prometheus-common"
 )
 
+const (
+	// returned by the Resource Health API, but not part of the availability states of the SDK
+	resourceHealthDegraded resourcehealth.AvailabilityStateValues = "Degraded"
+)
+
 type MetricsCollectorAzureRmHealth struct {
 	CollectorProcessorGeneral
 
 	prometheus struct {
-		resourceHealth *prometheus.GaugeVec
+		resourceHealth             *prometheus.GaugeVec
+		resourceHealthAvailability *prometheus.GaugeVec
+		resourceHealthReason       *prometheus.GaugeVec
 	}
 }
 
 func (m *MetricsCollectorAzureRmHealth) Setup(collector *CollectorGeneral) {
 	m.CollectorReference = collector
 
-	m.prometheus.resourceHealth = prometheus.NewGaugeVec(
+	m.prometheus.resourceHealth = m.newGaugeVec(
 		prometheus.GaugeOpts{
 			Name: "azurerm_resource_health",
 			Help: "Azure Resource health status information",
@@ -32,11 +39,43 @@ func (m *MetricsCollectorAzureRmHealth) Setup(collector *CollectorGeneral) {
 			"availabilityState",
 		},
 	)
-	prometheus.MustRegister(m.prometheus.resourceHealth)
+	m.MustRegister(m.prometheus.resourceHealth)
+
+	m.prometheus.resourceHealthAvailability = m.newGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_resourcehealth_availability",
+			Help: "Azure Resource availability state (one series per state Available, Degraded, Unavailable and Unknown, 1 for the current state)",
+		},
+		[]string{
+			"subscriptionID",
+			"resourceID",
+			"availabilityState",
+		},
+	)
+	m.MustRegister(m.prometheus.resourceHealthAvailability)
+
+	m.prometheus.resourceHealthReason = m.newGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_resource_health_reason",
+			Help: "Azure Resource health reason of resources which are not available (value is the time the state occurred)",
+		},
+		[]string{
+			"subscriptionID",
+			"resourceID",
+			"availabilityState",
+			"reasonType",
+			"reasonChronicity",
+			"healthEventCause",
+			"healthEventCategory",
+		},
+	)
+	m.MustRegister(m.prometheus.resourceHealthReason)
 }
 
 func (m *MetricsCollectorAzureRmHealth) Reset() {
 	m.prometheus.resourceHealth.Reset()
+	m.prometheus.resourceHealthAvailability.Reset()
+	m.prometheus.resourceHealthReason.Reset()
 }
 
 func (m *MetricsCollectorAzureRmHealth) Collect(ctx context.Context, logger *log.Entry, callback chan<- func(), subscription subscriptions.Subscription) {
@@ -52,7 +91,12 @@ func (m *MetricsCollectorAzureRmHealth) Collect(ctx context.Context, logger *log
 
 	availabilityStateValues := resourcehealth.PossibleAvailabilityStateValuesValues()
 
+	// enum-style states of azurerm_resourcehealth_availability, states unknown to the exporter are exported as Unknown
+	availabilityEnumValues := []resourcehealth.AvailabilityStateValues{resourcehealth.Available, resourceHealthDegraded, resourcehealth.Unavailable, resourcehealth.Unknown}
+
 	resourceHealthMetric := prometheusCommon.NewMetricsList()
+	resourceHealthAvailabilityMetric := prometheusCommon.NewMetricsList()
+	resourceHealthReasonMetric := prometheusCommon.NewMetricsList()
 
 	for list.NotDone() {
 		val := list.Value()
@@ -63,6 +107,24 @@ func (m *MetricsCollectorAzureRmHealth) Collect(ctx context.Context, logger *log
 
 		if val.Properties != nil {
 			resourceAvailabilityState = val.Properties.AvailabilityState
+
+			// reason of the state, eg. platform initiated (Unplanned) or user initiated (UserInitiated)
+			if resourceAvailabilityState != resourcehealth.Available {
+				occurredTime := float64(0)
+				if val.Properties.OccuredTime != nil {
+					occurredTime = float64(val.Properties.OccuredTime.Unix())
+				}
+
+				resourceHealthReasonMetric.Add(prometheus.Labels{
+					"subscriptionID":      to.String(subscription.SubscriptionID),
+					"resourceID":          toResourceId(&resourceId),
+					"availabilityState":   string(resourceAvailabilityState),
+					"reasonType":          to.String(val.Properties.ReasonType),
+					"reasonChronicity":    string(val.Properties.ReasonChronicity),
+					"healthEventCause":    to.String(val.Properties.HealthEventCause),
+					"healthEventCategory": to.String(val.Properties.HealthEventCategory),
+				}, occurredTime)
+			}
 		}
 
 		for _, availabilityState := range availabilityStateValues {
@@ -79,6 +141,25 @@ func (m *MetricsCollectorAzureRmHealth) Collect(ctx context.Context, logger *log
 			}
 		}
 
+		availabilityEnumState := resourcehealth.Unknown
+		for _, availabilityState := range availabilityEnumValues {
+			if availabilityState == resourceAvailabilityState {
+				availabilityEnumState = availabilityState
+			}
+		}
+		for _, availabilityState := range availabilityEnumValues {
+			value := float64(0)
+			if availabilityState == availabilityEnumState {
+				value = 1
+			}
+
+			resourceHealthAvailabilityMetric.Add(prometheus.Labels{
+				"subscriptionID":    to.String(subscription.SubscriptionID),
+				"resourceID":        toResourceId(&resourceId),
+				"availabilityState": string(availabilityState),
+			}, value)
+		}
+
 		if list.NextWithContext(ctx) != nil {
 			break
 		}
@@ -86,5 +167,7 @@ func (m *MetricsCollectorAzureRmHealth) Collect(ctx context.Context, logger *log
 
 	callback <- func() {
 		resourceHealthMetric.GaugeSet(m.prometheus.resourceHealth)
+		resourceHealthAvailabilityMetric.GaugeSet(m.prometheus.resourceHealthAvailability)
+		resourceHealthReasonMetric.GaugeSet(m.prometheus.resourceHealthReason)
 	}
 }
@@ -0,0 +1,228 @@
+package main
+
+import (
+	"context"
+	"github.com/Azure/azure-sdk-for-go/profiles/latest/compute/mgmt/compute"
+	"github.com/Azure/azure-sdk-for-go/profiles/latest/resources/mgmt/subscriptions"
+	"github.com/Azure/azure-sdk-for-go/services/preview/sql/mgmt/v5.0/sql"
+	"github.com/Azure/azure-sdk-for-go/services/preview/sqlvirtualmachine/mgmt/2017-03-01-preview/sqlvirtualmachine"
+	"github.com/Azure/go-autorest/autorest/to"
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+	prometheusCommon "github.com/webdevops/go-prometheus-common"
+	"strings"
+)
+
+type MetricsCollectorAzureRmHybridBenefit struct {
+	CollectorProcessorGeneral
+
+	prometheus struct {
+		hybridBenefit      *prometheus.GaugeVec
+		hybridBenefitCount *prometheus.GaugeVec
+	}
+}
+
+func (m *MetricsCollectorAzureRmHybridBenefit) Setup(collector *CollectorGeneral) {
+	m.CollectorReference = collector
+
+	m.prometheus.hybridBenefit = m.newGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_hybridbenefit_info",
+			Help: "Azure Hybrid Benefit license information",
+		},
+		[]string{
+			"resourceID",
+			"subscriptionID",
+			"resourceGroup",
+			"name",
+			"resourceType",
+			"licenseType",
+			"hybridBenefit",
+		},
+	)
+	m.MustRegister(m.prometheus.hybridBenefit)
+
+	m.prometheus.hybridBenefitCount = m.newGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_hybridbenefit_count",
+			Help: "Azure Hybrid Benefit resource count",
+		},
+		[]string{
+			"subscriptionID",
+			"resourceType",
+			"hybridBenefit",
+		},
+	)
+	m.MustRegister(m.prometheus.hybridBenefitCount)
+}
+
+func (m *MetricsCollectorAzureRmHybridBenefit) Reset() {
+	m.prometheus.hybridBenefit.Reset()
+	m.prometheus.hybridBenefitCount.Reset()
+}
+
+func (m *MetricsCollectorAzureRmHybridBenefit) Collect(ctx context.Context, logger *log.Entry, callback chan<- func(), subscription subscriptions.Subscription) {
+	infoMetric := prometheusCommon.NewMetricsList()
+	countMetric := prometheusCommon.NewMetricsList()
+
+	// counts per resourceType and hybridBenefit
+	counts := map[string]map[bool]float64{
+		"virtualMachine":     {true: 0, false: 0},
+		"sqlVirtualMachine":  {true: 0, false: 0},
+		"sqlDatabase":        {true: 0, false: 0},
+		"sqlManagedInstance": {true: 0, false: 0},
+	}
+
+	addResource := func(resourceId *string, name *string, resourceType, licenseType string, hybridBenefit bool) {
+		infoMetric.AddInfo(prometheus.Labels{
+			"resourceID":     toResourceId(resourceId),
+			"subscriptionID": to.String(subscription.SubscriptionID),
+			"resourceGroup":  extractResourceGroupFromAzureId(to.String(resourceId)),
+			"name":           to.String(name),
+			"resourceType":   resourceType,
+			"licenseType":    licenseType,
+			"hybridBenefit":  boolToString(hybridBenefit),
+		})
+		counts[resourceType][hybridBenefit]++
+	}
+
+	m.collectVirtualMachines(ctx, logger, subscription, addResource)
+	m.collectSqlVirtualMachines(ctx, logger, subscription, addResource)
+	m.collectSqlDatabases(ctx, logger, subscription, addResource)
+	m.collectSqlManagedInstances(ctx, logger, subscription, addResource)
+
+	for resourceType, resourceTypeCounts := range counts {
+		for hybridBenefit, count := range resourceTypeCounts {
+			countMetric.Add(prometheus.Labels{
+				"subscriptionID": to.String(subscription.SubscriptionID),
+				"resourceType":   resourceType,
+				"hybridBenefit":  boolToString(hybridBenefit),
+			}, count)
+		}
+	}
+
+	callback <- func() {
+		infoMetric.GaugeSet(m.prometheus.hybridBenefit)
+		countMetric.GaugeSet(m.prometheus.hybridBenefitCount)
+	}
+}
+
+// Collect Windows virtual machines, license type Windows_Server/Windows_Client is Hybrid Benefit
+func (m *MetricsCollectorAzureRmHybridBenefit) collectVirtualMachines(ctx context.Context, logger *log.Entry, subscription subscriptions.Subscription, addResource func(*string, *string, string, string, bool)) {
+	client := compute.NewVirtualMachinesClientWithBaseURI(azureEnvironment.ResourceManagerEndpoint, *subscription.SubscriptionID)
+	client.Authorizer = AzureAuthorizer
+	client.ResponseInspector = azureResponseInspector(&subscription)
+
+	list, err := client.ListAllComplete(ctx, "")
+	if err != nil {
+		logger.Panic(err)
+	}
+
+	for list.NotDone() {
+		val := list.Value()
+
+		if val.VirtualMachineProperties != nil && val.StorageProfile != nil && val.StorageProfile.OsDisk != nil {
+			if val.StorageProfile.OsDisk.OsType == compute.OperatingSystemTypesWindows {
+				licenseType := to.String(val.LicenseType)
+				hybridBenefit := strings.EqualFold(licenseType, "Windows_Server") || strings.EqualFold(licenseType, "Windows_Client")
+				addResource(val.ID, val.Name, "virtualMachine", licenseType, hybridBenefit)
+			}
+		}
+
+		if list.NextWithContext(ctx) != nil {
+			break
+		}
+	}
+}
+
+// Collect SQL virtual machines, license type AHUB is Hybrid Benefit
+func (m *MetricsCollectorAzureRmHybridBenefit) collectSqlVirtualMachines(ctx context.Context, logger *log.Entry, subscription subscriptions.Subscription, addResource func(*string, *string, string, string, bool)) {
+	client := sqlvirtualmachine.NewSQLVirtualMachinesClientWithBaseURI(azureEnvironment.ResourceManagerEndpoint, *subscription.SubscriptionID)
+	client.Authorizer = AzureAuthorizer
+	client.ResponseInspector = azureResponseInspector(&subscription)
+
+	list, err := client.ListComplete(ctx)
+	if err != nil {
+		logger.Panic(err)
+	}
+
+	for list.NotDone() {
+		val := list.Value()
+
+		if val.Properties != nil {
+			licenseType := string(val.SQLServerLicenseType)
+			addResource(val.ID, val.Name, "sqlVirtualMachine", licenseType, val.SQLServerLicenseType == sqlvirtualmachine.AHUB)
+		}
+
+		if list.NextWithContext(ctx) != nil {
+			break
+		}
+	}
+}
+
+// Collect SQL databases (vCore only), license type BasePrice is Hybrid Benefit
+func (m *MetricsCollectorAzureRmHybridBenefit) collectSqlDatabases(ctx context.Context, logger *log.Entry, subscription subscriptions.Subscription, addResource func(*string, *string, string, string, bool)) {
+	serverClient := sql.NewServersClientWithBaseURI(azureEnvironment.ResourceManagerEndpoint, *subscription.SubscriptionID)
+	serverClient.Authorizer = AzureAuthorizer
+	serverClient.ResponseInspector = azureResponseInspector(&subscription)
+
+	databaseClient := sql.NewDatabasesClientWithBaseURI(azureEnvironment.ResourceManagerEndpoint, *subscription.SubscriptionID)
+	databaseClient.Authorizer = AzureAuthorizer
+	databaseClient.ResponseInspector = azureResponseInspector(&subscription)
+
+	serverList, err := serverClient.ListComplete(ctx, "")
+	if err != nil {
+		logger.Panic(err)
+	}
+
+	for serverList.NotDone() {
+		server := serverList.Value()
+		resourceGroup := extractResourceGroupFromAzureId(to.String(server.ID))
+
+		databaseList, err := databaseClient.ListByServerComplete(ctx, resourceGroup, to.String(server.Name), "")
+		if err != nil {
+			logger.WithField("sqlServer", to.String(server.Name)).Error(err)
+		} else {
+			for databaseList.NotDone() {
+				val := databaseList.Value()
+
+				// license type is only available for vCore databases
+				if val.DatabaseProperties != nil && val.LicenseType != "" {
+					addResource(val.ID, val.Name, "sqlDatabase", string(val.LicenseType), val.LicenseType == sql.DatabaseLicenseTypeBasePrice)
+				}
+
+				if databaseList.NextWithContext(ctx) != nil {
+					break
+				}
+			}
+		}
+
+		if serverList.NextWithContext(ctx) != nil {
+			break
+		}
+	}
+}
+
+// Collect SQL managed instances, license type BasePrice is Hybrid Benefit
+func (m *MetricsCollectorAzureRmHybridBenefit) collectSqlManagedInstances(ctx context.Context, logger *log.Entry, subscription subscriptions.Subscription, addResource func(*string, *string, string, string, bool)) {
+	client := sql.NewManagedInstancesClientWithBaseURI(azureEnvironment.ResourceManagerEndpoint, *subscription.SubscriptionID)
+	client.Authorizer = AzureAuthorizer
+	client.ResponseInspector = azureResponseInspector(&subscription)
+
+	list, err := client.ListComplete(ctx, "")
+	if err != nil {
+		logger.Panic(err)
+	}
+
+	for list.NotDone() {
+		val := list.Value()
+
+		if val.ManagedInstanceProperties != nil {
+			addResource(val.ID, val.Name, "sqlManagedInstance", string(val.LicenseType), val.LicenseType == sql.ManagedInstanceLicenseTypeBasePrice)
+		}
+
+		if list.NextWithContext(ctx) != nil {
+			break
+		}
+	}
+}
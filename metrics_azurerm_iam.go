@@ -10,6 +10,7 @@ import (
 	"github.com/prometheus/client_golang/prometheus"
 	log "github.com/sirupsen/logrus"
 	prometheusCommon "github.com/webdevops/go-prometheus-common"
+	"strings"
 )
 
 type MetricsCollectorAzureRmIam struct {
@@ -21,6 +22,12 @@ type MetricsCollectorAzureRmIam struct {
 		roleAssignment *prometheus.GaugeVec
 		roleDefinition *prometheus.GaugeVec
 		principal      *prometheus.GaugeVec
+
+		authorizationRoleAssignment      *prometheus.GaugeVec
+		authorizationRoleAssignmentCount *prometheus.GaugeVec
+
+		classicAdministrator      *prometheus.GaugeVec
+		classicAdministratorCount *prometheus.GaugeVec
 	}
 }
 
@@ -38,7 +45,7 @@ func (m *MetricsCollectorAzureRmIam) Setup(collector *CollectorGeneral) {
 
 	m.graphclient = &graphclient
 
-	m.prometheus.roleAssignment = prometheus.NewGaugeVec(
+	m.prometheus.roleAssignment = m.newGaugeVec(
 		prometheus.GaugeOpts{
 			Name: "azurerm_iam_roleassignment_info",
 			Help: "Azure IAM RoleAssignment information",
@@ -52,9 +59,9 @@ func (m *MetricsCollectorAzureRmIam) Setup(collector *CollectorGeneral) {
 			"roleDefinitionID",
 		},
 	)
-	prometheus.MustRegister(m.prometheus.roleAssignment)
+	m.MustRegister(m.prometheus.roleAssignment)
 
-	m.prometheus.roleDefinition = prometheus.NewGaugeVec(
+	m.prometheus.roleDefinition = m.newGaugeVec(
 		prometheus.GaugeOpts{
 			Name: "azurerm_iam_roledefinition_info",
 			Help: "Azure IAM RoleDefinition information",
@@ -67,9 +74,9 @@ func (m *MetricsCollectorAzureRmIam) Setup(collector *CollectorGeneral) {
 			"roleType",
 		},
 	)
-	prometheus.MustRegister(m.prometheus.roleDefinition)
+	m.MustRegister(m.prometheus.roleDefinition)
 
-	m.prometheus.principal = prometheus.NewGaugeVec(
+	m.prometheus.principal = m.newGaugeVec(
 		prometheus.GaugeOpts{
 			Name: "azurerm_iam_principal_info",
 			Help: "Azure IAM Principal information",
@@ -81,21 +88,80 @@ func (m *MetricsCollectorAzureRmIam) Setup(collector *CollectorGeneral) {
 			"principalType",
 		},
 	)
-	prometheus.MustRegister(m.prometheus.principal)
+	m.MustRegister(m.prometheus.principal)
+
+	m.prometheus.authorizationRoleAssignment = m.newGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_authorization_roleassignment_info",
+			Help: "Azure role assignment information",
+		},
+		[]string{
+			"subscriptionID",
+			"principalId",
+			"principalType",
+			"roleDefinitionName",
+			"scope",
+		},
+	)
+	m.MustRegister(m.prometheus.authorizationRoleAssignment)
+
+	m.prometheus.authorizationRoleAssignmentCount = m.newGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_authorization_roleassignment_count",
+			Help: "Azure role assignment count per role and principal type",
+		},
+		[]string{
+			"subscriptionID",
+			"roleDefinitionName",
+			"principalType",
+		},
+	)
+	m.MustRegister(m.prometheus.authorizationRoleAssignmentCount)
+
+	m.prometheus.classicAdministrator = m.newGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_iam_classicadministrator_info",
+			Help: "Azure IAM classic administrator information",
+		},
+		[]string{
+			"subscriptionID",
+			"emailAddress",
+			"role",
+		},
+	)
+	m.MustRegister(m.prometheus.classicAdministrator)
+
+	m.prometheus.classicAdministratorCount = m.newGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_iam_classicadministrator_count",
+			Help: "Azure IAM classic administrator count",
+		},
+		[]string{
+			"subscriptionID",
+			"type",
+		},
+	)
+	m.MustRegister(m.prometheus.classicAdministratorCount)
 }
 
 func (m *MetricsCollectorAzureRmIam) Reset() {
 	m.prometheus.roleDefinition.Reset()
 	m.prometheus.roleAssignment.Reset()
 	m.prometheus.principal.Reset()
+	m.prometheus.authorizationRoleAssignment.Reset()
+	m.prometheus.authorizationRoleAssignmentCount.Reset()
+	m.prometheus.classicAdministrator.Reset()
+	m.prometheus.classicAdministratorCount.Reset()
 }
 
 func (m *MetricsCollectorAzureRmIam) Collect(ctx context.Context, logger *log.Entry, callback chan<- func(), subscription subscriptions.Subscription) {
-	m.collectRoleDefinitions(ctx, logger, callback, subscription)
-	m.collectRoleAssignments(ctx, logger, callback, subscription)
+	roleDefinitionNames := m.collectRoleDefinitions(ctx, logger, callback, subscription)
+	m.collectRoleAssignments(ctx, logger, callback, subscription, roleDefinitionNames)
+	m.collectClassicAdministrators(ctx, logger, callback, subscription)
 }
 
-func (m *MetricsCollectorAzureRmIam) collectRoleDefinitions(ctx context.Context, logger *log.Entry, callback chan<- func(), subscription subscriptions.Subscription) {
+// collectRoleDefinitions collects the role definitions and returns the role names by role definition id
+func (m *MetricsCollectorAzureRmIam) collectRoleDefinitions(ctx context.Context, logger *log.Entry, callback chan<- func(), subscription subscriptions.Subscription) map[string]string {
 	client := authorization.NewRoleDefinitionsClientWithBaseURI(azureEnvironment.ResourceManagerEndpoint, *subscription.SubscriptionID)
 	client.Authorizer = AzureAuthorizer
 	client.ResponseInspector = azureResponseInspector(&subscription)
@@ -107,10 +173,13 @@ func (m *MetricsCollectorAzureRmIam) collectRoleDefinitions(ctx context.Context,
 	}
 
 	infoMetric := prometheusCommon.NewMetricsList()
+	roleDefinitionNames := map[string]string{}
 
 	for list.NotDone() {
 		val := list.Value()
 
+		roleDefinitionNames[strings.ToLower(extractRoleDefinitionIdFromAzureId(to.String(val.ID)))] = to.String(val.RoleName)
+
 		infoLabels := prometheus.Labels{
 			"subscriptionID":   to.String(subscription.SubscriptionID),
 			"roleDefinitionID": extractRoleDefinitionIdFromAzureId(to.String(val.ID)),
@@ -128,9 +197,11 @@ func (m *MetricsCollectorAzureRmIam) collectRoleDefinitions(ctx context.Context,
 	callback <- func() {
 		infoMetric.GaugeSet(m.prometheus.roleDefinition)
 	}
+
+	return roleDefinitionNames
 }
 
-func (m *MetricsCollectorAzureRmIam) collectRoleAssignments(ctx context.Context, logger *log.Entry, callback chan<- func(), subscription subscriptions.Subscription) {
+func (m *MetricsCollectorAzureRmIam) collectRoleAssignments(ctx context.Context, logger *log.Entry, callback chan<- func(), subscription subscriptions.Subscription, roleDefinitionNames map[string]string) {
 	client := authorization.NewRoleAssignmentsClientWithBaseURI(azureEnvironment.ResourceManagerEndpoint, *subscription.SubscriptionID)
 	client.Authorizer = AzureAuthorizer
 	client.ResponseInspector = azureResponseInspector(&subscription)
@@ -142,13 +213,28 @@ func (m *MetricsCollectorAzureRmIam) collectRoleAssignments(ctx context.Context,
 	}
 
 	infoMetric := prometheusCommon.NewMetricsList()
+	authorizationInfoMetric := prometheusCommon.NewMetricsList()
+	authorizationCountMetric := prometheusCommon.NewMetricsList()
 
 	principalIdMap := map[string]string{}
 
+	// service principals with role assignments, shared with dependent collectors (eg. ServicePrincipal)
+	storeEnabled := m.CollectorReference.HasDependents()
+	storeList := []string{}
+
+	// count by role name and principal type
+	assignmentCount := map[string]map[string]float64{}
+
 	for list.NotDone() {
 		val := list.Value()
 		principalId := *val.PrincipalID
 
+		if storeEnabled && val.PrincipalType == authorization.ServicePrincipal {
+			if _, exists := principalIdMap[principalId]; !exists {
+				storeList = append(storeList, principalId)
+			}
+		}
+
 		infoLabels := prometheus.Labels{
 			"subscriptionID":   to.String(subscription.SubscriptionID),
 			"roleAssignmentID": toResourceId(val.ID),
@@ -159,21 +245,117 @@ func (m *MetricsCollectorAzureRmIam) collectRoleAssignments(ctx context.Context,
 		}
 		infoMetric.AddInfo(infoLabels)
 
+		// roles of other (eg. management group) scopes might not be listed, the id is used then
+		roleDefinitionId := extractRoleDefinitionIdFromAzureId(to.String(val.RoleDefinitionID))
+		roleDefinitionName, exists := roleDefinitionNames[strings.ToLower(roleDefinitionId)]
+		if !exists {
+			roleDefinitionName = roleDefinitionId
+		}
+		principalType := string(val.PrincipalType)
+
+		authorizationInfoMetric.AddInfo(prometheus.Labels{
+			"subscriptionID":     to.String(subscription.SubscriptionID),
+			"principalId":        principalId,
+			"principalType":      principalType,
+			"roleDefinitionName": roleDefinitionName,
+			"scope":              toResourceId(val.Scope),
+		})
+
+		if _, exists := assignmentCount[roleDefinitionName]; !exists {
+			assignmentCount[roleDefinitionName] = map[string]float64{}
+		}
+		assignmentCount[roleDefinitionName][principalType]++
+
 		principalIdMap[principalId] = principalId
 
 		if list.NextWithContext(ctx) != nil {
+			storeEnabled = false
 			break
 		}
 	}
 
+	if storeEnabled {
+		m.storeSet(collectorStoreServicePrincipals, subscription, storeList)
+	}
+
 	principalIdList := []string{}
 	for _, val := range principalIdMap {
 		principalIdList = append(principalIdList, val)
 	}
 	m.collectPrincipals(ctx, logger, callback, subscription, principalIdList)
 
+	for roleDefinitionName, principalTypeCount := range assignmentCount {
+		for principalType, count := range principalTypeCount {
+			authorizationCountMetric.Add(prometheus.Labels{
+				"subscriptionID":     to.String(subscription.SubscriptionID),
+				"roleDefinitionName": roleDefinitionName,
+				"principalType":      principalType,
+			}, count)
+		}
+	}
+
 	callback <- func() {
 		infoMetric.GaugeSet(m.prometheus.roleAssignment)
+		authorizationInfoMetric.GaugeSet(m.prometheus.authorizationRoleAssignment)
+		authorizationCountMetric.GaugeSet(m.prometheus.authorizationRoleAssignmentCount)
+	}
+}
+
+func (m *MetricsCollectorAzureRmIam) collectClassicAdministrators(ctx context.Context, logger *log.Entry, callback chan<- func(), subscription subscriptions.Subscription) {
+	client := authorization.NewClassicAdministratorsClientWithBaseURI(azureEnvironment.ResourceManagerEndpoint, *subscription.SubscriptionID)
+	client.Authorizer = AzureAuthorizer
+	client.ResponseInspector = azureResponseInspector(&subscription)
+
+	list, err := client.ListComplete(ctx)
+
+	if err != nil {
+		logger.Panic(err)
+	}
+
+	infoMetric := prometheusCommon.NewMetricsList()
+	countMetric := prometheusCommon.NewMetricsList()
+
+	adminCount := map[string]float64{
+		"serviceAdministrator": 0,
+		"coAdministrator":      0,
+	}
+
+	for list.NotDone() {
+		val := list.Value()
+
+		if val.ClassicAdministratorProperties != nil {
+			role := to.String(val.Role)
+
+			infoLabels := prometheus.Labels{
+				"subscriptionID": to.String(subscription.SubscriptionID),
+				"emailAddress":   to.String(val.EmailAddress),
+				"role":           role,
+			}
+			infoMetric.AddInfo(infoLabels)
+
+			// role is eg. "ServiceAdministrator;AccountAdministrator" or "CoAdministrator"
+			if strings.Contains(strings.ToLower(role), "coadministrator") {
+				adminCount["coAdministrator"]++
+			} else {
+				adminCount["serviceAdministrator"]++
+			}
+		}
+
+		if list.NextWithContext(ctx) != nil {
+			break
+		}
+	}
+
+	for adminType, count := range adminCount {
+		countMetric.Add(prometheus.Labels{
+			"subscriptionID": to.String(subscription.SubscriptionID),
+			"type":           adminType,
+		}, count)
+	}
+
+	callback <- func() {
+		infoMetric.GaugeSet(m.prometheus.classicAdministrator)
+		countMetric.GaugeSet(m.prometheus.classicAdministratorCount)
 	}
 }
 
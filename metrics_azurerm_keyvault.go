@@ -0,0 +1,340 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/Azure/azure-sdk-for-go/profiles/latest/keyvault/mgmt/keyvault"
+	"github.com/Azure/azure-sdk-for-go/profiles/latest/resources/mgmt/subscriptions"
+	"github.com/Azure/go-autorest/autorest"
+	"github.com/Azure/go-autorest/autorest/azure/auth"
+	"github.com/Azure/go-autorest/autorest/to"
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+	prometheusCommon "github.com/webdevops/go-prometheus-common"
+	"strings"
+)
+
+const (
+	// KeyVault data-plane api version for listing secrets, keys and certificates
+	AzureKeyVaultDataApiVersion = "7.3"
+)
+
+type MetricsCollectorAzureRmKeyVault struct {
+	CollectorProcessorGeneral
+
+	// authorizer for the KeyVault data-plane (only with --keyvault-expiry)
+	dataAuthorizer autorest.Authorizer
+
+	prometheus struct {
+		keyvault                      *prometheus.GaugeVec
+		keyvaultAccessPolicyCount     *prometheus.GaugeVec
+		keyvaultAccessPolicyPrivilege *prometheus.GaugeVec
+
+		keyvaultSecretExpiry      *prometheus.GaugeVec
+		keyvaultKeyExpiry         *prometheus.GaugeVec
+		keyvaultCertificateExpiry *prometheus.GaugeVec
+	}
+}
+
+// keyvaultDataItem is a secret, key or certificate item of a KeyVault data-plane list call
+type keyvaultDataItem struct {
+	ID         string `json:"id"`
+	Kid        string `json:"kid"`
+	Managed    bool   `json:"managed"`
+	Attributes struct {
+		Enabled *bool  `json:"enabled"`
+		Expires *int64 `json:"exp"`
+	} `json:"attributes"`
+}
+
+func (m *MetricsCollectorAzureRmKeyVault) Setup(collector *CollectorGeneral) {
+	m.CollectorReference = collector
+
+	if opts.KeyVault.Expiry {
+		authorizer, err := auth.NewAuthorizerFromEnvironmentWithResource(strings.TrimSuffix(azureEnvironment.ResourceIdentifiers.KeyVault, "/"))
+		if err != nil {
+			m.logger().Panic(err)
+		}
+		m.dataAuthorizer = authorizer
+	}
+
+	m.prometheus.keyvault = m.newGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_keyvault_info",
+			Help: "Azure KeyVault information",
+		},
+		append(
+			[]string{
+				"resourceID",
+				"subscriptionID",
+				"resourceGroup",
+				"name",
+				"location",
+				"sku",
+				"rbacAuthorization",
+				"softDelete",
+				"purgeProtection",
+			},
+			azureResourceTags.prometheusLabels...,
+		),
+	)
+	m.MustRegister(m.prometheus.keyvault)
+
+	m.prometheus.keyvaultAccessPolicyCount = m.newGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_keyvault_accesspolicy_count",
+			Help: "Azure KeyVault access policy count (only vaults in access policy mode)",
+		},
+		[]string{
+			"resourceID",
+			"subscriptionID",
+		},
+	)
+	m.MustRegister(m.prometheus.keyvaultAccessPolicyCount)
+
+	m.prometheus.keyvaultAccessPolicyPrivilege = m.newGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_keyvault_accesspolicy_privileged",
+			Help: "Azure KeyVault access policies granting purge or all permissions",
+		},
+		[]string{
+			"resourceID",
+			"subscriptionID",
+			"objectID",
+			"applicationID",
+			"scope",
+			"permission",
+		},
+	)
+	m.MustRegister(m.prometheus.keyvaultAccessPolicyPrivilege)
+
+	expiryLabels := []string{
+		"resourceID",
+		"subscriptionID",
+		"name",
+		"enabled",
+	}
+
+	m.prometheus.keyvaultSecretExpiry = m.newGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_keyvault_secret_expiry",
+			Help: "Azure KeyVault secret expiry timestamp (only with --keyvault-expiry)",
+		},
+		expiryLabels,
+	)
+	m.MustRegister(m.prometheus.keyvaultSecretExpiry)
+
+	m.prometheus.keyvaultKeyExpiry = m.newGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_keyvault_key_expiry",
+			Help: "Azure KeyVault key expiry timestamp (only with --keyvault-expiry)",
+		},
+		expiryLabels,
+	)
+	m.MustRegister(m.prometheus.keyvaultKeyExpiry)
+
+	m.prometheus.keyvaultCertificateExpiry = m.newGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_keyvault_certificate_expiry",
+			Help: "Azure KeyVault certificate expiry timestamp (only with --keyvault-expiry)",
+		},
+		expiryLabels,
+	)
+	m.MustRegister(m.prometheus.keyvaultCertificateExpiry)
+}
+
+func (m *MetricsCollectorAzureRmKeyVault) Reset() {
+	m.prometheus.keyvault.Reset()
+	m.prometheus.keyvaultAccessPolicyCount.Reset()
+	m.prometheus.keyvaultAccessPolicyPrivilege.Reset()
+	m.prometheus.keyvaultSecretExpiry.Reset()
+	m.prometheus.keyvaultKeyExpiry.Reset()
+	m.prometheus.keyvaultCertificateExpiry.Reset()
+}
+
+func (m *MetricsCollectorAzureRmKeyVault) Collect(ctx context.Context, logger *log.Entry, callback chan<- func(), subscription subscriptions.Subscription) {
+	client := keyvault.NewVaultsClientWithBaseURI(azureEnvironment.ResourceManagerEndpoint, *subscription.SubscriptionID)
+	client.Authorizer = AzureAuthorizer
+	client.ResponseInspector = azureResponseInspector(&subscription)
+
+	list, err := client.ListBySubscriptionComplete(ctx, nil)
+	if err != nil {
+		logger.Panic(err)
+	}
+
+	infoMetric := prometheusCommon.NewMetricsList()
+	accessPolicyCountMetric := prometheusCommon.NewMetricsList()
+	accessPolicyPrivilegeMetric := prometheusCommon.NewMetricsList()
+	secretExpiryMetric := prometheusCommon.NewMetricsList()
+	keyExpiryMetric := prometheusCommon.NewMetricsList()
+	certificateExpiryMetric := prometheusCommon.NewMetricsList()
+
+	for list.NotDone() {
+		val := list.Value()
+		resourceId := toResourceId(val.ID)
+
+		infoLabels := prometheus.Labels{
+			"resourceID":        resourceId,
+			"subscriptionID":    to.String(subscription.SubscriptionID),
+			"resourceGroup":     extractResourceGroupFromAzureId(to.String(val.ID)),
+			"name":              to.String(val.Name),
+			"location":          to.String(val.Location),
+			"sku":               "",
+			"rbacAuthorization": "",
+			"softDelete":        "",
+			"purgeProtection":   "",
+		}
+
+		if val.Properties != nil {
+			if val.Properties.Sku != nil {
+				infoLabels["sku"] = string(val.Properties.Sku.Name)
+			}
+			infoLabels["rbacAuthorization"] = boolToString(to.Bool(val.Properties.EnableRbacAuthorization))
+			infoLabels["softDelete"] = boolToString(to.Bool(val.Properties.EnableSoftDelete))
+			infoLabels["purgeProtection"] = boolToString(to.Bool(val.Properties.EnablePurgeProtection))
+
+			// access policies are ignored for vaults using rbac authorization
+			if !to.Bool(val.Properties.EnableRbacAuthorization) {
+				accessPolicyCount := 0
+				if val.Properties.AccessPolicies != nil {
+					accessPolicyCount = len(*val.Properties.AccessPolicies)
+
+					for _, accessPolicy := range *val.Properties.AccessPolicies {
+						for scope, permissionList := range keyvaultAccessPolicyPermissions(accessPolicy) {
+							for _, permission := range permissionList {
+								permission = strings.ToLower(permission)
+								if permission != "purge" && permission != "all" {
+									continue
+								}
+
+								applicationId := ""
+								if accessPolicy.ApplicationID != nil {
+									applicationId = accessPolicy.ApplicationID.String()
+								}
+
+								accessPolicyPrivilegeMetric.AddInfo(prometheus.Labels{
+									"resourceID":     resourceId,
+									"subscriptionID": to.String(subscription.SubscriptionID),
+									"objectID":       to.String(accessPolicy.ObjectID),
+									"applicationID":  applicationId,
+									"scope":          scope,
+									"permission":     permission,
+								})
+							}
+						}
+					}
+				}
+
+				accessPolicyCountMetric.Add(prometheus.Labels{
+					"resourceID":     resourceId,
+					"subscriptionID": to.String(subscription.SubscriptionID),
+				}, float64(accessPolicyCount))
+			}
+		}
+
+		infoLabels = azureResourceTags.appendPrometheusLabel(infoLabels, val.Tags)
+		infoMetric.AddInfo(infoLabels)
+
+		if m.dataAuthorizer != nil && val.Properties != nil && val.Properties.VaultURI != nil {
+			vaultLogger := logger.WithField("keyvault", to.String(val.ID))
+			client := m.dataClient(to.String(val.Properties.VaultURI))
+
+			for path, expiryMetric := range map[string]*prometheusCommon.MetricList{
+				"/secrets":      secretExpiryMetric,
+				"/keys":         keyExpiryMetric,
+				"/certificates": certificateExpiryMetric,
+			} {
+				itemList, err := client.List(ctx, path, AzureKeyVaultDataApiVersion)
+				if err != nil {
+					// eg. firewall or missing data-plane permissions
+					vaultLogger.Error(err)
+					continue
+				}
+
+				for _, row := range itemList {
+					item := keyvaultDataItem{}
+					if err := json.Unmarshal(row, &item); err != nil {
+						vaultLogger.Error(err)
+						continue
+					}
+
+					// secrets and keys backing a certificate are reported by the certificate
+					if item.Managed || item.Attributes.Expires == nil {
+						continue
+					}
+
+					itemId := item.ID
+					if itemId == "" {
+						itemId = item.Kid
+					}
+
+					expiryMetric.Add(prometheus.Labels{
+						"resourceID":     resourceId,
+						"subscriptionID": to.String(subscription.SubscriptionID),
+						"name":           itemId[strings.LastIndex(itemId, "/")+1:],
+						"enabled":        boolToString(item.Attributes.Enabled == nil || *item.Attributes.Enabled),
+					}, float64(*item.Attributes.Expires))
+				}
+			}
+		}
+
+		if list.NextWithContext(ctx) != nil {
+			break
+		}
+	}
+
+	callback <- func() {
+		infoMetric.GaugeSet(m.prometheus.keyvault)
+		accessPolicyCountMetric.GaugeSet(m.prometheus.keyvaultAccessPolicyCount)
+		accessPolicyPrivilegeMetric.GaugeSet(m.prometheus.keyvaultAccessPolicyPrivilege)
+		secretExpiryMetric.GaugeSet(m.prometheus.keyvaultSecretExpiry)
+		keyExpiryMetric.GaugeSet(m.prometheus.keyvaultKeyExpiry)
+		certificateExpiryMetric.GaugeSet(m.prometheus.keyvaultCertificateExpiry)
+	}
+}
+
+// dataClient returns a client for the KeyVault data-plane of one vault (vaultUri eg. https://myvault.vault.azure.net/)
+func (m *MetricsCollectorAzureRmKeyVault) dataClient(vaultUri string) AzureRestClient {
+	client := AzureRestClient{
+		Client:  autorest.NewClientWithUserAgent("azure-resourcemanager-exporter"),
+		BaseURI: strings.TrimSuffix(vaultUri, "/"),
+	}
+	client.Authorizer = m.dataAuthorizer
+
+	return client
+}
+
+// keyvaultAccessPolicyPermissions returns the permissions of an access policy by scope (keys, secrets, certificates, storage)
+func keyvaultAccessPolicyPermissions(accessPolicy keyvault.AccessPolicyEntry) map[string][]string {
+	ret := map[string][]string{}
+
+	if accessPolicy.Permissions == nil {
+		return ret
+	}
+
+	if accessPolicy.Permissions.Keys != nil {
+		for _, permission := range *accessPolicy.Permissions.Keys {
+			ret["keys"] = append(ret["keys"], string(permission))
+		}
+	}
+
+	if accessPolicy.Permissions.Secrets != nil {
+		for _, permission := range *accessPolicy.Permissions.Secrets {
+			ret["secrets"] = append(ret["secrets"], string(permission))
+		}
+	}
+
+	if accessPolicy.Permissions.Certificates != nil {
+		for _, permission := range *accessPolicy.Permissions.Certificates {
+			ret["certificates"] = append(ret["certificates"], string(permission))
+		}
+	}
+
+	if accessPolicy.Permissions.Storage != nil {
+		for _, permission := range *accessPolicy.Permissions.Storage {
+			ret["storage"] = append(ret["storage"], string(permission))
+		}
+	}
+
+	return ret
+}
@@ -0,0 +1,348 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/Azure/azure-sdk-for-go/profiles/latest/communication/mgmt/communication"
+	"github.com/Azure/azure-sdk-for-go/profiles/latest/notificationhubs/mgmt/notificationhubs"
+	"github.com/Azure/azure-sdk-for-go/profiles/latest/resources/mgmt/subscriptions"
+	"github.com/Azure/go-autorest/autorest/to"
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+	prometheusCommon "github.com/webdevops/go-prometheus-common"
+	"strings"
+)
+
+const (
+	// publicNetworkAccess is not available for Event Hubs in the used Azure SDK version
+	AzureMessagingNamespaceApiVersion = "2021-11-01"
+)
+
+type (
+	MetricsCollectorAzureRmMessaging struct {
+		CollectorProcessorGeneral
+
+		prometheus struct {
+			notificationHubNamespace *prometheus.GaugeVec
+			communicationService     *prometheus.GaugeVec
+
+			namespaceNetworkRuleSet      *prometheus.GaugeVec
+			namespaceNetworkRuleSetRules *prometheus.GaugeVec
+		}
+	}
+
+	azureMessagingNamespace struct {
+		ID       *string `json:"id"`
+		Name     *string `json:"name"`
+		Location *string `json:"location"`
+		Sku      *struct {
+			Name *string `json:"name"`
+		} `json:"sku"`
+		Properties *struct {
+			PublicNetworkAccess        *string           `json:"publicNetworkAccess"`
+			PrivateEndpointConnections []json.RawMessage `json:"privateEndpointConnections"`
+		} `json:"properties"`
+	}
+
+	azureMessagingNetworkRuleSet struct {
+		Properties *struct {
+			DefaultAction               *string           `json:"defaultAction"`
+			TrustedServiceAccessEnabled *bool             `json:"trustedServiceAccessEnabled"`
+			PublicNetworkAccess         *string           `json:"publicNetworkAccess"`
+			IPRules                     []json.RawMessage `json:"ipRules"`
+			VirtualNetworkRules         []json.RawMessage `json:"virtualNetworkRules"`
+		} `json:"properties"`
+	}
+)
+
+func (m *MetricsCollectorAzureRmMessaging) Setup(collector *CollectorGeneral) {
+	m.CollectorReference = collector
+
+	m.prometheus.notificationHubNamespace = m.newGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_notificationhub_namespace_info",
+			Help: "Azure Notification Hub namespace information",
+		},
+		append(
+			[]string{
+				"resourceID",
+				"subscriptionID",
+				"resourceGroup",
+				"name",
+				"location",
+				"sku",
+				"namespaceType",
+				"status",
+				"provisioningState",
+			},
+			azureResourceTags.prometheusLabels...,
+		),
+	)
+	m.MustRegister(m.prometheus.notificationHubNamespace)
+
+	m.prometheus.communicationService = m.newGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_communicationservice_info",
+			Help: "Azure Communication Services resource information",
+		},
+		append(
+			[]string{
+				"resourceID",
+				"subscriptionID",
+				"resourceGroup",
+				"name",
+				"location",
+				"dataLocation",
+				"hostName",
+				"notificationHubID",
+				"provisioningState",
+			},
+			azureResourceTags.prometheusLabels...,
+		),
+	)
+	m.MustRegister(m.prometheus.communicationService)
+
+	m.prometheus.namespaceNetworkRuleSet = m.newGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_messaging_networkruleset_info",
+			Help: "Azure Event Hubs and Service Bus namespace network rule set information",
+		},
+		[]string{
+			"resourceID",
+			"subscriptionID",
+			"resourceGroup",
+			"name",
+			"namespaceType",
+			"sku",
+			"defaultAction",
+			"trustedServiceBypass",
+			"publicNetworkAccess",
+			"privateEndpointOnly",
+		},
+	)
+	m.MustRegister(m.prometheus.namespaceNetworkRuleSet)
+
+	m.prometheus.namespaceNetworkRuleSetRules = m.newGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_messaging_networkruleset_rules",
+			Help: "Azure Event Hubs and Service Bus namespace network rule count",
+		},
+		[]string{
+			"resourceID",
+			"subscriptionID",
+			"type",
+		},
+	)
+	m.MustRegister(m.prometheus.namespaceNetworkRuleSetRules)
+}
+
+func (m *MetricsCollectorAzureRmMessaging) Reset() {
+	m.prometheus.notificationHubNamespace.Reset()
+	m.prometheus.communicationService.Reset()
+	m.prometheus.namespaceNetworkRuleSet.Reset()
+	m.prometheus.namespaceNetworkRuleSetRules.Reset()
+}
+
+func (m *MetricsCollectorAzureRmMessaging) Collect(ctx context.Context, logger *log.Entry, callback chan<- func(), subscription subscriptions.Subscription) {
+	m.collectNotificationHubNamespaces(ctx, logger, callback, subscription)
+	m.collectCommunicationServices(ctx, logger, callback, subscription)
+	m.collectNamespaceNetworkRuleSets(ctx, logger, callback, subscription)
+}
+
+// Collect Azure Notification Hub namespaces
+func (m *MetricsCollectorAzureRmMessaging) collectNotificationHubNamespaces(ctx context.Context, logger *log.Entry, callback chan<- func(), subscription subscriptions.Subscription) {
+	client := notificationhubs.NewNamespacesClientWithBaseURI(azureEnvironment.ResourceManagerEndpoint, *subscription.SubscriptionID)
+	client.Authorizer = AzureAuthorizer
+	client.ResponseInspector = azureResponseInspector(&subscription)
+
+	list, err := client.ListAllComplete(ctx)
+	if err != nil {
+		logger.Panic(err)
+	}
+
+	infoMetric := prometheusCommon.NewMetricsList()
+
+	for list.NotDone() {
+		val := list.Value()
+
+		infoLabels := prometheus.Labels{
+			"resourceID":        toResourceId(val.ID),
+			"subscriptionID":    to.String(subscription.SubscriptionID),
+			"resourceGroup":     extractResourceGroupFromAzureId(to.String(val.ID)),
+			"name":              to.String(val.Name),
+			"location":          to.String(val.Location),
+			"sku":               "",
+			"namespaceType":     "",
+			"status":            "",
+			"provisioningState": "",
+		}
+
+		if val.Sku != nil {
+			infoLabels["sku"] = string(val.Sku.Name)
+		}
+
+		if val.NamespaceProperties != nil {
+			infoLabels["namespaceType"] = string(val.NamespaceProperties.NamespaceType)
+			infoLabels["status"] = to.String(val.NamespaceProperties.Status)
+			infoLabels["provisioningState"] = strings.ToLower(to.String(val.NamespaceProperties.ProvisioningState))
+		}
+
+		infoLabels = azureResourceTags.appendPrometheusLabel(infoLabels, val.Tags)
+		infoMetric.AddInfo(infoLabels)
+
+		if list.NextWithContext(ctx) != nil {
+			break
+		}
+	}
+
+	callback <- func() {
+		infoMetric.GaugeSet(m.prometheus.notificationHubNamespace)
+	}
+}
+
+// Collect Azure Communication Services
+func (m *MetricsCollectorAzureRmMessaging) collectCommunicationServices(ctx context.Context, logger *log.Entry, callback chan<- func(), subscription subscriptions.Subscription) {
+	client := communication.NewServiceClientWithBaseURI(azureEnvironment.ResourceManagerEndpoint, *subscription.SubscriptionID)
+	client.Authorizer = AzureAuthorizer
+	client.ResponseInspector = azureResponseInspector(&subscription)
+
+	list, err := client.ListBySubscriptionComplete(ctx)
+	if err != nil {
+		logger.Panic(err)
+	}
+
+	infoMetric := prometheusCommon.NewMetricsList()
+
+	for list.NotDone() {
+		val := list.Value()
+
+		infoLabels := prometheus.Labels{
+			"resourceID":        toResourceId(val.ID),
+			"subscriptionID":    to.String(subscription.SubscriptionID),
+			"resourceGroup":     extractResourceGroupFromAzureId(to.String(val.ID)),
+			"name":              to.String(val.Name),
+			"location":          to.String(val.Location),
+			"dataLocation":      "",
+			"hostName":          "",
+			"notificationHubID": "",
+			"provisioningState": "",
+		}
+
+		if val.ServiceProperties != nil {
+			infoLabels["dataLocation"] = to.String(val.ServiceProperties.DataLocation)
+			infoLabels["hostName"] = to.String(val.ServiceProperties.HostName)
+			infoLabels["notificationHubID"] = toResourceId(val.ServiceProperties.NotificationHubID)
+			infoLabels["provisioningState"] = strings.ToLower(string(val.ServiceProperties.ProvisioningState))
+		}
+
+		infoLabels = azureResourceTags.appendPrometheusLabel(infoLabels, val.Tags)
+		infoMetric.AddInfo(infoLabels)
+
+		if list.NextWithContext(ctx) != nil {
+			break
+		}
+	}
+
+	callback <- func() {
+		infoMetric.GaugeSet(m.prometheus.communicationService)
+	}
+}
+
+// Collect Azure Event Hubs and Service Bus namespace network rule sets
+func (m *MetricsCollectorAzureRmMessaging) collectNamespaceNetworkRuleSets(ctx context.Context, logger *log.Entry, callback chan<- func(), subscription subscriptions.Subscription) {
+	client := NewAzureRestClient(&subscription)
+
+	infoMetric := prometheusCommon.NewMetricsList()
+	rulesMetric := prometheusCommon.NewMetricsList()
+
+	namespaceProviders := map[string]string{
+		"eventhub":   "Microsoft.EventHub",
+		"servicebus": "Microsoft.ServiceBus",
+	}
+
+	for namespaceType, provider := range namespaceProviders {
+		list, err := client.List(ctx, fmt.Sprintf("/subscriptions/%s/providers/%s/namespaces", *subscription.SubscriptionID, provider), AzureMessagingNamespaceApiVersion)
+		if err != nil {
+			logger.Panic(err)
+		}
+
+		for _, row := range list {
+			val := azureMessagingNamespace{}
+			if err := json.Unmarshal(row, &val); err != nil {
+				logger.Error(err)
+				continue
+			}
+
+			networkRuleSet := azureMessagingNetworkRuleSet{}
+			if err := client.Get(ctx, to.String(val.ID)+"/networkRuleSets/default", AzureMessagingNamespaceApiVersion, &networkRuleSet); err != nil {
+				logger.WithField("namespace", to.String(val.ID)).Error(err)
+				continue
+			}
+
+			resourceId := toResourceId(val.ID)
+
+			infoLabels := prometheus.Labels{
+				"resourceID":           resourceId,
+				"subscriptionID":       to.String(subscription.SubscriptionID),
+				"resourceGroup":        extractResourceGroupFromAzureId(to.String(val.ID)),
+				"name":                 to.String(val.Name),
+				"namespaceType":        namespaceType,
+				"sku":                  "",
+				"defaultAction":        "",
+				"trustedServiceBypass": "",
+				"publicNetworkAccess":  "",
+				"privateEndpointOnly":  "",
+			}
+
+			if val.Sku != nil {
+				infoLabels["sku"] = to.String(val.Sku.Name)
+			}
+
+			privateEndpointCount := 0
+			if val.Properties != nil {
+				infoLabels["publicNetworkAccess"] = to.String(val.Properties.PublicNetworkAccess)
+				privateEndpointCount = len(val.Properties.PrivateEndpointConnections)
+			}
+
+			ipRuleCount := 0
+			virtualNetworkRuleCount := 0
+			if networkRuleSet.Properties != nil {
+				infoLabels["defaultAction"] = to.String(networkRuleSet.Properties.DefaultAction)
+				infoLabels["trustedServiceBypass"] = boolToString(to.Bool(networkRuleSet.Properties.TrustedServiceAccessEnabled))
+				if networkRuleSet.Properties.PublicNetworkAccess != nil {
+					infoLabels["publicNetworkAccess"] = to.String(networkRuleSet.Properties.PublicNetworkAccess)
+				}
+				ipRuleCount = len(networkRuleSet.Properties.IPRules)
+				virtualNetworkRuleCount = len(networkRuleSet.Properties.VirtualNetworkRules)
+			}
+
+			// namespace is only reachable via private endpoints if public access is disabled
+			// or public access is denied without any ip/vnet exceptions
+			privateEndpointOnly := strings.EqualFold(infoLabels["publicNetworkAccess"], "Disabled")
+			if strings.EqualFold(infoLabels["defaultAction"], "Deny") && ipRuleCount == 0 && virtualNetworkRuleCount == 0 && privateEndpointCount > 0 {
+				privateEndpointOnly = true
+			}
+			infoLabels["privateEndpointOnly"] = boolToString(privateEndpointOnly)
+
+			infoMetric.AddInfo(infoLabels)
+
+			rulesMetric.Add(prometheus.Labels{
+				"resourceID":     resourceId,
+				"subscriptionID": to.String(subscription.SubscriptionID),
+				"type":           "ip",
+			}, float64(ipRuleCount))
+
+			rulesMetric.Add(prometheus.Labels{
+				"resourceID":     resourceId,
+				"subscriptionID": to.String(subscription.SubscriptionID),
+				"type":           "virtualNetwork",
+			}, float64(virtualNetworkRuleCount))
+		}
+	}
+
+	callback <- func() {
+		infoMetric.GaugeSet(m.prometheus.namespaceNetworkRuleSet)
+		rulesMetric.GaugeSet(m.prometheus.namespaceNetworkRuleSetRules)
+	}
+}
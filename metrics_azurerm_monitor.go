@@ -0,0 +1,159 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/Azure/azure-sdk-for-go/profiles/latest/resources/mgmt/subscriptions"
+	"github.com/Azure/azure-sdk-for-go/services/preview/monitor/mgmt/2019-06-01/insights"
+	"github.com/Azure/go-autorest/autorest/to"
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+	prometheusCommon "github.com/webdevops/go-prometheus-common"
+	"strings"
+	"time"
+)
+
+type MetricsCollectorAzureRmMonitor struct {
+	CollectorProcessorGeneral
+
+	prometheus struct {
+		metric *prometheus.GaugeVec
+	}
+}
+
+func (m *MetricsCollectorAzureRmMonitor) Setup(collector *CollectorGeneral) {
+	m.CollectorReference = collector
+
+	m.prometheus.metric = m.newGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_monitor_metric",
+			Help: "Azure Monitor platform metric value (latest datapoint)",
+		},
+		[]string{
+			"resourceID",
+			"subscriptionID",
+			"resourceGroup",
+			"resourceType",
+			"metric",
+			"aggregation",
+			"unit",
+		},
+	)
+	m.MustRegister(m.prometheus.metric)
+}
+
+// Dependencies uses the resource list of the Resource collector
+func (m *MetricsCollectorAzureRmMonitor) Dependencies() []string {
+	return []string{"Resource"}
+}
+
+func (m *MetricsCollectorAzureRmMonitor) Reset() {
+	m.prometheus.metric.Reset()
+}
+
+func (m *MetricsCollectorAzureRmMonitor) Collect(ctx context.Context, logger *log.Entry, callback chan<- func(), subscription subscriptions.Subscription) {
+	resourceCache, err := azureResourceCacheGet(ctx, subscription)
+	if err != nil {
+		logger.Panic(err)
+	}
+
+	metricsClient := insights.NewMetricsClientWithBaseURI(azureEnvironment.ResourceManagerEndpoint, *subscription.SubscriptionID)
+	metricsClient.Authorizer = AzureAuthorizer
+	metricsClient.ResponseInspector = azureResponseInspector(&subscription)
+
+	// group configured metrics by resource type and aggregation (one metrics request per resource and aggregation)
+	metricConfig := map[string]map[string][]string{}
+	for _, monitorMetric := range monitorMetricList {
+		if _, exists := metricConfig[monitorMetric.ResourceType]; !exists {
+			metricConfig[monitorMetric.ResourceType] = map[string][]string{}
+		}
+		metricConfig[monitorMetric.ResourceType][monitorMetric.Aggregation] = append(metricConfig[monitorMetric.ResourceType][monitorMetric.Aggregation], monitorMetric.Metric)
+	}
+
+	endTime := time.Now().UTC()
+	startTime := endTime.Add(-opts.Monitor.Timespan)
+	timespan := fmt.Sprintf("%s/%s", startTime.Format(time.RFC3339), endTime.Format(time.RFC3339))
+
+	metricList := prometheusCommon.NewMetricsList()
+
+	for resourceType, aggregationList := range metricConfig {
+		for _, val := range resourceCache.ListByType(resourceType) {
+			resourceId := val.ID
+
+			for aggregation, metricNames := range aggregationList {
+				result, err := metricsClient.List(ctx, resourceId, timespan, &opts.Monitor.Interval, strings.Join(metricNames, ","), aggregation, nil, "", "", insights.Data, "")
+				if err != nil {
+					logger.WithField("resourceID", resourceId).Error(err)
+					continue
+				}
+
+				if result.Value == nil {
+					continue
+				}
+
+				for _, metric := range *result.Value {
+					value, exists := azureMonitorMetricLatestValue(metric, aggregation)
+					if !exists {
+						continue
+					}
+
+					metricName := ""
+					if metric.Name != nil {
+						metricName = to.String(metric.Name.Value)
+					}
+
+					metricList.Add(prometheus.Labels{
+						"resourceID":     toResourceId(&resourceId),
+						"subscriptionID": to.String(subscription.SubscriptionID),
+						"resourceGroup":  extractResourceGroupFromAzureId(resourceId),
+						"resourceType":   resourceType,
+						"metric":         metricName,
+						"aggregation":    aggregation,
+						"unit":           string(metric.Unit),
+					}, value)
+				}
+			}
+		}
+	}
+
+	callback <- func() {
+		metricList.GaugeSet(m.prometheus.metric)
+	}
+}
+
+// returns the value of the latest datapoint (for the requested aggregation) of a metric
+func azureMonitorMetricLatestValue(metric insights.Metric, aggregation string) (value float64, exists bool) {
+	if metric.Timeseries == nil {
+		return
+	}
+
+	for _, timeseries := range *metric.Timeseries {
+		if timeseries.Data == nil {
+			continue
+		}
+
+		for _, data := range *timeseries.Data {
+			var dataValue *float64
+			switch aggregation {
+			case "average":
+				dataValue = data.Average
+			case "minimum":
+				dataValue = data.Minimum
+			case "maximum":
+				dataValue = data.Maximum
+			case "total":
+				dataValue = data.Total
+			case "count":
+				dataValue = data.Count
+			}
+
+			// datapoints are ordered by time, keep the last one with a value
+			if dataValue != nil {
+				value = *dataValue
+				exists = true
+			}
+		}
+	}
+
+	return
+}
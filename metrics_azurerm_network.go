@@ -0,0 +1,1067 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/Azure/azure-sdk-for-go/profiles/latest/network/mgmt/network"
+	"github.com/Azure/azure-sdk-for-go/profiles/latest/resources/mgmt/subscriptions"
+	"github.com/Azure/go-autorest/autorest/to"
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+	prometheusCommon "github.com/webdevops/go-prometheus-common"
+	"strconv"
+	"strings"
+)
+
+const (
+	// custom ip prefix advertisement (noInternetAdvertise) is not available in the used Azure SDK version
+	AzureCustomIpPrefixApiVersion = "2022-07-01"
+
+	// Bastion Developer SKU and scale units are not available in the used Azure SDK version
+	AzureBastionApiVersion = "2023-09-01"
+
+	// concurrent sessions per Bastion instance (scale unit) for medium workloads,
+	// Basic SKU has two fixed instances, Developer SKU is a shared deployment
+	AzureBastionRdpSessionsPerInstance = 20
+	AzureBastionSshSessionsPerInstance = 40
+	AzureBastionBasicInstances         = 2
+	AzureBastionDeveloperSessions      = 1
+)
+
+type MetricsCollectorAzureRmNetwork struct {
+	CollectorProcessorGeneral
+
+	prometheus struct {
+		networkInterface                *prometheus.GaugeVec
+		networkInterfaceIpConfiguration *prometheus.GaugeVec
+
+		applicationSecurityGroup        *prometheus.GaugeVec
+		applicationSecurityGroupMembers *prometheus.GaugeVec
+
+		networkSecurityGroup     *prometheus.GaugeVec
+		networkSecurityGroupRule *prometheus.GaugeVec
+
+		loadBalancer            *prometheus.GaugeVec
+		loadBalancerFrontend    *prometheus.GaugeVec
+		loadBalancerRuleCount   *prometheus.GaugeVec
+		loadBalancerBackendPool *prometheus.GaugeVec
+		loadBalancerRuleProbe   *prometheus.GaugeVec
+
+		customIpPrefix                 *prometheus.GaugeVec
+		customIpPrefixPublicIpPrefixes *prometheus.GaugeVec
+
+		bastion             *prometheus.GaugeVec
+		bastionScaleUnits   *prometheus.GaugeVec
+		bastionSessionLimit *prometheus.GaugeVec
+	}
+}
+
+// azureBastionHost is a Bastion host
+type azureBastionHost struct {
+	ID       string             `json:"id"`
+	Name     string             `json:"name"`
+	Location string             `json:"location"`
+	Tags     map[string]*string `json:"tags"`
+	Sku      *struct {
+		Name string `json:"name"`
+	} `json:"sku"`
+	Properties struct {
+		DnsName           string `json:"dnsName"`
+		ScaleUnits        *int   `json:"scaleUnits"`
+		ProvisioningState string `json:"provisioningState"`
+	} `json:"properties"`
+}
+
+// azureCustomIpPrefix is a custom ip prefix (BYOIP)
+type azureCustomIpPrefix struct {
+	ID         string             `json:"id"`
+	Name       string             `json:"name"`
+	Location   string             `json:"location"`
+	Zones      []string           `json:"zones"`
+	Tags       map[string]*string `json:"tags"`
+	Properties struct {
+		Cidr                 string `json:"cidr"`
+		CommissionedState    string `json:"commissionedState"`
+		ProvisioningState    string `json:"provisioningState"`
+		NoInternetAdvertise  bool   `json:"noInternetAdvertise"`
+		FailedReason         string `json:"failedReason"`
+		CustomIpPrefixParent *struct {
+			ID string `json:"id"`
+		} `json:"customIpPrefixParent"`
+		PublicIpPrefixes []struct {
+			ID string `json:"id"`
+		} `json:"publicIpPrefixes"`
+	} `json:"properties"`
+}
+
+func (m *MetricsCollectorAzureRmNetwork) Setup(collector *CollectorGeneral) {
+	m.CollectorReference = collector
+
+	m.prometheus.networkInterface = m.newGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_networkinterface_info",
+			Help: "Azure network interface information",
+		},
+		append(
+			[]string{
+				"resourceID",
+				"subscriptionID",
+				"resourceGroup",
+				"name",
+				"location",
+				"privateIpAddress",
+				"subnetID",
+				"attachedResourceID",
+				"macAddress",
+				"acceleratedNetworking",
+				"ipForwarding",
+				"provisioningState",
+			},
+			azureResourceTags.prometheusLabels...,
+		),
+	)
+	m.MustRegister(m.prometheus.networkInterface)
+
+	m.prometheus.networkInterfaceIpConfiguration = m.newGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_networkinterface_ipconfig_info",
+			Help: "Azure network interface ip configuration information",
+		},
+		[]string{
+			"resourceID",
+			"subscriptionID",
+			"ipConfiguration",
+			"primary",
+			"privateIpAddress",
+			"privateIpAllocationMethod",
+			"privateIpAddressVersion",
+			"subnetID",
+			"publicIpAddressID",
+		},
+	)
+	m.MustRegister(m.prometheus.networkInterfaceIpConfiguration)
+
+	m.prometheus.applicationSecurityGroup = m.newGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_applicationsecuritygroup_info",
+			Help: "Azure application security group information",
+		},
+		append(
+			[]string{
+				"resourceID",
+				"subscriptionID",
+				"resourceGroup",
+				"name",
+				"location",
+				"provisioningState",
+			},
+			azureResourceTags.prometheusLabels...,
+		),
+	)
+	m.MustRegister(m.prometheus.applicationSecurityGroup)
+
+	m.prometheus.applicationSecurityGroupMembers = m.newGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_applicationsecuritygroup_members",
+			Help: "Azure application security group member count (network interfaces)",
+		},
+		[]string{
+			"resourceID",
+			"subscriptionID",
+		},
+	)
+	m.MustRegister(m.prometheus.applicationSecurityGroupMembers)
+
+	m.prometheus.networkSecurityGroup = m.newGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_nsg_info",
+			Help: "Azure network security group information",
+		},
+		append(
+			[]string{
+				"resourceID",
+				"subscriptionID",
+				"resourceGroup",
+				"name",
+				"location",
+				"subnetCount",
+				"networkInterfaceCount",
+			},
+			azureResourceTags.prometheusLabels...,
+		),
+	)
+	m.MustRegister(m.prometheus.networkSecurityGroup)
+
+	m.prometheus.networkSecurityGroupRule = m.newGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_nsg_rule_info",
+			Help: "Azure network security group rule information (including default rules)",
+		},
+		[]string{
+			"resourceID",
+			"subscriptionID",
+			"rule",
+			"default",
+			"priority",
+			"direction",
+			"access",
+			"protocol",
+			"sourceAddressPrefix",
+			"sourcePortRange",
+			"destinationAddressPrefix",
+			"destinationPortRange",
+		},
+	)
+	m.MustRegister(m.prometheus.networkSecurityGroupRule)
+
+	m.prometheus.loadBalancer = m.newGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_loadbalancer_info",
+			Help: "Azure load balancer information",
+		},
+		append(
+			[]string{
+				"resourceID",
+				"subscriptionID",
+				"resourceGroup",
+				"name",
+				"location",
+				"skuName",
+				"skuTier",
+			},
+			azureResourceTags.prometheusLabels...,
+		),
+	)
+	m.MustRegister(m.prometheus.loadBalancer)
+
+	m.prometheus.loadBalancerFrontend = m.newGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_loadbalancer_frontend_info",
+			Help: "Azure load balancer frontend ip configuration",
+		},
+		[]string{
+			"resourceID",
+			"subscriptionID",
+			"frontend",
+			"privateIpAddress",
+			"publicIpAddressID",
+			"subnetID",
+			"zone",
+		},
+	)
+	m.MustRegister(m.prometheus.loadBalancerFrontend)
+
+	m.prometheus.loadBalancerRuleCount = m.newGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_loadbalancer_rule_count",
+			Help: "Azure load balancer rule count by type (loadBalancing, inboundNat, outbound)",
+		},
+		[]string{
+			"resourceID",
+			"subscriptionID",
+			"type",
+		},
+	)
+	m.MustRegister(m.prometheus.loadBalancerRuleCount)
+
+	m.prometheus.loadBalancerBackendPool = m.newGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_loadbalancer_backendpool_size",
+			Help: "Azure load balancer backend pool size (ip configurations and backend addresses)",
+		},
+		[]string{
+			"resourceID",
+			"subscriptionID",
+			"backendPool",
+		},
+	)
+	m.MustRegister(m.prometheus.loadBalancerBackendPool)
+
+	m.prometheus.loadBalancerRuleProbe = m.newGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_loadbalancer_rule_probe",
+			Help: "Azure load balancer rule health probe configuration (0 if rule has no probe)",
+		},
+		[]string{
+			"resourceID",
+			"subscriptionID",
+			"resourceGroup",
+			"rule",
+			"ruleProtocol",
+			"frontendPort",
+			"backendPort",
+			"probe",
+			"probeProtocol",
+			"probePort",
+			"probeRequestPath",
+			"probeInterval",
+			"probeThreshold",
+		},
+	)
+	m.MustRegister(m.prometheus.loadBalancerRuleProbe)
+
+	m.prometheus.customIpPrefix = m.newGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_customipprefix_info",
+			Help: "Azure custom ip prefix (BYOIP) information",
+		},
+		append(
+			[]string{
+				"resourceID",
+				"subscriptionID",
+				"resourceGroup",
+				"name",
+				"location",
+				"cidr",
+				"parentID",
+				"commissionedState",
+				"provisioningState",
+				"advertised",
+				"failedReason",
+				"zones",
+			},
+			azureResourceTags.prometheusLabels...,
+		),
+	)
+	m.MustRegister(m.prometheus.customIpPrefix)
+
+	m.prometheus.customIpPrefixPublicIpPrefixes = m.newGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_customipprefix_publicipprefix_count",
+			Help: "Azure custom ip prefix (BYOIP) number of derived public ip prefixes",
+		},
+		[]string{
+			"resourceID",
+			"subscriptionID",
+		},
+	)
+	m.MustRegister(m.prometheus.customIpPrefixPublicIpPrefixes)
+
+	m.prometheus.bastion = m.newGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_bastion_info",
+			Help: "Azure Bastion host information",
+		},
+		append(
+			[]string{
+				"resourceID",
+				"subscriptionID",
+				"resourceGroup",
+				"name",
+				"location",
+				"skuName",
+				"dnsName",
+				"provisioningState",
+			},
+			azureResourceTags.prometheusLabels...,
+		),
+	)
+	m.MustRegister(m.prometheus.bastion)
+
+	m.prometheus.bastionScaleUnits = m.newGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_bastion_scaleunits",
+			Help: "Azure Bastion host scale units (instances)",
+		},
+		[]string{
+			"resourceID",
+			"subscriptionID",
+		},
+	)
+	m.MustRegister(m.prometheus.bastionScaleUnits)
+
+	m.prometheus.bastionSessionLimit = m.newGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_bastion_session_limit",
+			Help: "Azure Bastion host concurrent session limit derived from sku and scale units (medium workload)",
+		},
+		[]string{
+			"resourceID",
+			"subscriptionID",
+			"protocol",
+		},
+	)
+	m.MustRegister(m.prometheus.bastionSessionLimit)
+}
+
+func (m *MetricsCollectorAzureRmNetwork) Reset() {
+	m.prometheus.networkInterface.Reset()
+	m.prometheus.networkInterfaceIpConfiguration.Reset()
+	m.prometheus.applicationSecurityGroup.Reset()
+	m.prometheus.applicationSecurityGroupMembers.Reset()
+	m.prometheus.networkSecurityGroup.Reset()
+	m.prometheus.networkSecurityGroupRule.Reset()
+	m.prometheus.loadBalancer.Reset()
+	m.prometheus.loadBalancerFrontend.Reset()
+	m.prometheus.loadBalancerRuleCount.Reset()
+	m.prometheus.loadBalancerBackendPool.Reset()
+	m.prometheus.loadBalancerRuleProbe.Reset()
+	m.prometheus.customIpPrefix.Reset()
+	m.prometheus.customIpPrefixPublicIpPrefixes.Reset()
+	m.prometheus.bastion.Reset()
+	m.prometheus.bastionScaleUnits.Reset()
+	m.prometheus.bastionSessionLimit.Reset()
+}
+
+func (m *MetricsCollectorAzureRmNetwork) Collect(ctx context.Context, logger *log.Entry, callback chan<- func(), subscription subscriptions.Subscription) {
+	asgMemberCount := m.collectNetworkInterfaces(ctx, logger, callback, subscription)
+	m.collectApplicationSecurityGroups(ctx, logger, callback, subscription, asgMemberCount)
+	m.collectNetworkSecurityGroups(ctx, logger, callback, subscription)
+	m.collectLoadBalancers(ctx, logger, callback, subscription)
+	m.collectCustomIpPrefixes(ctx, logger, callback, subscription)
+	m.collectBastionHosts(ctx, logger, callback, subscription)
+}
+
+// Collect Azure network interfaces and their ip configurations, returns the member count per application security group
+func (m *MetricsCollectorAzureRmNetwork) collectNetworkInterfaces(ctx context.Context, logger *log.Entry, callback chan<- func(), subscription subscriptions.Subscription) (asgMemberCount map[string]float64) {
+	client := network.NewInterfacesClientWithBaseURI(azureEnvironment.ResourceManagerEndpoint, *subscription.SubscriptionID)
+	client.Authorizer = AzureAuthorizer
+	client.ResponseInspector = azureResponseInspector(&subscription)
+
+	list, err := client.ListAllComplete(ctx)
+	if err != nil {
+		logger.Panic(err)
+	}
+
+	infoMetric := prometheusCommon.NewMetricsList()
+	ipConfigurationMetric := prometheusCommon.NewMetricsList()
+	asgMemberCount = map[string]float64{}
+
+	// shared with dependent collectors (eg. Orphaned)
+	storeEnabled := m.CollectorReference.HasDependents()
+	storeList := []network.Interface{}
+
+	for list.NotDone() {
+		val := list.Value()
+		if storeEnabled {
+			storeList = append(storeList, val)
+		}
+		resourceId := toResourceId(val.ID)
+		asgList := map[string]bool{}
+
+		infoLabels := prometheus.Labels{
+			"resourceID":            resourceId,
+			"subscriptionID":        to.String(subscription.SubscriptionID),
+			"resourceGroup":         extractResourceGroupFromAzureId(to.String(val.ID)),
+			"name":                  to.String(val.Name),
+			"location":              to.String(val.Location),
+			"privateIpAddress":      "",
+			"subnetID":              "",
+			"attachedResourceID":    "",
+			"macAddress":            "",
+			"acceleratedNetworking": "",
+			"ipForwarding":          "",
+			"provisioningState":     "",
+		}
+
+		if val.InterfacePropertiesFormat != nil {
+			infoLabels["macAddress"] = to.String(val.MacAddress)
+			infoLabels["acceleratedNetworking"] = boolToString(to.Bool(val.EnableAcceleratedNetworking))
+			infoLabels["ipForwarding"] = boolToString(to.Bool(val.EnableIPForwarding))
+			infoLabels["provisioningState"] = strings.ToLower(string(val.ProvisioningState))
+
+			if val.VirtualMachine != nil {
+				infoLabels["attachedResourceID"] = toResourceId(val.VirtualMachine.ID)
+			} else if val.PrivateEndpoint != nil {
+				infoLabels["attachedResourceID"] = toResourceId(val.PrivateEndpoint.ID)
+			}
+
+			if val.IPConfigurations != nil {
+				for _, ipConfiguration := range *val.IPConfigurations {
+					if ipConfiguration.InterfaceIPConfigurationPropertiesFormat == nil {
+						continue
+					}
+
+					ipConfigurationLabels := prometheus.Labels{
+						"resourceID":                resourceId,
+						"subscriptionID":            to.String(subscription.SubscriptionID),
+						"ipConfiguration":           to.String(ipConfiguration.Name),
+						"primary":                   boolToString(to.Bool(ipConfiguration.Primary)),
+						"privateIpAddress":          to.String(ipConfiguration.PrivateIPAddress),
+						"privateIpAllocationMethod": string(ipConfiguration.PrivateIPAllocationMethod),
+						"privateIpAddressVersion":   string(ipConfiguration.PrivateIPAddressVersion),
+						"subnetID":                  "",
+						"publicIpAddressID":         "",
+					}
+
+					if ipConfiguration.Subnet != nil {
+						ipConfigurationLabels["subnetID"] = toResourceId(ipConfiguration.Subnet.ID)
+					}
+
+					if ipConfiguration.PublicIPAddress != nil {
+						ipConfigurationLabels["publicIpAddressID"] = toResourceId(ipConfiguration.PublicIPAddress.ID)
+					}
+
+					// nic info shows the primary ip configuration
+					if to.Bool(ipConfiguration.Primary) {
+						infoLabels["privateIpAddress"] = ipConfigurationLabels["privateIpAddress"]
+						infoLabels["subnetID"] = ipConfigurationLabels["subnetID"]
+					}
+
+					if ipConfiguration.ApplicationSecurityGroups != nil {
+						for _, asg := range *ipConfiguration.ApplicationSecurityGroups {
+							asgList[strings.ToLower(to.String(asg.ID))] = true
+						}
+					}
+
+					ipConfigurationMetric.AddInfo(ipConfigurationLabels)
+				}
+			}
+		}
+
+		for asgId := range asgList {
+			asgMemberCount[asgId]++
+		}
+
+		infoLabels = azureResourceTags.appendPrometheusLabel(infoLabels, val.Tags)
+		infoMetric.AddInfo(infoLabels)
+
+		if list.NextWithContext(ctx) != nil {
+			storeEnabled = false
+			break
+		}
+	}
+
+	if storeEnabled {
+		m.storeSet(collectorStoreNetworkInterfaces, subscription, storeList)
+	}
+
+	callback <- func() {
+		infoMetric.GaugeSet(m.prometheus.networkInterface)
+		ipConfigurationMetric.GaugeSet(m.prometheus.networkInterfaceIpConfiguration)
+	}
+
+	return
+}
+
+// Collect Azure application security groups
+func (m *MetricsCollectorAzureRmNetwork) collectApplicationSecurityGroups(ctx context.Context, logger *log.Entry, callback chan<- func(), subscription subscriptions.Subscription, asgMemberCount map[string]float64) {
+	client := network.NewApplicationSecurityGroupsClientWithBaseURI(azureEnvironment.ResourceManagerEndpoint, *subscription.SubscriptionID)
+	client.Authorizer = AzureAuthorizer
+	client.ResponseInspector = azureResponseInspector(&subscription)
+
+	list, err := client.ListAllComplete(ctx)
+	if err != nil {
+		logger.Panic(err)
+	}
+
+	infoMetric := prometheusCommon.NewMetricsList()
+	membersMetric := prometheusCommon.NewMetricsList()
+
+	for list.NotDone() {
+		val := list.Value()
+		resourceId := toResourceId(val.ID)
+
+		infoLabels := prometheus.Labels{
+			"resourceID":        resourceId,
+			"subscriptionID":    to.String(subscription.SubscriptionID),
+			"resourceGroup":     extractResourceGroupFromAzureId(to.String(val.ID)),
+			"name":              to.String(val.Name),
+			"location":          to.String(val.Location),
+			"provisioningState": "",
+		}
+
+		if val.ApplicationSecurityGroupPropertiesFormat != nil {
+			infoLabels["provisioningState"] = strings.ToLower(string(val.ProvisioningState))
+		}
+
+		infoLabels = azureResourceTags.appendPrometheusLabel(infoLabels, val.Tags)
+		infoMetric.AddInfo(infoLabels)
+
+		membersMetric.Add(prometheus.Labels{
+			"resourceID":     resourceId,
+			"subscriptionID": to.String(subscription.SubscriptionID),
+		}, asgMemberCount[strings.ToLower(to.String(val.ID))])
+
+		if list.NextWithContext(ctx) != nil {
+			break
+		}
+	}
+
+	callback <- func() {
+		infoMetric.GaugeSet(m.prometheus.applicationSecurityGroup)
+		membersMetric.GaugeSet(m.prometheus.applicationSecurityGroupMembers)
+	}
+}
+
+// Collect Azure network security groups and their (default) security rules
+func (m *MetricsCollectorAzureRmNetwork) collectNetworkSecurityGroups(ctx context.Context, logger *log.Entry, callback chan<- func(), subscription subscriptions.Subscription) {
+	client := network.NewSecurityGroupsClientWithBaseURI(azureEnvironment.ResourceManagerEndpoint, *subscription.SubscriptionID)
+	client.Authorizer = AzureAuthorizer
+	client.ResponseInspector = azureResponseInspector(&subscription)
+
+	list, err := client.ListAllComplete(ctx)
+	if err != nil {
+		logger.Panic(err)
+	}
+
+	infoMetric := prometheusCommon.NewMetricsList()
+	ruleMetric := prometheusCommon.NewMetricsList()
+
+	// shared with dependent collectors (eg. Orphaned)
+	storeEnabled := m.CollectorReference.HasDependents()
+	storeList := []network.SecurityGroup{}
+
+	for list.NotDone() {
+		val := list.Value()
+		if storeEnabled {
+			storeList = append(storeList, val)
+		}
+		resourceId := toResourceId(val.ID)
+
+		infoLabels := prometheus.Labels{
+			"resourceID":            resourceId,
+			"subscriptionID":        to.String(subscription.SubscriptionID),
+			"resourceGroup":         extractResourceGroupFromAzureId(to.String(val.ID)),
+			"name":                  to.String(val.Name),
+			"location":              to.String(val.Location),
+			"subnetCount":           "0",
+			"networkInterfaceCount": "0",
+		}
+
+		if val.SecurityGroupPropertiesFormat != nil {
+			if val.Subnets != nil {
+				infoLabels["subnetCount"] = strconv.Itoa(len(*val.Subnets))
+			}
+			if val.NetworkInterfaces != nil {
+				infoLabels["networkInterfaceCount"] = strconv.Itoa(len(*val.NetworkInterfaces))
+			}
+
+			for isDefault, ruleList := range map[bool]*[]network.SecurityRule{false: val.SecurityRules, true: val.DefaultSecurityRules} {
+				if ruleList == nil {
+					continue
+				}
+
+				for _, rule := range *ruleList {
+					if rule.SecurityRulePropertiesFormat == nil {
+						continue
+					}
+
+					ruleMetric.AddInfo(prometheus.Labels{
+						"resourceID":               resourceId,
+						"subscriptionID":           to.String(subscription.SubscriptionID),
+						"rule":                     to.String(rule.Name),
+						"default":                  boolToString(isDefault),
+						"priority":                 strconv.Itoa(int(to.Int32(rule.Priority))),
+						"direction":                strings.ToLower(string(rule.Direction)),
+						"access":                   strings.ToLower(string(rule.Access)),
+						"protocol":                 strings.ToLower(string(rule.Protocol)),
+						"sourceAddressPrefix":      networkSecurityRuleValues(rule.SourceAddressPrefix, rule.SourceAddressPrefixes),
+						"sourcePortRange":          networkSecurityRuleValues(rule.SourcePortRange, rule.SourcePortRanges),
+						"destinationAddressPrefix": networkSecurityRuleValues(rule.DestinationAddressPrefix, rule.DestinationAddressPrefixes),
+						"destinationPortRange":     networkSecurityRuleValues(rule.DestinationPortRange, rule.DestinationPortRanges),
+					})
+				}
+			}
+		}
+
+		infoLabels = azureResourceTags.appendPrometheusLabel(infoLabels, val.Tags)
+		infoMetric.AddInfo(infoLabels)
+
+		if list.NextWithContext(ctx) != nil {
+			storeEnabled = false
+			break
+		}
+	}
+
+	if storeEnabled {
+		m.storeSet(collectorStoreSecurityGroups, subscription, storeList)
+	}
+
+	callback <- func() {
+		infoMetric.GaugeSet(m.prometheus.networkSecurityGroup)
+		ruleMetric.GaugeSet(m.prometheus.networkSecurityGroupRule)
+	}
+}
+
+// networkSecurityRuleValues joins the single and the list value of a security rule field (eg. sourceAddressPrefix and sourceAddressPrefixes)
+func networkSecurityRuleValues(value *string, valueList *[]string) string {
+	ret := []string{}
+	if to.String(value) != "" {
+		ret = append(ret, to.String(value))
+	}
+	if valueList != nil {
+		ret = append(ret, *valueList...)
+	}
+	return strings.Join(ret, ",")
+}
+
+// Collect Azure load balancers with frontends, rule counts, backend pool sizes and rule health probes
+func (m *MetricsCollectorAzureRmNetwork) collectLoadBalancers(ctx context.Context, logger *log.Entry, callback chan<- func(), subscription subscriptions.Subscription) {
+	client := network.NewLoadBalancersClientWithBaseURI(azureEnvironment.ResourceManagerEndpoint, *subscription.SubscriptionID)
+	client.Authorizer = AzureAuthorizer
+	client.ResponseInspector = azureResponseInspector(&subscription)
+
+	list, err := client.ListAllComplete(ctx)
+	if err != nil {
+		logger.Panic(err)
+	}
+
+	infoMetric := prometheusCommon.NewMetricsList()
+	frontendMetric := prometheusCommon.NewMetricsList()
+	ruleCountMetric := prometheusCommon.NewMetricsList()
+	backendPoolMetric := prometheusCommon.NewMetricsList()
+	ruleProbeMetric := prometheusCommon.NewMetricsList()
+
+	for list.NotDone() {
+		val := list.Value()
+		resourceId := toResourceId(val.ID)
+
+		infoLabels := prometheus.Labels{
+			"resourceID":     resourceId,
+			"subscriptionID": to.String(subscription.SubscriptionID),
+			"resourceGroup":  extractResourceGroupFromAzureId(to.String(val.ID)),
+			"name":           to.String(val.Name),
+			"location":       to.String(val.Location),
+			"skuName":        "",
+			"skuTier":        "",
+		}
+
+		if val.Sku != nil {
+			infoLabels["skuName"] = string(val.Sku.Name)
+			infoLabels["skuTier"] = string(val.Sku.Tier)
+		}
+
+		infoLabels = azureResourceTags.appendPrometheusLabel(infoLabels, val.Tags)
+		infoMetric.AddInfo(infoLabels)
+
+		if val.LoadBalancerPropertiesFormat != nil {
+			if val.FrontendIPConfigurations != nil {
+				for _, frontend := range *val.FrontendIPConfigurations {
+					frontendLabels := prometheus.Labels{
+						"resourceID":        resourceId,
+						"subscriptionID":    to.String(subscription.SubscriptionID),
+						"frontend":          to.String(frontend.Name),
+						"privateIpAddress":  "",
+						"publicIpAddressID": "",
+						"subnetID":          "",
+						"zone":              "",
+					}
+
+					if frontend.Zones != nil {
+						frontendLabels["zone"] = strings.Join(*frontend.Zones, ",")
+					}
+
+					if frontend.FrontendIPConfigurationPropertiesFormat != nil {
+						frontendLabels["privateIpAddress"] = to.String(frontend.PrivateIPAddress)
+						if frontend.PublicIPAddress != nil {
+							frontendLabels["publicIpAddressID"] = toResourceId(frontend.PublicIPAddress.ID)
+						}
+						if frontend.Subnet != nil {
+							frontendLabels["subnetID"] = toResourceId(frontend.Subnet.ID)
+						}
+					}
+
+					frontendMetric.AddInfo(frontendLabels)
+				}
+			}
+
+			ruleCount := map[string]int{
+				"loadBalancing": 0,
+				"inboundNat":    0,
+				"outbound":      0,
+			}
+			if val.LoadBalancingRules != nil {
+				ruleCount["loadBalancing"] = len(*val.LoadBalancingRules)
+			}
+			if val.InboundNatRules != nil {
+				ruleCount["inboundNat"] = len(*val.InboundNatRules)
+			}
+			if val.OutboundRules != nil {
+				ruleCount["outbound"] = len(*val.OutboundRules)
+			}
+			for ruleType, count := range ruleCount {
+				ruleCountMetric.Add(prometheus.Labels{
+					"resourceID":     resourceId,
+					"subscriptionID": to.String(subscription.SubscriptionID),
+					"type":           ruleType,
+				}, float64(count))
+			}
+
+			if val.BackendAddressPools != nil {
+				for _, pool := range *val.BackendAddressPools {
+					poolSize := 0
+					if pool.BackendAddressPoolPropertiesFormat != nil {
+						if pool.BackendIPConfigurations != nil {
+							poolSize += len(*pool.BackendIPConfigurations)
+						}
+						if pool.LoadBalancerBackendAddresses != nil {
+							poolSize += len(*pool.LoadBalancerBackendAddresses)
+						}
+					}
+
+					backendPoolMetric.Add(prometheus.Labels{
+						"resourceID":     resourceId,
+						"subscriptionID": to.String(subscription.SubscriptionID),
+						"backendPool":    to.String(pool.Name),
+					}, float64(poolSize))
+				}
+			}
+		}
+
+		if val.LoadBalancerPropertiesFormat != nil && val.LoadBalancingRules != nil {
+			probeList := map[string]network.Probe{}
+			if val.Probes != nil {
+				for _, probe := range *val.Probes {
+					probeList[strings.ToLower(to.String(probe.ID))] = probe
+				}
+			}
+
+			for _, rule := range *val.LoadBalancingRules {
+				if rule.LoadBalancingRulePropertiesFormat == nil {
+					continue
+				}
+
+				ruleLabels := prometheus.Labels{
+					"resourceID":       resourceId,
+					"subscriptionID":   to.String(subscription.SubscriptionID),
+					"resourceGroup":    extractResourceGroupFromAzureId(to.String(val.ID)),
+					"rule":             to.String(rule.Name),
+					"ruleProtocol":     string(rule.Protocol),
+					"frontendPort":     strconv.Itoa(int(to.Int32(rule.FrontendPort))),
+					"backendPort":      strconv.Itoa(int(to.Int32(rule.BackendPort))),
+					"probe":            "",
+					"probeProtocol":    "",
+					"probePort":        "",
+					"probeRequestPath": "",
+					"probeInterval":    "",
+					"probeThreshold":   "",
+				}
+
+				probeConfigured := false
+				if rule.Probe != nil {
+					if probe, exists := probeList[strings.ToLower(to.String(rule.Probe.ID))]; exists && probe.ProbePropertiesFormat != nil {
+						probeConfigured = true
+						ruleLabels["probe"] = to.String(probe.Name)
+						ruleLabels["probeProtocol"] = string(probe.Protocol)
+						ruleLabels["probePort"] = strconv.Itoa(int(to.Int32(probe.Port)))
+						ruleLabels["probeRequestPath"] = to.String(probe.RequestPath)
+						ruleLabels["probeInterval"] = strconv.Itoa(int(to.Int32(probe.IntervalInSeconds)))
+						ruleLabels["probeThreshold"] = strconv.Itoa(int(to.Int32(probe.NumberOfProbes)))
+					}
+				}
+
+				if probeConfigured {
+					ruleProbeMetric.Add(ruleLabels, 1)
+				} else {
+					ruleProbeMetric.Add(ruleLabels, 0)
+				}
+			}
+		}
+
+		if list.NextWithContext(ctx) != nil {
+			break
+		}
+	}
+
+	callback <- func() {
+		infoMetric.GaugeSet(m.prometheus.loadBalancer)
+		frontendMetric.GaugeSet(m.prometheus.loadBalancerFrontend)
+		ruleCountMetric.GaugeSet(m.prometheus.loadBalancerRuleCount)
+		backendPoolMetric.GaugeSet(m.prometheus.loadBalancerBackendPool)
+		ruleProbeMetric.GaugeSet(m.prometheus.loadBalancerRuleProbe)
+	}
+}
+
+// Collect Azure custom ip prefixes (BYOIP) with provisioning, commissioning and advertisement state
+func (m *MetricsCollectorAzureRmNetwork) collectCustomIpPrefixes(ctx context.Context, logger *log.Entry, callback chan<- func(), subscription subscriptions.Subscription) {
+	client := NewAzureRestClient(&subscription)
+
+	list, err := client.List(ctx, fmt.Sprintf("/subscriptions/%s/providers/Microsoft.Network/customIpPrefixes", *subscription.SubscriptionID), AzureCustomIpPrefixApiVersion)
+	if err != nil {
+		logger.Panic(err)
+	}
+
+	infoMetric := prometheusCommon.NewMetricsList()
+	publicIpPrefixMetric := prometheusCommon.NewMetricsList()
+
+	for _, row := range list {
+		customIpPrefix := azureCustomIpPrefix{}
+		if err := json.Unmarshal(row, &customIpPrefix); err != nil {
+			logger.Error(err)
+			continue
+		}
+
+		resourceId := toResourceId(&customIpPrefix.ID)
+
+		parentId := ""
+		if customIpPrefix.Properties.CustomIpPrefixParent != nil {
+			parentId = toResourceId(&customIpPrefix.Properties.CustomIpPrefixParent.ID)
+		}
+
+		// commissioned prefixes are advertised to the internet unless disabled
+		advertised := strings.EqualFold(customIpPrefix.Properties.CommissionedState, "Commissioned") && !customIpPrefix.Properties.NoInternetAdvertise
+
+		infoLabels := prometheus.Labels{
+			"resourceID":        resourceId,
+			"subscriptionID":    to.String(subscription.SubscriptionID),
+			"resourceGroup":     extractResourceGroupFromAzureId(customIpPrefix.ID),
+			"name":              customIpPrefix.Name,
+			"location":          strings.ToLower(customIpPrefix.Location),
+			"cidr":              customIpPrefix.Properties.Cidr,
+			"parentID":          parentId,
+			"commissionedState": customIpPrefix.Properties.CommissionedState,
+			"provisioningState": customIpPrefix.Properties.ProvisioningState,
+			"advertised":        boolToString(advertised),
+			"failedReason":      customIpPrefix.Properties.FailedReason,
+			"zones":             strings.Join(customIpPrefix.Zones, ","),
+		}
+		infoLabels = azureResourceTags.appendPrometheusLabel(infoLabels, customIpPrefix.Tags)
+		infoMetric.AddInfo(infoLabels)
+
+		publicIpPrefixMetric.Add(prometheus.Labels{
+			"resourceID":     resourceId,
+			"subscriptionID": to.String(subscription.SubscriptionID),
+		}, float64(len(customIpPrefix.Properties.PublicIpPrefixes)))
+	}
+
+	callback <- func() {
+		infoMetric.GaugeSet(m.prometheus.customIpPrefix)
+		publicIpPrefixMetric.GaugeSet(m.prometheus.customIpPrefixPublicIpPrefixes)
+	}
+}
+
+// Collect Azure Bastion hosts with sku, scale units and derived concurrent session limits
+func (m *MetricsCollectorAzureRmNetwork) collectBastionHosts(ctx context.Context, logger *log.Entry, callback chan<- func(), subscription subscriptions.Subscription) {
+	client := NewAzureRestClient(&subscription)
+
+	list, err := client.List(ctx, fmt.Sprintf("/subscriptions/%s/providers/Microsoft.Network/bastionHosts", *subscription.SubscriptionID), AzureBastionApiVersion)
+	if err != nil {
+		logger.Panic(err)
+	}
+
+	infoMetric := prometheusCommon.NewMetricsList()
+	scaleUnitsMetric := prometheusCommon.NewMetricsList()
+	sessionLimitMetric := prometheusCommon.NewMetricsList()
+
+	for _, row := range list {
+		bastionHost := azureBastionHost{}
+		if err := json.Unmarshal(row, &bastionHost); err != nil {
+			logger.Error(err)
+			continue
+		}
+
+		resourceId := toResourceId(&bastionHost.ID)
+
+		skuName := ""
+		if bastionHost.Sku != nil {
+			skuName = bastionHost.Sku.Name
+		}
+
+		infoLabels := prometheus.Labels{
+			"resourceID":        resourceId,
+			"subscriptionID":    to.String(subscription.SubscriptionID),
+			"resourceGroup":     extractResourceGroupFromAzureId(bastionHost.ID),
+			"name":              bastionHost.Name,
+			"location":          strings.ToLower(bastionHost.Location),
+			"skuName":           skuName,
+			"dnsName":           bastionHost.Properties.DnsName,
+			"provisioningState": bastionHost.Properties.ProvisioningState,
+		}
+		infoLabels = azureResourceTags.appendPrometheusLabel(infoLabels, bastionHost.Tags)
+		infoMetric.AddInfo(infoLabels)
+
+		rdpSessions := 0
+		sshSessions := 0
+		switch strings.ToLower(skuName) {
+		case "developer":
+			rdpSessions = AzureBastionDeveloperSessions
+			sshSessions = AzureBastionDeveloperSessions
+		case "basic":
+			scaleUnitsMetric.Add(prometheus.Labels{
+				"resourceID":     resourceId,
+				"subscriptionID": to.String(subscription.SubscriptionID),
+			}, AzureBastionBasicInstances)
+			rdpSessions = AzureBastionBasicInstances * AzureBastionRdpSessionsPerInstance
+			sshSessions = AzureBastionBasicInstances * AzureBastionSshSessionsPerInstance
+		default:
+			// Standard and Premium SKU
+			scaleUnits := AzureBastionBasicInstances
+			if bastionHost.Properties.ScaleUnits != nil {
+				scaleUnits = *bastionHost.Properties.ScaleUnits
+			}
+
+			scaleUnitsMetric.Add(prometheus.Labels{
+				"resourceID":     resourceId,
+				"subscriptionID": to.String(subscription.SubscriptionID),
+			}, float64(scaleUnits))
+			rdpSessions = scaleUnits * AzureBastionRdpSessionsPerInstance
+			sshSessions = scaleUnits * AzureBastionSshSessionsPerInstance
+		}
+
+		sessionLimitMetric.Add(prometheus.Labels{
+			"resourceID":     resourceId,
+			"subscriptionID": to.String(subscription.SubscriptionID),
+			"protocol":       "rdp",
+		}, float64(rdpSessions))
+
+		sessionLimitMetric.Add(prometheus.Labels{
+			"resourceID":     resourceId,
+			"subscriptionID": to.String(subscription.SubscriptionID),
+			"protocol":       "ssh",
+		}, float64(sshSessions))
+	}
+
+	callback <- func() {
+		infoMetric.GaugeSet(m.prometheus.bastion)
+		scaleUnitsMetric.GaugeSet(m.prometheus.bastionScaleUnits)
+		sessionLimitMetric.GaugeSet(m.prometheus.bastionSessionLimit)
+	}
+}
+
+// azureNetworkInterfaceList returns the network interfaces of the subscription shared by the Network collector
+// (collector store) or lists them
+func azureNetworkInterfaceList(ctx context.Context, logger *log.Entry, subscription subscriptions.Subscription) []network.Interface {
+	if storeList, exists := collectorStore.Get(collectorStoreNetworkInterfaces, *subscription.SubscriptionID); exists {
+		return storeList.([]network.Interface)
+	}
+
+	client := network.NewInterfacesClientWithBaseURI(azureEnvironment.ResourceManagerEndpoint, *subscription.SubscriptionID)
+	client.Authorizer = AzureAuthorizer
+	client.ResponseInspector = azureResponseInspector(&subscription)
+
+	list, err := client.ListAllComplete(ctx)
+	if err != nil {
+		logger.Panic(err)
+	}
+
+	ret := []network.Interface{}
+	for list.NotDone() {
+		ret = append(ret, list.Value())
+
+		if list.NextWithContext(ctx) != nil {
+			break
+		}
+	}
+
+	return ret
+}
+
+// azureNetworkSecurityGroupList returns the network security groups of the subscription shared by the Network collector
+// (collector store) or lists them
+func azureNetworkSecurityGroupList(ctx context.Context, logger *log.Entry, subscription subscriptions.Subscription) []network.SecurityGroup {
+	if storeList, exists := collectorStore.Get(collectorStoreSecurityGroups, *subscription.SubscriptionID); exists {
+		return storeList.([]network.SecurityGroup)
+	}
+
+	client := network.NewSecurityGroupsClientWithBaseURI(azureEnvironment.ResourceManagerEndpoint, *subscription.SubscriptionID)
+	client.Authorizer = AzureAuthorizer
+	client.ResponseInspector = azureResponseInspector(&subscription)
+
+	list, err := client.ListAllComplete(ctx)
+	if err != nil {
+		logger.Panic(err)
+	}
+
+	ret := []network.SecurityGroup{}
+	for list.NotDone() {
+		ret = append(ret, list.Value())
+
+		if list.NextWithContext(ctx) != nil {
+			break
+		}
+	}
+
+	return ret
+}
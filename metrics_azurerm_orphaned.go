@@ -0,0 +1,184 @@
+package main
+
+import (
+	"context"
+	"github.com/Azure/azure-sdk-for-go/profiles/latest/compute/mgmt/compute"
+	"github.com/Azure/azure-sdk-for-go/profiles/latest/network/mgmt/network"
+	"github.com/Azure/azure-sdk-for-go/profiles/latest/resources/mgmt/subscriptions"
+	"github.com/Azure/go-autorest/autorest/to"
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+	prometheusCommon "github.com/webdevops/go-prometheus-common"
+	"strings"
+)
+
+type MetricsCollectorAzureRmOrphaned struct {
+	CollectorProcessorGeneral
+
+	prometheus struct {
+		resourceOrphaned *prometheus.GaugeVec
+	}
+}
+
+func (m *MetricsCollectorAzureRmOrphaned) Setup(collector *CollectorGeneral) {
+	m.CollectorReference = collector
+
+	m.prometheus.resourceOrphaned = m.newGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_resource_orphaned",
+			Help: "Azure resource which is not attached or used by any other resource (eg. unattached disk)",
+		},
+		append(
+			[]string{
+				"resourceID",
+				"subscriptionID",
+				"resourceGroup",
+				"resourceType",
+				"name",
+				"location",
+				"reason",
+			},
+			azureResourceTags.prometheusLabels...,
+		),
+	)
+	m.MustRegister(m.prometheus.resourceOrphaned)
+}
+
+// Dependencies uses the network interfaces and security groups of the Network collector
+func (m *MetricsCollectorAzureRmOrphaned) Dependencies() []string {
+	return []string{"Network"}
+}
+
+func (m *MetricsCollectorAzureRmOrphaned) Reset() {
+	m.prometheus.resourceOrphaned.Reset()
+}
+
+func (m *MetricsCollectorAzureRmOrphaned) Collect(ctx context.Context, logger *log.Entry, callback chan<- func(), subscription subscriptions.Subscription) {
+	orphanedMetric := prometheusCommon.NewMetricsList()
+
+	addOrphaned := func(resourceId, location, reason string, tags map[string]*string) {
+		labels := prometheus.Labels{
+			"resourceID":     toResourceId(&resourceId),
+			"subscriptionID": to.String(subscription.SubscriptionID),
+			"resourceGroup":  extractResourceGroupFromAzureId(resourceId),
+			"resourceType":   extractResourceTypeFromAzureId(resourceId),
+			"name":           resourceId[strings.LastIndex(resourceId, "/")+1:],
+			"location":       strings.ToLower(location),
+			"reason":         reason,
+		}
+		labels = azureResourceTags.appendPrometheusLabel(labels, tags)
+		orphanedMetric.AddInfo(labels)
+	}
+
+	m.collectDisks(ctx, logger, subscription, addOrphaned)
+	m.collectNetworkInterfaces(ctx, logger, subscription, addOrphaned)
+	m.collectPublicIpAddresses(ctx, logger, subscription, addOrphaned)
+	m.collectAvailabilitySets(ctx, logger, subscription, addOrphaned)
+	m.collectNetworkSecurityGroups(ctx, logger, subscription, addOrphaned)
+
+	callback <- func() {
+		orphanedMetric.GaugeSet(m.prometheus.resourceOrphaned)
+	}
+}
+
+// collectDisks finds managed disks not attached to any virtual machine
+func (m *MetricsCollectorAzureRmOrphaned) collectDisks(ctx context.Context, logger *log.Entry, subscription subscriptions.Subscription, addOrphaned func(resourceId, location, reason string, tags map[string]*string)) {
+	client := compute.NewDisksClientWithBaseURI(azureEnvironment.ResourceManagerEndpoint, *subscription.SubscriptionID)
+	client.Authorizer = AzureAuthorizer
+	client.ResponseInspector = azureResponseInspector(&subscription)
+
+	list, err := client.ListComplete(ctx)
+	if err != nil {
+		logger.Panic(err)
+	}
+
+	for list.NotDone() {
+		val := list.Value()
+
+		if to.String(val.ManagedBy) == "" && val.DiskProperties != nil && val.DiskState == compute.DiskStateUnattached {
+			addOrphaned(to.String(val.ID), to.String(val.Location), "unattachedDisk", val.Tags)
+		}
+
+		if list.NextWithContext(ctx) != nil {
+			break
+		}
+	}
+}
+
+// collectNetworkInterfaces finds network interfaces not used by a virtual machine or private endpoint
+func (m *MetricsCollectorAzureRmOrphaned) collectNetworkInterfaces(ctx context.Context, logger *log.Entry, subscription subscriptions.Subscription, addOrphaned func(resourceId, location, reason string, tags map[string]*string)) {
+	for _, val := range azureNetworkInterfaceList(ctx, logger, subscription) {
+		if val.InterfacePropertiesFormat != nil && val.VirtualMachine == nil && val.PrivateEndpoint == nil {
+			addOrphaned(to.String(val.ID), to.String(val.Location), "unattachedNetworkInterface", val.Tags)
+		}
+	}
+}
+
+// collectPublicIpAddresses finds public ips not associated with an ip configuration or nat gateway
+func (m *MetricsCollectorAzureRmOrphaned) collectPublicIpAddresses(ctx context.Context, logger *log.Entry, subscription subscriptions.Subscription, addOrphaned func(resourceId, location, reason string, tags map[string]*string)) {
+	client := network.NewPublicIPAddressesClientWithBaseURI(azureEnvironment.ResourceManagerEndpoint, *subscription.SubscriptionID)
+	client.Authorizer = AzureAuthorizer
+	client.ResponseInspector = azureResponseInspector(&subscription)
+
+	list, err := client.ListAllComplete(ctx)
+	if err != nil {
+		logger.Panic(err)
+	}
+
+	for list.NotDone() {
+		val := list.Value()
+
+		if publicIpAttachedResourceId(val) == "" {
+			addOrphaned(to.String(val.ID), to.String(val.Location), "unassociatedPublicIp", val.Tags)
+		}
+
+		if list.NextWithContext(ctx) != nil {
+			break
+		}
+	}
+}
+
+// collectAvailabilitySets finds availability sets without virtual machines
+func (m *MetricsCollectorAzureRmOrphaned) collectAvailabilitySets(ctx context.Context, logger *log.Entry, subscription subscriptions.Subscription, addOrphaned func(resourceId, location, reason string, tags map[string]*string)) {
+	client := compute.NewAvailabilitySetsClientWithBaseURI(azureEnvironment.ResourceManagerEndpoint, *subscription.SubscriptionID)
+	client.Authorizer = AzureAuthorizer
+	client.ResponseInspector = azureResponseInspector(&subscription)
+
+	list, err := client.ListBySubscriptionComplete(ctx, "")
+	if err != nil {
+		logger.Panic(err)
+	}
+
+	for list.NotDone() {
+		val := list.Value()
+
+		if val.AvailabilitySetProperties == nil || val.VirtualMachines == nil || len(*val.VirtualMachines) == 0 {
+			addOrphaned(to.String(val.ID), to.String(val.Location), "emptyAvailabilitySet", val.Tags)
+		}
+
+		if list.NextWithContext(ctx) != nil {
+			break
+		}
+	}
+}
+
+// collectNetworkSecurityGroups finds network security groups neither associated with a subnet nor a network interface
+func (m *MetricsCollectorAzureRmOrphaned) collectNetworkSecurityGroups(ctx context.Context, logger *log.Entry, subscription subscriptions.Subscription, addOrphaned func(resourceId, location, reason string, tags map[string]*string)) {
+	for _, val := range azureNetworkSecurityGroupList(ctx, logger, subscription) {
+		if val.SecurityGroupPropertiesFormat != nil {
+			subnetCount := 0
+			if val.Subnets != nil {
+				subnetCount = len(*val.Subnets)
+			}
+
+			networkInterfaceCount := 0
+			if val.NetworkInterfaces != nil {
+				networkInterfaceCount = len(*val.NetworkInterfaces)
+			}
+
+			if subnetCount == 0 && networkInterfaceCount == 0 {
+				addOrphaned(to.String(val.ID), to.String(val.Location), "unassociatedNetworkSecurityGroup", val.Tags)
+			}
+		}
+	}
+}
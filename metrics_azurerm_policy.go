@@ -0,0 +1,288 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/Azure/azure-sdk-for-go/profiles/latest/resources/mgmt/subscriptions"
+	"github.com/Azure/azure-sdk-for-go/services/preview/resources/mgmt/2021-06-01-preview/policy"
+	"github.com/Azure/go-autorest/autorest/to"
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+	prometheusCommon "github.com/webdevops/go-prometheus-common"
+	"strings"
+)
+
+const (
+	// subscription wide list of guest configuration assignments is not available in the used Azure SDK version
+	AzureGuestConfigurationApiVersion = "2022-01-25"
+)
+
+type MetricsCollectorAzureRmPolicy struct {
+	CollectorProcessorGeneral
+
+	prometheus struct {
+		assignment      *prometheus.GaugeVec
+		assignmentCount *prometheus.GaugeVec
+
+		guestConfiguration          *prometheus.GaugeVec
+		guestConfigurationCompliant *prometheus.GaugeVec
+		guestConfigurationCount     *prometheus.GaugeVec
+	}
+}
+
+// azureGuestConfigurationAssignment is a guest configuration assignment of a virtual machine (scale set) or Arc machine
+type azureGuestConfigurationAssignment struct {
+	ID         string `json:"id"`
+	Name       string `json:"name"`
+	Properties struct {
+		TargetResourceID   string `json:"targetResourceId"`
+		ComplianceStatus   string `json:"complianceStatus"`
+		GuestConfiguration *struct {
+			Name           string `json:"name"`
+			Version        string `json:"version"`
+			AssignmentType string `json:"assignmentType"`
+		} `json:"guestConfiguration"`
+	} `json:"properties"`
+}
+
+func (m *MetricsCollectorAzureRmPolicy) Setup(collector *CollectorGeneral) {
+	m.CollectorReference = collector
+
+	m.prometheus.assignment = m.newGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_policy_assignment_info",
+			Help: "Azure Policy assignment information",
+		},
+		[]string{
+			"resourceID",
+			"subscriptionID",
+			"scope",
+			"name",
+			"displayName",
+			"policyDefinitionID",
+			"enforcementMode",
+			"effect",
+			"identityType",
+			"identityPrincipalID",
+			"nonComplianceMessage",
+		},
+	)
+	m.MustRegister(m.prometheus.assignment)
+
+	m.prometheus.assignmentCount = m.newGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_policy_assignment_count",
+			Help: "Azure Policy assignment count per scope",
+		},
+		[]string{
+			"subscriptionID",
+			"scope",
+		},
+	)
+	m.MustRegister(m.prometheus.assignmentCount)
+
+	m.prometheus.guestConfiguration = m.newGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_policy_guestconfiguration_info",
+			Help: "Azure Policy guest configuration assignment information",
+		},
+		[]string{
+			"resourceID",
+			"subscriptionID",
+			"resourceGroup",
+			"targetResourceID",
+			"configurationName",
+			"configurationVersion",
+			"assignmentType",
+			"complianceStatus",
+		},
+	)
+	m.MustRegister(m.prometheus.guestConfiguration)
+
+	m.prometheus.guestConfigurationCompliant = m.newGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_policy_guestconfiguration_compliant",
+			Help: "Azure Policy guest configuration compliance (1 compliant, 0 non-compliant, pending assignments are not exported)",
+		},
+		[]string{
+			"resourceID",
+			"subscriptionID",
+			"targetResourceID",
+			"configurationName",
+		},
+	)
+	m.MustRegister(m.prometheus.guestConfigurationCompliant)
+
+	m.prometheus.guestConfigurationCount = m.newGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_policy_guestconfiguration_count",
+			Help: "Azure Policy guest configuration assignment count per compliance status",
+		},
+		[]string{
+			"subscriptionID",
+			"complianceStatus",
+		},
+	)
+	m.MustRegister(m.prometheus.guestConfigurationCount)
+}
+
+func (m *MetricsCollectorAzureRmPolicy) Reset() {
+	m.prometheus.assignment.Reset()
+	m.prometheus.assignmentCount.Reset()
+	m.prometheus.guestConfiguration.Reset()
+	m.prometheus.guestConfigurationCompliant.Reset()
+	m.prometheus.guestConfigurationCount.Reset()
+}
+
+func (m *MetricsCollectorAzureRmPolicy) Collect(ctx context.Context, logger *log.Entry, callback chan<- func(), subscription subscriptions.Subscription) {
+	m.collectPolicyAssignments(ctx, logger, callback, subscription)
+
+	if opts.Policy.GuestConfiguration {
+		m.collectGuestConfigurationAssignments(ctx, logger, callback, subscription)
+	}
+}
+
+// Collect policy assignments
+func (m *MetricsCollectorAzureRmPolicy) collectPolicyAssignments(ctx context.Context, logger *log.Entry, callback chan<- func(), subscription subscriptions.Subscription) {
+	client := policy.NewAssignmentsClientWithBaseURI(azureEnvironment.ResourceManagerEndpoint, *subscription.SubscriptionID)
+	client.Authorizer = AzureAuthorizer
+	client.ResponseInspector = azureResponseInspector(&subscription)
+
+	list, err := client.ListComplete(ctx, "", nil)
+	if err != nil {
+		logger.Panic(err)
+	}
+
+	infoMetric := prometheusCommon.NewMetricsList()
+	scopeCount := map[string]float64{}
+
+	for list.NotDone() {
+		val := list.Value()
+
+		infoLabels := prometheus.Labels{
+			"resourceID":           toResourceId(val.ID),
+			"subscriptionID":       to.String(subscription.SubscriptionID),
+			"scope":                "",
+			"name":                 to.String(val.Name),
+			"displayName":          "",
+			"policyDefinitionID":   "",
+			"enforcementMode":      "",
+			"effect":               "",
+			"identityType":         "",
+			"identityPrincipalID":  "",
+			"nonComplianceMessage": boolToString(false),
+		}
+
+		if val.AssignmentProperties != nil {
+			scope := toResourceId(val.Scope)
+			scopeCount[scope]++
+
+			infoLabels["scope"] = scope
+			infoLabels["displayName"] = to.String(val.DisplayName)
+			infoLabels["policyDefinitionID"] = toResourceId(val.PolicyDefinitionID)
+			infoLabels["enforcementMode"] = string(val.EnforcementMode)
+
+			// effect is usually parameterized (eg. DeployIfNotExists vs. AuditIfNotExists)
+			if effect, exists := val.Parameters["effect"]; exists && effect != nil && effect.Value != nil {
+				infoLabels["effect"] = fmt.Sprintf("%v", effect.Value)
+			}
+
+			if val.NonComplianceMessages != nil && len(*val.NonComplianceMessages) > 0 {
+				infoLabels["nonComplianceMessage"] = boolToString(true)
+			}
+		}
+
+		if val.Identity != nil {
+			infoLabels["identityType"] = string(val.Identity.Type)
+			infoLabels["identityPrincipalID"] = to.String(val.Identity.PrincipalID)
+		}
+
+		infoMetric.AddInfo(infoLabels)
+
+		if list.NextWithContext(ctx) != nil {
+			break
+		}
+	}
+
+	countMetric := prometheusCommon.NewMetricsList()
+	for scope, count := range scopeCount {
+		countMetric.Add(prometheus.Labels{
+			"subscriptionID": to.String(subscription.SubscriptionID),
+			"scope":          scope,
+		}, count)
+	}
+
+	callback <- func() {
+		infoMetric.GaugeSet(m.prometheus.assignment)
+		countMetric.GaugeSet(m.prometheus.assignmentCount)
+	}
+}
+
+// Collect guest configuration assignments (compliance inside the operating system)
+func (m *MetricsCollectorAzureRmPolicy) collectGuestConfigurationAssignments(ctx context.Context, logger *log.Entry, callback chan<- func(), subscription subscriptions.Subscription) {
+	client := NewAzureRestClient(&subscription)
+
+	list, err := client.List(ctx, fmt.Sprintf("/subscriptions/%s/providers/Microsoft.GuestConfiguration/guestConfigurationAssignments", *subscription.SubscriptionID), AzureGuestConfigurationApiVersion)
+	if err != nil {
+		logger.Panic(err)
+	}
+
+	infoMetric := prometheusCommon.NewMetricsList()
+	compliantMetric := prometheusCommon.NewMetricsList()
+	statusCount := map[string]float64{}
+
+	for _, row := range list {
+		assignment := azureGuestConfigurationAssignment{}
+		if err := json.Unmarshal(row, &assignment); err != nil {
+			logger.Error(err)
+			continue
+		}
+
+		resourceId := toResourceId(&assignment.ID)
+		targetResourceId := toResourceId(&assignment.Properties.TargetResourceID)
+		complianceStatus := assignment.Properties.ComplianceStatus
+		statusCount[complianceStatus]++
+
+		configurationName, configurationVersion, assignmentType := assignment.Name, "", ""
+		if guestConfiguration := assignment.Properties.GuestConfiguration; guestConfiguration != nil {
+			configurationName = guestConfiguration.Name
+			configurationVersion = guestConfiguration.Version
+			assignmentType = guestConfiguration.AssignmentType
+		}
+
+		infoMetric.AddInfo(prometheus.Labels{
+			"resourceID":           resourceId,
+			"subscriptionID":       to.String(subscription.SubscriptionID),
+			"resourceGroup":        extractResourceGroupFromAzureId(assignment.ID),
+			"targetResourceID":     targetResourceId,
+			"configurationName":    configurationName,
+			"configurationVersion": configurationVersion,
+			"assignmentType":       assignmentType,
+			"complianceStatus":     complianceStatus,
+		})
+
+		if strings.EqualFold(complianceStatus, "Compliant") || strings.EqualFold(complianceStatus, "NonCompliant") {
+			compliantMetric.AddBool(prometheus.Labels{
+				"resourceID":        resourceId,
+				"subscriptionID":    to.String(subscription.SubscriptionID),
+				"targetResourceID":  targetResourceId,
+				"configurationName": configurationName,
+			}, strings.EqualFold(complianceStatus, "Compliant"))
+		}
+	}
+
+	countMetric := prometheusCommon.NewMetricsList()
+	for complianceStatus, count := range statusCount {
+		countMetric.Add(prometheus.Labels{
+			"subscriptionID":   to.String(subscription.SubscriptionID),
+			"complianceStatus": complianceStatus,
+		}, count)
+	}
+
+	callback <- func() {
+		infoMetric.GaugeSet(m.prometheus.guestConfiguration)
+		compliantMetric.GaugeSet(m.prometheus.guestConfigurationCompliant)
+		countMetric.GaugeSet(m.prometheus.guestConfigurationCount)
+	}
+}
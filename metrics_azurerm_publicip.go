@@ -0,0 +1,378 @@
+package main
+
+import (
+	"context"
+	"github.com/Azure/azure-sdk-for-go/profiles/latest/network/mgmt/network"
+	"github.com/Azure/azure-sdk-for-go/profiles/latest/resources/mgmt/subscriptions"
+	"github.com/Azure/go-autorest/autorest/to"
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+	prometheusCommon "github.com/webdevops/go-prometheus-common"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// Azure service tags are updated weekly
+	publicIpRegionRefreshInterval = 24 * time.Hour
+
+	// service tags with the address prefixes of one Azure region (eg. AzureCloud.westeurope)
+	publicIpRegionServiceTagPrefix = "AzureCloud."
+)
+
+type MetricsCollectorAzureRmPublicIp struct {
+	CollectorProcessorGeneral
+
+	prometheus struct {
+		publicIpReverseDns *prometheus.GaugeVec
+		publicIpUnexpected *prometheus.GaugeVec
+
+		publicIpRegionMismatch *prometheus.GaugeVec
+		publicIpRegionCount    *prometheus.GaugeVec
+
+		publicIpCreated *prometheus.CounterVec
+		publicIpDeleted *prometheus.CounterVec
+	}
+
+	// public ip resource ids of the previous run per subscription (churn counters)
+	publicIpList     map[string]map[string]bool
+	publicIpListLock sync.Mutex
+
+	// address prefixes of the Azure regions, shared by all subscriptions (--publicip-region)
+	regionPrefixList    []publicIpRegionPrefix
+	regionPrefixFetched time.Time
+	regionPrefixLock    sync.Mutex
+}
+
+type publicIpRegionPrefix struct {
+	ipNet  *net.IPNet
+	region string
+}
+
+func (m *MetricsCollectorAzureRmPublicIp) Setup(collector *CollectorGeneral) {
+	m.CollectorReference = collector
+
+	m.prometheus.publicIpReverseDns = m.newGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_publicip_reversedns",
+			Help: "Azure ResourceManager public ip reverse DNS names",
+		},
+		[]string{
+			"subscriptionID",
+			"resourceID",
+			"ipAddress",
+			"hostname",
+		},
+	)
+	m.MustRegister(m.prometheus.publicIpReverseDns)
+
+	m.prometheus.publicIpUnexpected = m.newGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_publicip_unexpected",
+			Help: "Azure ResourceManager public ip outside of approved (or inside of denied) CIDR prefixes",
+		},
+		[]string{
+			"subscriptionID",
+			"resourceID",
+			"resourceGroup",
+			"name",
+			"ipAddress",
+			"reason",
+		},
+	)
+	m.MustRegister(m.prometheus.publicIpUnexpected)
+
+	m.prometheus.publicIpRegionMismatch = m.newGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_publicip_region_mismatch",
+			Help: "Azure ResourceManager public ip address block region (1 if it differs from the resource location)",
+		},
+		[]string{
+			"subscriptionID",
+			"resourceID",
+			"resourceGroup",
+			"name",
+			"ipAddress",
+			"location",
+			"addressRegion",
+		},
+	)
+	m.MustRegister(m.prometheus.publicIpRegionMismatch)
+
+	m.prometheus.publicIpRegionCount = m.newGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_publicip_region_count",
+			Help: "Azure ResourceManager public ip count per address block region",
+		},
+		[]string{
+			"subscriptionID",
+			"addressRegion",
+		},
+	)
+	m.MustRegister(m.prometheus.publicIpRegionCount)
+
+	m.prometheus.publicIpCreated = m.newCounterVec(
+		prometheus.CounterOpts{
+			Name: "azurerm_publicip_created_total",
+			Help: "Azure ResourceManager public ips created (appeared) since the previous collection",
+		},
+		[]string{
+			"subscriptionID",
+		},
+	)
+	m.MustRegister(m.prometheus.publicIpCreated)
+
+	m.prometheus.publicIpDeleted = m.newCounterVec(
+		prometheus.CounterOpts{
+			Name: "azurerm_publicip_deleted_total",
+			Help: "Azure ResourceManager public ips deleted (disappeared) since the previous collection",
+		},
+		[]string{
+			"subscriptionID",
+		},
+	)
+	m.MustRegister(m.prometheus.publicIpDeleted)
+
+	m.publicIpList = map[string]map[string]bool{}
+}
+
+func (m *MetricsCollectorAzureRmPublicIp) Reset() {
+	m.prometheus.publicIpReverseDns.Reset()
+	m.prometheus.publicIpUnexpected.Reset()
+	m.prometheus.publicIpRegionMismatch.Reset()
+	m.prometheus.publicIpRegionCount.Reset()
+	// churn counters are not reset
+}
+
+func (m *MetricsCollectorAzureRmPublicIp) Collect(ctx context.Context, logger *log.Entry, callback chan<- func(), subscription subscriptions.Subscription) {
+	client := network.NewPublicIPAddressesClientWithBaseURI(azureEnvironment.ResourceManagerEndpoint, *subscription.SubscriptionID)
+	client.Authorizer = AzureAuthorizer
+	client.ResponseInspector = azureResponseInspector(&subscription)
+
+	list, err := client.ListAllComplete(ctx)
+	if err != nil {
+		logger.Panic(err)
+	}
+
+	reverseDnsMetric := prometheusCommon.NewMetricsList()
+	unexpectedMetric := prometheusCommon.NewMetricsList()
+	regionMismatchMetric := prometheusCommon.NewMetricsList()
+
+	var regionPrefixList []publicIpRegionPrefix
+	regionCount := map[string]float64{}
+	if opts.PublicIp.Region {
+		regionPrefixList, err = m.regionPrefixes(ctx, subscription)
+		if err != nil {
+			logger.Error(err)
+		}
+	}
+
+	// cidr lists may change with --config-watch
+	dynamicConfigLock.RLock()
+	allowList, denyList := publicIpAllowList, publicIpDenyList
+	dynamicConfigLock.RUnlock()
+
+	publicIpList := map[string]bool{}
+
+	for list.NotDone() {
+		val := list.Value()
+		ipAddress := to.String(val.IPAddress)
+		publicIpList[strings.ToLower(to.String(val.ID))] = true
+
+		if ip := net.ParseIP(ipAddress); ip != nil {
+			if opts.PublicIp.ReverseDns {
+				hostnameList, err := net.DefaultResolver.LookupAddr(ctx, ipAddress)
+				if err != nil {
+					logger.WithField("ipAddress", ipAddress).Debug(err)
+				}
+
+				for _, hostname := range hostnameList {
+					reverseDnsMetric.AddInfo(prometheus.Labels{
+						"subscriptionID": to.String(subscription.SubscriptionID),
+						"resourceID":     toResourceId(val.ID),
+						"ipAddress":      ipAddress,
+						"hostname":       strings.TrimSuffix(hostname, "."),
+					})
+				}
+			}
+
+			if len(allowList) > 0 || len(denyList) > 0 {
+				reason := ""
+				if len(allowList) > 0 && !ipNetListContains(allowList, ip) {
+					reason = "notAllowed"
+				}
+				if ipNetListContains(denyList, ip) {
+					reason = "denied"
+				}
+
+				labels := prometheus.Labels{
+					"subscriptionID": to.String(subscription.SubscriptionID),
+					"resourceID":     toResourceId(val.ID),
+					"resourceGroup":  extractResourceGroupFromAzureId(to.String(val.ID)),
+					"name":           to.String(val.Name),
+					"ipAddress":      ipAddress,
+					"reason":         reason,
+				}
+
+				if reason != "" {
+					unexpectedMetric.Add(labels, 1)
+				} else {
+					unexpectedMetric.Add(labels, 0)
+				}
+			}
+
+			if len(regionPrefixList) > 0 {
+				location := to.String(val.Location)
+				addressRegion := publicIpRegionLookup(regionPrefixList, ip)
+				regionCount[addressRegion]++
+
+				// addresses outside of the Azure ranges (eg. BYOIP) and global resources are no mismatch
+				regionMismatch := 0.0
+				if addressRegion != "" && location != "" && !strings.EqualFold(location, "global") && !strings.EqualFold(strings.ReplaceAll(location, " ", ""), addressRegion) {
+					regionMismatch = 1
+				}
+
+				regionMismatchMetric.Add(prometheus.Labels{
+					"subscriptionID": to.String(subscription.SubscriptionID),
+					"resourceID":     toResourceId(val.ID),
+					"resourceGroup":  extractResourceGroupFromAzureId(to.String(val.ID)),
+					"name":           to.String(val.Name),
+					"ipAddress":      ipAddress,
+					"location":       location,
+					"addressRegion":  addressRegion,
+				}, regionMismatch)
+			}
+		}
+
+		if list.NextWithContext(ctx) != nil {
+			break
+		}
+	}
+
+	regionCountMetric := prometheusCommon.NewMetricsList()
+	for addressRegion, count := range regionCount {
+		regionCountMetric.Add(prometheus.Labels{
+			"subscriptionID": to.String(subscription.SubscriptionID),
+			"addressRegion":  addressRegion,
+		}, count)
+	}
+
+	// churn counters are increased once per collection and not inside the callback,
+	// callbacks are replayed during blackout windows and collections of other subscriptions
+	createdCount, deletedCount := m.publicIpChurn(to.String(subscription.SubscriptionID), publicIpList)
+	churnLabels := prometheus.Labels{"subscriptionID": to.String(subscription.SubscriptionID)}
+	m.prometheus.publicIpCreated.With(churnLabels).Add(createdCount)
+	m.prometheus.publicIpDeleted.With(churnLabels).Add(deletedCount)
+
+	callback <- func() {
+		reverseDnsMetric.GaugeSet(m.prometheus.publicIpReverseDns)
+		unexpectedMetric.GaugeSet(m.prometheus.publicIpUnexpected)
+		regionMismatchMetric.GaugeSet(m.prometheus.publicIpRegionMismatch)
+		regionCountMetric.GaugeSet(m.prometheus.publicIpRegionCount)
+	}
+}
+
+// publicIpChurn compares the public ips with the previous run of the subscription and remembers the current list,
+// the first run is the baseline (counters are initialized with zero)
+func (m *MetricsCollectorAzureRmPublicIp) publicIpChurn(subscriptionId string, publicIpList map[string]bool) (created, deleted float64) {
+	m.publicIpListLock.Lock()
+	defer m.publicIpListLock.Unlock()
+
+	previousPublicIpList, exists := m.publicIpList[subscriptionId]
+	m.publicIpList[subscriptionId] = publicIpList
+	if !exists {
+		return 0, 0
+	}
+
+	for resourceId := range publicIpList {
+		if !previousPublicIpList[resourceId] {
+			created++
+		}
+	}
+
+	for resourceId := range previousPublicIpList {
+		if !publicIpList[resourceId] {
+			deleted++
+		}
+	}
+
+	return created, deleted
+}
+
+// regionPrefixes returns the address prefixes of the Azure regions from the service tags (cached for all subscriptions)
+func (m *MetricsCollectorAzureRmPublicIp) regionPrefixes(ctx context.Context, subscription subscriptions.Subscription) ([]publicIpRegionPrefix, error) {
+	m.regionPrefixLock.Lock()
+	defer m.regionPrefixLock.Unlock()
+
+	if len(m.regionPrefixList) > 0 && time.Since(m.regionPrefixFetched) < publicIpRegionRefreshInterval {
+		return m.regionPrefixList, nil
+	}
+
+	client := network.NewServiceTagsClientWithBaseURI(azureEnvironment.ResourceManagerEndpoint, *subscription.SubscriptionID)
+	client.Authorizer = AzureAuthorizer
+	client.ResponseInspector = azureResponseInspector(&subscription)
+
+	// the service tags are the same for all locations, the location only selects the endpoint
+	result, err := client.List(ctx, opts.Azure.Location[0])
+	if err != nil {
+		return m.regionPrefixList, err
+	}
+
+	regionPrefixList := []publicIpRegionPrefix{}
+	if result.Values != nil {
+		for _, serviceTag := range *result.Values {
+			if !strings.HasPrefix(to.String(serviceTag.Name), publicIpRegionServiceTagPrefix) || serviceTag.Properties == nil || serviceTag.Properties.AddressPrefixes == nil {
+				continue
+			}
+
+			region := strings.ToLower(to.String(serviceTag.Properties.Region))
+			if region == "" {
+				continue
+			}
+
+			for _, addressPrefix := range *serviceTag.Properties.AddressPrefixes {
+				if _, ipNet, err := net.ParseCIDR(addressPrefix); err == nil {
+					regionPrefixList = append(regionPrefixList, publicIpRegionPrefix{ipNet: ipNet, region: region})
+				}
+			}
+		}
+	}
+
+	m.regionPrefixList = regionPrefixList
+	m.regionPrefixFetched = time.Now()
+
+	return m.regionPrefixList, nil
+}
+
+// FlushCache fetches the region address prefixes again on the next run,
+// the current prefixes are kept if the service tags can't be fetched
+func (m *MetricsCollectorAzureRmPublicIp) FlushCache() {
+	m.regionPrefixLock.Lock()
+	defer m.regionPrefixLock.Unlock()
+
+	m.regionPrefixFetched = time.Time{}
+}
+
+// publicIpRegionLookup returns the region of the most specific address prefix containing the ip
+func publicIpRegionLookup(regionPrefixList []publicIpRegionPrefix, ip net.IP) string {
+	region, prefixLength := "", -1
+	for _, regionPrefix := range regionPrefixList {
+		if regionPrefix.ipNet.Contains(ip) {
+			if ones, _ := regionPrefix.ipNet.Mask.Size(); ones > prefixLength {
+				region, prefixLength = regionPrefix.region, ones
+			}
+		}
+	}
+	return region
+}
+
+func ipNetListContains(ipNetList []*net.IPNet, ip net.IP) bool {
+	for _, ipNet := range ipNetList {
+		if ipNet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
@@ -2,6 +2,8 @@ package main
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
 	"github.com/Azure/azure-sdk-for-go/profiles/latest/compute/mgmt/compute"
 	"github.com/Azure/azure-sdk-for-go/profiles/latest/network/mgmt/network"
 	"github.com/Azure/azure-sdk-for-go/profiles/latest/resources/mgmt/subscriptions"
@@ -10,6 +12,15 @@ import (
 	"github.com/prometheus/client_golang/prometheus"
 	log "github.com/sirupsen/logrus"
 	prometheusCommon "github.com/webdevops/go-prometheus-common"
+	"strings"
+	"sync"
+)
+
+const (
+	AzureNetAppApiVersion = "2022-05-01"
+
+	// file share usage (stats) is not available in the used Azure SDK version
+	AzureFileShareApiVersion = "2022-09-01"
 )
 
 type MetricsCollectorAzureRmQuota struct {
@@ -20,13 +31,46 @@ type MetricsCollectorAzureRmQuota struct {
 		quotaCurrent *prometheus.GaugeVec
 		quotaLimit   *prometheus.GaugeVec
 		quotaUsage   *prometheus.GaugeVec
+		quotaDelta   *prometheus.GaugeVec
 	}
+
+	// quota current values of the previous run per subscription (delta metric)
+	quotaCurrentPrevious     map[string]map[string]float64
+	quotaCurrentPreviousLock sync.Mutex
+}
+
+type azureNetAppCapacityPool struct {
+	ID         *string `json:"id"`
+	Location   *string `json:"location"`
+	Properties *struct {
+		Size *int64 `json:"size"`
+	} `json:"properties"`
+}
+
+type azureNetAppVolume struct {
+	Properties *struct {
+		UsageThreshold *int64 `json:"usageThreshold"`
+	} `json:"properties"`
 }
 
+type azureFileShare struct {
+	ID         *string `json:"id"`
+	Name       *string `json:"name"`
+	Properties *struct {
+		ShareQuota       *int64  `json:"shareQuota"`
+		ShareUsageBytes  *int64  `json:"shareUsageBytes"`
+		AccessTier       *string `json:"accessTier"`
+		EnabledProtocols *string `json:"enabledProtocols"`
+	} `json:"properties"`
+}
+
+// addQuota adds one quota (location, scope, resourceID for resource level quotas, quota, quotaName) to the quota metric family
+type azureQuotaAddFunc func(location, scope, resourceId, quota, quotaName string, currentValue, limitValue float64)
+
 func (m *MetricsCollectorAzureRmQuota) Setup(collector *CollectorGeneral) {
 	m.CollectorReference = collector
 
-	m.prometheus.quota = prometheus.NewGaugeVec(
+	m.prometheus.quota = m.newGaugeVec(
 		prometheus.GaugeOpts{
 			Name: "azurerm_quota_info",
 			Help: "Azure ResourceManager quota information",
@@ -35,12 +79,13 @@ func (m *MetricsCollectorAzureRmQuota) Setup(collector *CollectorGeneral) {
 			"subscriptionID",
 			"location",
 			"scope",
+			"resourceID",
 			"quota",
 			"quotaName",
 		},
 	)
 
-	m.prometheus.quotaCurrent = prometheus.NewGaugeVec(
+	m.prometheus.quotaCurrent = m.newGaugeVec(
 		prometheus.GaugeOpts{
 			Name: "azurerm_quota_current",
 			Help: "Azure ResourceManager quota current value",
@@ -49,11 +94,12 @@ func (m *MetricsCollectorAzureRmQuota) Setup(collector *CollectorGeneral) {
 			"subscriptionID",
 			"location",
 			"scope",
+			"resourceID",
 			"quota",
 		},
 	)
 
-	m.prometheus.quotaLimit = prometheus.NewGaugeVec(
+	m.prometheus.quotaLimit = m.newGaugeVec(
 		prometheus.GaugeOpts{
 			Name: "azurerm_quota_limit",
 			Help: "Azure ResourceManager quota limit",
@@ -62,11 +108,12 @@ func (m *MetricsCollectorAzureRmQuota) Setup(collector *CollectorGeneral) {
 			"subscriptionID",
 			"location",
 			"scope",
+			"resourceID",
 			"quota",
 		},
 	)
 
-	m.prometheus.quotaUsage = prometheus.NewGaugeVec(
+	m.prometheus.quotaUsage = m.newGaugeVec(
 		prometheus.GaugeOpts{
 			Name: "azurerm_quota_usage",
 			Help: "Azure ResourceManager quota usage in percent",
@@ -75,94 +122,118 @@ func (m *MetricsCollectorAzureRmQuota) Setup(collector *CollectorGeneral) {
 			"subscriptionID",
 			"location",
 			"scope",
+			"resourceID",
 			"quota",
 		},
 	)
 
-	prometheus.MustRegister(m.prometheus.quota)
-	prometheus.MustRegister(m.prometheus.quotaCurrent)
-	prometheus.MustRegister(m.prometheus.quotaLimit)
+	m.prometheus.quotaDelta = m.newGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_quota_current_delta",
+			Help: "Azure ResourceManager quota current value change since the previous collection",
+		},
+		[]string{
+			"subscriptionID",
+			"location",
+			"scope",
+			"resourceID",
+			"quota",
+		},
+	)
+
+	m.MustRegister(m.prometheus.quota)
+	m.MustRegister(m.prometheus.quotaCurrent)
+	m.MustRegister(m.prometheus.quotaLimit)
+	m.MustRegister(m.prometheus.quotaUsage)
+	m.MustRegister(m.prometheus.quotaDelta)
+
+	m.quotaCurrentPrevious = map[string]map[string]float64{}
 }
 
 func (m *MetricsCollectorAzureRmQuota) Reset() {
 	m.prometheus.quota.Reset()
 	m.prometheus.quotaCurrent.Reset()
 	m.prometheus.quotaLimit.Reset()
+	m.prometheus.quotaUsage.Reset()
+	m.prometheus.quotaDelta.Reset()
 }
 
 func (m *MetricsCollectorAzureRmQuota) Collect(ctx context.Context, logger *log.Entry, callback chan<- func(), subscription subscriptions.Subscription) {
-	m.collectAzureComputeUsage(ctx, logger, callback, subscription)
-	m.collectAzureNetworkUsage(ctx, logger, callback, subscription)
-	m.collectAzureStorageUsage(ctx, logger, callback, subscription)
-}
-
-// Collect Azure ComputeUsage metrics
-func (m *MetricsCollectorAzureRmQuota) collectAzureComputeUsage(ctx context.Context, logger *log.Entry, callback chan<- func(), subscription subscriptions.Subscription) {
-	client := compute.NewUsageClientWithBaseURI(azureEnvironment.ResourceManagerEndpoint, *subscription.SubscriptionID)
-	client.Authorizer = AzureAuthorizer
-	client.ResponseInspector = azureResponseInspector(&subscription)
-
 	quotaMetric := prometheusCommon.NewMetricsList()
 	quotaCurrentMetric := prometheusCommon.NewMetricsList()
 	quotaLimitMetric := prometheusCommon.NewMetricsList()
 	quotaUsageMetric := prometheusCommon.NewMetricsList()
-
-	for _, location := range m.CollectorReference.AzureLocations {
-		list, err := client.List(ctx, location)
-
-		if err != nil {
-			logger.Panic(err)
+	quotaDeltaMetric := prometheusCommon.NewMetricsList()
+
+	subscriptionId := to.String(subscription.SubscriptionID)
+	m.quotaCurrentPreviousLock.Lock()
+	quotaCurrentPrevious, previousExists := m.quotaCurrentPrevious[subscriptionId]
+	m.quotaCurrentPreviousLock.Unlock()
+	quotaCurrent := map[string]float64{}
+
+	addQuota := func(location, scope, resourceId, quota, quotaName string, currentValue, limitValue float64) {
+		labels := prometheus.Labels{
+			"subscriptionID": to.String(subscription.SubscriptionID),
+			"location":       location,
+			"scope":          scope,
+			"resourceID":     resourceId,
+			"quota":          quota,
 		}
 
-		for _, val := range list.Values() {
-			quotaName := to.String(val.Name.Value)
-			quotaNameLocalized := to.String(val.Name.LocalizedValue)
-			currentValue := float64(to.Int32(val.CurrentValue))
-			limitValue := float64(to.Int64(val.Limit))
-
-			labels := prometheus.Labels{
-				"subscriptionID": *subscription.SubscriptionID,
-				"location":       location,
-				"scope":          "compute",
-				"quota":          quotaName,
-			}
+		infoLabels := prometheus.Labels{
+			"subscriptionID": to.String(subscription.SubscriptionID),
+			"location":       location,
+			"scope":          scope,
+			"resourceID":     resourceId,
+			"quota":          quota,
+			"quotaName":      quotaName,
+		}
 
-			infoLabels := prometheus.Labels{
-				"subscriptionID": *subscription.SubscriptionID,
-				"location":       location,
-				"scope":          "compute",
-				"quota":          quotaName,
-				"quotaName":      quotaNameLocalized,
-			}
+		quotaMetric.AddInfo(infoLabels)
+		quotaCurrentMetric.Add(labels, currentValue)
+		quotaLimitMetric.Add(labels, limitValue)
+		if limitValue != 0 {
+			quotaUsageMetric.Add(labels, currentValue/limitValue)
+		}
 
-			quotaMetric.Add(infoLabels, 1)
-			quotaCurrentMetric.Add(labels, currentValue)
-			quotaLimitMetric.Add(labels, limitValue)
-			if limitValue != 0 {
-				quotaUsageMetric.Add(labels, currentValue/limitValue)
-			}
+		// delta is available from the second run, new quotas start with their current value as baseline
+		quotaKey := strings.Join([]string{location, scope, strings.ToLower(resourceId), quota}, "|")
+		quotaCurrent[quotaKey] = currentValue
+		if previousValue, exists := quotaCurrentPrevious[quotaKey]; exists {
+			quotaDeltaMetric.Add(labels, currentValue-previousValue)
+		} else if previousExists {
+			quotaDeltaMetric.Add(labels, 0)
 		}
 	}
 
+	m.collectAzureComputeUsage(ctx, logger, subscription, addQuota)
+	m.collectAzureNetworkUsage(ctx, logger, subscription, addQuota)
+	m.collectAzureStorageUsage(ctx, logger, subscription, addQuota)
+	m.collectAzureNetAppCapacityPools(ctx, logger, subscription, addQuota)
+	if opts.Quota.FileShares {
+		m.collectAzureFileShares(ctx, logger, subscription, addQuota)
+	}
+
+	m.quotaCurrentPreviousLock.Lock()
+	m.quotaCurrentPrevious[subscriptionId] = quotaCurrent
+	m.quotaCurrentPreviousLock.Unlock()
+
 	callback <- func() {
 		quotaMetric.GaugeSet(m.prometheus.quota)
 		quotaCurrentMetric.GaugeSet(m.prometheus.quotaCurrent)
 		quotaLimitMetric.GaugeSet(m.prometheus.quotaLimit)
 		quotaUsageMetric.GaugeSet(m.prometheus.quotaUsage)
+		quotaDeltaMetric.GaugeSet(m.prometheus.quotaDelta)
 	}
 }
 
-// Collect Azure NetworkUsage metrics
-func (m *MetricsCollectorAzureRmQuota) collectAzureNetworkUsage(ctx context.Context, logger *log.Entry, callback chan<- func(), subscription subscriptions.Subscription) {
-	client := network.NewUsagesClientWithBaseURI(azureEnvironment.ResourceManagerEndpoint, *subscription.SubscriptionID)
+// Collect Azure ComputeUsage metrics
+func (m *MetricsCollectorAzureRmQuota) collectAzureComputeUsage(ctx context.Context, logger *log.Entry, subscription subscriptions.Subscription, addQuota azureQuotaAddFunc) {
+	client := compute.NewUsageClientWithBaseURI(azureEnvironment.ResourceManagerEndpoint, *subscription.SubscriptionID)
 	client.Authorizer = AzureAuthorizer
 	client.ResponseInspector = azureResponseInspector(&subscription)
 
-	quotaMetric := prometheusCommon.NewMetricsList()
-	quotaCurrentMetric := prometheusCommon.NewMetricsList()
-	quotaLimitMetric := prometheusCommon.NewMetricsList()
-
-	for _, location := range opts.Azure.Location {
+	for _, location := range m.CollectorReference.AzureLocations {
 		list, err := client.List(ctx, location)
 
 		if err != nil {
@@ -170,49 +241,52 @@ func (m *MetricsCollectorAzureRmQuota) collectAzureNetworkUsage(ctx context.Cont
 		}
 
 		for _, val := range list.Values() {
-			quotaName := to.String(val.Name.Value)
-			quotaNameLocalized := to.String(val.Name.LocalizedValue)
-			currentValue := float64(to.Int64(val.CurrentValue))
-			limitValue := float64(to.Int64(val.Limit))
-
-			labels := prometheus.Labels{
-				"subscriptionID": to.String(subscription.SubscriptionID),
-				"location":       location,
-				"scope":          "network",
-				"quota":          quotaName,
-			}
+			addQuota(
+				location,
+				"compute",
+				"",
+				to.String(val.Name.Value),
+				to.String(val.Name.LocalizedValue),
+				float64(to.Int32(val.CurrentValue)),
+				float64(to.Int64(val.Limit)),
+			)
+		}
+	}
+}
 
-			infoLabels := prometheus.Labels{
-				"subscriptionID": to.String(subscription.SubscriptionID),
-				"location":       location,
-				"scope":          "network",
-				"quota":          quotaName,
-				"quotaName":      quotaNameLocalized,
-			}
+// Collect Azure NetworkUsage metrics
+func (m *MetricsCollectorAzureRmQuota) collectAzureNetworkUsage(ctx context.Context, logger *log.Entry, subscription subscriptions.Subscription, addQuota azureQuotaAddFunc) {
+	client := network.NewUsagesClientWithBaseURI(azureEnvironment.ResourceManagerEndpoint, *subscription.SubscriptionID)
+	client.Authorizer = AzureAuthorizer
+	client.ResponseInspector = azureResponseInspector(&subscription)
+
+	for _, location := range opts.Azure.Location {
+		list, err := client.List(ctx, location)
 
-			quotaMetric.Add(infoLabels, 1)
-			quotaCurrentMetric.Add(labels, currentValue)
-			quotaLimitMetric.Add(labels, limitValue)
+		if err != nil {
+			logger.Panic(err)
 		}
-	}
 
-	callback <- func() {
-		quotaMetric.GaugeSet(m.prometheus.quota)
-		quotaCurrentMetric.GaugeSet(m.prometheus.quotaCurrent)
-		quotaLimitMetric.GaugeSet(m.prometheus.quotaLimit)
+		for _, val := range list.Values() {
+			addQuota(
+				location,
+				"network",
+				"",
+				to.String(val.Name.Value),
+				to.String(val.Name.LocalizedValue),
+				float64(to.Int64(val.CurrentValue)),
+				float64(to.Int64(val.Limit)),
+			)
+		}
 	}
 }
 
-// Collect Azure StorageUsage metrics
-func (m *MetricsCollectorAzureRmQuota) collectAzureStorageUsage(ctx context.Context, logger *log.Entry, callback chan<- func(), subscription subscriptions.Subscription) {
+// Collect Azure StorageUsage metrics (storage account count limit)
+func (m *MetricsCollectorAzureRmQuota) collectAzureStorageUsage(ctx context.Context, logger *log.Entry, subscription subscriptions.Subscription, addQuota azureQuotaAddFunc) {
 	client := storage.NewUsagesClientWithBaseURI(azureEnvironment.ResourceManagerEndpoint, *subscription.SubscriptionID)
 	client.Authorizer = AzureAuthorizer
 	client.ResponseInspector = azureResponseInspector(&subscription)
 
-	quotaMetric := prometheusCommon.NewMetricsList()
-	quotaCurrentMetric := prometheusCommon.NewMetricsList()
-	quotaLimitMetric := prometheusCommon.NewMetricsList()
-
 	for _, location := range opts.Azure.Location {
 		list, err := client.ListByLocation(ctx, location)
 
@@ -221,34 +295,144 @@ func (m *MetricsCollectorAzureRmQuota) collectAzureStorageUsage(ctx context.Cont
 		}
 
 		for _, val := range *list.Value {
-			quotaName := to.String(val.Name.Value)
-			quotaNameLocalized := to.String(val.Name.LocalizedValue)
-			currentValue := float64(to.Int32(val.CurrentValue))
-			limitValue := float64(to.Int32(val.Limit))
-
-			quotaMetric.AddInfo(prometheus.Labels{
-				"subscriptionID": to.String(subscription.SubscriptionID),
-				"location":       location,
-				"scope":          "storage",
-				"quota":          quotaName,
-				"quotaName":      quotaNameLocalized,
-			})
-
-			labels := prometheus.Labels{
-				"subscriptionID": *subscription.SubscriptionID,
-				"location":       location,
-				"scope":          "storage",
-				"quota":          quotaName,
+			addQuota(
+				location,
+				"storage",
+				"",
+				to.String(val.Name.Value),
+				to.String(val.Name.LocalizedValue),
+				float64(to.Int32(val.CurrentValue)),
+				float64(to.Int32(val.Limit)),
+			)
+		}
+	}
+}
+
+// Collect Azure NetApp Files capacity pool quota (provisioned volume quota of the pool size in bytes)
+func (m *MetricsCollectorAzureRmQuota) collectAzureNetAppCapacityPools(ctx context.Context, logger *log.Entry, subscription subscriptions.Subscription, addQuota azureQuotaAddFunc) {
+	client := NewAzureRestClient(&subscription)
+
+	// NetApp is optional, errors must not fail the other quotas
+	accountList, err := client.List(ctx, fmt.Sprintf("/subscriptions/%s/providers/Microsoft.NetApp/netAppAccounts", *subscription.SubscriptionID), AzureNetAppApiVersion)
+	if err != nil {
+		logger.Error(err)
+		return
+	}
+
+	for _, accountRow := range accountList {
+		account := struct {
+			ID *string `json:"id"`
+		}{}
+		if err := json.Unmarshal(accountRow, &account); err != nil {
+			logger.Error(err)
+			continue
+		}
+
+		poolList, err := client.List(ctx, to.String(account.ID)+"/capacityPools", AzureNetAppApiVersion)
+		if err != nil {
+			logger.WithField("netAppAccount", to.String(account.ID)).Error(err)
+			continue
+		}
+
+		for _, poolRow := range poolList {
+			pool := azureNetAppCapacityPool{}
+			if err := json.Unmarshal(poolRow, &pool); err != nil {
+				logger.Error(err)
+				continue
+			}
+
+			if pool.Properties == nil {
+				continue
+			}
+
+			volumeList, err := client.List(ctx, to.String(pool.ID)+"/volumes", AzureNetAppApiVersion)
+			if err != nil {
+				logger.WithField("capacityPool", to.String(pool.ID)).Error(err)
+				continue
 			}
 
-			quotaCurrentMetric.Add(labels, currentValue)
-			quotaLimitMetric.Add(labels, limitValue)
+			provisionedSize := float64(0)
+			for _, volumeRow := range volumeList {
+				volume := azureNetAppVolume{}
+				if err := json.Unmarshal(volumeRow, &volume); err != nil {
+					logger.Error(err)
+					continue
+				}
+
+				if volume.Properties != nil {
+					provisionedSize += float64(to.Int64(volume.Properties.UsageThreshold))
+				}
+			}
+
+			addQuota(
+				strings.ToLower(to.String(pool.Location)),
+				"netapp",
+				toResourceId(pool.ID),
+				"capacityPoolSize",
+				"NetApp capacity pool size (bytes)",
+				provisionedSize,
+				float64(to.Int64(pool.Properties.Size)),
+			)
 		}
 	}
+}
 
-	callback <- func() {
-		quotaMetric.GaugeSet(m.prometheus.quota)
-		quotaCurrentMetric.GaugeSet(m.prometheus.quotaCurrent)
-		quotaLimitMetric.GaugeSet(m.prometheus.quotaLimit)
+// Collect Azure Files share quota and usage in bytes (--quota-fileshares)
+func (m *MetricsCollectorAzureRmQuota) collectAzureFileShares(ctx context.Context, logger *log.Entry, subscription subscriptions.Subscription, addQuota azureQuotaAddFunc) {
+	accountClient := storage.NewAccountsClientWithBaseURI(azureEnvironment.ResourceManagerEndpoint, *subscription.SubscriptionID)
+	accountClient.Authorizer = AzureAuthorizer
+	accountClient.ResponseInspector = azureResponseInspector(&subscription)
+
+	client := NewAzureRestClient(&subscription)
+
+	accountList, err := accountClient.ListComplete(ctx)
+	if err != nil {
+		logger.Panic(err)
+	}
+
+	for accountList.NotDone() {
+		account := accountList.Value()
+
+		// file shares are only supported by general purpose and file storage accounts
+		switch account.Kind {
+		case storage.KindStorage, storage.KindStorageV2, storage.KindFileStorage:
+			shareList, err := client.List(ctx, to.String(account.ID)+"/fileServices/default/shares", AzureFileShareApiVersion)
+			if err != nil {
+				logger.WithField("storageAccount", to.String(account.Name)).Error(err)
+				break
+			}
+
+			for _, shareRow := range shareList {
+				share := azureFileShare{}
+				if err := json.Unmarshal(shareRow, &share); err != nil {
+					logger.Error(err)
+					continue
+				}
+
+				// usage is only returned with stats
+				if err := client.GetWithQuery(ctx, to.String(share.ID), AzureFileShareApiVersion, map[string]interface{}{"$expand": "stats"}, &share); err != nil {
+					logger.WithField("fileShare", to.String(share.ID)).Error(err)
+					continue
+				}
+
+				if share.Properties == nil {
+					continue
+				}
+
+				addQuota(
+					strings.ToLower(to.String(account.Location)),
+					"files",
+					toResourceId(share.ID),
+					"shareQuota",
+					"Azure Files share quota (bytes)",
+					float64(to.Int64(share.Properties.ShareUsageBytes)),
+					float64(to.Int64(share.Properties.ShareQuota))*1024*1024*1024,
+				)
+			}
+		}
+
+		if accountList.NextWithContext(ctx) != nil {
+			break
+		}
 	}
 }
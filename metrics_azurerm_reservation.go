@@ -0,0 +1,356 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+	prometheusCommon "github.com/webdevops/go-prometheus-common"
+	"strings"
+	"time"
+)
+
+const (
+	// reservations and savings plans (incl. utilization) are not available in the used Azure SDK version
+	AzureReservationApiVersion = "2022-11-01"
+	AzureSavingsPlanApiVersion = "2022-11-01"
+)
+
+type MetricsCollectorAzureRmReservation struct {
+	CollectorProcessorCustom
+
+	prometheus struct {
+		reservation            *prometheus.GaugeVec
+		reservationQuantity    *prometheus.GaugeVec
+		reservationExpiry      *prometheus.GaugeVec
+		reservationUtilization *prometheus.GaugeVec
+
+		savingsPlan            *prometheus.GaugeVec
+		savingsPlanCommitment  *prometheus.GaugeVec
+		savingsPlanExpiry      *prometheus.GaugeVec
+		savingsPlanUtilization *prometheus.GaugeVec
+	}
+}
+
+// azureBenefitUtilization is the utilization of a reservation or savings plan (percentage per grain, eg. 1, 7 and 30 days)
+type azureBenefitUtilization struct {
+	Trend      string `json:"trend"`
+	Aggregates []struct {
+		Grain     float64 `json:"grain"`
+		GrainUnit string  `json:"grainUnit"`
+		Value     float64 `json:"value"`
+		ValueUnit string  `json:"valueUnit"`
+	} `json:"aggregates"`
+}
+
+// azureReservation is a Microsoft.Capacity reservation
+type azureReservation struct {
+	ID       string `json:"id"`
+	Location string `json:"location"`
+	Sku      struct {
+		Name string `json:"name"`
+	} `json:"sku"`
+	Properties struct {
+		DisplayName              string                   `json:"displayName"`
+		ReservedResourceType     string                   `json:"reservedResourceType"`
+		Quantity                 float64                  `json:"quantity"`
+		Term                     string                   `json:"term"`
+		AppliedScopeType         string                   `json:"appliedScopeType"`
+		DisplayProvisioningState string                   `json:"displayProvisioningState"`
+		Renew                    bool                     `json:"renew"`
+		ExpiryDate               string                   `json:"expiryDate"`
+		Utilization              *azureBenefitUtilization `json:"utilization"`
+	} `json:"properties"`
+}
+
+// azureSavingsPlan is a Microsoft.BillingBenefits savings plan
+type azureSavingsPlan struct {
+	ID  string `json:"id"`
+	Sku struct {
+		Name string `json:"name"`
+	} `json:"sku"`
+	Properties struct {
+		DisplayName              string `json:"displayName"`
+		Term                     string `json:"term"`
+		AppliedScopeType         string `json:"appliedScopeType"`
+		DisplayProvisioningState string `json:"displayProvisioningState"`
+		Renew                    bool   `json:"renew"`
+		ExpiryDateTime           string `json:"expiryDateTime"`
+		Commitment               *struct {
+			Grain        string  `json:"grain"`
+			CurrencyCode string  `json:"currencyCode"`
+			Amount       float64 `json:"amount"`
+		} `json:"commitment"`
+		Utilization *azureBenefitUtilization `json:"utilization"`
+	} `json:"properties"`
+}
+
+func (m *MetricsCollectorAzureRmReservation) Setup(collector *CollectorCustom) {
+	m.CollectorReference = collector
+
+	m.prometheus.reservation = m.newGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_reservation_info",
+			Help: "Azure reservation information",
+		},
+		[]string{
+			"resourceID",
+			"reservationOrderID",
+			"name",
+			"location",
+			"skuName",
+			"resourceType",
+			"term",
+			"appliedScopeType",
+			"state",
+			"renew",
+		},
+	)
+	m.MustRegister(m.prometheus.reservation)
+
+	m.prometheus.reservationQuantity = m.newGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_reservation_quantity",
+			Help: "Azure reservation quantity (reserved instances)",
+		},
+		[]string{
+			"resourceID",
+		},
+	)
+	m.MustRegister(m.prometheus.reservationQuantity)
+
+	m.prometheus.reservationExpiry = m.newGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_reservation_expiry",
+			Help: "Azure reservation expiry time",
+		},
+		[]string{
+			"resourceID",
+		},
+	)
+	m.MustRegister(m.prometheus.reservationExpiry)
+
+	m.prometheus.reservationUtilization = m.newGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_reservation_utilization",
+			Help: "Azure reservation utilization in percent (average of the last 1, 7 and 30 days)",
+		},
+		[]string{
+			"resourceID",
+			"grain",
+			"trend",
+		},
+	)
+	m.MustRegister(m.prometheus.reservationUtilization)
+
+	m.prometheus.savingsPlan = m.newGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_savingsplan_info",
+			Help: "Azure savings plan information",
+		},
+		[]string{
+			"resourceID",
+			"name",
+			"skuName",
+			"term",
+			"appliedScopeType",
+			"state",
+			"renew",
+		},
+	)
+	m.MustRegister(m.prometheus.savingsPlan)
+
+	m.prometheus.savingsPlanCommitment = m.newGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_savingsplan_commitment",
+			Help: "Azure savings plan commitment amount per grain (eg. hourly)",
+		},
+		[]string{
+			"resourceID",
+			"grain",
+			"currency",
+		},
+	)
+	m.MustRegister(m.prometheus.savingsPlanCommitment)
+
+	m.prometheus.savingsPlanExpiry = m.newGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_savingsplan_expiry",
+			Help: "Azure savings plan expiry time",
+		},
+		[]string{
+			"resourceID",
+		},
+	)
+	m.MustRegister(m.prometheus.savingsPlanExpiry)
+
+	m.prometheus.savingsPlanUtilization = m.newGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_savingsplan_utilization",
+			Help: "Azure savings plan utilization in percent of the commitment (average of the last 1, 7 and 30 days)",
+		},
+		[]string{
+			"resourceID",
+			"grain",
+			"trend",
+		},
+	)
+	m.MustRegister(m.prometheus.savingsPlanUtilization)
+}
+
+// Collect reservations and savings plans visible to the exporter identity (tenant wide, Reservations Reader needed)
+func (m *MetricsCollectorAzureRmReservation) Collect(ctx context.Context, logger *log.Entry) {
+	client := NewAzureRestClient(nil)
+
+	reservationMetric := prometheusCommon.NewMetricsList()
+	reservationQuantityMetric := prometheusCommon.NewMetricsList()
+	reservationExpiryMetric := prometheusCommon.NewMetricsList()
+	reservationUtilizationMetric := prometheusCommon.NewMetricsList()
+
+	reservationList, err := client.List(ctx, "/providers/Microsoft.Capacity/reservations", AzureReservationApiVersion)
+	if err != nil {
+		logger.Panic(err)
+	}
+
+	for _, row := range reservationList {
+		reservation := azureReservation{}
+		if err := json.Unmarshal(row, &reservation); err != nil {
+			logger.Error(err)
+			continue
+		}
+
+		resourceId := toResourceId(&reservation.ID)
+
+		// /providers/microsoft.capacity/reservationOrders/<order>/reservations/<reservation>
+		reservationOrderId := ""
+		if reservationIndex := strings.Index(strings.ToLower(reservation.ID), "/reservations/"); reservationIndex > 0 {
+			reservationOrderId = reservation.ID[:reservationIndex]
+			reservationOrderId = toResourceId(&reservationOrderId)
+		}
+
+		reservationMetric.AddInfo(prometheus.Labels{
+			"resourceID":         resourceId,
+			"reservationOrderID": reservationOrderId,
+			"name":               reservation.Properties.DisplayName,
+			"location":           strings.ToLower(reservation.Location),
+			"skuName":            reservation.Sku.Name,
+			"resourceType":       reservation.Properties.ReservedResourceType,
+			"term":               reservation.Properties.Term,
+			"appliedScopeType":   reservation.Properties.AppliedScopeType,
+			"state":              reservation.Properties.DisplayProvisioningState,
+			"renew":              boolToString(reservation.Properties.Renew),
+		})
+
+		reservationQuantityMetric.Add(prometheus.Labels{
+			"resourceID": resourceId,
+		}, reservation.Properties.Quantity)
+
+		if expiry, ok := parseBenefitTime(reservation.Properties.ExpiryDate); ok {
+			reservationExpiryMetric.Add(prometheus.Labels{
+				"resourceID": resourceId,
+			}, float64(expiry.Unix()))
+		}
+
+		addBenefitUtilization(reservationUtilizationMetric, resourceId, reservation.Properties.Utilization)
+	}
+
+	savingsPlanMetric := prometheusCommon.NewMetricsList()
+	savingsPlanCommitmentMetric := prometheusCommon.NewMetricsList()
+	savingsPlanExpiryMetric := prometheusCommon.NewMetricsList()
+	savingsPlanUtilizationMetric := prometheusCommon.NewMetricsList()
+
+	// savings plans need separate permissions (Savings plan Reader), reservations are still exported if denied
+	savingsPlanList, err := client.List(ctx, "/providers/Microsoft.BillingBenefits/savingsPlans", AzureSavingsPlanApiVersion)
+	if err != nil {
+		logger.Error(err)
+	}
+
+	for _, row := range savingsPlanList {
+		savingsPlan := azureSavingsPlan{}
+		if err := json.Unmarshal(row, &savingsPlan); err != nil {
+			logger.Error(err)
+			continue
+		}
+
+		resourceId := toResourceId(&savingsPlan.ID)
+
+		savingsPlanMetric.AddInfo(prometheus.Labels{
+			"resourceID":       resourceId,
+			"name":             savingsPlan.Properties.DisplayName,
+			"skuName":          savingsPlan.Sku.Name,
+			"term":             savingsPlan.Properties.Term,
+			"appliedScopeType": savingsPlan.Properties.AppliedScopeType,
+			"state":            savingsPlan.Properties.DisplayProvisioningState,
+			"renew":            boolToString(savingsPlan.Properties.Renew),
+		})
+
+		if savingsPlan.Properties.Commitment != nil {
+			savingsPlanCommitmentMetric.Add(prometheus.Labels{
+				"resourceID": resourceId,
+				"grain":      strings.ToLower(savingsPlan.Properties.Commitment.Grain),
+				"currency":   savingsPlan.Properties.Commitment.CurrencyCode,
+			}, savingsPlan.Properties.Commitment.Amount)
+		}
+
+		if expiry, ok := parseBenefitTime(savingsPlan.Properties.ExpiryDateTime); ok {
+			savingsPlanExpiryMetric.Add(prometheus.Labels{
+				"resourceID": resourceId,
+			}, float64(expiry.Unix()))
+		}
+
+		addBenefitUtilization(savingsPlanUtilizationMetric, resourceId, savingsPlan.Properties.Utilization)
+	}
+
+	metricsApply(func() {
+		m.prometheus.reservation.Reset()
+		m.prometheus.reservationQuantity.Reset()
+		m.prometheus.reservationExpiry.Reset()
+		m.prometheus.reservationUtilization.Reset()
+		m.prometheus.savingsPlan.Reset()
+		m.prometheus.savingsPlanCommitment.Reset()
+		m.prometheus.savingsPlanExpiry.Reset()
+		m.prometheus.savingsPlanUtilization.Reset()
+
+		reservationMetric.GaugeSet(m.prometheus.reservation)
+		reservationQuantityMetric.GaugeSet(m.prometheus.reservationQuantity)
+		reservationExpiryMetric.GaugeSet(m.prometheus.reservationExpiry)
+		reservationUtilizationMetric.GaugeSet(m.prometheus.reservationUtilization)
+		savingsPlanMetric.GaugeSet(m.prometheus.savingsPlan)
+		savingsPlanCommitmentMetric.GaugeSet(m.prometheus.savingsPlanCommitment)
+		savingsPlanExpiryMetric.GaugeSet(m.prometheus.savingsPlanExpiry)
+		savingsPlanUtilizationMetric.GaugeSet(m.prometheus.savingsPlanUtilization)
+	})
+}
+
+// addBenefitUtilization adds the utilization aggregates (eg. grain "7d") of a reservation or savings plan
+func addBenefitUtilization(metricList *prometheusCommon.MetricList, resourceId string, utilization *azureBenefitUtilization) {
+	if utilization == nil {
+		return
+	}
+
+	for _, aggregate := range utilization.Aggregates {
+		// eg. 7 days -> 7d
+		grain := fmt.Sprintf("%v", aggregate.Grain)
+		if aggregate.GrainUnit != "" {
+			grain += strings.ToLower(aggregate.GrainUnit[:1])
+		}
+
+		metricList.Add(prometheus.Labels{
+			"resourceID": resourceId,
+			"grain":      grain,
+			"trend":      strings.ToLower(utilization.Trend),
+		}, aggregate.Value)
+	}
+}
+
+// parseBenefitTime parses expiry dates of reservations (date) and savings plans (date time)
+func parseBenefitTime(value string) (time.Time, bool) {
+	for _, layout := range []string{time.RFC3339, "2006-01-02"} {
+		if parsedTime, err := time.Parse(layout, value); err == nil {
+			return parsedTime, true
+		}
+	}
+
+	return time.Time{}, false
+}
@@ -2,13 +2,24 @@ package main
 
 import (
 	"context"
+	"fmt"
 	"github.com/Azure/azure-sdk-for-go/profiles/latest/resources/mgmt/resources"
 	"github.com/Azure/azure-sdk-for-go/profiles/latest/resources/mgmt/subscriptions"
+	"github.com/Azure/azure-sdk-for-go/services/preview/monitor/mgmt/2019-06-01/insights"
 	"github.com/Azure/go-autorest/autorest/to"
 	"github.com/prometheus/client_golang/prometheus"
 	log "github.com/sirupsen/logrus"
 	prometheusCommon "github.com/webdevops/go-prometheus-common"
 	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	resourceListExpand = "createdTime,changedTime,provisioningState"
+
+	// Activity Log events can be delayed, the timespan of the previous query is partly queried again
+	resourceActivityLogOverlap = 15 * time.Minute
 )
 
 type MetricsCollectorAzureRmResources struct {
@@ -17,13 +28,52 @@ type MetricsCollectorAzureRmResources struct {
 	prometheus struct {
 		resource      *prometheus.GaugeVec
 		resourceGroup *prometheus.GaugeVec
+
+		resourceProvisioningFailed *prometheus.GaugeVec
+		regionResourceTypes        *prometheus.GaugeVec
+
+		resourceTagValue      *prometheus.GaugeVec
+		resourceGroupTagValue *prometheus.GaugeVec
+	}
+
+	// resources per subscription (--resource-activitylog, --eventgrid-collector)
+	inventory     map[string]*resourceInventory
+	inventoryLock sync.Mutex
+
+	// resourcegroups (lowercase) changed by Event Grid events per subscription, listed again by the next collection
+	eventGridResourceGroups map[string]map[string]bool
+}
+
+// resourceInventory is the cached resource list of a subscription grouped by resourcegroup (lowercase)
+type resourceInventory struct {
+	resourceGroups     map[string][]resources.GenericResourceExpanded
+	fullCollectionTime time.Time
+	activityLogTime    time.Time
+}
+
+// resourceCounts are the resource counts of a subscription summed up over all pages
+type resourceCounts struct {
+	// failed/canceled resources per resource type and provisioningState
+	provisioningFailed map[string]map[string]float64
+
+	// resources per region and resource type
+	regionResourceType map[string]map[string]float64
+
+	// all resources of the subscription (parallelism auto-tuning)
+	total int64
+}
+
+func newResourceCounts() *resourceCounts {
+	return &resourceCounts{
+		provisioningFailed: map[string]map[string]float64{},
+		regionResourceType: map[string]map[string]float64{},
 	}
 }
 
 func (m *MetricsCollectorAzureRmResources) Setup(collector *CollectorGeneral) {
 	m.CollectorReference = collector
 
-	m.prometheus.resource = prometheus.NewGaugeVec(
+	m.prometheus.resource = m.newGaugeVec(
 		prometheus.GaugeOpts{
 			Name: "azurerm_resource_info",
 			Help: "Azure Resource information",
@@ -41,9 +91,9 @@ func (m *MetricsCollectorAzureRmResources) Setup(collector *CollectorGeneral) {
 			azureResourceTags.prometheusLabels...,
 		),
 	)
-	prometheus.MustRegister(m.prometheus.resource)
+	m.MustRegister(m.prometheus.resource)
 
-	m.prometheus.resourceGroup = prometheus.NewGaugeVec(
+	m.prometheus.resourceGroup = m.newGaugeVec(
 		prometheus.GaugeOpts{
 			Name: "azurerm_resourcegroup_info",
 			Help: "Azure ResourceManager resourcegroup information",
@@ -59,12 +109,109 @@ func (m *MetricsCollectorAzureRmResources) Setup(collector *CollectorGeneral) {
 			azureResourceGroupTags.prometheusLabels...,
 		),
 	)
-	prometheus.MustRegister(m.prometheus.resourceGroup)
+	m.MustRegister(m.prometheus.resourceGroup)
+
+	m.prometheus.resourceProvisioningFailed = m.newGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_resource_provisioning_failed_count",
+			Help: "Azure Resource count with failed or canceled provisioningState per resource type",
+		},
+		[]string{
+			"subscriptionID",
+			"resourceType",
+			"provisioningState",
+		},
+	)
+	m.MustRegister(m.prometheus.resourceProvisioningFailed)
+
+	m.prometheus.regionResourceTypes = m.newGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_region_resource_types",
+			Help: "Azure Resource count per region and resource type",
+		},
+		[]string{
+			"subscriptionID",
+			"location",
+			"resourceType",
+		},
+	)
+	m.MustRegister(m.prometheus.regionResourceTypes)
+
+	m.prometheus.resourceTagValue = m.newGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_resource_tag_value",
+			Help: "Azure Resource numeric tag values (--azure-resource-tag-value)",
+		},
+		[]string{
+			"resourceID",
+			"subscriptionID",
+			"resourceGroup",
+			"tag",
+		},
+	)
+	m.MustRegister(m.prometheus.resourceTagValue)
+
+	m.prometheus.resourceGroupTagValue = m.newGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_resourcegroup_tag_value",
+			Help: "Azure ResourceManager resourcegroup numeric tag values (--azure-resourcegroup-tag-value)",
+		},
+		[]string{
+			"resourceID",
+			"subscriptionID",
+			"resourceGroup",
+			"tag",
+		},
+	)
+	m.MustRegister(m.prometheus.resourceGroupTagValue)
+
+	m.inventory = map[string]*resourceInventory{}
+	m.eventGridResourceGroups = map[string]map[string]bool{}
+}
+
+// Streaming applies the resource pages while collecting, subscriptions with 200k+ resources
+// would keep all pages of the run in memory otherwise
+func (m *MetricsCollectorAzureRmResources) Streaming() bool {
+	return true
+}
+
+// EventGridResourceGroups remembers the resourcegroups changed by Event Grid events, the next collection of the
+// subscription only lists these resourcegroups again (all resources without resourcegroups)
+func (m *MetricsCollectorAzureRmResources) EventGridResourceGroups(subscriptionId string, resourceGroups []string) {
+	m.inventoryLock.Lock()
+	defer m.inventoryLock.Unlock()
+
+	subscriptionId = strings.ToLower(subscriptionId)
+	if len(resourceGroups) == 0 {
+		delete(m.eventGridResourceGroups, subscriptionId)
+		return
+	}
+
+	if _, exists := m.eventGridResourceGroups[subscriptionId]; !exists {
+		m.eventGridResourceGroups[subscriptionId] = map[string]bool{}
+	}
+	for _, resourceGroup := range resourceGroups {
+		m.eventGridResourceGroups[subscriptionId][strings.ToLower(resourceGroup)] = true
+	}
+}
+
+// FlushCache drops the resource inventory and the pending Event Grid resourcegroups,
+// the next collection of each subscription lists all resources again
+func (m *MetricsCollectorAzureRmResources) FlushCache() {
+	m.inventoryLock.Lock()
+	defer m.inventoryLock.Unlock()
+
+	m.inventory = map[string]*resourceInventory{}
+	m.eventGridResourceGroups = map[string]map[string]bool{}
 }
 
 func (m *MetricsCollectorAzureRmResources) Reset() {
 	m.prometheus.resource.Reset()
 	m.prometheus.resourceGroup.Reset()
+	m.prometheus.resourceProvisioningFailed.Reset()
+	m.prometheus.regionResourceTypes.Reset()
+	m.prometheus.resourceTagValue.Reset()
+	m.prometheus.resourceGroupTagValue.Reset()
 }
 
 func (m *MetricsCollectorAzureRmResources) Collect(ctx context.Context, logger *log.Entry, callback chan<- func(), subscription subscriptions.Subscription) {
@@ -84,8 +231,11 @@ func (m *MetricsCollectorAzureRmResources) collectAzureResourceGroup(ctx context
 	}
 
 	infoMetric := prometheusCommon.NewMetricsList()
+	tagValueMetric := prometheusCommon.NewMetricsList()
 
 	for _, item := range *resourceGroupResult.Response().Value {
+		azureHierarchy.setResourceGroupTags(to.String(subscription.SubscriptionID), to.String(item.Name), item.Tags)
+
 		infoLabels := azureResourceGroupTags.appendPrometheusLabel(prometheus.Labels{
 			"resourceID":        toResourceId(item.ID),
 			"subscriptionID":    to.String(subscription.SubscriptionID),
@@ -94,29 +244,243 @@ func (m *MetricsCollectorAzureRmResources) collectAzureResourceGroup(ctx context
 			"provisioningState": strings.ToLower(to.String(item.Properties.ProvisioningState)),
 		}, item.Tags)
 		infoMetric.AddInfo(infoLabels)
+
+		for tagName, tagValue := range azureTagNumericValues(opts.Azure.ResourceGroupTagValues, item.Tags) {
+			tagValueMetric.Add(prometheus.Labels{
+				"resourceID":     toResourceId(item.ID),
+				"subscriptionID": to.String(subscription.SubscriptionID),
+				"resourceGroup":  to.String(item.Name),
+				"tag":            tagName,
+			}, tagValue)
+		}
 	}
 
 	callback <- func() {
 		infoMetric.GaugeSet(m.prometheus.resourceGroup)
+		tagValueMetric.GaugeSet(m.prometheus.resourceGroupTagValue)
 	}
 }
 
 func (m *MetricsCollectorAzureRmResources) collectAzureResources(ctx context.Context, logger *log.Entry, callback chan<- func(), subscription subscriptions.Subscription) {
+	if opts.Resource.ActivityLog || eventGridCollectsCollector(m.CollectorReference.Name) {
+		m.collectAzureResourcesIncremental(ctx, logger, callback, subscription)
+		return
+	}
+
 	client := resources.NewClientWithBaseURI(azureEnvironment.ResourceManagerEndpoint, *subscription.SubscriptionID)
 	client.Authorizer = AzureAuthorizer
 	client.ResponseInspector = azureResponseInspector(&subscription)
 
-	list, err := client.ListComplete(ctx, "", "createdTime,changedTime,provisioningState", nil)
+	// processed page by page, metrics of each page are passed as own callback so
+	// the page and the metrics (applied while collecting, see Streaming) can be released early
+	page, err := client.List(ctx, "", resourceListExpand, nil)
 
 	if err != nil {
 		logger.Panic(err)
 	}
 
+	counts := newResourceCounts()
+
+	// shared with dependent collectors (eg. AlertCoverage, Monitor)
+	storeEnabled := m.CollectorReference.HasDependents()
+	resourceCache := NewAzureResourceCache()
+
+	for page.NotDone() {
+		resourceMetric := prometheusCommon.NewMetricsList()
+		tagValueMetric := prometheusCommon.NewMetricsList()
+
+		m.addResources(subscription, page.Values(), resourceMetric, tagValueMetric, counts)
+
+		if storeEnabled {
+			for _, val := range page.Values() {
+				resourceCache.Add(val)
+			}
+		}
+
+		callback <- func() {
+			resourceMetric.GaugeSet(m.prometheus.resource)
+			tagValueMetric.GaugeSet(m.prometheus.resourceTagValue)
+		}
+
+		if page.NextWithContext(ctx) != nil {
+			storeEnabled = false
+			break
+		}
+	}
+
+	if storeEnabled {
+		m.storeSet(collectorStoreResources, subscription, resourceCache)
+	}
+
+	m.sendResourceCounts(callback, subscription, counts)
+}
+
+// collectAzureResourcesIncremental collects the resources from the inventory cache, the full resource list is only
+// fetched every --resource-full-interval, in between only resourcegroups with write/delete events in the
+// Activity Log or changed by Event Grid events are listed again. Without --resource-activitylog only collections
+// triggered by Event Grid events are incremental
+func (m *MetricsCollectorAzureRmResources) collectAzureResourcesIncremental(ctx context.Context, logger *log.Entry, callback chan<- func(), subscription subscriptions.Subscription) {
+	client := resources.NewClientWithBaseURI(azureEnvironment.ResourceManagerEndpoint, *subscription.SubscriptionID)
+	client.Authorizer = AzureAuthorizer
+	client.ResponseInspector = azureResponseInspector(&subscription)
+
+	subscriptionId := to.String(subscription.SubscriptionID)
+	now := time.Now().UTC()
+
+	m.inventoryLock.Lock()
+	inventory := m.inventory[subscriptionId]
+	eventGridResourceGroups := m.eventGridResourceGroups[strings.ToLower(subscriptionId)]
+	delete(m.eventGridResourceGroups, strings.ToLower(subscriptionId))
+	m.inventoryLock.Unlock()
+
+	fullCollection := inventory == nil || now.Sub(inventory.fullCollectionTime) >= opts.Resource.FullInterval
+	if !opts.Resource.ActivityLog && len(eventGridResourceGroups) == 0 {
+		fullCollection = true
+	}
+
+	if fullCollection {
+		inventory = &resourceInventory{
+			resourceGroups:     map[string][]resources.GenericResourceExpanded{},
+			fullCollectionTime: now,
+		}
+
+		list, err := client.ListComplete(ctx, "", resourceListExpand, nil)
+		if err != nil {
+			logger.Panic(err)
+		}
+
+		for list.NotDone() {
+			val := list.Value()
+			resourceGroup := strings.ToLower(extractResourceGroupFromAzureId(to.String(val.ID)))
+			inventory.resourceGroups[resourceGroup] = append(inventory.resourceGroups[resourceGroup], val)
+
+			if list.NextWithContext(ctx) != nil {
+				break
+			}
+		}
+	} else {
+		// copy of the cached inventory, concurrent collections of the subscription keep a consistent view
+		previousInventory := inventory
+		inventory = &resourceInventory{
+			resourceGroups:     make(map[string][]resources.GenericResourceExpanded, len(previousInventory.resourceGroups)),
+			fullCollectionTime: previousInventory.fullCollectionTime,
+		}
+		for resourceGroup, resourceList := range previousInventory.resourceGroups {
+			inventory.resourceGroups[resourceGroup] = resourceList
+		}
+
+		changedResourceGroups := map[string]bool{}
+		if opts.Resource.ActivityLog {
+			changedResourceGroups = m.activityLogChangedResourceGroups(ctx, logger, subscription, previousInventory.activityLogTime.Add(-resourceActivityLogOverlap), now)
+			logger.Debugf("activity log: %v changed resourcegroups", len(changedResourceGroups))
+		}
+		for resourceGroup := range eventGridResourceGroups {
+			changedResourceGroups[resourceGroup] = true
+		}
+
+		for resourceGroup := range changedResourceGroups {
+			resourceList := []resources.GenericResourceExpanded{}
+
+			list, err := client.ListByResourceGroupComplete(ctx, resourceGroup, "", resourceListExpand, nil)
+			if err != nil {
+				// resourcegroup was deleted
+				if azureRestIsNotFound(err) {
+					delete(inventory.resourceGroups, resourceGroup)
+					continue
+				}
+				logger.Panic(err)
+			}
+
+			for list.NotDone() {
+				resourceList = append(resourceList, list.Value())
+
+				if list.NextWithContext(ctx) != nil {
+					break
+				}
+			}
+
+			inventory.resourceGroups[resourceGroup] = resourceList
+		}
+	}
+	inventory.activityLogTime = now
+
+	m.inventoryLock.Lock()
+	m.inventory[subscriptionId] = inventory
+	m.inventoryLock.Unlock()
+
 	resourceMetric := prometheusCommon.NewMetricsList()
+	tagValueMetric := prometheusCommon.NewMetricsList()
+	counts := newResourceCounts()
+
+	storeEnabled := m.CollectorReference.HasDependents()
+	resourceCache := NewAzureResourceCache()
+
+	for _, resourceList := range inventory.resourceGroups {
+		m.addResources(subscription, resourceList, resourceMetric, tagValueMetric, counts)
+
+		if storeEnabled {
+			for _, val := range resourceList {
+				resourceCache.Add(val)
+			}
+		}
+	}
 
+	if storeEnabled {
+		m.storeSet(collectorStoreResources, subscription, resourceCache)
+	}
+
+	callback <- func() {
+		resourceMetric.GaugeSet(m.prometheus.resource)
+		tagValueMetric.GaugeSet(m.prometheus.resourceTagValue)
+	}
+
+	m.sendResourceCounts(callback, subscription, counts)
+}
+
+// activityLogChangedResourceGroups returns the resourcegroups (lowercase) with succeeded write or delete operations
+// in the Activity Log between startTime and endTime
+func (m *MetricsCollectorAzureRmResources) activityLogChangedResourceGroups(ctx context.Context, logger *log.Entry, subscription subscriptions.Subscription, startTime, endTime time.Time) map[string]bool {
+	client := insights.NewActivityLogsClientWithBaseURI(azureEnvironment.ResourceManagerEndpoint, *subscription.SubscriptionID)
+	client.Authorizer = AzureAuthorizer
+	client.ResponseInspector = azureResponseInspector(&subscription)
+
+	filter := fmt.Sprintf(
+		"eventTimestamp ge '%s' and eventTimestamp le '%s'",
+		startTime.Format(time.RFC3339),
+		endTime.Format(time.RFC3339),
+	)
+
+	list, err := client.ListComplete(ctx, filter, "resourceGroupName,operationName,status")
+	if err != nil {
+		logger.Panic(err)
+	}
+
+	ret := map[string]bool{}
 	for list.NotDone() {
 		val := list.Value()
 
+		if val.ResourceGroupName != nil && val.OperationName != nil && val.Status != nil {
+			operationName := strings.ToLower(to.String(val.OperationName.Value))
+			status := to.String(val.Status.Value)
+
+			if status == "Succeeded" && (strings.HasSuffix(operationName, "/write") || strings.HasSuffix(operationName, "/delete")) {
+				ret[strings.ToLower(*val.ResourceGroupName)] = true
+			}
+		}
+
+		if list.NextWithContext(ctx) != nil {
+			break
+		}
+	}
+
+	return ret
+}
+
+// addResources adds the info and tag value metrics of the resources and sums up the resource counts
+func (m *MetricsCollectorAzureRmResources) addResources(subscription subscriptions.Subscription, resourceList []resources.GenericResourceExpanded, resourceMetric, tagValueMetric *prometheusCommon.MetricList, counts *resourceCounts) {
+	counts.total += int64(len(resourceList))
+
+	for _, val := range resourceList {
 		infoLabels := prometheus.Labels{
 			"subscriptionID":    to.String(subscription.SubscriptionID),
 			"resourceID":        toResourceId(val.ID),
@@ -129,12 +493,60 @@ func (m *MetricsCollectorAzureRmResources) collectAzureResources(ctx context.Con
 		infoLabels = azureResourceTags.appendPrometheusLabel(infoLabels, val.Tags)
 		resourceMetric.AddInfo(infoLabels)
 
-		if list.NextWithContext(ctx) != nil {
-			break
+		for tagName, tagValue := range azureTagNumericValues(opts.Azure.ResourceTagValues, val.Tags) {
+			tagValueMetric.Add(prometheus.Labels{
+				"resourceID":     toResourceId(val.ID),
+				"subscriptionID": to.String(subscription.SubscriptionID),
+				"resourceGroup":  extractResourceGroupFromAzureId(to.String(val.ID)),
+				"tag":            tagName,
+			}, tagValue)
+		}
+
+		location := strings.ToLower(to.String(val.Location))
+		if _, exists := counts.regionResourceType[location]; !exists {
+			counts.regionResourceType[location] = map[string]float64{}
+		}
+		counts.regionResourceType[location][strings.ToLower(to.String(val.Type))]++
+
+		switch provisioningState := strings.ToLower(to.String(val.ProvisioningState)); provisioningState {
+		case "failed", "canceled":
+			resourceType := strings.ToLower(to.String(val.Type))
+			if _, exists := counts.provisioningFailed[resourceType]; !exists {
+				counts.provisioningFailed[resourceType] = map[string]float64{}
+			}
+			counts.provisioningFailed[resourceType][provisioningState]++
+		}
+	}
+}
+
+// sendResourceCounts passes the summed up resource counts of the subscription as callback
+func (m *MetricsCollectorAzureRmResources) sendResourceCounts(callback chan<- func(), subscription subscriptions.Subscription, counts *resourceCounts) {
+	azureResourceCountSet(to.String(subscription.SubscriptionID), counts.total)
+
+	provisioningFailedMetric := prometheusCommon.NewMetricsList()
+	for resourceType, stateCount := range counts.provisioningFailed {
+		for provisioningState, count := range stateCount {
+			provisioningFailedMetric.Add(prometheus.Labels{
+				"subscriptionID":    to.String(subscription.SubscriptionID),
+				"resourceType":      resourceType,
+				"provisioningState": provisioningState,
+			}, count)
+		}
+	}
+
+	regionResourceTypesMetric := prometheusCommon.NewMetricsList()
+	for location, resourceTypeCount := range counts.regionResourceType {
+		for resourceType, count := range resourceTypeCount {
+			regionResourceTypesMetric.Add(prometheus.Labels{
+				"subscriptionID": to.String(subscription.SubscriptionID),
+				"location":       location,
+				"resourceType":   resourceType,
+			}, count)
 		}
 	}
 
 	callback <- func() {
-		resourceMetric.GaugeSet(m.prometheus.resource)
+		provisioningFailedMetric.GaugeSet(m.prometheus.resourceProvisioningFailed)
+		regionResourceTypesMetric.GaugeSet(m.prometheus.regionResourceTypes)
 	}
 }
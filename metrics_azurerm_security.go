@@ -17,15 +17,18 @@ type MetricsCollectorAzureRmSecurity struct {
 	CollectorProcessorGeneral
 
 	prometheus struct {
-		securitycenterCompliance *prometheus.GaugeVec
-		advisorRecommendations   *prometheus.GaugeVec
+		securitycenterCompliance                  *prometheus.GaugeVec
+		securitycenterPricing                     *prometheus.GaugeVec
+		securitycenterSecureScore                 *prometheus.GaugeVec
+		securitycenterSecureScoreControlResources *prometheus.GaugeVec
+		advisorRecommendations                    *prometheus.GaugeVec
 	}
 }
 
 func (m *MetricsCollectorAzureRmSecurity) Setup(collector *CollectorGeneral) {
 	m.CollectorReference = collector
 
-	m.prometheus.securitycenterCompliance = prometheus.NewGaugeVec(
+	m.prometheus.securitycenterCompliance = m.newGaugeVec(
 		prometheus.GaugeOpts{
 			Name: "azurerm_securitycenter_compliance",
 			Help: "Azure Audit SecurityCenter compliance status",
@@ -36,9 +39,51 @@ func (m *MetricsCollectorAzureRmSecurity) Setup(collector *CollectorGeneral) {
 			"assessmentType",
 		},
 	)
-	prometheus.MustRegister(m.prometheus.securitycenterCompliance)
+	m.MustRegister(m.prometheus.securitycenterCompliance)
 
-	m.prometheus.advisorRecommendations = prometheus.NewGaugeVec(
+	m.prometheus.securitycenterPricing = m.newGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_securitycenter_pricing_info",
+			Help: "Azure SecurityCenter (Defender for Cloud) plan pricing tier (Free: plan disabled, Standard: plan enabled)",
+		},
+		[]string{
+			"subscriptionID",
+			"plan",
+			"tier",
+		},
+	)
+	m.MustRegister(m.prometheus.securitycenterPricing)
+
+	m.prometheus.securitycenterSecureScore = m.newGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_securitycenter_securescore_percentage",
+			Help: "Azure SecurityCenter secure score ratio (current divided by maximum score) per subscription and control",
+		},
+		[]string{
+			"subscriptionID",
+			"secureScore",
+			"control",
+			"controlName",
+		},
+	)
+	m.MustRegister(m.prometheus.securitycenterSecureScore)
+
+	m.prometheus.securitycenterSecureScoreControlResources = m.newGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_securitycenter_securescore_control_resources",
+			Help: "Azure SecurityCenter secure score control resource count by health status",
+		},
+		[]string{
+			"subscriptionID",
+			"secureScore",
+			"control",
+			"controlName",
+			"status",
+		},
+	)
+	m.MustRegister(m.prometheus.securitycenterSecureScoreControlResources)
+
+	m.prometheus.advisorRecommendations = m.newGaugeVec(
 		prometheus.GaugeOpts{
 			Name: "azurerm_advisor_recommendation",
 			Help: "Azure Audit Advisor recommendation",
@@ -54,16 +99,21 @@ func (m *MetricsCollectorAzureRmSecurity) Setup(collector *CollectorGeneral) {
 			"risk",
 		},
 	)
-	prometheus.MustRegister(m.prometheus.advisorRecommendations)
+	m.MustRegister(m.prometheus.advisorRecommendations)
 }
 
 func (m *MetricsCollectorAzureRmSecurity) Reset() {
 	m.prometheus.securitycenterCompliance.Reset()
+	m.prometheus.securitycenterPricing.Reset()
+	m.prometheus.securitycenterSecureScore.Reset()
+	m.prometheus.securitycenterSecureScoreControlResources.Reset()
 	m.prometheus.advisorRecommendations.Reset()
 }
 
 func (m *MetricsCollectorAzureRmSecurity) Collect(ctx context.Context, logger *log.Entry, callback chan<- func(), subscription subscriptions.Subscription) {
 	m.collectAzureAdvisorRecommendations(ctx, logger, callback, subscription)
+	m.collectAzureSecureScore(ctx, logger, callback, subscription)
+	m.collectAzureSecurityPricing(ctx, logger, callback, subscription)
 	for _, location := range m.CollectorReference.AzureLocations {
 		m.collectAzureSecurityCompliance(ctx, logger, callback, subscription, location)
 	}
@@ -99,6 +149,126 @@ func (m *MetricsCollectorAzureRmSecurity) collectAzureSecurityCompliance(ctx con
 	}
 }
 
+func (m *MetricsCollectorAzureRmSecurity) collectAzureSecurityPricing(ctx context.Context, logger *log.Entry, callback chan<- func(), subscription subscriptions.Subscription) {
+	// pricings are not location specific
+	client := security.NewPricingsClientWithBaseURI(azureEnvironment.ResourceManagerEndpoint, *subscription.SubscriptionID, "")
+	client.Authorizer = AzureAuthorizer
+	client.ResponseInspector = azureResponseInspector(&subscription)
+
+	pricingResult, err := client.List(ctx)
+	if err != nil {
+		logger.Error(err)
+		return
+	}
+
+	infoMetric := prometheusCommon.NewMetricsList()
+
+	if pricingResult.Value != nil {
+		for _, pricing := range *pricingResult.Value {
+			tier := ""
+			if pricing.PricingProperties != nil {
+				tier = string(pricing.PricingTier)
+			}
+
+			infoMetric.AddInfo(prometheus.Labels{
+				"subscriptionID": to.String(subscription.SubscriptionID),
+				"plan":           to.String(pricing.Name),
+				"tier":           tier,
+			})
+		}
+	}
+
+	callback <- func() {
+		infoMetric.GaugeSet(m.prometheus.securitycenterPricing)
+	}
+}
+
+func (m *MetricsCollectorAzureRmSecurity) collectAzureSecureScore(ctx context.Context, logger *log.Entry, callback chan<- func(), subscription subscriptions.Subscription) {
+	// secure scores are not location specific
+	client := security.NewSecureScoresClientWithBaseURI(azureEnvironment.ResourceManagerEndpoint, *subscription.SubscriptionID, "")
+	client.Authorizer = AzureAuthorizer
+	client.ResponseInspector = azureResponseInspector(&subscription)
+
+	controlClient := security.NewSecureScoreControlsClientWithBaseURI(azureEnvironment.ResourceManagerEndpoint, *subscription.SubscriptionID, "")
+	controlClient.Authorizer = AzureAuthorizer
+	controlClient.ResponseInspector = azureResponseInspector(&subscription)
+
+	list, err := client.ListComplete(ctx)
+	if err != nil {
+		logger.Error(err)
+		return
+	}
+
+	secureScoreMetric := prometheusCommon.NewMetricsList()
+	controlResourcesMetric := prometheusCommon.NewMetricsList()
+
+	for list.NotDone() {
+		val := list.Value()
+		secureScoreName := to.String(val.Name)
+
+		if val.SecureScoreItemProperties != nil && val.SecureScoreItemProperties.ScoreDetails != nil && val.SecureScoreItemProperties.ScoreDetails.Percentage != nil {
+			secureScoreMetric.Add(prometheus.Labels{
+				"subscriptionID": to.String(subscription.SubscriptionID),
+				"secureScore":    secureScoreName,
+				"control":        "",
+				"controlName":    "",
+			}, *val.SecureScoreItemProperties.ScoreDetails.Percentage)
+		}
+
+		controlList, err := controlClient.ListBySecureScoreComplete(ctx, secureScoreName, "")
+		if err != nil {
+			logger.WithField("secureScore", secureScoreName).Error(err)
+		} else {
+			for controlList.NotDone() {
+				control := controlList.Value()
+
+				if control.SecureScoreControlScoreDetails != nil {
+					controlLabels := prometheus.Labels{
+						"subscriptionID": to.String(subscription.SubscriptionID),
+						"secureScore":    secureScoreName,
+						"control":        to.String(control.Name),
+						"controlName":    to.String(control.DisplayName),
+					}
+
+					if control.ScoreDetails != nil && control.ScoreDetails.Percentage != nil {
+						secureScoreMetric.Add(controlLabels, *control.ScoreDetails.Percentage)
+					}
+
+					resourceCounts := map[string]*int32{
+						"healthy":       control.HealthyResourceCount,
+						"unhealthy":     control.UnhealthyResourceCount,
+						"notApplicable": control.NotApplicableResourceCount,
+					}
+					for status, count := range resourceCounts {
+						if count != nil {
+							controlResourcesMetric.Add(prometheus.Labels{
+								"subscriptionID": controlLabels["subscriptionID"],
+								"secureScore":    controlLabels["secureScore"],
+								"control":        controlLabels["control"],
+								"controlName":    controlLabels["controlName"],
+								"status":         status,
+							}, float64(*count))
+						}
+					}
+				}
+
+				if controlList.NextWithContext(ctx) != nil {
+					break
+				}
+			}
+		}
+
+		if list.NextWithContext(ctx) != nil {
+			break
+		}
+	}
+
+	callback <- func() {
+		secureScoreMetric.GaugeSet(m.prometheus.securitycenterSecureScore)
+		controlResourcesMetric.GaugeSet(m.prometheus.securitycenterSecureScoreControlResources)
+	}
+}
+
 func (m *MetricsCollectorAzureRmSecurity) collectAzureAdvisorRecommendations(ctx context.Context, logger *log.Entry, callback chan<- func(), subscription subscriptions.Subscription) {
 	client := advisor.NewRecommendationsClientWithBaseURI(azureEnvironment.ResourceManagerEndpoint, *subscription.SubscriptionID)
 	client.Authorizer = AzureAuthorizer
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/Azure/azure-sdk-for-go/profiles/latest/resources/mgmt/subscriptions"
+	"github.com/Azure/go-autorest/autorest/to"
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+	prometheusCommon "github.com/webdevops/go-prometheus-common"
+	"strings"
+)
+
+const (
+	// Service Fabric managed clusters are not available in the used Azure SDK version
+	AzureServiceFabricManagedClusterApiVersion = "2021-05-01"
+)
+
+type (
+	MetricsCollectorAzureRmServiceFabric struct {
+		CollectorProcessorGeneral
+
+		prometheus struct {
+			managedCluster         *prometheus.GaugeVec
+			managedClusterNodeType *prometheus.GaugeVec
+		}
+	}
+
+	azureServiceFabricManagedCluster struct {
+		ID       *string            `json:"id"`
+		Name     *string            `json:"name"`
+		Location *string            `json:"location"`
+		Tags     map[string]*string `json:"tags"`
+		Sku      *struct {
+			Name *string `json:"name"`
+		} `json:"sku"`
+		Properties *struct {
+			ClusterState       *string `json:"clusterState"`
+			ClusterUpgradeMode *string `json:"clusterUpgradeMode"`
+			ClusterCodeVersion *string `json:"clusterCodeVersion"`
+			ProvisioningState  *string `json:"provisioningState"`
+		} `json:"properties"`
+	}
+
+	azureServiceFabricManagedClusterNodeType struct {
+		ID         *string `json:"id"`
+		Name       *string `json:"name"`
+		Properties *struct {
+			IsPrimary       *bool   `json:"isPrimary"`
+			VMInstanceCount *int64  `json:"vmInstanceCount"`
+			VMSize          *string `json:"vmSize"`
+		} `json:"properties"`
+	}
+)
+
+func (m *MetricsCollectorAzureRmServiceFabric) Setup(collector *CollectorGeneral) {
+	m.CollectorReference = collector
+
+	m.prometheus.managedCluster = m.newGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_servicefabric_managedcluster_info",
+			Help: "Azure Service Fabric managed cluster information",
+		},
+		append(
+			[]string{
+				"resourceID",
+				"subscriptionID",
+				"resourceGroup",
+				"name",
+				"location",
+				"sku",
+				"clusterState",
+				"upgradeMode",
+				"codeVersion",
+				"provisioningState",
+			},
+			azureResourceTags.prometheusLabels...,
+		),
+	)
+	m.MustRegister(m.prometheus.managedCluster)
+
+	m.prometheus.managedClusterNodeType = m.newGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_servicefabric_managedcluster_nodetype_instances",
+			Help: "Azure Service Fabric managed cluster node type instance count",
+		},
+		[]string{
+			"resourceID",
+			"subscriptionID",
+			"nodeType",
+			"vmSize",
+			"isPrimary",
+		},
+	)
+	m.MustRegister(m.prometheus.managedClusterNodeType)
+}
+
+func (m *MetricsCollectorAzureRmServiceFabric) Reset() {
+	m.prometheus.managedCluster.Reset()
+	m.prometheus.managedClusterNodeType.Reset()
+}
+
+func (m *MetricsCollectorAzureRmServiceFabric) Collect(ctx context.Context, logger *log.Entry, callback chan<- func(), subscription subscriptions.Subscription) {
+	client := NewAzureRestClient(&subscription)
+
+	list, err := client.List(ctx, fmt.Sprintf("/subscriptions/%s/providers/Microsoft.ServiceFabric/managedClusters", *subscription.SubscriptionID), AzureServiceFabricManagedClusterApiVersion)
+	if err != nil {
+		logger.Panic(err)
+	}
+
+	infoMetric := prometheusCommon.NewMetricsList()
+	nodeTypeMetric := prometheusCommon.NewMetricsList()
+
+	for _, row := range list {
+		val := azureServiceFabricManagedCluster{}
+		if err := json.Unmarshal(row, &val); err != nil {
+			logger.Error(err)
+			continue
+		}
+
+		resourceId := toResourceId(val.ID)
+
+		infoLabels := prometheus.Labels{
+			"resourceID":        resourceId,
+			"subscriptionID":    to.String(subscription.SubscriptionID),
+			"resourceGroup":     extractResourceGroupFromAzureId(to.String(val.ID)),
+			"name":              to.String(val.Name),
+			"location":          to.String(val.Location),
+			"sku":               "",
+			"clusterState":      "",
+			"upgradeMode":       "",
+			"codeVersion":       "",
+			"provisioningState": "",
+		}
+
+		if val.Sku != nil {
+			infoLabels["sku"] = to.String(val.Sku.Name)
+		}
+
+		if val.Properties != nil {
+			infoLabels["clusterState"] = to.String(val.Properties.ClusterState)
+			infoLabels["upgradeMode"] = to.String(val.Properties.ClusterUpgradeMode)
+			infoLabels["codeVersion"] = to.String(val.Properties.ClusterCodeVersion)
+			infoLabels["provisioningState"] = strings.ToLower(to.String(val.Properties.ProvisioningState))
+		}
+
+		infoLabels = azureResourceTags.appendPrometheusLabel(infoLabels, val.Tags)
+		infoMetric.AddInfo(infoLabels)
+
+		nodeTypeList, err := client.List(ctx, to.String(val.ID)+"/nodeTypes", AzureServiceFabricManagedClusterApiVersion)
+		if err != nil {
+			logger.WithField("managedCluster", to.String(val.Name)).Error(err)
+			continue
+		}
+
+		for _, nodeTypeRow := range nodeTypeList {
+			nodeType := azureServiceFabricManagedClusterNodeType{}
+			if err := json.Unmarshal(nodeTypeRow, &nodeType); err != nil {
+				logger.Error(err)
+				continue
+			}
+
+			if nodeType.Properties == nil {
+				continue
+			}
+
+			nodeTypeMetric.Add(prometheus.Labels{
+				"resourceID":     resourceId,
+				"subscriptionID": to.String(subscription.SubscriptionID),
+				"nodeType":       to.String(nodeType.Name),
+				"vmSize":         to.String(nodeType.Properties.VMSize),
+				"isPrimary":      boolToString(to.Bool(nodeType.Properties.IsPrimary)),
+			}, float64(to.Int64(nodeType.Properties.VMInstanceCount)))
+		}
+	}
+
+	callback <- func() {
+		infoMetric.GaugeSet(m.prometheus.managedCluster)
+		nodeTypeMetric.GaugeSet(m.prometheus.managedClusterNodeType)
+	}
+}
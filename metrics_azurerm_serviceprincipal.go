@@ -0,0 +1,308 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/Azure/azure-sdk-for-go/profiles/latest/resources/mgmt/subscriptions"
+	"github.com/Azure/azure-sdk-for-go/services/preview/authorization/mgmt/2020-04-01-preview/authorization"
+	"github.com/Azure/go-autorest/autorest"
+	"github.com/Azure/go-autorest/autorest/azure"
+	"github.com/Azure/go-autorest/autorest/azure/auth"
+	"github.com/Azure/go-autorest/autorest/to"
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+	prometheusCommon "github.com/webdevops/go-prometheus-common"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// Microsoft Graph api version, service principal credentials are not available in the (deprecated) Azure AD Graph
+	MicrosoftGraphApiVersion = "v1.0"
+
+	servicePrincipalTypeManagedIdentity = "ManagedIdentity"
+)
+
+type MetricsCollectorAzureRmServicePrincipal struct {
+	CollectorProcessorGeneral
+
+	graphClient autorest.Client
+	graphUrl    string
+
+	prometheus struct {
+		servicePrincipal           *prometheus.GaugeVec
+		servicePrincipalCredential *prometheus.GaugeVec
+	}
+
+	// Microsoft Graph lookups of the current run, shared by the subscriptions (principals with role assignments
+	// in several subscriptions are only fetched once per run)
+	graphCache     map[string]*servicePrincipalGraphLookup
+	graphCacheRun  time.Time
+	graphCacheLock sync.Mutex
+}
+
+type servicePrincipalGraphLookup struct {
+	// closed when the lookup finished, concurrent collections of other subscriptions wait for the result
+	done chan struct{}
+
+	servicePrincipal *microsoftGraphServicePrincipal
+	application      *microsoftGraphApplication
+}
+
+type microsoftGraphServicePrincipal struct {
+	ID                   string                     `json:"id"`
+	AppID                string                     `json:"appId"`
+	DisplayName          string                     `json:"displayName"`
+	ServicePrincipalType string                     `json:"servicePrincipalType"`
+	PasswordCredentials  []microsoftGraphCredential `json:"passwordCredentials"`
+	KeyCredentials       []microsoftGraphCredential `json:"keyCredentials"`
+}
+
+type microsoftGraphApplication struct {
+	ID                  string                     `json:"id"`
+	PasswordCredentials []microsoftGraphCredential `json:"passwordCredentials"`
+	KeyCredentials      []microsoftGraphCredential `json:"keyCredentials"`
+}
+
+type microsoftGraphCredential struct {
+	KeyID       string     `json:"keyId"`
+	DisplayName string     `json:"displayName"`
+	EndDateTime *time.Time `json:"endDateTime"`
+}
+
+func (m *MetricsCollectorAzureRmServicePrincipal) Setup(collector *CollectorGeneral) {
+	m.CollectorReference = collector
+
+	// init Microsoft Graph client
+	graphEndpoint := strings.TrimSuffix(opts.Graph.MicrosoftEndpoint, "/")
+	authorizer, err := auth.NewAuthorizerFromEnvironmentWithResource(graphEndpoint)
+	if err != nil {
+		m.logger().Panic(err)
+	}
+	m.graphClient = autorest.NewClientWithUserAgent("azure-resourcemanager-exporter")
+	m.graphClient.Authorizer = authorizer
+	m.graphClient.ResponseInspector = azureResponseInspector(nil)
+	m.graphUrl = graphEndpoint + "/" + MicrosoftGraphApiVersion
+
+	m.prometheus.servicePrincipal = m.newGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_serviceprincipal_info",
+			Help: "Azure service principal (with role assignments in the subscription) information",
+		},
+		[]string{
+			"subscriptionID",
+			"principalID",
+			"appID",
+			"displayName",
+			"servicePrincipalType",
+		},
+	)
+	m.MustRegister(m.prometheus.servicePrincipal)
+
+	m.prometheus.servicePrincipalCredential = m.newGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_serviceprincipal_credential_expiry",
+			Help: "Azure service principal client secret and certificate expiry timestamp",
+		},
+		[]string{
+			"subscriptionID",
+			"principalID",
+			"appID",
+			"credentialID",
+			"credentialName",
+			"credentialType",
+			"owner",
+		},
+	)
+	m.MustRegister(m.prometheus.servicePrincipalCredential)
+}
+
+// Dependencies uses the service principals with role assignments of the IAM collector
+func (m *MetricsCollectorAzureRmServicePrincipal) Dependencies() []string {
+	return []string{"IAM"}
+}
+
+func (m *MetricsCollectorAzureRmServicePrincipal) Reset() {
+	m.prometheus.servicePrincipal.Reset()
+	m.prometheus.servicePrincipalCredential.Reset()
+}
+
+func (m *MetricsCollectorAzureRmServicePrincipal) Collect(ctx context.Context, logger *log.Entry, callback chan<- func(), subscription subscriptions.Subscription) {
+	infoMetric := prometheusCommon.NewMetricsList()
+	credentialMetric := prometheusCommon.NewMetricsList()
+
+	for _, principalId := range m.servicePrincipalIdList(ctx, logger, subscription) {
+		lookup := m.graphLookup(ctx, logger.WithField("principalID", principalId), principalId)
+		if lookup.servicePrincipal == nil {
+			continue
+		}
+		servicePrincipal := lookup.servicePrincipal
+
+		infoMetric.AddInfo(prometheus.Labels{
+			"subscriptionID":       to.String(subscription.SubscriptionID),
+			"principalID":          servicePrincipal.ID,
+			"appID":                servicePrincipal.AppID,
+			"displayName":          servicePrincipal.DisplayName,
+			"servicePrincipalType": servicePrincipal.ServicePrincipalType,
+		})
+
+		// credentials of managed identities are managed by Azure
+		if servicePrincipal.ServicePrincipalType == servicePrincipalTypeManagedIdentity {
+			continue
+		}
+
+		credentialLabels := func(credential microsoftGraphCredential, credentialType, owner string) prometheus.Labels {
+			return prometheus.Labels{
+				"subscriptionID": to.String(subscription.SubscriptionID),
+				"principalID":    servicePrincipal.ID,
+				"appID":          servicePrincipal.AppID,
+				"credentialID":   credential.KeyID,
+				"credentialName": credential.DisplayName,
+				"credentialType": credentialType,
+				"owner":          owner,
+			}
+		}
+
+		addCredentials := func(passwordCredentials, keyCredentials []microsoftGraphCredential, owner string) {
+			for _, credential := range passwordCredentials {
+				if credential.EndDateTime != nil {
+					credentialMetric.AddTime(credentialLabels(credential, "password", owner), *credential.EndDateTime)
+				}
+			}
+			for _, credential := range keyCredentials {
+				if credential.EndDateTime != nil {
+					credentialMetric.AddTime(credentialLabels(credential, "certificate", owner), *credential.EndDateTime)
+				}
+			}
+		}
+
+		addCredentials(servicePrincipal.PasswordCredentials, servicePrincipal.KeyCredentials, "servicePrincipal")
+
+		if lookup.application != nil {
+			addCredentials(lookup.application.PasswordCredentials, lookup.application.KeyCredentials, "application")
+		}
+	}
+
+	callback <- func() {
+		infoMetric.GaugeSet(m.prometheus.servicePrincipal)
+		credentialMetric.GaugeSet(m.prometheus.servicePrincipalCredential)
+	}
+}
+
+// servicePrincipalIdList returns the service principals with role assignments in the subscription shared by the
+// IAM collector (collector store) or lists the role assignments
+func (m *MetricsCollectorAzureRmServicePrincipal) servicePrincipalIdList(ctx context.Context, logger *log.Entry, subscription subscriptions.Subscription) []string {
+	if storeList, exists := collectorStore.Get(collectorStoreServicePrincipals, *subscription.SubscriptionID); exists {
+		return storeList.([]string)
+	}
+
+	client := authorization.NewRoleAssignmentsClientWithBaseURI(azureEnvironment.ResourceManagerEndpoint, *subscription.SubscriptionID)
+	client.Authorizer = AzureAuthorizer
+	client.ResponseInspector = azureResponseInspector(&subscription)
+
+	list, err := client.ListComplete(ctx, "", "")
+	if err != nil {
+		logger.Panic(err)
+	}
+
+	principalIdList := []string{}
+	principalIdMap := map[string]bool{}
+	for list.NotDone() {
+		val := list.Value()
+
+		principalId := to.String(val.PrincipalID)
+		if val.PrincipalType == authorization.ServicePrincipal && !principalIdMap[principalId] {
+			principalIdMap[principalId] = true
+			principalIdList = append(principalIdList, principalId)
+		}
+
+		if list.NextWithContext(ctx) != nil {
+			break
+		}
+	}
+
+	return principalIdList
+}
+
+// graphLookup fetches the service principal and its app registration once per run, failed and not found lookups
+// are cached as well (servicePrincipal or application is nil)
+func (m *MetricsCollectorAzureRmServicePrincipal) graphLookup(ctx context.Context, logger *log.Entry, principalId string) *servicePrincipalGraphLookup {
+	m.graphCacheLock.Lock()
+	if m.graphCache == nil || !m.graphCacheRun.Equal(m.CollectorReference.collectionStartTime) {
+		m.graphCache = map[string]*servicePrincipalGraphLookup{}
+		m.graphCacheRun = m.CollectorReference.collectionStartTime
+	}
+	lookup, exists := m.graphCache[principalId]
+	if !exists {
+		lookup = &servicePrincipalGraphLookup{done: make(chan struct{})}
+		m.graphCache[principalId] = lookup
+	}
+	m.graphCacheLock.Unlock()
+
+	if exists {
+		select {
+		case <-lookup.done:
+			return lookup
+		case <-ctx.Done():
+			return &servicePrincipalGraphLookup{}
+		}
+	}
+	defer close(lookup.done)
+
+	servicePrincipal := microsoftGraphServicePrincipal{}
+	err := m.graphGet(ctx, "/servicePrincipals/"+url.PathEscape(principalId), "id,appId,displayName,servicePrincipalType,passwordCredentials,keyCredentials", &servicePrincipal)
+	if err != nil {
+		if !azureRestIsNotFound(err) {
+			logger.Error(err)
+		}
+		return lookup
+	}
+	lookup.servicePrincipal = &servicePrincipal
+
+	// credentials of managed identities are managed by Azure
+	if servicePrincipal.ServicePrincipalType == servicePrincipalTypeManagedIdentity {
+		return lookup
+	}
+
+	// client secrets are usually added to the app registration, apps of other tenants are not found
+	application := microsoftGraphApplication{}
+	err = m.graphGet(ctx, fmt.Sprintf("/applications(appId='%s')", url.PathEscape(servicePrincipal.AppID)), "id,passwordCredentials,keyCredentials", &application)
+	if err != nil {
+		if !azureRestIsNotFound(err) {
+			logger.Error(err)
+		}
+		return lookup
+	}
+	lookup.application = &application
+
+	return lookup
+}
+
+// graphGet fetches one Microsoft Graph object (only the selected properties) and unmarshals it into result
+func (m *MetricsCollectorAzureRmServicePrincipal) graphGet(ctx context.Context, path string, selectProperties string, result interface{}) error {
+	req, err := autorest.Prepare(
+		(&http.Request{}).WithContext(ctx),
+		autorest.AsGet(),
+		autorest.WithBaseURL(m.graphUrl),
+		autorest.WithPath(path),
+		autorest.WithQueryParameters(map[string]interface{}{"$select": selectProperties}),
+	)
+	if err != nil {
+		return err
+	}
+
+	resp, err := m.graphClient.Send(req)
+	if err != nil {
+		return err
+	}
+
+	return autorest.Respond(
+		resp,
+		azure.WithErrorUnlessStatusCode(http.StatusOK),
+		autorest.ByUnmarshallingJSON(result),
+		autorest.ByClosing(),
+	)
+}
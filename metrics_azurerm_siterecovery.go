@@ -0,0 +1,243 @@
+package main
+
+import (
+	"context"
+	"github.com/Azure/azure-sdk-for-go/profiles/latest/recoveryservices/mgmt/recoveryservices"
+	"github.com/Azure/azure-sdk-for-go/profiles/latest/recoveryservices/mgmt/siterecovery"
+	"github.com/Azure/azure-sdk-for-go/profiles/latest/resources/mgmt/subscriptions"
+	"github.com/Azure/go-autorest/autorest/to"
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+	prometheusCommon "github.com/webdevops/go-prometheus-common"
+	"strings"
+)
+
+const (
+	// replication and failover health of a replicated item without warnings or critical errors
+	siteRecoveryHealthNormal = "Normal"
+)
+
+type MetricsCollectorAzureRmSiteRecovery struct {
+	CollectorProcessorGeneral
+
+	prometheus struct {
+		itemInfo   *prometheus.GaugeVec
+		itemRpo    *prometheus.GaugeVec
+		itemStatus *prometheus.GaugeVec
+	}
+}
+
+func (m *MetricsCollectorAzureRmSiteRecovery) Setup(collector *CollectorGeneral) {
+	m.CollectorReference = collector
+
+	m.prometheus.itemInfo = m.newGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_siterecovery_item_info",
+			Help: "Azure Site Recovery replicated item information",
+		},
+		[]string{
+			"resourceID",
+			"subscriptionID",
+			"resourceGroup",
+			"vaultName",
+			"itemName",
+			"protectedItemType",
+			"replicationProvider",
+			"primaryFabric",
+			"recoveryFabric",
+			"policyName",
+			"activeLocation",
+			"protectionState",
+			"replicationHealth",
+			"failoverHealth",
+			"testFailoverState",
+		},
+	)
+	m.MustRegister(m.prometheus.itemInfo)
+
+	m.prometheus.itemRpo = m.newGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_siterecovery_item_rpo_seconds",
+			Help: "Azure Site Recovery replicated item current recovery point objective in seconds",
+		},
+		[]string{
+			"resourceID",
+			"subscriptionID",
+		},
+	)
+	m.MustRegister(m.prometheus.itemRpo)
+
+	m.prometheus.itemStatus = m.newGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_siterecovery_item_status",
+			Help: "Azure Site Recovery replicated item status (replication health, failover readiness, health errors and last failovers)",
+		},
+		[]string{
+			"resourceID",
+			"subscriptionID",
+			"type",
+		},
+	)
+	m.MustRegister(m.prometheus.itemStatus)
+}
+
+func (m *MetricsCollectorAzureRmSiteRecovery) Reset() {
+	m.prometheus.itemInfo.Reset()
+	m.prometheus.itemRpo.Reset()
+	m.prometheus.itemStatus.Reset()
+}
+
+func (m *MetricsCollectorAzureRmSiteRecovery) Collect(ctx context.Context, logger *log.Entry, callback chan<- func(), subscription subscriptions.Subscription) {
+	vaultClient := recoveryservices.NewVaultsClientWithBaseURI(azureEnvironment.ResourceManagerEndpoint, *subscription.SubscriptionID)
+	vaultClient.Authorizer = AzureAuthorizer
+	vaultClient.ResponseInspector = azureResponseInspector(&subscription)
+
+	vaultList, err := vaultClient.ListBySubscriptionIDComplete(ctx)
+	if err != nil {
+		logger.Panic(err)
+	}
+
+	infoMetric := prometheusCommon.NewMetricsList()
+	rpoMetric := prometheusCommon.NewMetricsList()
+	statusMetric := prometheusCommon.NewMetricsList()
+
+	for vaultList.NotDone() {
+		vault := vaultList.Value()
+		vaultName := to.String(vault.Name)
+		resourceGroup := extractResourceGroupFromAzureId(to.String(vault.ID))
+
+		// vaults without Site Recovery (eg. backup only) return an empty list
+		itemClient := siterecovery.NewReplicationProtectedItemsClientWithBaseURI(azureEnvironment.ResourceManagerEndpoint, *subscription.SubscriptionID, resourceGroup, vaultName)
+		itemClient.Authorizer = AzureAuthorizer
+		itemClient.ResponseInspector = azureResponseInspector(&subscription)
+
+		list, err := itemClient.ListComplete(ctx, "", "")
+		if err != nil {
+			logger.WithField("vault", vaultName).Error(err)
+		} else {
+			for list.NotDone() {
+				val := list.Value()
+
+				if item := val.Properties; item != nil {
+					resourceId := toResourceId(val.ID)
+					replicationProvider, rpoSeconds := siteRecoveryProviderDetails(item.ProviderSpecificDetails)
+
+					infoMetric.AddInfo(prometheus.Labels{
+						"resourceID":          resourceId,
+						"subscriptionID":      to.String(subscription.SubscriptionID),
+						"resourceGroup":       resourceGroup,
+						"vaultName":           vaultName,
+						"itemName":            to.String(item.FriendlyName),
+						"protectedItemType":   to.String(item.ProtectedItemType),
+						"replicationProvider": replicationProvider,
+						"primaryFabric":       to.String(item.PrimaryFabricFriendlyName),
+						"recoveryFabric":      to.String(item.RecoveryFabricFriendlyName),
+						"policyName":          to.String(item.PolicyFriendlyName),
+						"activeLocation":      to.String(item.ActiveLocation),
+						"protectionState":     to.String(item.ProtectionState),
+						"replicationHealth":   to.String(item.ReplicationHealth),
+						"failoverHealth":      to.String(item.FailoverHealth),
+						"testFailoverState":   to.String(item.TestFailoverState),
+					})
+
+					if rpoSeconds != nil {
+						rpoMetric.Add(prometheus.Labels{
+							"resourceID":     resourceId,
+							"subscriptionID": to.String(subscription.SubscriptionID),
+						}, float64(*rpoSeconds))
+					}
+
+					statusMetric.AddBool(prometheus.Labels{
+						"resourceID":     resourceId,
+						"subscriptionID": to.String(subscription.SubscriptionID),
+						"type":           "replicationHealthy",
+					}, strings.EqualFold(to.String(item.ReplicationHealth), siteRecoveryHealthNormal))
+
+					statusMetric.AddBool(prometheus.Labels{
+						"resourceID":     resourceId,
+						"subscriptionID": to.String(subscription.SubscriptionID),
+						"type":           "failoverReady",
+					}, strings.EqualFold(to.String(item.FailoverHealth), siteRecoveryHealthNormal))
+
+					healthErrorCount := 0
+					if item.HealthErrors != nil {
+						healthErrorCount = len(*item.HealthErrors)
+					}
+					statusMetric.Add(prometheus.Labels{
+						"resourceID":     resourceId,
+						"subscriptionID": to.String(subscription.SubscriptionID),
+						"type":           "healthErrors",
+					}, float64(healthErrorCount))
+
+					if item.LastSuccessfulFailoverTime != nil {
+						statusMetric.AddTime(prometheus.Labels{
+							"resourceID":     resourceId,
+							"subscriptionID": to.String(subscription.SubscriptionID),
+							"type":           "lastSuccessfulFailover",
+						}, item.LastSuccessfulFailoverTime.ToTime())
+					}
+
+					if item.LastSuccessfulTestFailoverTime != nil {
+						statusMetric.AddTime(prometheus.Labels{
+							"resourceID":     resourceId,
+							"subscriptionID": to.String(subscription.SubscriptionID),
+							"type":           "lastSuccessfulTestFailover",
+						}, item.LastSuccessfulTestFailoverTime.ToTime())
+					}
+				}
+
+				if list.NextWithContext(ctx) != nil {
+					break
+				}
+			}
+		}
+
+		if vaultList.NextWithContext(ctx) != nil {
+			break
+		}
+	}
+
+	callback <- func() {
+		infoMetric.GaugeSet(m.prometheus.itemInfo)
+		rpoMetric.GaugeSet(m.prometheus.itemRpo)
+		statusMetric.GaugeSet(m.prometheus.itemStatus)
+	}
+}
+
+// siteRecoveryProviderDetails returns the replication provider (eg. A2A for Azure to Azure) and the current RPO,
+// the RPO is only reported by the Azure (A2A), Hyper-V to Azure and VMware/physical to Azure providers
+func siteRecoveryProviderDetails(details siterecovery.BasicReplicationProviderSpecificSettings) (string, *int64) {
+	if details == nil {
+		return "", nil
+	}
+
+	if val, ok := details.AsA2AReplicationDetails(); ok {
+		return string(val.InstanceType), val.RpoInSeconds
+	}
+	if val, ok := details.AsHyperVReplicaAzureReplicationDetails(); ok {
+		return string(val.InstanceType), val.RpoInSeconds
+	}
+	if val, ok := details.AsInMageAzureV2ReplicationDetails(); ok {
+		return string(val.InstanceType), val.RpoInSeconds
+	}
+	if val, ok := details.AsInMageRcmReplicationDetails(); ok {
+		return string(val.InstanceType), val.LastRpoInSeconds
+	}
+	if val, ok := details.AsInMageReplicationDetails(); ok {
+		return string(val.InstanceType), nil
+	}
+	if val, ok := details.AsHyperVReplicaReplicationDetails(); ok {
+		return string(val.InstanceType), nil
+	}
+	if val, ok := details.AsHyperVReplicaBlueReplicationDetails(); ok {
+		return string(val.InstanceType), nil
+	}
+	if val, ok := details.AsHyperVReplicaBaseReplicationDetails(); ok {
+		return string(val.InstanceType), nil
+	}
+	if val, ok := details.AsReplicationProviderSpecificSettings(); ok {
+		return string(val.InstanceType), nil
+	}
+
+	return "", nil
+}
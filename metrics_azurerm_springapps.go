@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"github.com/Azure/azure-sdk-for-go/profiles/latest/appplatform/mgmt/appplatform"
+	"github.com/Azure/azure-sdk-for-go/profiles/latest/resources/mgmt/subscriptions"
+	"github.com/Azure/go-autorest/autorest/to"
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+	prometheusCommon "github.com/webdevops/go-prometheus-common"
+	"strings"
+)
+
+type MetricsCollectorAzureRmSpringApps struct {
+	CollectorProcessorGeneral
+
+	prometheus struct {
+		service  *prometheus.GaugeVec
+		appCount *prometheus.GaugeVec
+	}
+}
+
+func (m *MetricsCollectorAzureRmSpringApps) Setup(collector *CollectorGeneral) {
+	m.CollectorReference = collector
+
+	m.prometheus.service = m.newGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_springapps_info",
+			Help: "Azure Spring Apps instance information",
+		},
+		append(
+			[]string{
+				"resourceID",
+				"subscriptionID",
+				"resourceGroup",
+				"name",
+				"location",
+				"skuName",
+				"skuTier",
+				"provisioningState",
+			},
+			azureResourceTags.prometheusLabels...,
+		),
+	)
+	m.MustRegister(m.prometheus.service)
+
+	m.prometheus.appCount = m.newGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_springapps_app_count",
+			Help: "Azure Spring Apps instance app count",
+		},
+		[]string{
+			"resourceID",
+			"subscriptionID",
+		},
+	)
+	m.MustRegister(m.prometheus.appCount)
+}
+
+func (m *MetricsCollectorAzureRmSpringApps) Reset() {
+	m.prometheus.service.Reset()
+	m.prometheus.appCount.Reset()
+}
+
+func (m *MetricsCollectorAzureRmSpringApps) Collect(ctx context.Context, logger *log.Entry, callback chan<- func(), subscription subscriptions.Subscription) {
+	client := appplatform.NewServicesClientWithBaseURI(azureEnvironment.ResourceManagerEndpoint, *subscription.SubscriptionID)
+	client.Authorizer = AzureAuthorizer
+	client.ResponseInspector = azureResponseInspector(&subscription)
+
+	appClient := appplatform.NewAppsClientWithBaseURI(azureEnvironment.ResourceManagerEndpoint, *subscription.SubscriptionID)
+	appClient.Authorizer = AzureAuthorizer
+	appClient.ResponseInspector = azureResponseInspector(&subscription)
+
+	list, err := client.ListBySubscriptionComplete(ctx)
+	if err != nil {
+		logger.Panic(err)
+	}
+
+	infoMetric := prometheusCommon.NewMetricsList()
+	appCountMetric := prometheusCommon.NewMetricsList()
+
+	for list.NotDone() {
+		val := list.Value()
+		resourceId := toResourceId(val.ID)
+		resourceGroup := extractResourceGroupFromAzureId(to.String(val.ID))
+
+		infoLabels := prometheus.Labels{
+			"resourceID":        resourceId,
+			"subscriptionID":    to.String(subscription.SubscriptionID),
+			"resourceGroup":     resourceGroup,
+			"name":              to.String(val.Name),
+			"location":          to.String(val.Location),
+			"skuName":           "",
+			"skuTier":           "",
+			"provisioningState": "",
+		}
+
+		if val.Sku != nil {
+			infoLabels["skuName"] = to.String(val.Sku.Name)
+			infoLabels["skuTier"] = to.String(val.Sku.Tier)
+		}
+
+		if val.Properties != nil {
+			infoLabels["provisioningState"] = strings.ToLower(string(val.Properties.ProvisioningState))
+		}
+
+		infoLabels = azureResourceTags.appendPrometheusLabel(infoLabels, val.Tags)
+		infoMetric.AddInfo(infoLabels)
+
+		appList, err := appClient.ListComplete(ctx, resourceGroup, to.String(val.Name))
+		if err != nil {
+			logger.WithField("springApps", to.String(val.Name)).Error(err)
+		} else {
+			appCount := 0
+			for appList.NotDone() {
+				appCount++
+
+				if appList.NextWithContext(ctx) != nil {
+					break
+				}
+			}
+
+			appCountMetric.Add(prometheus.Labels{
+				"resourceID":     resourceId,
+				"subscriptionID": to.String(subscription.SubscriptionID),
+			}, float64(appCount))
+		}
+
+		if list.NextWithContext(ctx) != nil {
+			break
+		}
+	}
+
+	callback <- func() {
+		infoMetric.GaugeSet(m.prometheus.service)
+		appCountMetric.GaugeSet(m.prometheus.appCount)
+	}
+}
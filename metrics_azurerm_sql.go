@@ -0,0 +1,216 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/Azure/azure-sdk-for-go/profiles/latest/resources/mgmt/subscriptions"
+	"github.com/Azure/go-autorest/autorest/to"
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+	prometheusCommon "github.com/webdevops/go-prometheus-common"
+	"strings"
+)
+
+const (
+	// backup retention policies are not available in the used Azure SDK version
+	AzureSqlApiVersion = "2021-11-01"
+)
+
+type MetricsCollectorAzureRmSql struct {
+	CollectorProcessorGeneral
+
+	prometheus struct {
+		database                *prometheus.GaugeVec
+		databaseBackupRetention *prometheus.GaugeVec
+		databaseLtrConfigured   *prometheus.GaugeVec
+	}
+}
+
+// azureSqlResource is a SQL server or database
+type azureSqlResource struct {
+	ID       string             `json:"id"`
+	Name     string             `json:"name"`
+	Location string             `json:"location"`
+	Tags     map[string]*string `json:"tags"`
+	Sku      *struct {
+		Name string `json:"name"`
+		Tier string `json:"tier"`
+	} `json:"sku"`
+}
+
+// azureSqlBackupRetentionPolicy is a short-term or long-term backup retention policy of a database
+type azureSqlBackupRetentionPolicy struct {
+	Properties struct {
+		RetentionDays    *int   `json:"retentionDays"`
+		WeeklyRetention  string `json:"weeklyRetention"`
+		MonthlyRetention string `json:"monthlyRetention"`
+		YearlyRetention  string `json:"yearlyRetention"`
+	} `json:"properties"`
+}
+
+func (m *MetricsCollectorAzureRmSql) Setup(collector *CollectorGeneral) {
+	m.CollectorReference = collector
+
+	m.prometheus.database = m.newGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_sql_database_info",
+			Help: "Azure SQL database information",
+		},
+		append(
+			[]string{
+				"resourceID",
+				"subscriptionID",
+				"resourceGroup",
+				"server",
+				"name",
+				"location",
+				"skuName",
+				"skuTier",
+			},
+			azureResourceTags.prometheusLabels...,
+		),
+	)
+	m.MustRegister(m.prometheus.database)
+
+	m.prometheus.databaseBackupRetention = m.newGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_sql_database_backup_retention_days",
+			Help: "Azure SQL database short-term backup retention (point in time restore) in days",
+		},
+		[]string{
+			"resourceID",
+			"subscriptionID",
+		},
+	)
+	m.MustRegister(m.prometheus.databaseBackupRetention)
+
+	m.prometheus.databaseLtrConfigured = m.newGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_sql_database_ltr_configured",
+			Help: "Azure SQL database long-term backup retention is configured (1 if any of weekly, monthly or yearly retention is set)",
+		},
+		[]string{
+			"resourceID",
+			"subscriptionID",
+			"weeklyRetention",
+			"monthlyRetention",
+			"yearlyRetention",
+		},
+	)
+	m.MustRegister(m.prometheus.databaseLtrConfigured)
+}
+
+func (m *MetricsCollectorAzureRmSql) Reset() {
+	m.prometheus.database.Reset()
+	m.prometheus.databaseBackupRetention.Reset()
+	m.prometheus.databaseLtrConfigured.Reset()
+}
+
+// Collect Azure SQL databases with short-term and long-term backup retention policies
+func (m *MetricsCollectorAzureRmSql) Collect(ctx context.Context, logger *log.Entry, callback chan<- func(), subscription subscriptions.Subscription) {
+	client := NewAzureRestClient(&subscription)
+
+	serverList, err := client.List(ctx, fmt.Sprintf("/subscriptions/%s/providers/Microsoft.Sql/servers", *subscription.SubscriptionID), AzureSqlApiVersion)
+	if err != nil {
+		logger.Panic(err)
+	}
+
+	infoMetric := prometheusCommon.NewMetricsList()
+	backupRetentionMetric := prometheusCommon.NewMetricsList()
+	ltrConfiguredMetric := prometheusCommon.NewMetricsList()
+
+	for _, serverRow := range serverList {
+		server := azureSqlResource{}
+		if err := json.Unmarshal(serverRow, &server); err != nil {
+			logger.Error(err)
+			continue
+		}
+
+		serverLogger := logger.WithField("sqlServer", server.Name)
+
+		databaseList, err := client.List(ctx, server.ID+"/databases", AzureSqlApiVersion)
+		if err != nil {
+			serverLogger.Error(err)
+			continue
+		}
+
+		for _, databaseRow := range databaseList {
+			database := azureSqlResource{}
+			if err := json.Unmarshal(databaseRow, &database); err != nil {
+				serverLogger.Error(err)
+				continue
+			}
+
+			// master database is managed by Azure and has no backup retention policies
+			if strings.EqualFold(database.Name, "master") {
+				continue
+			}
+
+			resourceId := toResourceId(&database.ID)
+
+			infoLabels := prometheus.Labels{
+				"resourceID":     resourceId,
+				"subscriptionID": to.String(subscription.SubscriptionID),
+				"resourceGroup":  extractResourceGroupFromAzureId(database.ID),
+				"server":         server.Name,
+				"name":           database.Name,
+				"location":       strings.ToLower(database.Location),
+				"skuName":        "",
+				"skuTier":        "",
+			}
+			if database.Sku != nil {
+				infoLabels["skuName"] = database.Sku.Name
+				infoLabels["skuTier"] = database.Sku.Tier
+			}
+			infoLabels = azureResourceTags.appendPrometheusLabel(infoLabels, database.Tags)
+			infoMetric.AddInfo(infoLabels)
+
+			shortTermPolicy := azureSqlBackupRetentionPolicy{}
+			if err := client.Get(ctx, database.ID+"/backupShortTermRetentionPolicies/default", AzureSqlApiVersion, &shortTermPolicy); err != nil {
+				serverLogger.WithField("sqlDatabase", database.Name).Error(err)
+			} else if shortTermPolicy.Properties.RetentionDays != nil {
+				backupRetentionMetric.Add(prometheus.Labels{
+					"resourceID":     resourceId,
+					"subscriptionID": to.String(subscription.SubscriptionID),
+				}, float64(*shortTermPolicy.Properties.RetentionDays))
+			}
+
+			longTermPolicy := azureSqlBackupRetentionPolicy{}
+			if err := client.Get(ctx, database.ID+"/backupLongTermRetentionPolicies/default", AzureSqlApiVersion, &longTermPolicy); err != nil {
+				serverLogger.WithField("sqlDatabase", database.Name).Error(err)
+			} else {
+				ltrLabels := prometheus.Labels{
+					"resourceID":       resourceId,
+					"subscriptionID":   to.String(subscription.SubscriptionID),
+					"weeklyRetention":  sqlRetentionValue(longTermPolicy.Properties.WeeklyRetention),
+					"monthlyRetention": sqlRetentionValue(longTermPolicy.Properties.MonthlyRetention),
+					"yearlyRetention":  sqlRetentionValue(longTermPolicy.Properties.YearlyRetention),
+				}
+
+				ltrConfigured := ltrLabels["weeklyRetention"] != "" || ltrLabels["monthlyRetention"] != "" || ltrLabels["yearlyRetention"] != ""
+				if ltrConfigured {
+					ltrConfiguredMetric.Add(ltrLabels, 1)
+				} else {
+					ltrConfiguredMetric.Add(ltrLabels, 0)
+				}
+			}
+		}
+	}
+
+	callback <- func() {
+		infoMetric.GaugeSet(m.prometheus.database)
+		backupRetentionMetric.GaugeSet(m.prometheus.databaseBackupRetention)
+		ltrConfiguredMetric.GaugeSet(m.prometheus.databaseLtrConfigured)
+	}
+}
+
+// sqlRetentionValue returns the ISO8601 retention (eg. P4W), disabled retentions (PT0S) are returned as empty string
+func sqlRetentionValue(val string) string {
+	switch strings.ToUpper(val) {
+	case "", "PT0S", "P0D":
+		return ""
+	default:
+		return val
+	}
+}
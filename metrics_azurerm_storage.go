@@ -0,0 +1,426 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/Azure/azure-sdk-for-go/profiles/latest/resources/mgmt/subscriptions"
+	"github.com/Azure/azure-sdk-for-go/profiles/latest/storage/mgmt/storage"
+	"github.com/Azure/go-autorest/autorest/to"
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+	prometheusCommon "github.com/webdevops/go-prometheus-common"
+	"net/http"
+	"strings"
+)
+
+type MetricsCollectorAzureRmStorage struct {
+	CollectorProcessorGeneral
+
+	prometheus struct {
+		storageAccountLifecyclePolicy      *prometheus.GaugeVec
+		storageAccountLifecyclePolicyRules *prometheus.GaugeVec
+
+		fileShare           *prometheus.GaugeVec
+		fileShareQuota      *prometheus.GaugeVec
+		fileShareUsageBytes *prometheus.GaugeVec
+
+		replication                *prometheus.GaugeVec
+		replicationLastSync        *prometheus.GaugeVec
+		replicationLastGeoFailover *prometheus.GaugeVec
+	}
+}
+
+func (m *MetricsCollectorAzureRmStorage) Setup(collector *CollectorGeneral) {
+	m.CollectorReference = collector
+
+	m.prometheus.storageAccountLifecyclePolicy = m.newGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_storageaccount_lifecyclepolicy",
+			Help: "Azure StorageAccount has a lifecycle management policy (1 if policy exists)",
+		},
+		[]string{
+			"resourceID",
+			"subscriptionID",
+			"resourceGroup",
+			"name",
+			"kind",
+		},
+	)
+	m.MustRegister(m.prometheus.storageAccountLifecyclePolicy)
+
+	m.prometheus.storageAccountLifecyclePolicyRules = m.newGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_storageaccount_lifecyclepolicy_rules",
+			Help: "Azure StorageAccount lifecycle management policy rule count",
+		},
+		[]string{
+			"resourceID",
+			"subscriptionID",
+			"enabled",
+		},
+	)
+	m.MustRegister(m.prometheus.storageAccountLifecyclePolicyRules)
+
+	m.prometheus.fileShare = m.newGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_storageaccount_fileshare_info",
+			Help: "Azure Files share information",
+		},
+		[]string{
+			"resourceID",
+			"subscriptionID",
+			"resourceGroup",
+			"storageAccount",
+			"name",
+			"accessTier",
+			"enabledProtocols",
+		},
+	)
+	m.MustRegister(m.prometheus.fileShare)
+
+	m.prometheus.fileShareQuota = m.newGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_storageaccount_fileshare_quota_gb",
+			Help: "Azure Files share provisioned quota in GiB",
+		},
+		[]string{
+			"resourceID",
+			"subscriptionID",
+		},
+	)
+	m.MustRegister(m.prometheus.fileShareQuota)
+
+	m.prometheus.fileShareUsageBytes = m.newGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_storageaccount_fileshare_usage_bytes",
+			Help: "Azure Files share usage in bytes",
+		},
+		[]string{
+			"resourceID",
+			"subscriptionID",
+		},
+	)
+	m.MustRegister(m.prometheus.fileShareUsageBytes)
+
+	m.prometheus.replication = m.newGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_storageaccount_replication_info",
+			Help: "Azure StorageAccount replication information",
+		},
+		[]string{
+			"resourceID",
+			"subscriptionID",
+			"resourceGroup",
+			"name",
+			"skuName",
+			"replicationType",
+			"primaryLocation",
+			"secondaryLocation",
+			"statusOfPrimary",
+			"statusOfSecondary",
+			"failoverInProgress",
+		},
+	)
+	m.MustRegister(m.prometheus.replication)
+
+	m.prometheus.replicationLastSync = m.newGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_storageaccount_geo_lastsync",
+			Help: "Azure StorageAccount geo replication last sync time (writes before are available on the secondary)",
+		},
+		[]string{
+			"resourceID",
+			"subscriptionID",
+			"status",
+			"canFailover",
+		},
+	)
+	m.MustRegister(m.prometheus.replicationLastSync)
+
+	m.prometheus.replicationLastGeoFailover = m.newGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_storageaccount_geo_failover_last",
+			Help: "Azure StorageAccount last geo failover time",
+		},
+		[]string{
+			"resourceID",
+			"subscriptionID",
+		},
+	)
+	m.MustRegister(m.prometheus.replicationLastGeoFailover)
+}
+
+func (m *MetricsCollectorAzureRmStorage) Reset() {
+	m.prometheus.storageAccountLifecyclePolicy.Reset()
+	m.prometheus.storageAccountLifecyclePolicyRules.Reset()
+	m.prometheus.fileShare.Reset()
+	m.prometheus.fileShareQuota.Reset()
+	m.prometheus.fileShareUsageBytes.Reset()
+	m.prometheus.replication.Reset()
+	m.prometheus.replicationLastSync.Reset()
+	m.prometheus.replicationLastGeoFailover.Reset()
+}
+
+func (m *MetricsCollectorAzureRmStorage) Collect(ctx context.Context, logger *log.Entry, callback chan<- func(), subscription subscriptions.Subscription) {
+	m.collectLifecyclePolicies(ctx, logger, callback, subscription)
+	m.collectReplication(ctx, logger, callback, subscription)
+
+	if opts.Storage.FileShares {
+		m.collectFileShares(ctx, logger, callback, subscription)
+	}
+}
+
+// Collect storage account lifecycle management policies
+func (m *MetricsCollectorAzureRmStorage) collectLifecyclePolicies(ctx context.Context, logger *log.Entry, callback chan<- func(), subscription subscriptions.Subscription) {
+	accountClient := storage.NewAccountsClientWithBaseURI(azureEnvironment.ResourceManagerEndpoint, *subscription.SubscriptionID)
+	accountClient.Authorizer = AzureAuthorizer
+	accountClient.ResponseInspector = azureResponseInspector(&subscription)
+
+	policyClient := storage.NewManagementPoliciesClientWithBaseURI(azureEnvironment.ResourceManagerEndpoint, *subscription.SubscriptionID)
+	policyClient.Authorizer = AzureAuthorizer
+	policyClient.ResponseInspector = azureResponseInspector(&subscription)
+
+	accountList, err := accountClient.ListComplete(ctx)
+	if err != nil {
+		logger.Panic(err)
+	}
+
+	policyMetric := prometheusCommon.NewMetricsList()
+	policyRulesMetric := prometheusCommon.NewMetricsList()
+
+	for accountList.NotDone() {
+		account := accountList.Value()
+		accountName := to.String(account.Name)
+		resourceGroup := extractResourceGroupFromAzureId(to.String(account.ID))
+		resourceId := toResourceId(account.ID)
+
+		// lifecycle management is only supported for blob storage capable accounts
+		switch account.Kind {
+		case storage.KindStorageV2, storage.KindBlobStorage, storage.KindBlockBlobStorage:
+			policy, err := policyClient.Get(ctx, resourceGroup, accountName)
+			policyExists := false
+			if err != nil {
+				if policy.Response.Response == nil || policy.Response.StatusCode != http.StatusNotFound {
+					logger.WithField("storageAccount", accountName).Error(err)
+					break
+				}
+			} else {
+				policyExists = true
+			}
+
+			policyLabels := prometheus.Labels{
+				"resourceID":     resourceId,
+				"subscriptionID": to.String(subscription.SubscriptionID),
+				"resourceGroup":  resourceGroup,
+				"name":           accountName,
+				"kind":           string(account.Kind),
+			}
+
+			if policyExists {
+				policyMetric.Add(policyLabels, 1)
+			} else {
+				policyMetric.Add(policyLabels, 0)
+			}
+
+			ruleCount := map[bool]float64{true: 0, false: 0}
+			if policyExists && policy.ManagementPolicyProperties != nil && policy.Policy != nil && policy.Policy.Rules != nil {
+				for _, rule := range *policy.Policy.Rules {
+					// rules are enabled by default
+					ruleCount[rule.Enabled == nil || *rule.Enabled]++
+				}
+			}
+
+			for enabled, count := range ruleCount {
+				policyRulesMetric.Add(prometheus.Labels{
+					"resourceID":     resourceId,
+					"subscriptionID": to.String(subscription.SubscriptionID),
+					"enabled":        boolToString(enabled),
+				}, count)
+			}
+		}
+
+		if accountList.NextWithContext(ctx) != nil {
+			break
+		}
+	}
+
+	callback <- func() {
+		policyMetric.GaugeSet(m.prometheus.storageAccountLifecyclePolicy)
+		policyRulesMetric.GaugeSet(m.prometheus.storageAccountLifecyclePolicyRules)
+	}
+}
+
+// Collect Azure Files shares with access tier, quota and usage (--storage-fileshares)
+func (m *MetricsCollectorAzureRmStorage) collectFileShares(ctx context.Context, logger *log.Entry, callback chan<- func(), subscription subscriptions.Subscription) {
+	accountClient := storage.NewAccountsClientWithBaseURI(azureEnvironment.ResourceManagerEndpoint, *subscription.SubscriptionID)
+	accountClient.Authorizer = AzureAuthorizer
+	accountClient.ResponseInspector = azureResponseInspector(&subscription)
+
+	client := NewAzureRestClient(&subscription)
+
+	accountList, err := accountClient.ListComplete(ctx)
+	if err != nil {
+		logger.Panic(err)
+	}
+
+	infoMetric := prometheusCommon.NewMetricsList()
+	quotaMetric := prometheusCommon.NewMetricsList()
+	usageMetric := prometheusCommon.NewMetricsList()
+
+	for accountList.NotDone() {
+		account := accountList.Value()
+		accountName := to.String(account.Name)
+
+		// file shares are only supported by general purpose and file storage accounts
+		switch account.Kind {
+		case storage.KindStorage, storage.KindStorageV2, storage.KindFileStorage:
+			shareList, err := client.List(ctx, to.String(account.ID)+"/fileServices/default/shares", AzureFileShareApiVersion)
+			if err != nil {
+				logger.WithField("storageAccount", accountName).Error(err)
+				break
+			}
+
+			for _, shareRow := range shareList {
+				share := azureFileShare{}
+				if err := json.Unmarshal(shareRow, &share); err != nil {
+					logger.Error(err)
+					continue
+				}
+
+				// usage is only returned with stats
+				if err := client.GetWithQuery(ctx, to.String(share.ID), AzureFileShareApiVersion, map[string]interface{}{"$expand": "stats"}, &share); err != nil {
+					logger.WithField("fileShare", to.String(share.ID)).Error(err)
+					continue
+				}
+
+				if share.Properties == nil {
+					continue
+				}
+
+				resourceId := toResourceId(share.ID)
+
+				infoMetric.AddInfo(prometheus.Labels{
+					"resourceID":       resourceId,
+					"subscriptionID":   to.String(subscription.SubscriptionID),
+					"resourceGroup":    extractResourceGroupFromAzureId(to.String(share.ID)),
+					"storageAccount":   accountName,
+					"name":             to.String(share.Name),
+					"accessTier":       to.String(share.Properties.AccessTier),
+					"enabledProtocols": strings.ToLower(to.String(share.Properties.EnabledProtocols)),
+				})
+
+				quotaMetric.Add(prometheus.Labels{
+					"resourceID":     resourceId,
+					"subscriptionID": to.String(subscription.SubscriptionID),
+				}, float64(to.Int64(share.Properties.ShareQuota)))
+
+				if share.Properties.ShareUsageBytes != nil {
+					usageMetric.Add(prometheus.Labels{
+						"resourceID":     resourceId,
+						"subscriptionID": to.String(subscription.SubscriptionID),
+					}, float64(*share.Properties.ShareUsageBytes))
+				}
+			}
+		}
+
+		if accountList.NextWithContext(ctx) != nil {
+			break
+		}
+	}
+
+	callback <- func() {
+		infoMetric.GaugeSet(m.prometheus.fileShare)
+		quotaMetric.GaugeSet(m.prometheus.fileShareQuota)
+		usageMetric.GaugeSet(m.prometheus.fileShareUsageBytes)
+	}
+}
+
+// Collect storage account replication type, secondary region, geo failover and geo replication sync status
+func (m *MetricsCollectorAzureRmStorage) collectReplication(ctx context.Context, logger *log.Entry, callback chan<- func(), subscription subscriptions.Subscription) {
+	accountClient := storage.NewAccountsClientWithBaseURI(azureEnvironment.ResourceManagerEndpoint, *subscription.SubscriptionID)
+	accountClient.Authorizer = AzureAuthorizer
+	accountClient.ResponseInspector = azureResponseInspector(&subscription)
+
+	accountList, err := accountClient.ListComplete(ctx)
+	if err != nil {
+		logger.Panic(err)
+	}
+
+	replicationMetric := prometheusCommon.NewMetricsList()
+	lastSyncMetric := prometheusCommon.NewMetricsList()
+	lastGeoFailoverMetric := prometheusCommon.NewMetricsList()
+
+	for accountList.NotDone() {
+		account := accountList.Value()
+		accountName := to.String(account.Name)
+		resourceGroup := extractResourceGroupFromAzureId(to.String(account.ID))
+		resourceId := toResourceId(account.ID)
+
+		skuName := ""
+		replicationType := ""
+		if account.Sku != nil {
+			skuName = string(account.Sku.Name)
+			// eg. Standard_RAGZRS
+			replicationType = strings.ToUpper(skuName[strings.LastIndex(skuName, "_")+1:])
+		}
+
+		replicationLabels := prometheus.Labels{
+			"resourceID":         resourceId,
+			"subscriptionID":     to.String(subscription.SubscriptionID),
+			"resourceGroup":      resourceGroup,
+			"name":               accountName,
+			"skuName":            skuName,
+			"replicationType":    replicationType,
+			"primaryLocation":    "",
+			"secondaryLocation":  "",
+			"statusOfPrimary":    "",
+			"statusOfSecondary":  "",
+			"failoverInProgress": "false",
+		}
+
+		if account.AccountProperties != nil {
+			replicationLabels["primaryLocation"] = strings.ToLower(to.String(account.PrimaryLocation))
+			replicationLabels["secondaryLocation"] = strings.ToLower(to.String(account.SecondaryLocation))
+			replicationLabels["statusOfPrimary"] = string(account.StatusOfPrimary)
+			replicationLabels["statusOfSecondary"] = string(account.StatusOfSecondary)
+			replicationLabels["failoverInProgress"] = boolToString(to.Bool(account.FailoverInProgress))
+
+			if account.LastGeoFailoverTime != nil {
+				lastGeoFailoverMetric.Add(prometheus.Labels{
+					"resourceID":     resourceId,
+					"subscriptionID": to.String(subscription.SubscriptionID),
+				}, float64(account.LastGeoFailoverTime.Unix()))
+			}
+		}
+
+		replicationMetric.AddInfo(replicationLabels)
+
+		// geo replication stats are only available for geo redundant accounts and need an extra request
+		if strings.Contains(replicationType, "GRS") || strings.Contains(replicationType, "GZRS") {
+			accountWithStats, err := accountClient.GetProperties(ctx, resourceGroup, accountName, storage.AccountExpandGeoReplicationStats)
+			if err != nil {
+				logger.WithField("storageAccount", accountName).Error(err)
+			} else if accountWithStats.AccountProperties != nil && accountWithStats.GeoReplicationStats != nil {
+				stats := accountWithStats.GeoReplicationStats
+				if stats.LastSyncTime != nil {
+					lastSyncMetric.Add(prometheus.Labels{
+						"resourceID":     resourceId,
+						"subscriptionID": to.String(subscription.SubscriptionID),
+						"status":         string(stats.Status),
+						"canFailover":    boolToString(to.Bool(stats.CanFailover)),
+					}, float64(stats.LastSyncTime.Unix()))
+				}
+			}
+		}
+
+		if accountList.NextWithContext(ctx) != nil {
+			break
+		}
+	}
+
+	callback <- func() {
+		replicationMetric.GaugeSet(m.prometheus.replication)
+		lastSyncMetric.GaugeSet(m.prometheus.replicationLastSync)
+		lastGeoFailoverMetric.GaugeSet(m.prometheus.replicationLastGeoFailover)
+	}
+}
@@ -0,0 +1,478 @@
+package main
+
+import (
+	"context"
+	"github.com/Azure/azure-sdk-for-go/profiles/latest/compute/mgmt/compute"
+	"github.com/Azure/azure-sdk-for-go/profiles/latest/resources/mgmt/subscriptions"
+	"github.com/Azure/azure-sdk-for-go/services/preview/monitor/mgmt/2019-06-01/insights"
+	"github.com/Azure/go-autorest/autorest/date"
+	"github.com/Azure/go-autorest/autorest/to"
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+	prometheusCommon "github.com/webdevops/go-prometheus-common"
+	"path"
+	"strconv"
+	"strings"
+)
+
+type MetricsCollectorAzureRmVirtualMachine struct {
+	CollectorProcessorGeneral
+
+	prometheus struct {
+		vmImage             *prometheus.GaugeVec
+		vmImageEndOfSupport *prometheus.GaugeVec
+
+		vmMaintenanceScheduled *prometheus.GaugeVec
+
+		ciRunnerPoolCapacity *prometheus.GaugeVec
+
+		vmDiskAttachment *prometheus.GaugeVec
+		vmNicAttachment  *prometheus.GaugeVec
+	}
+}
+
+func (m *MetricsCollectorAzureRmVirtualMachine) Setup(collector *CollectorGeneral) {
+	m.CollectorReference = collector
+
+	m.prometheus.vmImage = m.newGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_vm_image_info",
+			Help: "Azure virtual machine image reference information",
+		},
+		append(
+			[]string{
+				"resourceID",
+				"subscriptionID",
+				"resourceGroup",
+				"name",
+				"location",
+				"osType",
+				"imagePublisher",
+				"imageOffer",
+				"imageSku",
+				"imageVersion",
+				"imageID",
+			},
+			azureResourceTags.prometheusLabels...,
+		),
+	)
+	m.MustRegister(m.prometheus.vmImage)
+
+	m.prometheus.vmImageEndOfSupport = m.newGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_vm_image_endofsupport",
+			Help: "Azure virtual machine image is on the end-of-support list (1 if end-of-support)",
+		},
+		[]string{
+			"resourceID",
+			"subscriptionID",
+			"imagePublisher",
+			"imageOffer",
+			"imageSku",
+		},
+	)
+	m.MustRegister(m.prometheus.vmImageEndOfSupport)
+
+	m.prometheus.vmMaintenanceScheduled = m.newGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_vm_maintenance_scheduled",
+			Help: "Azure virtual machine scheduled host maintenance windows (timestamp)",
+		},
+		[]string{
+			"resourceID",
+			"subscriptionID",
+			"resourceGroup",
+			"name",
+			"type",
+			"customerInitiatedAllowed",
+		},
+	)
+	m.MustRegister(m.prometheus.vmMaintenanceScheduled)
+
+	m.prometheus.ciRunnerPoolCapacity = m.newGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_cirunner_pool_capacity",
+			Help: "Azure virtual machine scale set CI runner pool capacity (desired, current, max)",
+		},
+		[]string{
+			"resourceID",
+			"subscriptionID",
+			"resourceGroup",
+			"name",
+			"runnerType",
+			"type",
+		},
+	)
+	m.MustRegister(m.prometheus.ciRunnerPoolCapacity)
+
+	m.prometheus.vmDiskAttachment = m.newGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_vm_disk_attachment",
+			Help: "Azure virtual machine managed disk attachments (os and data disks)",
+		},
+		[]string{
+			"vmID",
+			"subscriptionID",
+			"diskID",
+			"diskType",
+			"lun",
+		},
+	)
+	m.MustRegister(m.prometheus.vmDiskAttachment)
+
+	m.prometheus.vmNicAttachment = m.newGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_vm_nic_attachment",
+			Help: "Azure virtual machine network interface attachments",
+		},
+		[]string{
+			"vmID",
+			"subscriptionID",
+			"nicID",
+			"primary",
+		},
+	)
+	m.MustRegister(m.prometheus.vmNicAttachment)
+}
+
+func (m *MetricsCollectorAzureRmVirtualMachine) Reset() {
+	m.prometheus.vmImage.Reset()
+	m.prometheus.vmImageEndOfSupport.Reset()
+	m.prometheus.vmMaintenanceScheduled.Reset()
+	m.prometheus.ciRunnerPoolCapacity.Reset()
+	m.prometheus.vmDiskAttachment.Reset()
+	m.prometheus.vmNicAttachment.Reset()
+}
+
+func (m *MetricsCollectorAzureRmVirtualMachine) Collect(ctx context.Context, logger *log.Entry, callback chan<- func(), subscription subscriptions.Subscription) {
+	m.collectImages(ctx, logger, callback, subscription)
+	m.collectMaintenance(ctx, logger, callback, subscription)
+
+	if opts.VirtualMachine.CiRunnerTag != "" {
+		m.collectCiRunnerPools(ctx, logger, callback, subscription)
+	}
+}
+
+// Collect Azure virtual machine image references and disk/nic attachments
+func (m *MetricsCollectorAzureRmVirtualMachine) collectImages(ctx context.Context, logger *log.Entry, callback chan<- func(), subscription subscriptions.Subscription) {
+	client := compute.NewVirtualMachinesClientWithBaseURI(azureEnvironment.ResourceManagerEndpoint, *subscription.SubscriptionID)
+	client.Authorizer = AzureAuthorizer
+	client.ResponseInspector = azureResponseInspector(&subscription)
+
+	list, err := client.ListAllComplete(ctx, "")
+	if err != nil {
+		logger.Panic(err)
+	}
+
+	imageMetric := prometheusCommon.NewMetricsList()
+	endOfSupportMetric := prometheusCommon.NewMetricsList()
+	diskAttachmentMetric := prometheusCommon.NewMetricsList()
+	nicAttachmentMetric := prometheusCommon.NewMetricsList()
+
+	for list.NotDone() {
+		val := list.Value()
+		resourceId := toResourceId(val.ID)
+
+		imageLabels := prometheus.Labels{
+			"resourceID":     resourceId,
+			"subscriptionID": to.String(subscription.SubscriptionID),
+			"resourceGroup":  extractResourceGroupFromAzureId(to.String(val.ID)),
+			"name":           to.String(val.Name),
+			"location":       to.String(val.Location),
+			"osType":         "",
+			"imagePublisher": "",
+			"imageOffer":     "",
+			"imageSku":       "",
+			"imageVersion":   "",
+			"imageID":        "",
+		}
+
+		if val.VirtualMachineProperties != nil && val.StorageProfile != nil {
+			if val.StorageProfile.OsDisk != nil {
+				imageLabels["osType"] = string(val.StorageProfile.OsDisk.OsType)
+			}
+
+			if image := val.StorageProfile.ImageReference; image != nil {
+				imageLabels["imagePublisher"] = to.String(image.Publisher)
+				imageLabels["imageOffer"] = to.String(image.Offer)
+				imageLabels["imageSku"] = to.String(image.Sku)
+				imageLabels["imageVersion"] = to.String(image.ExactVersion)
+				if imageLabels["imageVersion"] == "" {
+					imageLabels["imageVersion"] = to.String(image.Version)
+				}
+
+				if image.ID != nil {
+					imageLabels["imageID"] = toResourceId(image.ID)
+				} else if image.SharedGalleryImageID != nil {
+					imageLabels["imageID"] = toResourceId(image.SharedGalleryImageID)
+				}
+
+				// only marketplace images can be matched against the end-of-support list
+				if len(opts.VirtualMachine.ImageEol) > 0 && image.Publisher != nil {
+					endOfSupportLabels := prometheus.Labels{
+						"resourceID":     resourceId,
+						"subscriptionID": to.String(subscription.SubscriptionID),
+						"imagePublisher": imageLabels["imagePublisher"],
+						"imageOffer":     imageLabels["imageOffer"],
+						"imageSku":       imageLabels["imageSku"],
+					}
+
+					if vmImageIsEndOfSupport(imageLabels["imagePublisher"], imageLabels["imageOffer"], imageLabels["imageSku"]) {
+						endOfSupportMetric.Add(endOfSupportLabels, 1)
+					} else {
+						endOfSupportMetric.Add(endOfSupportLabels, 0)
+					}
+				}
+			}
+		}
+
+		imageLabels = azureResourceTags.appendPrometheusLabel(imageLabels, val.Tags)
+		imageMetric.AddInfo(imageLabels)
+
+		// relationship metrics for joins between vm, disk and nic metrics
+		if val.VirtualMachineProperties != nil && val.StorageProfile != nil {
+			if osDisk := val.StorageProfile.OsDisk; osDisk != nil && osDisk.ManagedDisk != nil && osDisk.ManagedDisk.ID != nil {
+				diskAttachmentMetric.AddInfo(prometheus.Labels{
+					"vmID":           resourceId,
+					"subscriptionID": to.String(subscription.SubscriptionID),
+					"diskID":         toResourceId(osDisk.ManagedDisk.ID),
+					"diskType":       "os",
+					"lun":            "",
+				})
+			}
+
+			if val.StorageProfile.DataDisks != nil {
+				for _, dataDisk := range *val.StorageProfile.DataDisks {
+					if dataDisk.ManagedDisk == nil || dataDisk.ManagedDisk.ID == nil {
+						continue
+					}
+
+					lun := ""
+					if dataDisk.Lun != nil {
+						lun = strconv.FormatInt(int64(*dataDisk.Lun), 10)
+					}
+
+					diskAttachmentMetric.AddInfo(prometheus.Labels{
+						"vmID":           resourceId,
+						"subscriptionID": to.String(subscription.SubscriptionID),
+						"diskID":         toResourceId(dataDisk.ManagedDisk.ID),
+						"diskType":       "data",
+						"lun":            lun,
+					})
+				}
+			}
+		}
+
+		if val.VirtualMachineProperties != nil && val.NetworkProfile != nil && val.NetworkProfile.NetworkInterfaces != nil {
+			for _, nic := range *val.NetworkProfile.NetworkInterfaces {
+				primary := false
+				if nic.NetworkInterfaceReferenceProperties != nil {
+					primary = to.Bool(nic.Primary)
+				}
+
+				nicAttachmentMetric.AddInfo(prometheus.Labels{
+					"vmID":           resourceId,
+					"subscriptionID": to.String(subscription.SubscriptionID),
+					"nicID":          toResourceId(nic.ID),
+					"primary":        boolToString(primary),
+				})
+			}
+		}
+
+		if list.NextWithContext(ctx) != nil {
+			break
+		}
+	}
+
+	callback <- func() {
+		imageMetric.GaugeSet(m.prometheus.vmImage)
+		endOfSupportMetric.GaugeSet(m.prometheus.vmImageEndOfSupport)
+		diskAttachmentMetric.GaugeSet(m.prometheus.vmDiskAttachment)
+		nicAttachmentMetric.GaugeSet(m.prometheus.vmNicAttachment)
+	}
+}
+
+// Collect Azure virtual machine scheduled maintenance from the instance view
+func (m *MetricsCollectorAzureRmVirtualMachine) collectMaintenance(ctx context.Context, logger *log.Entry, callback chan<- func(), subscription subscriptions.Subscription) {
+	client := compute.NewVirtualMachinesClientWithBaseURI(azureEnvironment.ResourceManagerEndpoint, *subscription.SubscriptionID)
+	client.Authorizer = AzureAuthorizer
+	client.ResponseInspector = azureResponseInspector(&subscription)
+
+	list, err := client.ListAllComplete(ctx, "true")
+	if err != nil {
+		logger.Panic(err)
+	}
+
+	maintenanceMetric := prometheusCommon.NewMetricsList()
+
+	for list.NotDone() {
+		val := list.Value()
+
+		if val.VirtualMachineProperties != nil && val.InstanceView != nil && val.InstanceView.MaintenanceRedeployStatus != nil {
+			maintenance := val.InstanceView.MaintenanceRedeployStatus
+
+			addMaintenanceTime := func(maintenanceType string, timestamp *date.Time) {
+				if timestamp == nil {
+					return
+				}
+
+				maintenanceMetric.AddTime(prometheus.Labels{
+					"resourceID":               toResourceId(val.ID),
+					"subscriptionID":           to.String(subscription.SubscriptionID),
+					"resourceGroup":            extractResourceGroupFromAzureId(to.String(val.ID)),
+					"name":                     to.String(val.Name),
+					"type":                     maintenanceType,
+					"customerInitiatedAllowed": boolToString(to.Bool(maintenance.IsCustomerInitiatedMaintenanceAllowed)),
+				}, timestamp.ToTime())
+			}
+
+			addMaintenanceTime("preMaintenanceWindowStart", maintenance.PreMaintenanceWindowStartTime)
+			addMaintenanceTime("preMaintenanceWindowEnd", maintenance.PreMaintenanceWindowEndTime)
+			addMaintenanceTime("maintenanceWindowStart", maintenance.MaintenanceWindowStartTime)
+			addMaintenanceTime("maintenanceWindowEnd", maintenance.MaintenanceWindowEndTime)
+		}
+
+		if list.NextWithContext(ctx) != nil {
+			break
+		}
+	}
+
+	callback <- func() {
+		maintenanceMetric.GaugeSet(m.prometheus.vmMaintenanceScheduled)
+	}
+}
+
+// Collect capacity of scale sets tagged as CI runner pools (--vm-cirunner-tag), tag value is the runner type
+func (m *MetricsCollectorAzureRmVirtualMachine) collectCiRunnerPools(ctx context.Context, logger *log.Entry, callback chan<- func(), subscription subscriptions.Subscription) {
+	client := compute.NewVirtualMachineScaleSetsClientWithBaseURI(azureEnvironment.ResourceManagerEndpoint, *subscription.SubscriptionID)
+	client.Authorizer = AzureAuthorizer
+	client.ResponseInspector = azureResponseInspector(&subscription)
+
+	vmClient := compute.NewVirtualMachineScaleSetVMsClientWithBaseURI(azureEnvironment.ResourceManagerEndpoint, *subscription.SubscriptionID)
+	vmClient.Authorizer = AzureAuthorizer
+	vmClient.ResponseInspector = azureResponseInspector(&subscription)
+
+	autoscaleClient := insights.NewAutoscaleSettingsClientWithBaseURI(azureEnvironment.ResourceManagerEndpoint, *subscription.SubscriptionID)
+	autoscaleClient.Authorizer = AzureAuthorizer
+	autoscaleClient.ResponseInspector = azureResponseInspector(&subscription)
+
+	list, err := client.ListAllComplete(ctx)
+	if err != nil {
+		logger.Panic(err)
+	}
+
+	capacityMetric := prometheusCommon.NewMetricsList()
+
+	// max capacity of enabled autoscale settings by target resource
+	var autoscaleMaxCapacity map[string]float64
+
+	for list.NotDone() {
+		val := list.Value()
+
+		runnerType, isRunnerPool := vmssCiRunnerType(val.Tags)
+		if isRunnerPool {
+			if autoscaleMaxCapacity == nil {
+				autoscaleMaxCapacity = m.fetchAutoscaleMaxCapacity(ctx, logger, autoscaleClient)
+			}
+
+			resourceGroup := extractResourceGroupFromAzureId(to.String(val.ID))
+			addCapacity := func(capacityType string, value float64) {
+				capacityMetric.Add(prometheus.Labels{
+					"resourceID":     toResourceId(val.ID),
+					"subscriptionID": to.String(subscription.SubscriptionID),
+					"resourceGroup":  resourceGroup,
+					"name":           to.String(val.Name),
+					"runnerType":     runnerType,
+					"type":           capacityType,
+				}, value)
+			}
+
+			if val.Sku != nil && val.Sku.Capacity != nil {
+				addCapacity("desired", float64(*val.Sku.Capacity))
+			}
+
+			vmList, err := vmClient.ListComplete(ctx, resourceGroup, to.String(val.Name), "", "", "")
+			if err != nil {
+				logger.WithField("vmss", to.String(val.ID)).Error(err)
+			} else {
+				instanceCount := float64(0)
+				for vmList.NotDone() {
+					instanceCount++
+
+					if vmList.NextWithContext(ctx) != nil {
+						break
+					}
+				}
+				addCapacity("current", instanceCount)
+			}
+
+			// max capacity is only known for scale sets managed by autoscale
+			if maxCapacity, exists := autoscaleMaxCapacity[strings.ToLower(to.String(val.ID))]; exists {
+				addCapacity("max", maxCapacity)
+			}
+		}
+
+		if list.NextWithContext(ctx) != nil {
+			break
+		}
+	}
+
+	callback <- func() {
+		capacityMetric.GaugeSet(m.prometheus.ciRunnerPoolCapacity)
+	}
+}
+
+// fetchAutoscaleMaxCapacity returns the highest maximum capacity of all profiles of enabled autoscale settings by (lowercase) target resource id
+func (m *MetricsCollectorAzureRmVirtualMachine) fetchAutoscaleMaxCapacity(ctx context.Context, logger *log.Entry, client insights.AutoscaleSettingsClient) map[string]float64 {
+	ret := map[string]float64{}
+
+	list, err := client.ListBySubscriptionComplete(ctx)
+	if err != nil {
+		logger.Error(err)
+		return ret
+	}
+
+	for list.NotDone() {
+		val := list.Value()
+
+		if val.AutoscaleSetting != nil && to.Bool(val.Enabled) && val.Profiles != nil {
+			targetResourceId := strings.ToLower(to.String(val.TargetResourceURI))
+			for _, profile := range *val.Profiles {
+				if profile.Capacity == nil {
+					continue
+				}
+
+				if maxCapacity, err := strconv.ParseFloat(to.String(profile.Capacity.Maximum), 64); err == nil && maxCapacity > ret[targetResourceId] {
+					ret[targetResourceId] = maxCapacity
+				}
+			}
+		}
+
+		if list.NextWithContext(ctx) != nil {
+			break
+		}
+	}
+
+	return ret
+}
+
+// vmssCiRunnerType returns the runner type (tag value) if the scale set is tagged as CI runner pool
+func vmssCiRunnerType(tags map[string]*string) (string, bool) {
+	for tagName, tagValue := range tags {
+		if strings.EqualFold(tagName, opts.VirtualMachine.CiRunnerTag) {
+			return strings.ToLower(to.String(tagValue)), true
+		}
+	}
+	return "", false
+}
+
+// vmImageIsEndOfSupport checks the image against the --vm-image-eol patterns (case insensitive)
+func vmImageIsEndOfSupport(publisher, offer, sku string) bool {
+	image := strings.ToLower(publisher + ":" + offer + ":" + sku)
+	for _, imagePattern := range opts.VirtualMachine.ImageEol {
+		if matched, _ := path.Match(strings.ToLower(imagePattern), image); matched {
+			return true
+		}
+	}
+	return false
+}
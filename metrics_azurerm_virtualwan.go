@@ -0,0 +1,425 @@
+package main
+
+import (
+	"context"
+	"github.com/Azure/azure-sdk-for-go/profiles/latest/network/mgmt/network"
+	"github.com/Azure/azure-sdk-for-go/profiles/latest/resources/mgmt/subscriptions"
+	"github.com/Azure/go-autorest/autorest/to"
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+	prometheusCommon "github.com/webdevops/go-prometheus-common"
+	"strings"
+)
+
+type MetricsCollectorAzureRmVirtualWan struct {
+	CollectorProcessorGeneral
+
+	prometheus struct {
+		virtualWan           *prometheus.GaugeVec
+		virtualHub           *prometheus.GaugeVec
+		virtualHubRouteTable *prometheus.GaugeVec
+		virtualHubRoutes     *prometheus.GaugeVec
+		virtualHubConnection *prometheus.GaugeVec
+	}
+}
+
+func (m *MetricsCollectorAzureRmVirtualWan) Setup(collector *CollectorGeneral) {
+	m.CollectorReference = collector
+
+	m.prometheus.virtualWan = m.newGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_virtualwan_info",
+			Help: "Azure ResourceManager Virtual WAN information",
+		},
+		append(
+			[]string{
+				"resourceID",
+				"subscriptionID",
+				"resourceGroup",
+				"name",
+				"location",
+				"type",
+				"provisioningState",
+			},
+			azureResourceTags.prometheusLabels...,
+		),
+	)
+	m.MustRegister(m.prometheus.virtualWan)
+
+	m.prometheus.virtualHub = m.newGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_virtualwan_hub_info",
+			Help: "Azure ResourceManager Virtual WAN hub information",
+		},
+		append(
+			[]string{
+				"resourceID",
+				"subscriptionID",
+				"resourceGroup",
+				"name",
+				"location",
+				"virtualWanID",
+				"addressPrefix",
+				"sku",
+				"provisioningState",
+				"routingState",
+			},
+			azureResourceTags.prometheusLabels...,
+		),
+	)
+	m.MustRegister(m.prometheus.virtualHub)
+
+	m.prometheus.virtualHubRouteTable = m.newGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_virtualwan_hub_routetable_info",
+			Help: "Azure ResourceManager Virtual WAN hub route table information",
+		},
+		[]string{
+			"resourceID",
+			"subscriptionID",
+			"hubID",
+			"name",
+			"provisioningState",
+		},
+	)
+	m.MustRegister(m.prometheus.virtualHubRouteTable)
+
+	m.prometheus.virtualHubRoutes = m.newGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_virtualwan_hub_routetable_routes",
+			Help: "Azure ResourceManager Virtual WAN hub route table route count",
+		},
+		[]string{
+			"resourceID",
+			"subscriptionID",
+			"hubID",
+		},
+	)
+	m.MustRegister(m.prometheus.virtualHubRoutes)
+
+	m.prometheus.virtualHubConnection = m.newGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_virtualwan_hub_connection_info",
+			Help: "Azure ResourceManager Virtual WAN hub connection information (VNet, VPN and ExpressRoute)",
+		},
+		[]string{
+			"resourceID",
+			"subscriptionID",
+			"hubID",
+			"name",
+			"connectionType",
+			"remoteResourceID",
+			"provisioningState",
+			"connectionStatus",
+		},
+	)
+	m.MustRegister(m.prometheus.virtualHubConnection)
+}
+
+func (m *MetricsCollectorAzureRmVirtualWan) Reset() {
+	m.prometheus.virtualWan.Reset()
+	m.prometheus.virtualHub.Reset()
+	m.prometheus.virtualHubRouteTable.Reset()
+	m.prometheus.virtualHubRoutes.Reset()
+	m.prometheus.virtualHubConnection.Reset()
+}
+
+func (m *MetricsCollectorAzureRmVirtualWan) Collect(ctx context.Context, logger *log.Entry, callback chan<- func(), subscription subscriptions.Subscription) {
+	m.collectVirtualWans(ctx, logger, callback, subscription)
+	m.collectVirtualHubs(ctx, logger, callback, subscription)
+	m.collectVpnGatewayConnections(ctx, logger, callback, subscription)
+	m.collectExpressRouteGatewayConnections(ctx, logger, callback, subscription)
+}
+
+// Collect Azure Virtual WANs
+func (m *MetricsCollectorAzureRmVirtualWan) collectVirtualWans(ctx context.Context, logger *log.Entry, callback chan<- func(), subscription subscriptions.Subscription) {
+	client := network.NewVirtualWansClientWithBaseURI(azureEnvironment.ResourceManagerEndpoint, *subscription.SubscriptionID)
+	client.Authorizer = AzureAuthorizer
+	client.ResponseInspector = azureResponseInspector(&subscription)
+
+	list, err := client.ListComplete(ctx)
+	if err != nil {
+		logger.Panic(err)
+	}
+
+	infoMetric := prometheusCommon.NewMetricsList()
+
+	for list.NotDone() {
+		val := list.Value()
+
+		infoLabels := prometheus.Labels{
+			"resourceID":        toResourceId(val.ID),
+			"subscriptionID":    to.String(subscription.SubscriptionID),
+			"resourceGroup":     extractResourceGroupFromAzureId(to.String(val.ID)),
+			"name":              to.String(val.Name),
+			"location":          to.String(val.Location),
+			"type":              "",
+			"provisioningState": "",
+		}
+
+		if val.VirtualWanProperties != nil {
+			infoLabels["type"] = to.String(val.VirtualWanProperties.Type)
+			infoLabels["provisioningState"] = strings.ToLower(string(val.VirtualWanProperties.ProvisioningState))
+		}
+
+		infoLabels = azureResourceTags.appendPrometheusLabel(infoLabels, val.Tags)
+		infoMetric.AddInfo(infoLabels)
+
+		if list.NextWithContext(ctx) != nil {
+			break
+		}
+	}
+
+	callback <- func() {
+		infoMetric.GaugeSet(m.prometheus.virtualWan)
+	}
+}
+
+// Collect Azure Virtual WAN hubs, their route tables and VNet connections
+func (m *MetricsCollectorAzureRmVirtualWan) collectVirtualHubs(ctx context.Context, logger *log.Entry, callback chan<- func(), subscription subscriptions.Subscription) {
+	client := network.NewVirtualHubsClientWithBaseURI(azureEnvironment.ResourceManagerEndpoint, *subscription.SubscriptionID)
+	client.Authorizer = AzureAuthorizer
+	client.ResponseInspector = azureResponseInspector(&subscription)
+
+	routeTableClient := network.NewHubRouteTablesClientWithBaseURI(azureEnvironment.ResourceManagerEndpoint, *subscription.SubscriptionID)
+	routeTableClient.Authorizer = AzureAuthorizer
+	routeTableClient.ResponseInspector = azureResponseInspector(&subscription)
+
+	connectionClient := network.NewHubVirtualNetworkConnectionsClientWithBaseURI(azureEnvironment.ResourceManagerEndpoint, *subscription.SubscriptionID)
+	connectionClient.Authorizer = AzureAuthorizer
+	connectionClient.ResponseInspector = azureResponseInspector(&subscription)
+
+	list, err := client.ListComplete(ctx)
+	if err != nil {
+		logger.Panic(err)
+	}
+
+	infoMetric := prometheusCommon.NewMetricsList()
+	routeTableMetric := prometheusCommon.NewMetricsList()
+	routesMetric := prometheusCommon.NewMetricsList()
+	connectionMetric := prometheusCommon.NewMetricsList()
+
+	for list.NotDone() {
+		val := list.Value()
+		hubId := toResourceId(val.ID)
+		hubName := to.String(val.Name)
+		resourceGroup := extractResourceGroupFromAzureId(to.String(val.ID))
+
+		infoLabels := prometheus.Labels{
+			"resourceID":        hubId,
+			"subscriptionID":    to.String(subscription.SubscriptionID),
+			"resourceGroup":     resourceGroup,
+			"name":              hubName,
+			"location":          to.String(val.Location),
+			"virtualWanID":      "",
+			"addressPrefix":     "",
+			"sku":               "",
+			"provisioningState": "",
+			"routingState":      "",
+		}
+
+		if val.VirtualHubProperties != nil {
+			if val.VirtualHubProperties.VirtualWan != nil {
+				infoLabels["virtualWanID"] = toResourceId(val.VirtualHubProperties.VirtualWan.ID)
+			}
+			infoLabels["addressPrefix"] = to.String(val.VirtualHubProperties.AddressPrefix)
+			infoLabels["sku"] = to.String(val.VirtualHubProperties.Sku)
+			infoLabels["provisioningState"] = strings.ToLower(string(val.VirtualHubProperties.ProvisioningState))
+			infoLabels["routingState"] = strings.ToLower(string(val.VirtualHubProperties.RoutingState))
+		}
+
+		infoLabels = azureResourceTags.appendPrometheusLabel(infoLabels, val.Tags)
+		infoMetric.AddInfo(infoLabels)
+
+		// route tables
+		routeTableList, err := routeTableClient.ListComplete(ctx, resourceGroup, hubName)
+		if err != nil {
+			logger.WithField("virtualHub", hubName).Error(err)
+		} else {
+			for routeTableList.NotDone() {
+				routeTable := routeTableList.Value()
+
+				routeTableLabels := prometheus.Labels{
+					"resourceID":        toResourceId(routeTable.ID),
+					"subscriptionID":    to.String(subscription.SubscriptionID),
+					"hubID":             hubId,
+					"name":              to.String(routeTable.Name),
+					"provisioningState": "",
+				}
+
+				routeCount := 0
+				if routeTable.HubRouteTableProperties != nil {
+					routeTableLabels["provisioningState"] = strings.ToLower(string(routeTable.HubRouteTableProperties.ProvisioningState))
+					if routeTable.HubRouteTableProperties.Routes != nil {
+						routeCount = len(*routeTable.HubRouteTableProperties.Routes)
+					}
+				}
+
+				routeTableMetric.AddInfo(routeTableLabels)
+				routesMetric.Add(prometheus.Labels{
+					"resourceID":     toResourceId(routeTable.ID),
+					"subscriptionID": to.String(subscription.SubscriptionID),
+					"hubID":          hubId,
+				}, float64(routeCount))
+
+				if routeTableList.NextWithContext(ctx) != nil {
+					break
+				}
+			}
+		}
+
+		// vnet connections
+		connectionList, err := connectionClient.ListComplete(ctx, resourceGroup, hubName)
+		if err != nil {
+			logger.WithField("virtualHub", hubName).Error(err)
+		} else {
+			for connectionList.NotDone() {
+				connection := connectionList.Value()
+
+				connectionLabels := prometheus.Labels{
+					"resourceID":        toResourceId(connection.ID),
+					"subscriptionID":    to.String(subscription.SubscriptionID),
+					"hubID":             hubId,
+					"name":              to.String(connection.Name),
+					"connectionType":    "vnet",
+					"remoteResourceID":  "",
+					"provisioningState": "",
+					"connectionStatus":  "",
+				}
+
+				if connection.HubVirtualNetworkConnectionProperties != nil {
+					if connection.HubVirtualNetworkConnectionProperties.RemoteVirtualNetwork != nil {
+						connectionLabels["remoteResourceID"] = toResourceId(connection.HubVirtualNetworkConnectionProperties.RemoteVirtualNetwork.ID)
+					}
+					connectionLabels["provisioningState"] = strings.ToLower(string(connection.HubVirtualNetworkConnectionProperties.ProvisioningState))
+				}
+
+				connectionMetric.AddInfo(connectionLabels)
+
+				if connectionList.NextWithContext(ctx) != nil {
+					break
+				}
+			}
+		}
+
+		if list.NextWithContext(ctx) != nil {
+			break
+		}
+	}
+
+	callback <- func() {
+		infoMetric.GaugeSet(m.prometheus.virtualHub)
+		routeTableMetric.GaugeSet(m.prometheus.virtualHubRouteTable)
+		routesMetric.GaugeSet(m.prometheus.virtualHubRoutes)
+		connectionMetric.GaugeSet(m.prometheus.virtualHubConnection)
+	}
+}
+
+// Collect VPN connections of Virtual WAN VPN gateways
+func (m *MetricsCollectorAzureRmVirtualWan) collectVpnGatewayConnections(ctx context.Context, logger *log.Entry, callback chan<- func(), subscription subscriptions.Subscription) {
+	client := network.NewVpnGatewaysClientWithBaseURI(azureEnvironment.ResourceManagerEndpoint, *subscription.SubscriptionID)
+	client.Authorizer = AzureAuthorizer
+	client.ResponseInspector = azureResponseInspector(&subscription)
+
+	list, err := client.ListComplete(ctx)
+	if err != nil {
+		logger.Panic(err)
+	}
+
+	connectionMetric := prometheusCommon.NewMetricsList()
+
+	for list.NotDone() {
+		val := list.Value()
+
+		if val.VpnGatewayProperties != nil && val.VpnGatewayProperties.Connections != nil {
+			hubId := ""
+			if val.VpnGatewayProperties.VirtualHub != nil {
+				hubId = toResourceId(val.VpnGatewayProperties.VirtualHub.ID)
+			}
+
+			for _, connection := range *val.VpnGatewayProperties.Connections {
+				connectionLabels := prometheus.Labels{
+					"resourceID":        toResourceId(connection.ID),
+					"subscriptionID":    to.String(subscription.SubscriptionID),
+					"hubID":             hubId,
+					"name":              to.String(connection.Name),
+					"connectionType":    "vpn",
+					"remoteResourceID":  "",
+					"provisioningState": "",
+					"connectionStatus":  "",
+				}
+
+				if connection.VpnConnectionProperties != nil {
+					if connection.VpnConnectionProperties.RemoteVpnSite != nil {
+						connectionLabels["remoteResourceID"] = toResourceId(connection.VpnConnectionProperties.RemoteVpnSite.ID)
+					}
+					connectionLabels["provisioningState"] = strings.ToLower(string(connection.VpnConnectionProperties.ProvisioningState))
+					connectionLabels["connectionStatus"] = strings.ToLower(string(connection.VpnConnectionProperties.ConnectionStatus))
+				}
+
+				connectionMetric.AddInfo(connectionLabels)
+			}
+		}
+
+		if list.NextWithContext(ctx) != nil {
+			break
+		}
+	}
+
+	callback <- func() {
+		connectionMetric.GaugeSet(m.prometheus.virtualHubConnection)
+	}
+}
+
+// Collect ExpressRoute connections of Virtual WAN ExpressRoute gateways
+func (m *MetricsCollectorAzureRmVirtualWan) collectExpressRouteGatewayConnections(ctx context.Context, logger *log.Entry, callback chan<- func(), subscription subscriptions.Subscription) {
+	client := network.NewExpressRouteGatewaysClientWithBaseURI(azureEnvironment.ResourceManagerEndpoint, *subscription.SubscriptionID)
+	client.Authorizer = AzureAuthorizer
+	client.ResponseInspector = azureResponseInspector(&subscription)
+
+	list, err := client.ListBySubscription(ctx)
+	if err != nil {
+		logger.Panic(err)
+	}
+
+	connectionMetric := prometheusCommon.NewMetricsList()
+
+	if list.Value != nil {
+		for _, val := range *list.Value {
+			if val.ExpressRouteGatewayProperties == nil || val.ExpressRouteGatewayProperties.ExpressRouteConnections == nil {
+				continue
+			}
+
+			hubId := ""
+			if val.ExpressRouteGatewayProperties.VirtualHub != nil {
+				hubId = toResourceId(val.ExpressRouteGatewayProperties.VirtualHub.ID)
+			}
+
+			for _, connection := range *val.ExpressRouteGatewayProperties.ExpressRouteConnections {
+				connectionLabels := prometheus.Labels{
+					"resourceID":        toResourceId(connection.ID),
+					"subscriptionID":    to.String(subscription.SubscriptionID),
+					"hubID":             hubId,
+					"name":              to.String(connection.Name),
+					"connectionType":    "expressroute",
+					"remoteResourceID":  "",
+					"provisioningState": "",
+					"connectionStatus":  "",
+				}
+
+				if connection.ExpressRouteConnectionProperties != nil {
+					if connection.ExpressRouteConnectionProperties.ExpressRouteCircuitPeering != nil {
+						connectionLabels["remoteResourceID"] = toResourceId(connection.ExpressRouteConnectionProperties.ExpressRouteCircuitPeering.ID)
+					}
+					connectionLabels["provisioningState"] = strings.ToLower(string(connection.ExpressRouteConnectionProperties.ProvisioningState))
+				}
+
+				connectionMetric.AddInfo(connectionLabels)
+			}
+		}
+	}
+
+	callback <- func() {
+		connectionMetric.GaugeSet(m.prometheus.virtualHubConnection)
+	}
+}
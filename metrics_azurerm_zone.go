@@ -0,0 +1,290 @@
+package main
+
+import (
+	"context"
+	"github.com/Azure/azure-sdk-for-go/profiles/latest/compute/mgmt/compute"
+	"github.com/Azure/azure-sdk-for-go/profiles/latest/network/mgmt/network"
+	"github.com/Azure/azure-sdk-for-go/profiles/latest/resources/mgmt/subscriptions"
+	"github.com/Azure/go-autorest/autorest/to"
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+	prometheusCommon "github.com/webdevops/go-prometheus-common"
+	"sort"
+	"strings"
+)
+
+const (
+	// zone label for resources without availability zone placement
+	AzureZoneNone = "none"
+)
+
+type MetricsCollectorAzureRmZone struct {
+	CollectorProcessorGeneral
+
+	prometheus struct {
+		resourceZone      *prometheus.GaugeVec
+		resourceZoneCount *prometheus.GaugeVec
+		vmssZoneInstances *prometheus.GaugeVec
+	}
+}
+
+type azureZoneCountKey struct {
+	location     string
+	resourceType string
+	zone         string
+}
+
+func (m *MetricsCollectorAzureRmZone) Setup(collector *CollectorGeneral) {
+	m.CollectorReference = collector
+
+	m.prometheus.resourceZone = m.newGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_resource_zone_info",
+			Help: "Azure resource availability zone placement",
+		},
+		[]string{
+			"resourceID",
+			"subscriptionID",
+			"resourceGroup",
+			"name",
+			"location",
+			"resourceType",
+			"zones",
+			"zoneRedundant",
+		},
+	)
+	m.MustRegister(m.prometheus.resourceZone)
+
+	m.prometheus.resourceZoneCount = m.newGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_resource_zone_count",
+			Help: "Azure resource count per availability zone",
+		},
+		[]string{
+			"subscriptionID",
+			"location",
+			"resourceType",
+			"zone",
+		},
+	)
+	m.MustRegister(m.prometheus.resourceZoneCount)
+
+	m.prometheus.vmssZoneInstances = m.newGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_vmss_zone_instances",
+			Help: "Azure virtual machine scale set instance count per availability zone",
+		},
+		[]string{
+			"resourceID",
+			"subscriptionID",
+			"zone",
+		},
+	)
+	m.MustRegister(m.prometheus.vmssZoneInstances)
+}
+
+func (m *MetricsCollectorAzureRmZone) Reset() {
+	m.prometheus.resourceZone.Reset()
+	m.prometheus.resourceZoneCount.Reset()
+	m.prometheus.vmssZoneInstances.Reset()
+}
+
+func (m *MetricsCollectorAzureRmZone) Collect(ctx context.Context, logger *log.Entry, callback chan<- func(), subscription subscriptions.Subscription) {
+	infoMetric := prometheusCommon.NewMetricsList()
+	countMetric := prometheusCommon.NewMetricsList()
+	vmssInstancesMetric := prometheusCommon.NewMetricsList()
+
+	zoneCount := map[azureZoneCountKey]float64{}
+
+	addResource := func(resourceId, name, location *string, resourceType string, zones *[]string) {
+		zoneList := []string{}
+		if zones != nil {
+			zoneList = append(zoneList, *zones...)
+		}
+		sort.Strings(zoneList)
+
+		infoMetric.AddInfo(prometheus.Labels{
+			"resourceID":     toResourceId(resourceId),
+			"subscriptionID": to.String(subscription.SubscriptionID),
+			"resourceGroup":  extractResourceGroupFromAzureId(to.String(resourceId)),
+			"name":           to.String(name),
+			"location":       strings.ToLower(to.String(location)),
+			"resourceType":   resourceType,
+			"zones":          strings.Join(zoneList, ","),
+			"zoneRedundant":  boolToString(len(zoneList) > 1),
+		})
+
+		if len(zoneList) == 0 {
+			zoneList = []string{AzureZoneNone}
+		}
+
+		for _, zone := range zoneList {
+			zoneCount[azureZoneCountKey{
+				location:     strings.ToLower(to.String(location)),
+				resourceType: resourceType,
+				zone:         zone,
+			}]++
+		}
+	}
+
+	m.collectVirtualMachines(ctx, logger, subscription, addResource)
+	m.collectVirtualMachineScaleSets(ctx, logger, subscription, addResource, vmssInstancesMetric)
+	m.collectPublicIps(ctx, logger, subscription, addResource)
+	m.collectApplicationGateways(ctx, logger, subscription, addResource)
+	m.collectNatGateways(ctx, logger, subscription, addResource)
+
+	for key, count := range zoneCount {
+		countMetric.Add(prometheus.Labels{
+			"subscriptionID": to.String(subscription.SubscriptionID),
+			"location":       key.location,
+			"resourceType":   key.resourceType,
+			"zone":           key.zone,
+		}, count)
+	}
+
+	callback <- func() {
+		infoMetric.GaugeSet(m.prometheus.resourceZone)
+		countMetric.GaugeSet(m.prometheus.resourceZoneCount)
+		vmssInstancesMetric.GaugeSet(m.prometheus.vmssZoneInstances)
+	}
+}
+
+func (m *MetricsCollectorAzureRmZone) collectVirtualMachines(ctx context.Context, logger *log.Entry, subscription subscriptions.Subscription, addResource func(*string, *string, *string, string, *[]string)) {
+	client := compute.NewVirtualMachinesClientWithBaseURI(azureEnvironment.ResourceManagerEndpoint, *subscription.SubscriptionID)
+	client.Authorizer = AzureAuthorizer
+	client.ResponseInspector = azureResponseInspector(&subscription)
+
+	list, err := client.ListAllComplete(ctx, "")
+	if err != nil {
+		logger.Panic(err)
+	}
+
+	for list.NotDone() {
+		val := list.Value()
+		addResource(val.ID, val.Name, val.Location, "virtualMachine", val.Zones)
+
+		if list.NextWithContext(ctx) != nil {
+			break
+		}
+	}
+}
+
+// Collect scale sets and the zone placement of their instances
+func (m *MetricsCollectorAzureRmZone) collectVirtualMachineScaleSets(ctx context.Context, logger *log.Entry, subscription subscriptions.Subscription, addResource func(*string, *string, *string, string, *[]string), vmssInstancesMetric *prometheusCommon.MetricList) {
+	client := compute.NewVirtualMachineScaleSetsClientWithBaseURI(azureEnvironment.ResourceManagerEndpoint, *subscription.SubscriptionID)
+	client.Authorizer = AzureAuthorizer
+	client.ResponseInspector = azureResponseInspector(&subscription)
+
+	vmClient := compute.NewVirtualMachineScaleSetVMsClientWithBaseURI(azureEnvironment.ResourceManagerEndpoint, *subscription.SubscriptionID)
+	vmClient.Authorizer = AzureAuthorizer
+	vmClient.ResponseInspector = azureResponseInspector(&subscription)
+
+	list, err := client.ListAllComplete(ctx)
+	if err != nil {
+		logger.Panic(err)
+	}
+
+	for list.NotDone() {
+		val := list.Value()
+		addResource(val.ID, val.Name, val.Location, "virtualMachineScaleSet", val.Zones)
+
+		// instance distribution is only relevant for zonal scale sets
+		if val.Zones != nil && len(*val.Zones) > 0 {
+			instanceCount := map[string]float64{}
+			for _, zone := range *val.Zones {
+				instanceCount[zone] = 0
+			}
+
+			vmList, err := vmClient.ListComplete(ctx, extractResourceGroupFromAzureId(to.String(val.ID)), to.String(val.Name), "", "", "")
+			if err != nil {
+				logger.WithField("vmss", to.String(val.ID)).Error(err)
+			} else {
+				for vmList.NotDone() {
+					vm := vmList.Value()
+
+					zone := AzureZoneNone
+					if vm.Zones != nil && len(*vm.Zones) > 0 {
+						zone = (*vm.Zones)[0]
+					}
+					instanceCount[zone]++
+
+					if vmList.NextWithContext(ctx) != nil {
+						break
+					}
+				}
+
+				for zone, count := range instanceCount {
+					vmssInstancesMetric.Add(prometheus.Labels{
+						"resourceID":     toResourceId(val.ID),
+						"subscriptionID": to.String(subscription.SubscriptionID),
+						"zone":           zone,
+					}, count)
+				}
+			}
+		}
+
+		if list.NextWithContext(ctx) != nil {
+			break
+		}
+	}
+}
+
+func (m *MetricsCollectorAzureRmZone) collectPublicIps(ctx context.Context, logger *log.Entry, subscription subscriptions.Subscription, addResource func(*string, *string, *string, string, *[]string)) {
+	client := network.NewPublicIPAddressesClientWithBaseURI(azureEnvironment.ResourceManagerEndpoint, *subscription.SubscriptionID)
+	client.Authorizer = AzureAuthorizer
+	client.ResponseInspector = azureResponseInspector(&subscription)
+
+	list, err := client.ListAllComplete(ctx)
+	if err != nil {
+		logger.Panic(err)
+	}
+
+	for list.NotDone() {
+		val := list.Value()
+		addResource(val.ID, val.Name, val.Location, "publicIp", val.Zones)
+
+		if list.NextWithContext(ctx) != nil {
+			break
+		}
+	}
+}
+
+func (m *MetricsCollectorAzureRmZone) collectApplicationGateways(ctx context.Context, logger *log.Entry, subscription subscriptions.Subscription, addResource func(*string, *string, *string, string, *[]string)) {
+	client := network.NewApplicationGatewaysClientWithBaseURI(azureEnvironment.ResourceManagerEndpoint, *subscription.SubscriptionID)
+	client.Authorizer = AzureAuthorizer
+	client.ResponseInspector = azureResponseInspector(&subscription)
+
+	list, err := client.ListAllComplete(ctx)
+	if err != nil {
+		logger.Panic(err)
+	}
+
+	for list.NotDone() {
+		val := list.Value()
+		addResource(val.ID, val.Name, val.Location, "applicationGateway", val.Zones)
+
+		if list.NextWithContext(ctx) != nil {
+			break
+		}
+	}
+}
+
+func (m *MetricsCollectorAzureRmZone) collectNatGateways(ctx context.Context, logger *log.Entry, subscription subscriptions.Subscription, addResource func(*string, *string, *string, string, *[]string)) {
+	client := network.NewNatGatewaysClientWithBaseURI(azureEnvironment.ResourceManagerEndpoint, *subscription.SubscriptionID)
+	client.Authorizer = AzureAuthorizer
+	client.ResponseInspector = azureResponseInspector(&subscription)
+
+	list, err := client.ListAllComplete(ctx)
+	if err != nil {
+		logger.Panic(err)
+	}
+
+	for list.NotDone() {
+		val := list.Value()
+		addResource(val.ID, val.Name, val.Location, "natGateway", val.Zones)
+
+		if list.NextWithContext(ctx) != nil {
+			break
+		}
+	}
+}
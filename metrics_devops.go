@@ -0,0 +1,279 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/Azure/go-autorest/autorest"
+	"github.com/Azure/go-autorest/autorest/azure"
+	"github.com/Azure/go-autorest/autorest/azure/auth"
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+	prometheusCommon "github.com/webdevops/go-prometheus-common"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const (
+	// Azure AD application (resource) of Azure DevOps, used if no personal access token is set
+	AzureDevOpsResourceId = "499b84ac-1321-427f-aa17-267ca6975798"
+
+	AzureDevOpsApiVersion = "6.0"
+
+	// resource usage (parallel jobs) is only available as preview api
+	AzureDevOpsResourceUsageApiVersion = "6.0-preview"
+)
+
+type MetricsCollectorDevOps struct {
+	CollectorProcessorCustom
+
+	client *autorest.Client
+
+	prometheus struct {
+		agentPool       *prometheus.GaugeVec
+		agentPoolAgents *prometheus.GaugeVec
+		parallelism     *prometheus.GaugeVec
+		success         *prometheus.GaugeVec
+	}
+}
+
+type azureDevOpsAgentPool struct {
+	Id       int64  `json:"id"`
+	Name     string `json:"name"`
+	Size     int64  `json:"size"`
+	IsHosted bool   `json:"isHosted"`
+	PoolType string `json:"poolType"`
+}
+
+type azureDevOpsAgent struct {
+	Name    string `json:"name"`
+	Status  string `json:"status"`
+	Enabled bool   `json:"enabled"`
+}
+
+type azureDevOpsResourceUsage struct {
+	ResourceLimit struct {
+		TotalCount int64 `json:"totalCount"`
+	} `json:"resourceLimit"`
+	UsedCount int64 `json:"usedCount"`
+}
+
+func (m *MetricsCollectorDevOps) Setup(collector *CollectorCustom) {
+	m.CollectorReference = collector
+
+	// init azure devops client
+	client := autorest.NewClientWithUserAgent("azure-resourcemanager-exporter")
+	if opts.DevOps.AccessToken != "" {
+		client.Authorizer = autorest.NewBasicAuthorizer("", opts.DevOps.AccessToken)
+	} else {
+		authorizer, err := auth.NewAuthorizerFromEnvironmentWithResource(AzureDevOpsResourceId)
+		if err != nil {
+			m.logger().Panic(err)
+		}
+		client.Authorizer = authorizer
+	}
+	m.client = &client
+
+	m.prometheus.agentPool = m.newGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_devops_agentpool_info",
+			Help: "Azure DevOps agent pool information",
+		},
+		[]string{
+			"organization",
+			"poolID",
+			"name",
+			"poolType",
+			"hosted",
+		},
+	)
+	m.MustRegister(m.prometheus.agentPool)
+
+	m.prometheus.agentPoolAgents = m.newGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_devops_agentpool_agents",
+			Help: "Azure DevOps agent pool agent count",
+		},
+		[]string{
+			"organization",
+			"poolID",
+			"status",
+			"enabled",
+		},
+	)
+	m.MustRegister(m.prometheus.agentPoolAgents)
+
+	m.prometheus.parallelism = m.newGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_devops_parallelism",
+			Help: "Azure DevOps parallel job quota (limit) and usage (used)",
+		},
+		[]string{
+			"organization",
+			"parallelismTag",
+			"hosted",
+			"type",
+		},
+	)
+	m.MustRegister(m.prometheus.parallelism)
+
+	m.prometheus.success = m.newGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "azurerm_devops_collect_success",
+			Help: "Azure DevOps collection success (0 if the agent pools couldn't be fetched, metrics of the last successful run are kept)",
+		},
+		[]string{
+			"organization",
+		},
+	)
+	m.MustRegister(m.prometheus.success)
+}
+
+func (m *MetricsCollectorDevOps) Collect(ctx context.Context, logger *log.Entry) {
+	agentPoolMetric := prometheusCommon.NewMetricsList()
+	agentPoolAgentsMetric := prometheusCommon.NewMetricsList()
+	parallelismMetric := prometheusCommon.NewMetricsList()
+
+	organization := azureDevOpsOrganizationName(opts.DevOps.Organization)
+
+	poolList := struct {
+		Value []azureDevOpsAgentPool `json:"value"`
+	}{}
+	if err := m.sendRequest(ctx, "/_apis/distributedtask/pools", map[string]interface{}{"api-version": AzureDevOpsApiVersion}, &poolList); err != nil {
+		// custom collectors are not recovered, a failed request must not stop the exporter
+		logger.Errorf("failed to fetch agent pools: %v", err)
+		metricsApply(func() {
+			m.prometheus.success.With(prometheus.Labels{"organization": organization}).Set(0)
+		})
+		return
+	}
+
+	for _, pool := range poolList.Value {
+		poolId := strconv.FormatInt(pool.Id, 10)
+
+		agentPoolMetric.AddInfo(prometheus.Labels{
+			"organization": organization,
+			"poolID":       poolId,
+			"name":         pool.Name,
+			"poolType":     pool.PoolType,
+			"hosted":       boolToString(pool.IsHosted),
+		})
+
+		// agents of hosted pools are managed by Microsoft
+		if pool.IsHosted {
+			continue
+		}
+
+		agentList := struct {
+			Value []azureDevOpsAgent `json:"value"`
+		}{}
+		if err := m.sendRequest(ctx, fmt.Sprintf("/_apis/distributedtask/pools/%v/agents", pool.Id), map[string]interface{}{"api-version": AzureDevOpsApiVersion}, &agentList); err != nil {
+			logger.WithField("agentPool", pool.Name).Error(err)
+			continue
+		}
+
+		agentCount := map[string]map[bool]float64{
+			"online":  {true: 0, false: 0},
+			"offline": {true: 0, false: 0},
+		}
+		for _, agent := range agentList.Value {
+			status := strings.ToLower(agent.Status)
+			if _, exists := agentCount[status]; !exists {
+				agentCount[status] = map[bool]float64{true: 0, false: 0}
+			}
+			agentCount[status][agent.Enabled]++
+		}
+
+		for status, statusCount := range agentCount {
+			for enabled, count := range statusCount {
+				agentPoolAgentsMetric.Add(prometheus.Labels{
+					"organization": organization,
+					"poolID":       poolId,
+					"status":       status,
+					"enabled":      boolToString(enabled),
+				}, count)
+			}
+		}
+	}
+
+	// parallel jobs: public (open source projects) and private, microsoft-hosted and self-hosted
+	for _, parallelismTag := range []string{"Private", "Public"} {
+		for _, hosted := range []bool{true, false} {
+			usage := azureDevOpsResourceUsage{}
+			query := map[string]interface{}{
+				"parallelismTag": parallelismTag,
+				"poolIsHosted":   hosted,
+				"api-version":    AzureDevOpsResourceUsageApiVersion,
+			}
+			if err := m.sendRequest(ctx, "/_apis/distributedtask/resourceusage", query, &usage); err != nil {
+				logger.WithField("parallelismTag", parallelismTag).Error(err)
+				continue
+			}
+
+			parallelismMetric.Add(prometheus.Labels{
+				"organization":   organization,
+				"parallelismTag": strings.ToLower(parallelismTag),
+				"hosted":         boolToString(hosted),
+				"type":           "limit",
+			}, float64(usage.ResourceLimit.TotalCount))
+
+			parallelismMetric.Add(prometheus.Labels{
+				"organization":   organization,
+				"parallelismTag": strings.ToLower(parallelismTag),
+				"hosted":         boolToString(hosted),
+				"type":           "used",
+			}, float64(usage.UsedCount))
+		}
+	}
+
+	metricsApply(func() {
+		m.prometheus.agentPool.Reset()
+		m.prometheus.agentPoolAgents.Reset()
+		m.prometheus.parallelism.Reset()
+
+		agentPoolMetric.GaugeSet(m.prometheus.agentPool)
+		agentPoolAgentsMetric.GaugeSet(m.prometheus.agentPoolAgents)
+		parallelismMetric.GaugeSet(m.prometheus.parallelism)
+		m.prometheus.success.With(prometheus.Labels{"organization": organization}).Set(1)
+	})
+}
+
+// sendRequest sends a GET request to the Azure DevOps organization and unmarshals the json response into result
+func (m *MetricsCollectorDevOps) sendRequest(ctx context.Context, path string, query map[string]interface{}, result interface{}) error {
+	req, err := autorest.Prepare(
+		(&http.Request{}).WithContext(ctx),
+		autorest.AsGet(),
+		autorest.WithBaseURL(strings.TrimSuffix(opts.DevOps.Organization, "/")),
+		autorest.WithPath(path),
+		autorest.WithQueryParameters(query),
+	)
+	if err != nil {
+		return err
+	}
+
+	resp, err := m.client.Send(req, autorest.DoRetryForStatusCodes(m.client.RetryAttempts, m.client.RetryDuration, autorest.StatusCodesForRetry...))
+	if err != nil {
+		return err
+	}
+
+	return autorest.Respond(
+		resp,
+		azure.WithErrorUnlessStatusCode(http.StatusOK),
+		autorest.ByUnmarshallingJSON(result),
+		autorest.ByClosing(),
+	)
+}
+
+// azureDevOpsOrganizationName returns the organization name of an organization url
+// (https://dev.azure.com/org or https://org.visualstudio.com)
+func azureDevOpsOrganizationName(organizationUrl string) string {
+	organization := strings.TrimSuffix(organizationUrl, "/")
+	organization = strings.TrimPrefix(organization, "https://")
+	organization = strings.TrimPrefix(organization, "http://")
+
+	if strings.HasPrefix(organization, "dev.azure.com/") {
+		return strings.TrimPrefix(organization, "dev.azure.com/")
+	}
+
+	return strings.TrimSuffix(organization, ".visualstudio.com")
+}
@@ -18,7 +18,7 @@ type MetricsCollectorExporter struct {
 func (m *MetricsCollectorExporter) Setup(collector *CollectorCustom) {
 	m.CollectorReference = collector
 
-	m.prometheus.stats = prometheus.NewGaugeVec(
+	m.prometheus.stats = m.newGaugeVec(
 		prometheus.GaugeOpts{
 			Name: "azurerm_stats",
 			Help: "Azure ResourceManager stats",
@@ -28,7 +28,7 @@ func (m *MetricsCollectorExporter) Setup(collector *CollectorCustom) {
 			"type",
 		},
 	)
-	prometheus.MustRegister(m.prometheus.stats)
+	m.MustRegister(m.prometheus.stats)
 }
 
 func (m *MetricsCollectorExporter) Collect(ctx context.Context, logger *log.Entry) {
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"fmt"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+const (
+	// label added to the metrics of peer instances
+	FederationPeerLabel = "federationPeer"
+)
+
+// federationPeerGatherer fetches the metrics of a sibling exporter instance (--federation-peer)
+type federationPeerGatherer struct {
+	url    string
+	client *http.Client
+}
+
+func newFederationPeerGatherer(url string) federationPeerGatherer {
+	if !strings.Contains(strings.TrimPrefix(strings.TrimPrefix(url, "http://"), "https://"), "/") {
+		url = strings.TrimSuffix(url, "/") + "/metrics"
+	}
+
+	return federationPeerGatherer{
+		url:    url,
+		client: &http.Client{Timeout: opts.Federation.Timeout},
+	}
+}
+
+// Gather fetches and parses the metrics of the peer and adds the peer label to all metrics
+func (g federationPeerGatherer) Gather() ([]*dto.MetricFamily, error) {
+	resp, err := g.client.Get(g.url)
+	if err != nil {
+		return nil, fmt.Errorf("federation peer %v: %v", g.url, err)
+	}
+	defer resp.Body.Close() //nolint:errcheck
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("federation peer %v: unexpected status code %v", g.url, resp.StatusCode)
+	}
+
+	parser := expfmt.TextParser{}
+	metricFamilies, err := parser.TextToMetricFamilies(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("federation peer %v: %v", g.url, err)
+	}
+
+	labelName := FederationPeerLabel
+	labelValue := g.url
+
+	ret := []*dto.MetricFamily{}
+	for _, metricFamily := range metricFamilies {
+		for _, metric := range metricFamily.Metric {
+			metric.Label = append(metric.Label, &dto.LabelPair{Name: &labelName, Value: &labelValue})
+			sort.Slice(metric.Label, func(i, j int) bool {
+				return metric.Label[i].GetName() < metric.Label[j].GetName()
+			})
+		}
+		ret = append(ret, metricFamily)
+	}
+
+	return ret, nil
+}
@@ -32,7 +32,7 @@ func (m *MetricsCollectorGraphApps) Setup(collector *CollectorCustom) {
 
 	m.client = &client
 
-	m.prometheus.apps = prometheus.NewGaugeVec(
+	m.prometheus.apps = m.newGaugeVec(
 		prometheus.GaugeOpts{
 			Name: "azurerm_graph_app_info",
 			Help: "Azure GraphQL applications information",
@@ -44,9 +44,9 @@ func (m *MetricsCollectorGraphApps) Setup(collector *CollectorCustom) {
 			"appObjectType",
 		},
 	)
-	prometheus.MustRegister(m.prometheus.apps)
+	m.MustRegister(m.prometheus.apps)
 
-	m.prometheus.appsCredentials = prometheus.NewGaugeVec(
+	m.prometheus.appsCredentials = m.newGaugeVec(
 		prometheus.GaugeOpts{
 			Name: "azurerm_graph_app_credential",
 			Help: "Azure GraphQL application credentials status",
@@ -58,7 +58,7 @@ func (m *MetricsCollectorGraphApps) Setup(collector *CollectorCustom) {
 			"type",
 		},
 	)
-	prometheus.MustRegister(m.prometheus.appsCredentials)
+	m.MustRegister(m.prometheus.appsCredentials)
 }
 
 func (m *MetricsCollectorGraphApps) Collect(ctx context.Context, logger *log.Entry) {
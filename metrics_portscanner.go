@@ -8,6 +8,8 @@ import (
 	"github.com/prometheus/client_golang/prometheus"
 	log "github.com/sirupsen/logrus"
 	"os"
+	"strings"
+	"time"
 )
 
 type MetricsCollectorPortscanner struct {
@@ -29,7 +31,7 @@ func (m *MetricsCollectorPortscanner) Setup(collector *CollectorCustom) {
 	m.portscanner = &Portscanner{}
 	m.portscanner.Init()
 
-	m.prometheus.publicIpInfo = prometheus.NewGaugeVec(
+	m.prometheus.publicIpInfo = m.newGaugeVec(
 		prometheus.GaugeOpts{
 			Name: "azurerm_publicip_info",
 			Help: "Azure ResourceManager public ip resource information",
@@ -39,13 +41,23 @@ func (m *MetricsCollectorPortscanner) Setup(collector *CollectorCustom) {
 			"resourceID",
 			"resourceGroup",
 			"name",
+			"location",
 			"ipAddressVersion",
 			"ipAddress",
+			"allocationMethod",
+			"skuName",
+			"skuTier",
+			"zones",
+			"fqdn",
+			"dnsLabel",
+			"attachedResourceID",
+			"attachedResourceType",
+			"attachedResourceName",
 		},
 	)
-	prometheus.MustRegister(m.prometheus.publicIpInfo)
+	m.MustRegister(m.prometheus.publicIpInfo)
 
-	m.prometheus.publicIpPortscanStatus = prometheus.NewGaugeVec(
+	m.prometheus.publicIpPortscanStatus = m.newGaugeVec(
 		prometheus.GaugeOpts{
 			Name: "azurerm_publicip_portscan_status",
 			Help: "Azure ResourceManager public ip portscan status",
@@ -55,9 +67,9 @@ func (m *MetricsCollectorPortscanner) Setup(collector *CollectorCustom) {
 			"type",
 		},
 	)
-	prometheus.MustRegister(m.prometheus.publicIpPortscanStatus)
+	m.MustRegister(m.prometheus.publicIpPortscanStatus)
 
-	m.prometheus.publicIpPortscanPort = prometheus.NewGaugeVec(
+	m.prometheus.publicIpPortscanPort = m.newGaugeVec(
 		prometheus.GaugeOpts{
 			Name: "azurerm_publicip_portscan_port",
 			Help: "Azure ResourceManager public ip open port",
@@ -69,7 +81,7 @@ func (m *MetricsCollectorPortscanner) Setup(collector *CollectorCustom) {
 			"description",
 		},
 	)
-	prometheus.MustRegister(m.prometheus.publicIpPortscanPort)
+	m.MustRegister(m.prometheus.publicIpPortscanPort)
 
 	m.portscanner.Callbacks.FinishScan = func(c *Portscanner) {
 		m.logger().Infof("finished for %v IPs", len(m.portscanner.PublicIps))
@@ -78,16 +90,18 @@ func (m *MetricsCollectorPortscanner) Setup(collector *CollectorCustom) {
 			m.logger().Infof("saved to cache")
 			m.portscanner.CacheSave(opts.Cache.Path)
 		}
+
+		portscannerSinksSend(c, m.logger())
 	}
 
 	m.portscanner.Callbacks.StartupScan = func(c *Portscanner) {
 		m.logger().Infof(
 			"starting for %v IPs (parallel:%v, threads per run:%v, timeout:%vs, portranges:%v)",
 			len(c.PublicIps),
-			opts.Portscan.Parallel,
-			opts.Portscan.Threads,
-			opts.Portscan.Timeout,
-			portscanPortRange,
+			c.Settings.Parallel,
+			c.Settings.Threads,
+			c.Settings.Timeout.Seconds(),
+			c.Settings.PortRange,
 		)
 
 		m.prometheus.publicIpPortscanStatus.Reset()
@@ -135,6 +149,11 @@ func (m *MetricsCollectorPortscanner) Setup(collector *CollectorCustom) {
 		m.prometheus.publicIpPortscanPort.With(result.Labels).Set(result.Value)
 	}
 
+	// results are published while scanning, not only after the collection run
+	m.portscanner.Callbacks.ResultPublish = func(c *Portscanner) {
+		metricsPublish(m.CollectorReference.Name, time.Now())
+	}
+
 	if opts.Cache.Path != "" {
 		if _, err := os.Stat(opts.Cache.Path); !os.IsNotExist(err) {
 			m.logger().Infof("load from cache")
@@ -144,7 +163,21 @@ func (m *MetricsCollectorPortscanner) Setup(collector *CollectorCustom) {
 }
 
 func (m *MetricsCollectorPortscanner) Collect(ctx context.Context, logger *log.Entry) {
-	publicIpList := m.fetchPublicIpAdresses(ctx, logger, m.CollectorReference.AzureSubscriptions)
+	publicIpList := m.fetchPublicIpAdresses(ctx, logger, m.CollectorReference.GetAzureSubscriptions())
+
+	// public ips of subscriptions in a blackout window are not scanned
+	if len(blackoutWindows) > 0 {
+		scanList := []network.PublicIPAddress{}
+		for _, pip := range publicIpList {
+			subscription := subscriptions.Subscription{SubscriptionID: to.StringPtr(extractSubscriptionIdFromAzureId(to.String(pip.ID)))}
+			if isBlackout, window := blackoutActive(subscription, BlackoutTargetPortscan); isBlackout {
+				logger.WithField("ipAddress", to.String(pip.IPAddress)).Debugf("port scan paused by blackout window \"%v\"", window)
+				continue
+			}
+			scanList = append(scanList, pip)
+		}
+		publicIpList = scanList
+	}
 
 	m.portscanner.SetAzurePublicIpList(publicIpList)
 
@@ -176,17 +209,79 @@ func (m *MetricsCollectorPortscanner) fetchPublicIpAdresses(ctx context.Context,
 		}
 	}
 
-	m.prometheus.publicIpInfo.Reset()
-	for _, pip := range pipList {
-		m.prometheus.publicIpInfo.With(prometheus.Labels{
-			"subscriptionID":   extractSubscriptionIdFromAzureId(to.String(pip.ID)),
-			"resourceID":       toResourceId(pip.ID),
-			"resourceGroup":    extractResourceGroupFromAzureId(to.String(pip.ID)),
-			"name":             to.String(pip.Name),
-			"ipAddressVersion": string(pip.PublicIPAddressVersion),
-			"ipAddress":        to.String(pip.IPAddress),
-		}).Set(1)
-	}
+	metricsApply(func() {
+		m.prometheus.publicIpInfo.Reset()
+		for _, pip := range pipList {
+			infoLabels := prometheus.Labels{
+				"subscriptionID":       extractSubscriptionIdFromAzureId(to.String(pip.ID)),
+				"resourceID":           toResourceId(pip.ID),
+				"resourceGroup":        extractResourceGroupFromAzureId(to.String(pip.ID)),
+				"name":                 to.String(pip.Name),
+				"location":             strings.ToLower(to.String(pip.Location)),
+				"ipAddressVersion":     string(pip.PublicIPAddressVersion),
+				"ipAddress":            to.String(pip.IPAddress),
+				"allocationMethod":     string(pip.PublicIPAllocationMethod),
+				"skuName":              "",
+				"skuTier":              "",
+				"zones":                "",
+				"fqdn":                 "",
+				"dnsLabel":             "",
+				"attachedResourceID":   "",
+				"attachedResourceType": "",
+				"attachedResourceName": "",
+			}
+
+			if pip.Sku != nil {
+				infoLabels["skuName"] = string(pip.Sku.Name)
+				infoLabels["skuTier"] = string(pip.Sku.Tier)
+			}
+
+			if pip.Zones != nil {
+				infoLabels["zones"] = strings.Join(*pip.Zones, ",")
+			}
+
+			if pip.DNSSettings != nil {
+				infoLabels["fqdn"] = to.String(pip.DNSSettings.Fqdn)
+				infoLabels["dnsLabel"] = to.String(pip.DNSSettings.DomainNameLabel)
+			}
+
+			if attachedResourceId := publicIpAttachedResourceId(pip); attachedResourceId != "" {
+				infoLabels["attachedResourceID"] = toResourceId(&attachedResourceId)
+				infoLabels["attachedResourceType"] = extractResourceTypeFromAzureId(attachedResourceId)
+				infoLabels["attachedResourceName"] = attachedResourceId[strings.LastIndex(attachedResourceId, "/")+1:]
+			}
+
+			m.prometheus.publicIpInfo.With(infoLabels).Set(1)
+		}
+	})
 
 	return pipList
 }
+
+// publicIpAttachedResourceId returns the id of the resource using the public ip (eg. network interface, load balancer,
+// nat gateway or bastion host), ip configuration ids are trimmed to the parent resource
+func publicIpAttachedResourceId(pip network.PublicIPAddress) string {
+	if pip.PublicIPAddressPropertiesFormat == nil {
+		return ""
+	}
+
+	attachedId := ""
+	if pip.IPConfiguration != nil {
+		attachedId = to.String(pip.IPConfiguration.ID)
+	} else if pip.NatGateway != nil {
+		attachedId = to.String(pip.NatGateway.ID)
+	}
+
+	providerIndex := strings.LastIndex(strings.ToLower(attachedId), "/providers/")
+	if providerIndex < 0 {
+		return attachedId
+	}
+
+	// <provider>/<type>/<name>[/<childType>/<childName>]
+	parts := strings.Split(strings.Trim(attachedId[providerIndex+len("/providers/"):], "/"), "/")
+	if len(parts) > 3 {
+		parts = parts[:3]
+	}
+
+	return attachedId[:providerIndex] + "/providers/" + strings.Join(parts, "/")
+}
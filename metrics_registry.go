@@ -0,0 +1,384 @@
+package main
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	log "github.com/sirupsen/logrus"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	// metrics of the collectors are applied (reset and set) to a registry per collector (working buffer) while holding
+	// the lock, finished collection runs are published (double buffer) and scrapes only see published runs
+	metricsRegistryLock sync.Mutex
+
+	// collector name (lowercase) of each metric and registry (working buffer) of each collector
+	metricsCollectorByMetric     = map[string]string{}
+	metricsCollectorRegistries   = map[string]*prometheus.Registry{}
+	metricsCollectorByMetricLock sync.RWMutex
+
+	// published collection runs by collector name (lowercase), map[string]metricsPublishedRun replaced atomically
+	metricsPublished atomic.Value
+
+	// registerer of the metrics of a collector (replaced by generate-dashboards to record the metadata)
+	metricsCollectorRegisterer = func(collectorName string) prometheus.Registerer {
+		return metricsCollectorRegistry(collectorName)
+	}
+)
+
+// metricsPublishedRun is the gathered metrics of the last finished collection run of a collector, the metrics are
+// shared by the scrapes and must not be modified
+type metricsPublishedRun struct {
+	metricFamilies []*dto.MetricFamily
+	collectionTime time.Time
+}
+
+type metricsRegistryGatherer struct {
+	prometheus.Gatherer
+
+	// only gather metrics of these collectors (lowercase, --metrics.group), all metrics if nil
+	collectors map[string]bool
+}
+
+// metricsGroup is a group of collectors served on /metrics/<name> (--metrics.group)
+type metricsGroup struct {
+	name       string
+	bind       string
+	collectors map[string]bool
+}
+
+// metricsCollectorRegistry returns the registry (working buffer) of the collector
+func metricsCollectorRegistry(collectorName string) *prometheus.Registry {
+	collectorName = strings.ToLower(collectorName)
+
+	metricsCollectorByMetricLock.Lock()
+	defer metricsCollectorByMetricLock.Unlock()
+
+	if _, exists := metricsCollectorRegistries[collectorName]; !exists {
+		metricsCollectorRegistries[collectorName] = prometheus.NewRegistry()
+	}
+	return metricsCollectorRegistries[collectorName]
+}
+
+// metricsApply applies metric updates to the working buffer, the updates are visible after metricsPublish
+func metricsApply(apply func()) {
+	metricsRegistryLock.Lock()
+	defer metricsRegistryLock.Unlock()
+	apply()
+}
+
+// metricsCollectorRegister remembers the collector of a metric (registry, collection timestamps, --metrics.group)
+func metricsCollectorRegister(collectorName, metricName string) {
+	metricsCollectorByMetricLock.Lock()
+	defer metricsCollectorByMetricLock.Unlock()
+	metricsCollectorByMetric[metricName] = strings.ToLower(collectorName)
+}
+
+// metricsPublish publishes the metrics of a finished collection run of the collector, scrapes see either the
+// previous or the new run (must be called inside metricsApply)
+func metricsPublish(collectorName string, collectionTime time.Time) {
+	metricsPublishKeeping(collectorName, collectionTime, nil)
+}
+
+// metricsPublishKeeping publishes the run like metricsPublish, the metrics of the kept subscriptions (lowercase,
+// not collected by the run) are taken from the previous run of the collector
+func metricsPublishKeeping(collectorName string, collectionTime time.Time, keptSubscriptions map[string]bool) {
+	collectorName = strings.ToLower(collectorName)
+
+	metricsCollectorByMetricLock.RLock()
+	registry, exists := metricsCollectorRegistries[collectorName]
+	metricsCollectorByMetricLock.RUnlock()
+	if !exists {
+		return
+	}
+
+	metricFamilies, err := registry.Gather()
+	if err != nil {
+		log.WithField("collector", collectorName).Errorf("failed to publish metrics: %v", err)
+		return
+	}
+
+	// copy on write, scrapes keep reading the previous map
+	published := map[string]metricsPublishedRun{}
+	if previous, ok := metricsPublished.Load().(map[string]metricsPublishedRun); ok {
+		for name, run := range previous {
+			published[name] = run
+		}
+	}
+
+	if len(keptSubscriptions) > 0 {
+		metricFamilies = metricFamiliesKeepSubscriptions(metricFamilies, published[collectorName].metricFamilies, keptSubscriptions)
+	}
+
+	published[collectorName] = metricsPublishedRun{metricFamilies: metricFamilies, collectionTime: collectionTime}
+	metricsPublished.Store(published)
+}
+
+// metricFamiliesKeepSubscriptions replaces the metrics of the kept subscriptions (subscriptionID label) by the
+// metrics of the previous run, the metrics of the previous run are shared and not modified
+func metricFamiliesKeepSubscriptions(metricFamilies, previousMetricFamilies []*dto.MetricFamily, keptSubscriptions map[string]bool) []*dto.MetricFamily {
+	isKept := func(metric *dto.Metric) bool {
+		for _, label := range metric.Label {
+			if label.GetName() == "subscriptionID" {
+				return keptSubscriptions[strings.ToLower(label.GetValue())]
+			}
+		}
+		return false
+	}
+
+	metricFamilyByName := map[string]*dto.MetricFamily{}
+	for _, metricFamily := range metricFamilies {
+		metrics := []*dto.Metric{}
+		for _, metric := range metricFamily.Metric {
+			if !isKept(metric) {
+				metrics = append(metrics, metric)
+			}
+		}
+		metricFamily.Metric = metrics
+		metricFamilyByName[metricFamily.GetName()] = metricFamily
+	}
+
+	for _, previousMetricFamily := range previousMetricFamilies {
+		for _, metric := range previousMetricFamily.Metric {
+			if !isKept(metric) {
+				continue
+			}
+
+			metricFamily, exists := metricFamilyByName[previousMetricFamily.GetName()]
+			if !exists {
+				keptMetricFamily := *previousMetricFamily
+				keptMetricFamily.Metric = nil
+				metricFamily = &keptMetricFamily
+				metricFamilyByName[metricFamily.GetName()] = metricFamily
+				metricFamilies = append(metricFamilies, metricFamily)
+			}
+			metricFamily.Metric = append(metricFamily.Metric, metric)
+		}
+	}
+
+	ret := []*dto.MetricFamily{}
+	for _, metricFamily := range metricFamilies {
+		if len(metricFamily.Metric) > 0 {
+			ret = append(ret, metricFamily)
+		}
+	}
+	return ret
+}
+
+// metricsGather gathers the metrics of the gatherer (exporter metrics) and the published collection runs,
+// the metrics of the published runs are only copied (and can be modified) if modified is set
+func metricsGather(gatherer prometheus.Gatherer, modified bool) ([]*dto.MetricFamily, error) {
+	metricFamilies, err := gatherer.Gather()
+
+	published, _ := metricsPublished.Load().(map[string]metricsPublishedRun)
+	for _, run := range published {
+		for _, metricFamily := range run.metricFamilies {
+			if modified || opts.Metrics.Timestamps {
+				metricFamily = metricFamilyCopy(metricFamily, run.collectionTime)
+			}
+			metricFamilies = append(metricFamilies, metricFamily)
+		}
+	}
+
+	sort.Slice(metricFamilies, func(i, j int) bool {
+		return metricFamilies[i].GetName() < metricFamilies[j].GetName()
+	})
+
+	return metricFamilies, err
+}
+
+// metricFamilyCopy copies the metric family (and labels) of a published run, samples get the collection time
+// as timestamp with --metrics.timestamps
+func metricFamilyCopy(metricFamily *dto.MetricFamily, collectionTime time.Time) *dto.MetricFamily {
+	timestampMs := collectionTime.UnixNano() / int64(time.Millisecond)
+
+	ret := *metricFamily
+	ret.Metric = make([]*dto.Metric, len(metricFamily.Metric))
+	for i, metric := range metricFamily.Metric {
+		metricCopy := *metric
+		metricCopy.Label = append([]*dto.LabelPair{}, metric.Label...)
+		if opts.Metrics.Timestamps {
+			metricCopy.TimestampMs = &timestampMs
+		}
+		ret.Metric[i] = &metricCopy
+	}
+
+	return &ret
+}
+
+// Gather gathers the exporter metrics and the published collection runs of the collectors
+func (g metricsRegistryGatherer) Gather() ([]*dto.MetricFamily, error) {
+	// published runs are shared by all scrapes and only copied if transformed
+	subscriptionMappingTransform := len(subscriptionMappingLabelNames) > 0 || opts.Metrics.HierarchyLabels
+	compatTransform := opts.Metrics.ManagedPrometheus || opts.Metrics.NamePrefix != "" || opts.Metrics.Cluster != ""
+
+	metricFamilies, err := metricsGather(g.Gatherer, subscriptionMappingTransform || compatTransform)
+
+	metricsCollectorByMetricLock.RLock()
+	defer metricsCollectorByMetricLock.RUnlock()
+
+	if g.collectors != nil {
+		filteredMetricFamilies := []*dto.MetricFamily{}
+		for _, metricFamily := range metricFamilies {
+			if g.collectors[metricsCollectorByMetric[metricFamily.GetName()]] {
+				filteredMetricFamilies = append(filteredMetricFamilies, metricFamily)
+			}
+		}
+		metricFamilies = filteredMetricFamilies
+	}
+
+	if len(metricsAllowList) > 0 || len(metricsDenyList) > 0 {
+		filteredMetricFamilies := []*dto.MetricFamily{}
+		for _, metricFamily := range metricFamilies {
+			if metricsFilterAllowed(metricFamily.GetName()) {
+				filteredMetricFamilies = append(filteredMetricFamilies, metricFamily)
+			}
+		}
+		metricFamilies = filteredMetricFamilies
+	}
+
+	if subscriptionMappingTransform {
+		metricsSubscriptionMappingTransform(metricFamilies)
+	}
+
+	if compatTransform {
+		metricsCompatTransform(metricFamilies)
+	}
+
+	return metricFamilies, err
+}
+
+// metricsFilterAllowed checks a metric against --metrics.allow and --metrics.deny,
+// patterns without collector apply to the metrics of all collectors
+func metricsFilterAllowed(metricName string) bool {
+	collectorName, exists := metricsCollectorByMetric[metricName]
+	if !exists {
+		// exporter internal metrics (eg. go_*) are not filtered
+		return true
+	}
+
+	matches := func(patterns []string) bool {
+		for _, pattern := range patterns {
+			if matched, _ := path.Match(pattern, metricName); matched {
+				return true
+			}
+		}
+		return false
+	}
+
+	for _, name := range []string{"", collectorName} {
+		if allowList, exists := metricsAllowList[name]; exists && !matches(allowList) {
+			return false
+		}
+
+		if matches(metricsDenyList[name]) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// metricsExportName returns the exported name of a metric (--metrics.name-prefix, --metrics.managed-prometheus)
+func metricsExportName(name string) string {
+	name = opts.Metrics.NamePrefix + name
+	if opts.Metrics.ManagedPrometheus {
+		// colons are reserved for recording rules
+		name = strings.ReplaceAll(name, ":", "_")
+	}
+	return name
+}
+
+// metricsCompatTransform applies the naming conventions of Azure Monitor managed Prometheus
+// (--metrics.managed-prometheus, --metrics.name-prefix and --metrics.cluster)
+func metricsCompatTransform(metricFamilies []*dto.MetricFamily) {
+	clusterLabelName := "cluster"
+	clusterLabelValue := opts.Metrics.Cluster
+
+	for _, metricFamily := range metricFamilies {
+		name := metricsExportName(metricFamily.GetName())
+		metricFamily.Name = &name
+
+		if clusterLabelValue == "" {
+			continue
+		}
+
+		for _, metric := range metricFamily.Metric {
+			labelExists := false
+			for _, label := range metric.Label {
+				if label.GetName() == clusterLabelName {
+					labelExists = true
+					break
+				}
+			}
+
+			if !labelExists {
+				metric.Label = append(metric.Label, &dto.LabelPair{Name: &clusterLabelName, Value: &clusterLabelValue})
+				sort.Slice(metric.Label, func(i, j int) bool {
+					return metric.Label[i].GetName() < metric.Label[j].GetName()
+				})
+			}
+		}
+	}
+}
+
+// metricsSubscriptionMappingTransform adds the labels of --subscription-mapping and --metrics.hierarchy-labels to all
+// metrics with a subscriptionID label, all labels are added (empty if not set for the subscription) to keep the label
+// names of a metric family consistent
+func metricsSubscriptionMappingTransform(metricFamilies []*dto.MetricFamily) {
+	labelNames := subscriptionMappingLabelNames
+	if opts.Metrics.HierarchyLabels {
+		labelNames = append([]string{AZURE_HIERARCHY_LABEL_SUBSCRIPTION_NAME, AZURE_HIERARCHY_LABEL_MANAGEMENTGROUP_PATH}, labelNames...)
+	}
+
+	// hierarchy labels by subscription (lowercase id), looked up once per gather
+	hierarchyLabelsCache := map[string]map[string]string{}
+
+	for _, metricFamily := range metricFamilies {
+		for _, metric := range metricFamily.Metric {
+			subscriptionId := ""
+			existingLabels := map[string]bool{}
+			for _, label := range metric.Label {
+				existingLabels[label.GetName()] = true
+				if label.GetName() == "subscriptionID" {
+					subscriptionId = strings.ToLower(label.GetValue())
+				}
+			}
+
+			if subscriptionId == "" {
+				continue
+			}
+
+			mappingLabels := subscriptionMappingLabels[subscriptionId]
+			hierarchyLabels, exists := hierarchyLabelsCache[subscriptionId]
+			if !exists && opts.Metrics.HierarchyLabels {
+				hierarchyLabels = azureHierarchy.hierarchyLabels(subscriptionId)
+				hierarchyLabelsCache[subscriptionId] = hierarchyLabels
+			}
+
+			for _, labelName := range labelNames {
+				// labels of the metric are not overwritten
+				if existingLabels[labelName] {
+					continue
+				}
+
+				value, exists := hierarchyLabels[labelName]
+				if !exists {
+					value = mappingLabels[labelName]
+				}
+				name := labelName
+				metric.Label = append(metric.Label, &dto.LabelPair{Name: &name, Value: &value})
+				existingLabels[labelName] = true
+			}
+
+			sort.Slice(metric.Label, func(i, j int) bool {
+				return metric.Label[i].GetName() < metric.Label[j].GetName()
+			})
+		}
+	}
+}
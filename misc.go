@@ -1,9 +1,13 @@
 package main
 
 import (
+	"fmt"
 	"github.com/Azure/go-autorest/autorest/to"
+	log "github.com/sirupsen/logrus"
 	"regexp"
+	"strconv"
 	"strings"
+	"time"
 )
 
 var (
@@ -11,6 +15,9 @@ var (
 	resourceGroupFromResourceIdRegExp = regexp.MustCompile("/subscriptions/[^/]+/resourceGroups/([^/]*)")
 	providerFromResourceIdRegExp      = regexp.MustCompile("/subscriptions/[^/]+/resourceGroups/[^/]+/providers/([^/]*)")
 	roleDefinitionIdRegExp            = regexp.MustCompile("/Microsoft.Authorization/roleDefinitions/([^/]*)")
+	timespanRegExp                    = regexp.MustCompile(`^(?:([0-9]+)\.)?([0-9]+):([0-9]+):([0-9]+)(?:\.[0-9]+)?$`)
+	azureErrorForbiddenRegExp         = regexp.MustCompile(`StatusCode=403\b`)
+	azureErrorBudgetRegExp            = regexp.MustCompile(`\b` + collectorBudgetErrorPrefix + `: `)
 )
 
 func toResourceId(val *string) (resourceId string) {
@@ -52,6 +59,28 @@ func extractProviderFromAzureId(azureId string) (provider string) {
 	return
 }
 
+// extractResourceTypeFromAzureId returns the (lowercase) resource type of a resource id,
+// eg. microsoft.network/loadbalancers/backendaddresspools
+func extractResourceTypeFromAzureId(azureId string) (resourceType string) {
+	azureIdLower := strings.ToLower(azureId)
+
+	providerIndex := strings.LastIndex(azureIdLower, "/providers/")
+	if providerIndex < 0 {
+		if resourceGroupFromResourceIdRegExp.MatchString(azureId) {
+			return "microsoft.resources/resourcegroups"
+		}
+		return
+	}
+
+	parts := strings.Split(strings.Trim(azureIdLower[providerIndex+len("/providers/"):], "/"), "/")
+	typeParts := []string{parts[0]}
+	for i := 1; i < len(parts); i += 2 {
+		typeParts = append(typeParts, parts[i])
+	}
+
+	return strings.Join(typeParts, "/")
+}
+
 func extractRoleDefinitionIdFromAzureId(azureId string) (roleDefinitionId string) {
 	if subMatch := roleDefinitionIdRegExp.FindStringSubmatch(azureId); len(subMatch) >= 1 {
 		roleDefinitionId = subMatch[1]
@@ -71,3 +100,57 @@ func stringsTrimSuffixCI(str, suffix string) string {
 
 	return str
 }
+
+// parses .NET timespan format (eg "13.23:59:59") as used by some Azure APIs
+func parseTimespan(val string) (duration time.Duration, err error) {
+	subMatch := timespanRegExp.FindStringSubmatch(val)
+	if len(subMatch) == 0 {
+		err = fmt.Errorf("unable to parse timespan \"%v\"", val)
+		return
+	}
+
+	units := []time.Duration{24 * time.Hour, time.Hour, time.Minute, time.Second}
+	for i, unit := range units {
+		if subMatch[i+1] == "" {
+			continue
+		}
+
+		value, parseErr := strconv.ParseInt(subMatch[i+1], 10, 64)
+		if parseErr != nil {
+			err = parseErr
+			return
+		}
+		duration += time.Duration(value) * unit
+	}
+
+	return
+}
+
+func boolToString(val bool) string {
+	if val {
+		return "true"
+	}
+	return "false"
+}
+
+// azureErrorIsForbidden checks if an (recovered) Azure API error is a 403 Forbidden response,
+// autorest errors are formatted as "...: StatusCode=403 -- Original Error: ..."
+func azureErrorIsForbidden(err interface{}) bool {
+	switch v := err.(type) {
+	case *log.Entry:
+		return azureErrorForbiddenRegExp.MatchString(v.Message)
+	default:
+		return azureErrorForbiddenRegExp.MatchString(fmt.Sprint(v))
+	}
+}
+
+// azureErrorIsBudget checks if an (recovered) Azure API error is a list call aborted or skipped by --scrape-budget,
+// the transport error is wrapped by autorest as "...: Original Error: Get \"...\": scrape budget: ..."
+func azureErrorIsBudget(err interface{}) bool {
+	switch v := err.(type) {
+	case *log.Entry:
+		return azureErrorBudgetRegExp.MatchString(v.Message)
+	default:
+		return azureErrorBudgetRegExp.MatchString(fmt.Sprint(v))
+	}
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseTimespan(t *testing.T) {
+	testCases := []struct {
+		value    string
+		wantErr  bool
+		duration time.Duration
+	}{
+		{value: "00:05:00", duration: 5 * time.Minute},
+		{value: "01:02:03", duration: time.Hour + 2*time.Minute + 3*time.Second},
+		{value: "13.23:59:59", duration: 13*24*time.Hour + 23*time.Hour + 59*time.Minute + 59*time.Second},
+		{value: "1.00:00:00.5000000", duration: 24 * time.Hour},
+		{value: "", wantErr: true},
+		{value: "5", wantErr: true},
+		{value: "P1D", wantErr: true},
+		{value: "1.00:00", wantErr: true},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.value, func(t *testing.T) {
+			duration, err := parseTimespan(testCase.value)
+			if testCase.wantErr {
+				if err == nil {
+					t.Fatalf("expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if duration != testCase.duration {
+				t.Errorf("expected %v, got %v", testCase.duration, duration)
+			}
+		})
+	}
+}
+
+func TestExtractResourceTypeFromAzureId(t *testing.T) {
+	testCases := []struct {
+		azureId      string
+		resourceType string
+	}{
+		{
+			azureId:      "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/rg/providers/Microsoft.Storage/storageAccounts/account",
+			resourceType: "microsoft.storage/storageaccounts",
+		},
+		{
+			azureId:      "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/rg/providers/Microsoft.Network/loadBalancers/lb/backendAddressPools/pool",
+			resourceType: "microsoft.network/loadbalancers/backendaddresspools",
+		},
+		{
+			// extension resources use the last provider
+			azureId:      "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/rg/providers/Microsoft.Compute/virtualMachines/vm/providers/Microsoft.Insights/diagnosticSettings/setting",
+			resourceType: "microsoft.insights/diagnosticsettings",
+		},
+		{
+			azureId:      "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/rg",
+			resourceType: "microsoft.resources/resourcegroups",
+		},
+		{
+			azureId:      "/subscriptions/00000000-0000-0000-0000-000000000000",
+			resourceType: "",
+		},
+		{
+			azureId:      "",
+			resourceType: "",
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.azureId, func(t *testing.T) {
+			if resourceType := extractResourceTypeFromAzureId(testCase.azureId); resourceType != testCase.resourceType {
+				t.Errorf("expected %q, got %q", testCase.resourceType, resourceType)
+			}
+		})
+	}
+}
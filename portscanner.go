@@ -21,10 +21,19 @@ type PortscannerResult struct {
 	Value     float64
 }
 
+// PortscannerSettings are taken from the configuration at the start of each scan (may change with --config-watch)
+type PortscannerSettings struct {
+	Parallel  int
+	Threads   int
+	Timeout   time.Duration
+	PortRange []Portrange
+}
+
 type Portscanner struct {
 	List      map[string][]PortscannerResult
 	PublicIps map[string]network.PublicIPAddress
-	Enabled   bool `json:"-"`
+	Enabled   bool                `json:"-"`
+	Settings  PortscannerSettings `json:"-"`
 	mux       sync.Mutex
 
 	logger *log.Entry
@@ -36,6 +45,7 @@ type Portscanner struct {
 		FinishScanIpAdress func(c *Portscanner, pip network.PublicIPAddress, elapsed float64)
 		ResultCleanup      func(c *Portscanner)
 		ResultPush         func(c *Portscanner, result PortscannerResult)
+		ResultPublish      func(c *Portscanner)
 	} `json:"-"`
 }
 
@@ -52,6 +62,7 @@ func (c *Portscanner) Init() {
 	c.Callbacks.FinishScanIpAdress = func(c *Portscanner, pip network.PublicIPAddress, elapsed float64) {}
 	c.Callbacks.ResultCleanup = func(c *Portscanner) {}
 	c.Callbacks.ResultPush = func(c *Portscanner, result PortscannerResult) {}
+	c.Callbacks.ResultPublish = func(c *Portscanner) {}
 }
 
 func (c *Portscanner) Enable() {
@@ -140,8 +151,11 @@ func (c *Portscanner) Cleanup() {
 
 func (c *Portscanner) Publish() {
 	c.mux.Lock()
-	c.Callbacks.ResultCleanup(c)
-	c.pushResults()
+	metricsApply(func() {
+		c.Callbacks.ResultCleanup(c)
+		c.pushResults()
+		c.Callbacks.ResultPublish(c)
+	})
 	c.mux.Unlock()
 }
 
@@ -154,7 +168,16 @@ func (c *Portscanner) pushResults() {
 }
 
 func (c *Portscanner) Start() {
-	portscanTimeout := time.Duration(opts.Portscan.Timeout) * time.Second
+	dynamicConfigLock.RLock()
+	c.Settings = PortscannerSettings{
+		Parallel:  opts.Portscan.Parallel,
+		Threads:   opts.Portscan.Threads,
+		Timeout:   time.Duration(opts.Portscan.Timeout) * time.Second,
+		PortRange: portscanPortRange,
+	}
+	dynamicConfigLock.RUnlock()
+
+	portscanTimeout := c.Settings.Timeout
 
 	c.Callbacks.StartupScan(c)
 
@@ -162,7 +185,7 @@ func (c *Portscanner) Start() {
 	c.Cleanup()
 	c.Publish()
 
-	swg := sizedwaitgroup.New(opts.Portscan.Parallel)
+	swg := sizedwaitgroup.New(c.Settings.Parallel)
 	for _, pip := range c.PublicIps {
 		swg.Add()
 		go func(pip network.PublicIPAddress, portscanTimeout time.Duration) {
@@ -199,9 +222,9 @@ func (c *Portscanner) scanIp(pip network.PublicIPAddress, portscanTimeout time.D
 		return
 	}
 
-	ps := scanner.NewPortScanner(ipAddress, portscanTimeout, opts.Portscan.Threads)
+	ps := scanner.NewPortScanner(ipAddress, portscanTimeout, c.Settings.Threads)
 
-	for _, portrange := range portscanPortRange {
+	for _, portrange := range c.Settings.PortRange {
 		openedPorts := ps.GetOpenedPort(portrange.FirstPort, portrange.LastPort)
 
 		for _, port := range openedPorts {
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"github.com/Azure/go-autorest/autorest/to"
+	log "github.com/sirupsen/logrus"
+	"strconv"
+	"time"
+)
+
+var (
+	portscannerSinks []PortscannerSink
+)
+
+// PortscannerFinding is an open port of a public ip found by a portscan
+type PortscannerFinding struct {
+	TimeGenerated      string
+	IpAddress          string
+	Protocol           string
+	Port               int
+	SubscriptionId     string
+	PublicIpResourceId string
+	AttachedResourceId string
+}
+
+// PortscannerSink receives the findings of each finished portscan (additionally to the Prometheus metrics)
+type PortscannerSink interface {
+	Name() string
+	Send(ctx context.Context, findings []PortscannerFinding) error
+}
+
+// init the sinks for portscan findings (--portscan-logs-endpoint)
+func initPortscannerSinks() {
+	if opts.Portscan.Logs.Endpoint != "" {
+		portscannerSinks = append(portscannerSinks, NewPortscannerLogAnalyticsSink())
+	}
+}
+
+// portscannerSinksSend sends the findings of the finished portscan to all sinks
+func portscannerSinksSend(c *Portscanner, logger *log.Entry) {
+	if len(portscannerSinks) == 0 {
+		return
+	}
+
+	findings := portscannerFindings(c, time.Now())
+	for _, sink := range portscannerSinks {
+		contextLogger := logger.WithField("sink", sink.Name())
+		if err := sink.Send(context.Background(), findings); err != nil {
+			contextLogger.Errorf("failed to send %v portscan findings: %v", len(findings), err)
+			continue
+		}
+		contextLogger.Infof("sent %v portscan findings", len(findings))
+	}
+}
+
+// portscannerFindings builds the findings from the results of the scanned public ips
+func portscannerFindings(c *Portscanner, scanTime time.Time) []PortscannerFinding {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	findings := []PortscannerFinding{}
+	for ipAddress, results := range c.List {
+		pip, exists := c.PublicIps[ipAddress]
+		if !exists {
+			continue
+		}
+
+		publicIpResourceId := to.String(pip.ID)
+		for _, result := range results {
+			port, _ := strconv.Atoi(result.Labels["port"])
+			findings = append(findings, PortscannerFinding{
+				TimeGenerated:      scanTime.UTC().Format(time.RFC3339),
+				IpAddress:          ipAddress,
+				Protocol:           result.Labels["protocol"],
+				Port:               port,
+				SubscriptionId:     extractSubscriptionIdFromAzureId(publicIpResourceId),
+				PublicIpResourceId: toResourceId(&publicIpResourceId),
+				AttachedResourceId: publicIpAttachedResourceId(pip),
+			})
+		}
+	}
+
+	return findings
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/Azure/go-autorest/autorest"
+	"github.com/Azure/go-autorest/autorest/azure"
+	"github.com/Azure/go-autorest/autorest/azure/auth"
+	log "github.com/sirupsen/logrus"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const (
+	// Azure Monitor resource for Azure AD authentication and Logs Ingestion api version
+	AzureMonitorResourceId           = "https://monitor.azure.com/"
+	AzureLogsIngestionApiVersion     = "2023-01-01"
+	portscannerLogAnalyticsBatchSize = 1000
+)
+
+// PortscannerLogAnalyticsSink sends portscan findings to a Log Analytics workspace (eg. custom table of
+// Microsoft Sentinel) using the Logs Ingestion API of a data collection endpoint and rule
+type PortscannerLogAnalyticsSink struct {
+	client autorest.Client
+	url    string
+}
+
+func NewPortscannerLogAnalyticsSink() *PortscannerLogAnalyticsSink {
+	if opts.Portscan.Logs.Dcr == "" {
+		log.Panic("--portscan-logs-dcr is required for --portscan-logs-endpoint")
+	}
+
+	authorizer, err := auth.NewAuthorizerFromEnvironmentWithResource(AzureMonitorResourceId)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	client := autorest.NewClientWithUserAgent("azure-resourcemanager-exporter")
+	client.Authorizer = authorizer
+
+	return &PortscannerLogAnalyticsSink{
+		client: client,
+		url: fmt.Sprintf(
+			"%s/dataCollectionRules/%s/streams/%s?api-version=%s",
+			strings.TrimSuffix(opts.Portscan.Logs.Endpoint, "/"),
+			url.PathEscape(opts.Portscan.Logs.Dcr),
+			url.PathEscape(opts.Portscan.Logs.Stream),
+			AzureLogsIngestionApiVersion,
+		),
+	}
+}
+
+func (s *PortscannerLogAnalyticsSink) Name() string {
+	return "loganalytics"
+}
+
+// Send uploads the findings in batches (the Logs Ingestion API accepts up to 1 MB per request)
+func (s *PortscannerLogAnalyticsSink) Send(ctx context.Context, findings []PortscannerFinding) error {
+	for start := 0; start < len(findings); start += portscannerLogAnalyticsBatchSize {
+		end := start + portscannerLogAnalyticsBatchSize
+		if end > len(findings) {
+			end = len(findings)
+		}
+
+		if err := s.upload(ctx, findings[start:end]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *PortscannerLogAnalyticsSink) upload(ctx context.Context, findings []PortscannerFinding) error {
+	req, err := autorest.Prepare(
+		(&http.Request{}).WithContext(ctx),
+		autorest.AsPost(),
+		autorest.AsContentType("application/json"),
+		autorest.WithBaseURL(s.url),
+		autorest.WithJSON(findings),
+	)
+	if err != nil {
+		return err
+	}
+
+	resp, err := s.client.Send(req)
+	if err != nil {
+		return err
+	}
+
+	return autorest.Respond(
+		resp,
+		azure.WithErrorUnlessStatusCode(http.StatusNoContent),
+		autorest.ByClosing(),
+	)
+}
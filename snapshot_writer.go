@@ -0,0 +1,160 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/Azure/go-autorest/autorest"
+	"github.com/Azure/go-autorest/autorest/azure"
+	"github.com/Azure/go-autorest/autorest/azure/auth"
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	// Azure Storage resource for Azure AD authentication and blob api version
+	AzureStorageResourceId = "https://storage.azure.com/"
+	AzureBlobApiVersion    = "2020-10-02"
+)
+
+var (
+	snapshotClient     *autorest.Client
+	snapshotContainer  *url.URL
+	snapshotCollectors map[string]bool
+)
+
+// snapshotRow is one metric sample of an inventory snapshot (one json object per line)
+type snapshotRow struct {
+	Time      string            `json:"time"`
+	Collector string            `json:"collector"`
+	Metric    string            `json:"metric"`
+	Labels    map[string]string `json:"labels"`
+	Value     float64           `json:"value"`
+}
+
+// init the inventory snapshot writer (--snapshot-url), SAS urls are used as is, otherwise Azure AD authentication is used
+func initSnapshotWriter() {
+	containerUrl, err := url.Parse(strings.TrimSuffix(opts.Snapshot.Url, "/"))
+	if err != nil {
+		log.Panic(err)
+	}
+	snapshotContainer = containerUrl
+
+	client := autorest.NewClientWithUserAgent("azure-resourcemanager-exporter")
+	if containerUrl.RawQuery == "" {
+		authorizer, err := auth.NewAuthorizerFromEnvironmentWithResource(AzureStorageResourceId)
+		if err != nil {
+			log.Panic(err)
+		}
+		client.Authorizer = authorizer
+	}
+	snapshotClient = &client
+
+	if len(opts.Snapshot.Collectors) > 0 {
+		snapshotCollectors = map[string]bool{}
+		for _, collectorName := range opts.Snapshot.Collectors {
+			snapshotCollectors[strings.ToLower(collectorName)] = true
+		}
+	}
+}
+
+// snapshotWrite uploads the metrics of a finished collection run as gzipped NDJSON blob
+// (<container>/<collector>/<yyyy>/<mm>/<dd>/<time>.ndjson.gz)
+func snapshotWrite(collectorName string, collectionTime time.Time) {
+	collectorName = strings.ToLower(collectorName)
+	if snapshotClient == nil || (snapshotCollectors != nil && !snapshotCollectors[collectorName]) {
+		return
+	}
+
+	logger := log.WithField("collector", collectorName)
+
+	metricFamilies, err := metricsRegistryGatherer{Gatherer: prometheus.DefaultGatherer, collectors: map[string]bool{collectorName: true}}.Gather()
+	if err != nil {
+		logger.Errorf("inventory snapshot failed: %v", err)
+		return
+	}
+
+	collectionTime = collectionTime.UTC()
+	body := bytes.Buffer{}
+	gzipWriter := gzip.NewWriter(&body)
+	encoder := json.NewEncoder(gzipWriter)
+	rowCount := 0
+	for _, metricFamily := range metricFamilies {
+		for _, metric := range metricFamily.Metric {
+			row := snapshotRow{
+				Time:      collectionTime.Format(time.RFC3339),
+				Collector: collectorName,
+				Metric:    metricFamily.GetName(),
+				Labels:    map[string]string{},
+			}
+
+			for _, label := range metric.Label {
+				row.Labels[label.GetName()] = label.GetValue()
+			}
+
+			switch {
+			case metric.Gauge != nil:
+				row.Value = metric.Gauge.GetValue()
+			case metric.Counter != nil:
+				row.Value = metric.Counter.GetValue()
+			default:
+				continue
+			}
+
+			if err := encoder.Encode(row); err != nil {
+				logger.Errorf("inventory snapshot failed: %v", err)
+				return
+			}
+			rowCount++
+		}
+	}
+
+	if err := gzipWriter.Close(); err != nil {
+		logger.Errorf("inventory snapshot failed: %v", err)
+		return
+	}
+
+	blobUrl := *snapshotContainer
+	blobUrl.Path = fmt.Sprintf("%s/%s/%s/%s.ndjson.gz", snapshotContainer.Path, collectorName, collectionTime.Format("2006/01/02"), collectionTime.Format("20060102T150405Z"))
+
+	if err := snapshotUpload(blobUrl.String(), body.Bytes()); err != nil {
+		logger.Errorf("inventory snapshot upload failed: %v", err)
+		return
+	}
+
+	logger.Debugf("inventory snapshot with %v rows uploaded to %v", rowCount, blobUrl.Path)
+}
+
+// snapshotUpload uploads the content as block blob (single put)
+func snapshotUpload(blobUrl string, content []byte) error {
+	req, err := autorest.Prepare(
+		(&http.Request{}).WithContext(context.Background()),
+		autorest.AsPut(),
+		autorest.WithBaseURL(blobUrl),
+		autorest.WithHeader("x-ms-version", AzureBlobApiVersion),
+		autorest.WithHeader("x-ms-blob-type", "BlockBlob"),
+		autorest.WithHeader("x-ms-blob-content-type", "application/x-ndjson"),
+		autorest.WithHeader("x-ms-blob-content-encoding", "gzip"),
+		autorest.WithBytes(&content),
+	)
+	if err != nil {
+		return err
+	}
+
+	resp, err := snapshotClient.Send(req)
+	if err != nil {
+		return err
+	}
+
+	return autorest.Respond(
+		resp,
+		azure.WithErrorUnlessStatusCode(http.StatusCreated),
+		autorest.ByClosing(),
+	)
+}